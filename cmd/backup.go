@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 	"github.com/taemon1337/ec-manager/pkg/ami"
@@ -12,10 +14,11 @@ import (
 // backupCmd represents the backup command
 var backupCmd = &cobra.Command{
 	Use:   "backup",
-	Short: "Create a backup AMI from an EC2 instance",
-	Long: `backup creates a backup AMI from an EC2 instance. You can specify a single instance
-using the --instance-id flag, or back up all instances with the ami-migrate=enabled tag
-by using the --enabled flag.`,
+	Short: "Snapshot the volumes of an EC2 instance without migrating it",
+	Long: `backup snapshots every EBS volume attached to an EC2 instance, running or stopped,
+without migrating it. You can specify a single instance using the --instance-id flag, or
+back up all instances with the ami-migrate=enabled tag by using the --enabled flag.
+Use --wait to return only once the snapshots have completed.`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		// Validate required flags
 		instanceID, _ := cmd.Flags().GetString("instance-id")
@@ -33,6 +36,7 @@ by using the --enabled flag.`,
 		// Get flag values
 		instanceID, _ := cmd.Flags().GetString("instance-id")
 		enabled, _ := cmd.Flags().GetBool("enabled")
+		wait, _ := cmd.Flags().GetBool("wait")
 
 		// Create AWS clients
 		ec2Client, err := client.GetEC2Client(cmd.Context())
@@ -41,7 +45,7 @@ by using the --enabled flag.`,
 		}
 
 		// Create AMI service
-		svc := ami.NewService(ec2Client)
+		svc := ami.NewService(ec2Client, serviceOptions()...)
 
 		// Get instances to backup
 		var instances []string
@@ -63,22 +67,46 @@ by using the --enabled flag.`,
 		}
 
 		// Backup each instance
+		var results []backupResult
 		for _, instance := range instances {
-			logger.Info(fmt.Sprintf("Creating backup AMI for instance %s", instance))
-			if err := svc.BackupInstance(cmd.Context(), instance); err != nil {
+			logger.Info(fmt.Sprintf("Creating backup snapshots for instance %s", instance))
+			snapshotIDs, err := svc.BackupInstance(cmd.Context(), instance, ami.BackupOptions{Wait: wait})
+			if err != nil {
 				return fmt.Errorf("failed to backup instance %s: %v", instance, err)
 			}
 			logger.Info(fmt.Sprintf("Successfully created backup for instance %s", instance))
+			results = append(results, backupResult{InstanceID: instance, SnapshotIDs: snapshotIDs})
 		}
 
+		if ok, err := writeOutput(cmd, results); ok {
+			return err
+		}
+		printBackupResults(cmd, results)
 		return nil
 	},
 }
 
+// backupResult lists the snapshots taken of one instance
+type backupResult struct {
+	InstanceID  string   `json:"instance_id"`
+	SnapshotIDs []string `json:"snapshot_ids"`
+}
+
 func init() {
 	rootCmd.AddCommand(backupCmd)
 
 	// Add flags
 	backupCmd.Flags().String("instance-id", "", "ID of the instance to backup")
 	backupCmd.Flags().Bool("enabled", false, "Backup all instances with ami-migrate=enabled tag")
+	backupCmd.Flags().Bool("wait", false, "Wait for the snapshots to complete")
+}
+
+// printBackupResults writes a table of the snapshots taken by a backup run
+func printBackupResults(cmd *cobra.Command, results []backupResult) {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tSNAPSHOTS")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\n", result.InstanceID, strings.Join(result.SnapshotIDs, ","))
+	}
+	w.Flush()
 }
@@ -198,7 +198,7 @@ func TestBackupCmd(t *testing.T) {
 				}
 
 				// Create AMI service
-				svc := ami.NewService(ec2Client)
+				svc := ami.NewService(ec2Client, serviceOptions()...)
 
 				// Get instances to backup
 				var instances []string
@@ -221,7 +221,7 @@ func TestBackupCmd(t *testing.T) {
 
 				// Backup each instance
 				for _, instance := range instances {
-					if err := svc.BackupInstance(context.Background(), instance); err != nil {
+					if _, err := svc.BackupInstance(context.Background(), instance, ami.BackupOptions{}); err != nil {
 						return fmt.Errorf("failed to backup instance %s: %v", instance, err)
 					}
 					logger.Info("Successfully backed up instance", "instanceID", instance)
@@ -2,12 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/spf13/cobra"
 	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
 )
 
 var checkCmd = &cobra.Command{
@@ -17,8 +18,16 @@ var checkCmd = &cobra.Command{
 Shows:
 - Current AMI details
 - Latest available AMI
-- Migration recommendation`,
+- Migration recommendation
+
+With --latest-tag, check audits every instance enrolled in migration instead:
+it lists those not running the newest AMI with that tag and exits non-zero
+when there are any, e.g. as a nightly CI gate.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if latestTag, _ := cmd.Flags().GetString("latest-tag"); latestTag != "" {
+			return checkOutdated(cmd, latestTag)
+		}
+
 		// Get user ID
 		userID, err := getUserID(cmd)
 		if err != nil {
@@ -26,14 +35,14 @@ Shows:
 		}
 
 		// Load AWS configuration
-		cfg, err := config.LoadDefaultConfig(cmd.Context())
+		cfg, err := client.LoadAWSConfig(cmd.Context())
 		if err != nil {
 			return fmt.Errorf("load AWS config: %w", err)
 		}
 
 		// Create EC2 client and AMI service
 		ec2Client := ec2.NewFromConfig(cfg)
-		svc := ami.NewService(ec2Client)
+		svc := ami.NewService(ec2Client, serviceOptions()...)
 
 		// Check migration status
 		status, err := svc.CheckMigrationStatus(cmd.Context(), userID)
@@ -42,6 +51,9 @@ Shows:
 		}
 
 		// Display results
+		if ok, err := writeOutput(cmd, status); ok {
+			return err
+		}
 		fmt.Printf("Instance Status for %s:\n", status.InstanceID)
 		fmt.Printf("  OS Type:        %s\n", status.OSType)
 		fmt.Printf("  Instance Type:  %s\n", status.InstanceType)
@@ -81,5 +93,42 @@ Shows:
 func init() {
 	rootCmd.AddCommand(checkCmd)
 	checkCmd.Flags().String("user", "", "User ID to check instances for")
-	checkCmd.MarkFlagRequired("user")
+	checkCmd.Flags().String("latest-tag", "", "Report the enrolled instances not running the newest AMI with this key=value tag (e.g. ami-migrate=latest)")
+}
+
+// checkOutdated reports the enrolled instances not running the newest AMI
+// tagged latestTag, given as key=value, and fails when there are any
+func checkOutdated(cmd *cobra.Command, latestTag string) error {
+	key, value, ok := strings.Cut(latestTag, "=")
+	if !ok || key == "" || value == "" {
+		return fmt.Errorf("invalid --latest-tag %q: use key=value, e.g. ami-migrate=latest", latestTag)
+	}
+
+	ec2Client, err := client.GetEC2Client(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get EC2 client: %w", err)
+	}
+	svc := ami.NewService(ec2Client, serviceOptions()...)
+
+	report, err := svc.CheckOutdated(cmd.Context(), key, value)
+	if err != nil {
+		return fmt.Errorf("failed to check for outdated instances: %v", err)
+	}
+
+	if printed, err := writeOutput(cmd, report); err != nil {
+		return err
+	} else if !printed {
+		fmt.Fprintf(cmd.OutOrStdout(), "Latest AMI: %s\n", report.LatestAMI)
+		if len(report.Outdated) == 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "All %d enrolled instances are up to date\n", report.Checked)
+		} else {
+			printManagedInstances(cmd, report.Outdated)
+		}
+	}
+
+	if len(report.Outdated) > 0 {
+		return fmt.Errorf("%d of %d enrolled instances are not running %s",
+			len(report.Outdated), report.Checked, report.LatestAMI)
+	}
+	return nil
 }
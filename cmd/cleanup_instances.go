@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// cleanupInstancesCmd represents the cleanup-instances command
+var cleanupInstancesCmd = &cobra.Command{
+	Use:   "cleanup-instances",
+	Short: "Terminate old instances kept after migration",
+	Long: `cleanup-instances terminates the old instances that migrate --retain-old-instance
+kept stopped, once they have been retained for longer than --older-than. Only
+stopped instances tagged ami-migrate-replaced-by and ami-migrate-retained-at are
+considered. Instances whose replacement is no longer running, or doesn't name them
+as its source, are left alone, since the old instance may then be the only working
+copy.
+
+Use --delete-snapshots to also delete the migration snapshots of the terminated
+instances; they can't be rolled back afterwards. Use --dry-run to list the
+instances that would be terminated without terminating them. The instances are
+listed and confirmation is asked for before terminating anything; pass --yes to
+skip the prompt.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		if olderThan <= 0 {
+			return fmt.Errorf("--older-than must be greater than zero")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger.Info("Starting replaced instance cleanup")
+
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		deleteSnapshots, _ := cmd.Flags().GetBool("delete-snapshots")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		if !dryRun {
+			if err := confirmInstanceCleanup(cmd, svc, olderThan, deleteSnapshots); err != nil {
+				return err
+			}
+		}
+
+		cleanups, err := svc.CleanupReplacedInstances(cmd.Context(), olderThan, ami.ReplacedCleanupOptions{
+			DryRun:          dryRun,
+			DeleteSnapshots: deleteSnapshots,
+		})
+		printed, outErr := writeOutput(cmd, cleanups)
+		if !printed {
+			printInstanceCleanups(cmd, cleanups)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clean up replaced instances: %v", err)
+		}
+		return outErr
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanupInstancesCmd)
+
+	// Add flags
+	cleanupInstancesCmd.Flags().Duration("older-than", 7*24*time.Hour, "Only terminate instances retained for longer than this")
+	cleanupInstancesCmd.Flags().Bool("delete-snapshots", false, "Also delete the migration snapshots of the terminated instances")
+	cleanupInstancesCmd.Flags().Bool("dry-run", false, "List the instances that would be terminated without terminating them")
+	addYesFlag(cleanupInstancesCmd)
+}
+
+// confirmInstanceCleanup lists the replaced instances a cleanup would
+// terminate and asks the user to confirm, unless --yes was given
+func confirmInstanceCleanup(cmd *cobra.Command, svc *ami.Service, olderThan time.Duration, deleteSnapshots bool) error {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return nil
+	}
+	cleanups, err := svc.CleanupReplacedInstances(cmd.Context(), olderThan, ami.ReplacedCleanupOptions{DryRun: true})
+	if err != nil {
+		return fmt.Errorf("failed to list replaced instances to clean up: %v", err)
+	}
+
+	var lines []string
+	for _, cleanup := range cleanups {
+		// Instances that are kept have another reason
+		if cleanup.Reason == "dry run" {
+			lines = append(lines, fmt.Sprintf("  %s (replaced by %s)", cleanup.InstanceID, cleanup.ReplacedBy))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	summary := fmt.Sprintf("%d replaced instance(s) will be terminated", len(lines))
+	if deleteSnapshots {
+		summary += " and their migration snapshots deleted"
+	}
+	return confirmAction(cmd, fmt.Sprintf("%s:\n%s", summary, strings.Join(lines, "\n")))
+}
+
+// printInstanceCleanups writes a table of the instances handled by a cleanup run
+func printInstanceCleanups(cmd *cobra.Command, cleanups []ami.ReplacedInstance) {
+	if len(cleanups) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No replaced instances to clean up")
+		return
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tREPLACED BY\tRETAINED\tTERMINATED\tSNAPSHOTS\tREASON")
+	for _, cleanup := range cleanups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%s\n",
+			cleanup.InstanceID,
+			cleanup.ReplacedBy,
+			cleanup.RetainedAt.Format(time.RFC3339),
+			cleanup.Terminated,
+			strings.Join(cleanup.DeletedSnapshots, ","),
+			cleanup.Reason)
+	}
+	w.Flush()
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// cleanupSnapshotsCmd represents the cleanup-snapshots command
+var cleanupSnapshotsCmd = &cobra.Command{
+	Use:   "cleanup-snapshots",
+	Short: "Delete old migration snapshots",
+	Long: `cleanup-snapshots deletes the backup snapshots taken during migrations once they
+are older than --older-than. Only snapshots tagged created-by=ec-manager are
+considered, and snapshots that still back an AMI are kept.
+
+Use --dry-run to list the snapshots that would be deleted without deleting them.
+The snapshots are listed and confirmation is asked for before deleting anything;
+pass --yes to skip the prompt.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		if olderThan <= 0 {
+			return fmt.Errorf("--older-than must be greater than zero")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger.Info("Starting snapshot cleanup")
+
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		if !dryRun {
+			if err := confirmSnapshotCleanup(cmd, svc, olderThan); err != nil {
+				return err
+			}
+		}
+
+		cleanups, err := svc.CleanupSnapshots(cmd.Context(), olderThan, dryRun)
+		printed, outErr := writeOutput(cmd, cleanups)
+		if !printed {
+			printSnapshotCleanups(cmd, cleanups)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clean up snapshots: %v", err)
+		}
+		return outErr
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanupSnapshotsCmd)
+
+	// Add flags
+	cleanupSnapshotsCmd.Flags().Duration("older-than", 30*24*time.Hour, "Only delete snapshots older than this")
+	cleanupSnapshotsCmd.Flags().Bool("dry-run", false, "List the snapshots that would be deleted without deleting them")
+	addYesFlag(cleanupSnapshotsCmd)
+}
+
+// confirmSnapshotCleanup lists the snapshots a cleanup would delete and asks
+// the user to confirm, unless --yes was given
+func confirmSnapshotCleanup(cmd *cobra.Command, svc *ami.Service, olderThan time.Duration) error {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return nil
+	}
+	cleanups, err := svc.CleanupSnapshots(cmd.Context(), olderThan, true)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots to clean up: %v", err)
+	}
+
+	var lines []string
+	for _, cleanup := range cleanups {
+		// Snapshots still backing an AMI have another reason and are kept
+		if cleanup.Reason == "dry run" {
+			lines = append(lines, fmt.Sprintf("  %s (volume %s of %s)", cleanup.SnapshotID, cleanup.VolumeID, cleanup.InstanceID))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return confirmAction(cmd, fmt.Sprintf("%d snapshot(s) will be deleted:\n%s", len(lines), strings.Join(lines, "\n")))
+}
+
+// printSnapshotCleanups writes a table of the snapshots handled by a cleanup run
+func printSnapshotCleanups(cmd *cobra.Command, cleanups []ami.SnapshotCleanup) {
+	if len(cleanups) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No snapshots to clean up")
+		return
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tINSTANCE\tVOLUME\tCREATED\tDELETED\tREASON")
+	for _, cleanup := range cleanups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%s\n",
+			cleanup.SnapshotID,
+			cleanup.InstanceID,
+			cleanup.VolumeID,
+			cleanup.StartTime.Format(time.RFC3339),
+			cleanup.Deleted,
+			cleanup.Reason)
+	}
+	w.Flush()
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// cloneResult describes an instance launched by the clone command
+type cloneResult struct {
+	SourceInstanceID string `json:"source_instance_id"`
+	InstanceID       string `json:"instance_id"`
+	AMI              string `json:"ami"`
+}
+
+// cloneCmd represents the clone command
+var cloneCmd = &cobra.Command{
+	Use:   "clone",
+	Short: "Launch a copy of an instance on another AMI",
+	Long: `clone launches a copy of --instance-id on --ami to try the AMI out, with the same
+instance type, subnet, security groups, instance profile, key pair and tags. The
+command waits for the clone to be running and prints its ID.
+
+The source instance is left alone: it isn't stopped, snapshotted or tagged. The
+clone's root volume comes from --ami and it gets no data volumes. Tags that
+enrol the source in migrations, such as ami-migrate=enabled, aren't copied, and
+the clone is tagged ami-migrate-cloned-from with the source instance ID.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		for _, flag := range []string{"instance-id", "ami"} {
+			if value, _ := cmd.Flags().GetString(flag); value == "" {
+				return fmt.Errorf("--%s is required", flag)
+			}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instanceID, _ := cmd.Flags().GetString("instance-id")
+		amiID, _ := cmd.Flags().GetString("ami")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		cloneID, err := svc.CloneInstance(cmd.Context(), instanceID, amiID)
+		if err != nil {
+			if cloneID != "" {
+				return fmt.Errorf("failed to clone instance %s, clone %s was launched: %v", instanceID, cloneID, err)
+			}
+			return fmt.Errorf("failed to clone instance %s: %v", instanceID, err)
+		}
+
+		result := cloneResult{SourceInstanceID: instanceID, InstanceID: cloneID, AMI: amiID}
+		if ok, err := writeOutput(cmd, result); ok {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Cloned instance %s to %s on AMI %s\n", instanceID, cloneID, amiID)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cloneCmd)
+
+	// Add flags
+	cloneCmd.Flags().String("instance-id", "", "ID of the instance to clone")
+	cloneCmd.Flags().String("ami", "", "AMI ID to launch the clone from")
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// addYesFlag adds the --yes flag that skips confirmAction's prompt
+func addYesFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation before changing anything")
+}
+
+// confirmAction prints summary and asks the user to confirm before a
+// destructive action, unless --yes was given. When stdin isn't a terminal
+// nobody can answer, so --yes is required instead of waiting for input.
+func confirmAction(cmd *cobra.Command, summary string) error {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return nil
+	}
+	if !isTerminal(cmd.InOrStdin()) {
+		return fmt.Errorf("stdin is not a terminal, pass --yes to confirm:\n%s", summary)
+	}
+
+	fmt.Fprintln(cmd.ErrOrStderr(), summary)
+	if !promptYesNo(cmd, "Continue?") {
+		return fmt.Errorf("aborted")
+	}
+	return nil
+}
+
+// promptYesNo asks question on stderr and reports whether the answer read
+// from stdin was yes
+func promptYesNo(cmd *cobra.Command, question string) bool {
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s [y/N] ", question)
+	var answer string
+	// An empty line, EOF or anything else is a no
+	fmt.Fscanln(cmd.InOrStdin(), &answer)
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// isTerminal reports whether in is an interactive terminal. Readers that
+// aren't files, such as one set with cmd.SetIn, are assumed to answer.
+func isTerminal(in io.Reader) bool {
+	file, ok := in.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
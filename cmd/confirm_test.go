@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmAction(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		input      string
+		wantErr    string
+		wantPrompt bool
+	}{
+		{
+			name:       "yes answer",
+			input:      "y\n",
+			wantPrompt: true,
+		},
+		{
+			name:       "full yes answer",
+			input:      "YES\n",
+			wantPrompt: true,
+		},
+		{
+			name:       "no answer",
+			input:      "n\n",
+			wantErr:    "aborted",
+			wantPrompt: true,
+		},
+		{
+			name:       "empty answer defaults to no",
+			input:      "\n",
+			wantErr:    "aborted",
+			wantPrompt: true,
+		},
+		{
+			name:       "end of input is a no",
+			wantErr:    "aborted",
+			wantPrompt: true,
+		},
+		{
+			name: "--yes skips the prompt",
+			args: []string{"--yes"},
+		},
+		{
+			name: "-y skips the prompt",
+			args: []string{"-y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addYesFlag(cmd)
+			require.NoError(t, cmd.Flags().Parse(tt.args))
+
+			var stderr bytes.Buffer
+			cmd.SetIn(strings.NewReader(tt.input))
+			cmd.SetErr(&stderr)
+
+			err := confirmAction(cmd, "AMI ami-123 will be deregistered")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if tt.wantPrompt {
+				assert.Equal(t, "AMI ami-123 will be deregistered\nContinue? [y/N] ", stderr.String())
+			} else {
+				assert.Empty(t, stderr.String())
+			}
+		})
+	}
+}
+
+func TestConfirmActionWithoutTerminal(t *testing.T) {
+	// A pipe stands in for stdin redirected from a file or another process
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+	_, err = w.WriteString("y\n")
+	require.NoError(t, err)
+
+	cmd := &cobra.Command{}
+	addYesFlag(cmd)
+	cmd.SetIn(r)
+	cmd.SetErr(&bytes.Buffer{})
+
+	err = confirmAction(cmd, "1 snapshot(s) will be deleted")
+	assert.EqualError(t, err, "stdin is not a terminal, pass --yes to confirm:\n1 snapshot(s) will be deleted")
+
+	require.NoError(t, cmd.Flags().Set("yes", "true"))
+	assert.NoError(t, confirmAction(cmd, "1 snapshot(s) will be deleted"))
+}
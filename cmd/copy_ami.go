@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// copyAMICmd represents the copy-ami command
+var copyAMICmd = &cobra.Command{
+	Use:   "copy-ami",
+	Short: "Copy an AMI to another region",
+	Long: `copy-ami copies an AMI from --source-region to --dest-region so instances in the
+destination region can be migrated to it. The command waits for the copy to
+become available and prints the destination AMI ID.
+
+The copy keeps the source AMI's name. If an AMI with that name already exists in
+the destination region it is reused instead of being copied again.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		for _, flag := range []string{"ami-id", "source-region", "dest-region"} {
+			if value, _ := cmd.Flags().GetString(flag); value == "" {
+				return fmt.Errorf("--%s is required", flag)
+			}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		amiID, _ := cmd.Flags().GetString("ami-id")
+		sourceRegion, _ := cmd.Flags().GetString("source-region")
+		destRegion, _ := cmd.Flags().GetString("dest-region")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		destAMI, err := svc.CopyAMI(cmd.Context(), amiID, sourceRegion, destRegion)
+		if err != nil {
+			return fmt.Errorf("failed to copy AMI: %v", err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "AMI %s is available in %s as %s\n", amiID, destRegion, destAMI)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(copyAMICmd)
+
+	// Add flags
+	copyAMICmd.Flags().String("ami-id", "", "AMI ID to copy")
+	copyAMICmd.Flags().String("source-region", "", "Region the AMI is in")
+	copyAMICmd.Flags().String("dest-region", "", "Region to copy the AMI to")
+}
@@ -5,17 +5,25 @@ import (
 	"math/rand"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/spf13/cobra"
 	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
 )
 
 var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new EC2 instance",
 	Long: `Create a new EC2 instance with the specified configuration.
-The instance will be tagged with your user ID and ami-migrate tags.`,
+The instance will be tagged with your user ID and ami-migrate tags.
+
+Use --ami to launch from a specific AMI instead of the latest one for --os,
+and --instance-type to pick an instance type instead of --size. The command
+waits for the instance to be running before returning.
+
+Use --launch-template to launch from an EC2 launch template. --os or --ami and
+--size or --instance-type are then optional and override the template's
+settings when given, as do the networking flags.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get user ID
 		userID, err := getUserID(cmd)
@@ -38,21 +46,35 @@ The instance will be tagged with your user ID and ami-migrate tags.`,
 		if err != nil {
 			return err
 		}
+		if name == "" {
+			name = generateInstanceName()
+		}
 
-		// Validate required flags
-		if osType == "" || size == "" {
-			return fmt.Errorf("--os and --size flags are required")
+		amiID, _ := cmd.Flags().GetString("ami")
+		instanceType, _ := cmd.Flags().GetString("instance-type")
+		subnetID, _ := cmd.Flags().GetString("subnet-id")
+		securityGroupIDs, _ := cmd.Flags().GetStringSlice("security-group-ids")
+		keyName, _ := cmd.Flags().GetString("key-name")
+		tags, _ := cmd.Flags().GetStringToString("tag")
+		launchTemplate := launchTemplateFromFlags(cmd)
+
+		// Validate required flags, which a launch template can stand in for
+		if osType == "" && amiID == "" && launchTemplate == nil {
+			return fmt.Errorf("--os, --ami or --launch-template flag is required")
+		}
+		if size == "" && instanceType == "" && launchTemplate == nil {
+			return fmt.Errorf("--size, --instance-type or --launch-template flag is required")
 		}
 
 		// Load AWS configuration
-		cfg, err := config.LoadDefaultConfig(cmd.Context())
+		cfg, err := client.LoadAWSConfig(cmd.Context())
 		if err != nil {
 			return fmt.Errorf("load AWS config: %w", err)
 		}
 
 		// Create EC2 client and AMI service
 		ec2Client := ec2.NewFromConfig(cfg)
-		amiService := ami.NewService(ec2Client)
+		amiService := ami.NewService(ec2Client, serviceOptions()...)
 
 		// Create instance config
 		config := ami.InstanceConfig{
@@ -60,6 +82,14 @@ The instance will be tagged with your user ID and ami-migrate tags.`,
 			OSType: osType,
 			Size:   size,
 			Name:   name,
+
+			AMIID:            amiID,
+			InstanceType:     instanceType,
+			SubnetID:         subnetID,
+			SecurityGroupIDs: securityGroupIDs,
+			KeyName:          keyName,
+			Tags:             tags,
+			LaunchTemplate:   launchTemplate,
 		}
 
 		// Create instance
@@ -78,10 +108,16 @@ func init() {
 	rootCmd.AddCommand(createCmd)
 	createCmd.Flags().String("user", "", "Your user ID")
 	createCmd.Flags().String("os", "", "OS type (linux or windows)")
-	createCmd.Flags().String("size", "", "Instance size (e.g. t2.micro)")
+	createCmd.Flags().String("size", "", "Instance size (small, medium, large or xlarge)")
 	createCmd.Flags().String("name", "", "Instance name (optional, random if not provided)")
-	createCmd.MarkFlagRequired("os")
-	createCmd.MarkFlagRequired("size")
+	createCmd.Flags().String("ami", "", "AMI ID to launch (overrides the latest AMI for --os)")
+	createCmd.Flags().String("instance-type", "", "EC2 instance type (overrides --size, e.g. m5.large)")
+	createCmd.Flags().String("subnet-id", "", "Subnet to launch the instance in")
+	createCmd.Flags().StringSlice("security-group-ids", nil, "Security group IDs to attach")
+	createCmd.Flags().String("key-name", "", "EC2 key pair name")
+	createCmd.Flags().String("launch-template", "", "Launch from this launch template ID or name")
+	createCmd.Flags().String("launch-template-version", "", "Version of --launch-template to use (defaults to the template's default version)")
+	createCmd.Flags().StringToString("tag", nil, "Extra tag to apply, as key=value (repeatable)")
 }
 
 func generateInstanceName() string {
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// createAMICmd represents the create-ami command
+var createAMICmd = &cobra.Command{
+	Use:   "create-ami",
+	Short: "Create an AMI from an instance",
+	Long: `create-ami bakes a new AMI from --instance-id, e.g. a golden instance that
+has been patched, so other instances can be migrated to it. The command waits
+for the AMI to become available, applies the --tag tags and prints the AMI ID.
+
+EC2 reboots the instance while the image is taken so its file systems are
+consistent. Pass --no-reboot to keep it running; the image may then not be
+consistent.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		for _, flag := range []string{"instance-id", "name"} {
+			if value, _ := cmd.Flags().GetString(flag); value == "" {
+				return fmt.Errorf("--%s is required", flag)
+			}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instanceID, _ := cmd.Flags().GetString("instance-id")
+		name, _ := cmd.Flags().GetString("name")
+		noReboot, _ := cmd.Flags().GetBool("no-reboot")
+		tags, _ := cmd.Flags().GetStringToString("tag")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		imageID, err := svc.CreateImageFromInstance(cmd.Context(), instanceID, name, tags, noReboot)
+		if err != nil {
+			return fmt.Errorf("failed to create AMI: %v", err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "AMI %s created from %s\n", imageID, instanceID)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(createAMICmd)
+
+	// Add flags
+	createAMICmd.Flags().String("instance-id", "", "Instance ID to create the AMI from")
+	createAMICmd.Flags().String("name", "", "Name of the new AMI")
+	createAMICmd.Flags().Bool("no-reboot", false, "Don't reboot the instance before taking the image")
+	createAMICmd.Flags().StringToString("tag", nil, "Tag to apply to the AMI, as Key=Value (repeatable)")
+}
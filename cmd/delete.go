@@ -3,10 +3,10 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/spf13/cobra"
 	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
 )
 
 var deleteCmd = &cobra.Command{
@@ -33,14 +33,14 @@ Requires the instance ID to be specified.`,
 		}
 
 		// Load AWS configuration
-		cfg, err := config.LoadDefaultConfig(cmd.Context())
+		cfg, err := client.LoadAWSConfig(cmd.Context())
 		if err != nil {
 			return fmt.Errorf("load AWS config: %w", err)
 		}
 
 		// Create EC2 client and AMI service
 		ec2Client := ec2.NewFromConfig(cfg)
-		svc := ami.NewService(ec2Client)
+		svc := ami.NewService(ec2Client, serviceOptions()...)
 
 		// Verify instance ownership
 		instances, err := svc.ListUserInstances(cmd.Context(), userID)
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// deregisterAMICmd represents the deregister-ami command
+var deregisterAMICmd = &cobra.Command{
+	Use:   "deregister-ami",
+	Short: "Retire an AMI that is no longer in use",
+	Long: `deregister-ami retires an old AMI once every instance has been migrated off it.
+The AMI is not deregistered while any running instance still uses it.
+
+Use --delete-snapshots to also delete the snapshots backing the AMI, or
+--deprecate to mark the AMI deprecated instead of deregistering it. Confirmation
+is asked for before deregistering; pass --yes to skip the prompt.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		amiID, _ := cmd.Flags().GetString("ami-id")
+		if amiID == "" {
+			return fmt.Errorf("--ami-id is required")
+		}
+		deprecate, _ := cmd.Flags().GetBool("deprecate")
+		deleteSnapshots, _ := cmd.Flags().GetBool("delete-snapshots")
+		if deprecate && deleteSnapshots {
+			return fmt.Errorf("--delete-snapshots can't be used with --deprecate")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		amiID, _ := cmd.Flags().GetString("ami-id")
+		deprecate, _ := cmd.Flags().GetBool("deprecate")
+		deleteSnapshots, _ := cmd.Flags().GetBool("delete-snapshots")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		if deprecate {
+			if err := svc.DeprecateAMI(cmd.Context(), amiID, time.Now()); err != nil {
+				return fmt.Errorf("failed to deprecate AMI: %v", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deprecated AMI %s\n", amiID)
+			return nil
+		}
+
+		summary := fmt.Sprintf("AMI %s will be deregistered", amiID)
+		if deleteSnapshots {
+			summary += " and its backing snapshots deleted"
+		}
+		if err := confirmAction(cmd, summary); err != nil {
+			return err
+		}
+
+		result, err := svc.DeregisterAMI(cmd.Context(), amiID, ami.DeregisterOptions{
+			DeleteSnapshots: deleteSnapshots,
+		})
+		if result != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "Deregistered AMI %s\n", result.AMIID)
+			for _, snapshotID := range result.DeletedSnapshots {
+				fmt.Fprintf(cmd.OutOrStdout(), "Deleted snapshot %s\n", snapshotID)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to deregister AMI: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deregisterAMICmd)
+
+	// Add flags
+	deregisterAMICmd.Flags().String("ami-id", "", "AMI ID to retire")
+	deregisterAMICmd.Flags().Bool("delete-snapshots", false, "Also delete the snapshots backing the AMI")
+	deregisterAMICmd.Flags().Bool("deprecate", false, "Mark the AMI deprecated instead of deregistering it")
+	addYesFlag(deregisterAMICmd)
+}
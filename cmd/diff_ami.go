@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// diffAMICmd represents the diff-ami command
+var diffAMICmd = &cobra.Command{
+	Use:   "diff-ami",
+	Short: "Compare the metadata of two AMIs",
+	Long: `diff-ami compares --to-ami against --from-ami, e.g. a new golden AMI against
+the one the fleet runs, before promoting it. It prints the fields that were
+added, removed or changed: architecture, root device type and name,
+virtualization, boot mode, ENA support, each block device mapping's size,
+type, IOPS, throughput, encryption and delete-on-termination, and the tags.
+Snapshot IDs always differ and aren't compared.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		for _, flag := range []string{"from-ami", "to-ami"} {
+			if value, _ := cmd.Flags().GetString(flag); value == "" {
+				return fmt.Errorf("--%s is required", flag)
+			}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fromAMI, _ := cmd.Flags().GetString("from-ami")
+		toAMI, _ := cmd.Flags().GetString("to-ami")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		diff, err := svc.DiffAMIs(cmd.Context(), fromAMI, toAMI)
+		if err != nil {
+			return fmt.Errorf("failed to diff AMIs: %v", err)
+		}
+		if ok, err := writeOutput(cmd, diff); ok {
+			return err
+		}
+		printAMIDiff(cmd, diff)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffAMICmd)
+
+	// Add flags
+	diffAMICmd.Flags().String("from-ami", "", "AMI to compare against, e.g. the one the fleet runs")
+	diffAMICmd.Flags().String("to-ami", "", "AMI to compare, e.g. the new golden AMI")
+}
+
+// printAMIDiff writes a table of the fields that differ between two AMIs
+func printAMIDiff(cmd *cobra.Command, diff *ami.AMIDiff) {
+	if len(diff.Changes) == 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "No differences between %s and %s\n", diff.From, diff.To)
+		return
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "FIELD\tCHANGE\t%s\t%s\n", diff.From, diff.To)
+	for _, change := range diff.Changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.Field, change.Change, change.From, change.To)
+	}
+	w.Flush()
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// findAMICmd represents the find-ami command
+var findAMICmd = &cobra.Command{
+	Use:   "find-ami",
+	Short: "Search for AMIs by name, owner, architecture and tags",
+	Long: `find-ami lists the AMIs matching every given filter, newest first.
+
+--name accepts the * and ? wildcards of the EC2 name filter, e.g. "golden-ubuntu-*".
+--owner takes account IDs or self, amazon or aws-marketplace and may be repeated;
+without it only the account's own AMIs are searched. --tag Key=Value may be repeated
+and an AMI must carry all of them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		owners, _ := cmd.Flags().GetStringSlice("owner")
+		architecture, _ := cmd.Flags().GetString("architecture")
+		tags, _ := cmd.Flags().GetStringToString("tag")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		found, err := svc.FindAMIs(cmd.Context(), ami.AMICriteria{
+			NamePattern:  name,
+			Owners:       owners,
+			Architecture: architecture,
+			Tags:         tags,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find AMIs: %v", err)
+		}
+		printed, err := writeOutput(cmd, found)
+		if !printed {
+			printFoundAMIs(cmd, found)
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(findAMICmd)
+
+	// Add flags
+	findAMICmd.Flags().String("name", "", "AMI name to match, with * and ? wildcards")
+	findAMICmd.Flags().StringSlice("owner", nil, "Owner of the AMIs: an account ID, self, amazon or aws-marketplace (default self)")
+	findAMICmd.Flags().String("architecture", "", "Architecture of the AMIs, e.g. x86_64 or arm64")
+	findAMICmd.Flags().StringToString("tag", nil, "Tag the AMIs must carry, as Key=Value")
+}
+
+// printFoundAMIs writes a table of the AMIs matched by find-ami
+func printFoundAMIs(cmd *cobra.Command, found []ami.FoundAMI) {
+	if len(found) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No AMIs found")
+		return
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AMI\tNAME\tOWNER\tARCHITECTURE\tSTATE\tCREATED")
+	for _, image := range found {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			image.AMIID,
+			image.Name,
+			image.OwnerID,
+			image.Architecture,
+			image.State,
+			image.CreationDate)
+	}
+	w.Flush()
+}
@@ -2,12 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"text/tabwriter"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/spf13/cobra"
 	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
 )
 
 var listCmd = &cobra.Command{
@@ -19,23 +20,58 @@ Shows instance details including:
 - OS type and size
 - Current state
 - IP addresses
-- Current and latest AMI versions`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		// Get user ID
-		userID, err := getUserID(cmd)
-		if err != nil {
-			return err
+- Current and latest AMI versions
+
+With --enrolled, lists every instance tagged for migration instead, with the
+migration status, message and timestamp recorded on its ami-migrate-* tags.
+--since narrows that list to instances migrated, or attempted, after a time,
+given as RFC3339 (2024-06-01T00:00:00Z) or as a duration ago (24h).`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if since, _ := cmd.Flags().GetString("since"); since != "" {
+			if enrolled, _ := cmd.Flags().GetBool("enrolled"); !enrolled {
+				return fmt.Errorf("--since can only be used with --enrolled")
+			}
 		}
+		_, err := sinceFromFlags(cmd)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		enrolled, _ := cmd.Flags().GetBool("enrolled")
 
 		// Load AWS configuration
-		cfg, err := config.LoadDefaultConfig(cmd.Context())
+		cfg, err := client.LoadAWSConfig(cmd.Context())
 		if err != nil {
 			return fmt.Errorf("load AWS config: %w", err)
 		}
 
 		// Create EC2 client and AMI service
 		ec2Client := ec2.NewFromConfig(cfg)
-		amiService := ami.NewService(ec2Client)
+		amiService := ami.NewService(ec2Client, serviceOptions()...)
+
+		if enrolled {
+			instances, err := amiService.ListInstances(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to list instances: %v", err)
+			}
+			since, err := sinceFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if !since.IsZero() {
+				instances = ami.FilterMigratedSince(instances, since)
+			}
+			if ok, err := writeOutput(cmd, instances); ok {
+				return err
+			}
+			printManagedInstances(cmd, instances)
+			return nil
+		}
+
+		// Get user ID
+		userID, err := getUserID(cmd)
+		if err != nil {
+			return err
+		}
 
 		// List instances
 		instances, err := amiService.ListUserInstances(cmd.Context(), userID)
@@ -44,6 +80,9 @@ Shows instance details including:
 		}
 
 		// Display results
+		if ok, err := writeOutput(cmd, instances); ok {
+			return err
+		}
 		if len(instances) == 0 {
 			fmt.Printf("No instances found for user: %s\n", userID)
 			fmt.Println("\nTo create a new instance:")
@@ -78,5 +117,48 @@ Shows instance details including:
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().String("user", "", "User ID to list instances for")
-	listCmd.MarkFlagRequired("user")
+	listCmd.Flags().Bool("enrolled", false, "List all instances enrolled in migration with their migration status")
+	listCmd.Flags().String("since", "", "With --enrolled, only list instances migrated after this time (RFC3339, or a duration ago such as 24h)")
+}
+
+// sinceFromFlags parses --since as an RFC3339 time or as a duration before
+// now. It returns the zero time when --since isn't set.
+func sinceFromFlags(cmd *cobra.Command) (time.Time, error) {
+	since, _ := cmd.Flags().GetString("since")
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, since); err == nil {
+		return parsed, nil
+	}
+	if ago, err := time.ParseDuration(since); err == nil && ago > 0 {
+		return time.Now().Add(-ago), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use an RFC3339 time such as 2024-06-01T00:00:00Z or a duration such as 24h", since)
+}
+
+// printManagedInstances writes a table of enrolled instances and their migration status
+func printManagedInstances(cmd *cobra.Command, instances []ami.ManagedInstance) {
+	if len(instances) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No instances enrolled in migration")
+		return
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tNAME\tSTATE\tAMI\tSTATUS\tUPDATED\tMESSAGE")
+	for _, instance := range instances {
+		updated := ""
+		if !instance.Timestamp.IsZero() {
+			updated = instance.Timestamp.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			instance.InstanceID,
+			instance.Name,
+			instance.State,
+			instance.CurrentAMI,
+			instance.Status,
+			updated,
+			instance.Message)
+	}
+	w.Flush()
 }
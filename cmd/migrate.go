@@ -1,13 +1,25 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/spf13/cobra"
 	"github.com/taemon1337/ec-manager/pkg/ami"
 	"github.com/taemon1337/ec-manager/pkg/client"
 	"github.com/taemon1337/ec-manager/pkg/logger"
+	"gopkg.in/yaml.v3"
 )
 
 // migrateCmd represents the migrate command
@@ -16,7 +28,50 @@ var migrateCmd = &cobra.Command{
 	Short: "Migrate EC2 instances to a new AMI",
 	Long: `migrate moves EC2 instances to a new AMI. You can specify a single instance
 using the --instance-id flag, or migrate all instances with the ami-migrate=enabled tag
-by using the --enabled flag. The --new-ami flag is required to specify the target AMI.`,
+by using the --enabled flag. The --new-ami flag is required to specify the target AMI.
+Add --old-ami to only migrate the --enabled instances still running that AMI.
+Repeat --enabled-value to migrate the instances tagged ami-migrate with any of
+several values instead of enabled, e.g. canary and prod in one run.
+Add --only-stopped or --only-running to only migrate the --enabled instances in
+that state, e.g. the stopped ones overnight and the running ones in a maintenance
+window. Running instances still need the ami-migrate-if-running=enabled tag or
+--force.
+
+To keep the long snapshot step out of the disruptive one, run with
+--snapshot-only first, e.g. during a quiet window: the backup snapshots are
+taken and the instances tagged ami-migrate-status=snapshotted, but they aren't
+stopped or replaced. Run again with --cutover to stop and replace the
+snapshotted instances using those snapshots. Data written after the snapshots
+were taken is not carried over to the replacements.
+
+Use --dry-run to print the planned actions as JSON (or YAML with --output yaml)
+without changing anything. Add --dry-run-output-file to also save the plan as
+JSON, e.g. for change-management approval, and --dry-run-graph-file to render
+it as a Graphviz DOT graph of the instances by batch and availability zone.
+Otherwise the instances that will be replaced are listed and confirmation is
+asked for first; pass --yes to skip the prompt.
+
+Use --pre-stop-script or --pre-stop-document to shut applications down cleanly
+through AWS Systems Manager before running instances are stopped, and the
+--health-check-* flags to smoke test each replacement before the migration
+is marked completed.
+
+Use --launch-template to launch the replacements from an EC2 launch template,
+taking their networking, instance profile and tags from it instead of the
+original instance. The --new-ami AMI and the instance type override the
+template's.
+
+The replacements get their original instance's user data; use --user-data-file
+to launch them with the user data in a file instead.
+
+Use --spot to launch the replacements as Spot instances. Instances tagged
+ami-migrate-critical=enabled are always replaced On-Demand.
+
+Give --region a comma-separated list, or use --all-regions, to migrate the
+--enabled instances of several regions at once. --new-ami is taken to be in
+--new-ami-region (the first --region by default); the other regions migrate
+to their copy of it made by copy-ami. A region that fails doesn't stop the
+others.`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		// Validate required flags
 		instanceID, _ := cmd.Flags().GetString("instance-id")
@@ -31,6 +86,74 @@ by using the --enabled flag. The --new-ami flag is required to specify the targe
 			return fmt.Errorf("--new-ami flag must be specified")
 		}
 
+		if oldAMI, _ := cmd.Flags().GetString("old-ami"); oldAMI != "" && !enabled {
+			return fmt.Errorf("--old-ami can only be used with --enabled")
+		}
+
+		if enabledValues, _ := cmd.Flags().GetStringSlice("enabled-value"); len(enabledValues) > 0 && !enabled {
+			return fmt.Errorf("--enabled-value can only be used with --enabled")
+		}
+
+		onlyStopped, _ := cmd.Flags().GetBool("only-stopped")
+		onlyRunning, _ := cmd.Flags().GetBool("only-running")
+		if onlyStopped && onlyRunning {
+			return fmt.Errorf("--only-stopped can't be used with --only-running")
+		}
+		if (onlyStopped || onlyRunning) && !enabled {
+			return fmt.Errorf("--only-stopped and --only-running can only be used with --enabled")
+		}
+
+		snapshotOnly, _ := cmd.Flags().GetBool("snapshot-only")
+		if cutover, _ := cmd.Flags().GetBool("cutover"); snapshotOnly && cutover {
+			return fmt.Errorf("--snapshot-only can't be used with --cutover")
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if priceTable, _ := cmd.Flags().GetString("price-table"); priceTable != "" && !dryRun {
+			return fmt.Errorf("--price-table can only be used with --dry-run")
+		}
+		if planFile, _ := cmd.Flags().GetString("dry-run-output-file"); planFile != "" && !dryRun {
+			return fmt.Errorf("--dry-run-output-file can only be used with --dry-run")
+		}
+		if graphFile, _ := cmd.Flags().GetString("dry-run-graph-file"); graphFile != "" && !dryRun {
+			return fmt.Errorf("--dry-run-graph-file can only be used with --dry-run")
+		}
+
+		if outputEvents, _ := cmd.Flags().GetBool("output-events"); outputEvents {
+			if !enabled || dryRun {
+				return fmt.Errorf("--output-events can only be used with --enabled migrations, not --dry-run")
+			}
+			for _, flag := range []string{"progress", "no-wait"} {
+				if set, _ := cmd.Flags().GetBool(flag); set {
+					return fmt.Errorf("--output-events can't be used with --%s", flag)
+				}
+			}
+			if outputFormat != outputTable {
+				return fmt.Errorf("--output-events can't be used with --output %s", outputFormat)
+			}
+		}
+
+		kmsKeyID, _ := cmd.Flags().GetString("kms-key-id")
+		encryptUnencrypted, _ := cmd.Flags().GetBool("encrypt-unencrypted-snapshots")
+		if encryptUnencrypted && kmsKeyID == "" {
+			return fmt.Errorf("--encrypt-unencrypted-snapshots requires --kms-key-id")
+		}
+
+		allRegions, _ := cmd.Flags().GetBool("all-regions")
+		if allRegions || len(regionList()) > 1 {
+			if !enabled || instanceID != "" {
+				return fmt.Errorf("migrating several regions requires --enabled instead of --instance-id")
+			}
+			for _, flag := range []string{"no-wait", "confirm-batches"} {
+				if set, _ := cmd.Flags().GetBool(flag); set {
+					return fmt.Errorf("--%s can't be used when migrating several regions", flag)
+				}
+			}
+			if newAMIRegion(cmd) == "" {
+				return fmt.Errorf("--all-regions requires --new-ami-region or --region to say which region --new-ami is in")
+			}
+		}
+
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -38,50 +161,252 @@ by using the --enabled flag. The --new-ami flag is required to specify the targe
 
 		// Get flag values
 		instanceID, _ := cmd.Flags().GetString("instance-id")
-		enabled, _ := cmd.Flags().GetBool("enabled")
 		newAMI, _ := cmd.Flags().GetString("new-ami")
+		oldAMI, _ := cmd.Flags().GetString("old-ami")
+		enabledValues, _ := cmd.Flags().GetStringSlice("enabled-value")
+		onlyState := stateScopeFromFlags(cmd)
+		snapshotOnly, _ := cmd.Flags().GetBool("snapshot-only")
+		cutover, _ := cmd.Flags().GetBool("cutover")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		tagSelectors, _ := cmd.Flags().GetStringToString("tag")
+		excludeTags, _ := cmd.Flags().GetStringToString("exclude-tag")
+		nameFilter, _ := cmd.Flags().GetString("name-filter")
+		excludeMigrated, _ := cmd.Flags().GetBool("exclude-migrated")
+		force, _ := cmd.Flags().GetBool("force")
+		instanceType, _ := cmd.Flags().GetString("instance-type")
+		keepSnapshots, _ := cmd.Flags().GetBool("keep-snapshots-on-failure")
+		waitForSnapshots, _ := cmd.Flags().GetBool("wait-for-snapshots")
+		retainOld, _ := cmd.Flags().GetBool("retain-old-instance")
+		overrideProtection, _ := cmd.Flags().GetBool("override-termination-protection")
+		nameSuffix, _ := cmd.Flags().GetString("name-suffix")
+		copyTagKeys, _ := cmd.Flags().GetStringSlice("copy-tag-keys")
+		skipTagKeys, _ := cmd.Flags().GetStringSlice("skip-tag-keys")
+		var snapshotSelector ami.SnapshotSelector
+		if rootOnly, _ := cmd.Flags().GetBool("snapshot-root-only"); rootOnly {
+			snapshotSelector = ami.RootVolumeOnly
+		}
+		kmsKeyID, _ := cmd.Flags().GetString("kms-key-id")
+		encryptUnencrypted, _ := cmd.Flags().GetBool("encrypt-unencrypted-snapshots")
+		metricsNamespace, _ := cmd.Flags().GetString("metrics-namespace")
+		launchTemplate := launchTemplateFromFlags(cmd)
+		notifyTopicARN, _ := cmd.Flags().GetString("notify-sns-topic")
+		notifyWebhookURL, _ := cmd.Flags().GetString("notify-webhook")
+		preStopHook, err := preStopHookFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		healthCheck, err := healthCheckFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		spot, err := spotFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		priceTable, err := priceTableFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		userData, err := userDataFromFlags(cmd)
+		if err != nil {
+			return err
+		}
 
 		// Create AWS clients
 		ctx := cmd.Context()
 		if ctx == nil {
 			ctx = context.Background()
 		}
-		ec2Client, err := client.GetEC2Client(ctx)
+		// newService creates the AMI service with the clients the flags need,
+		// in the region set on ctx if any
+		newService := func(ctx context.Context) (*ami.Service, error) {
+			ec2Client, err := client.GetEC2Client(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get EC2 client: %w", err)
+			}
+			opts := serviceOptions()
+			if verifyTags, _ := cmd.Flags().GetBool("verify-status-tags"); verifyTags {
+				opts = append(opts, ami.WithStatusTagVerification(ami.DefaultStatusTagVerifyAttempts, ami.DefaultStatusTagVerifyInterval))
+			}
+			if preStopHook != nil || (healthCheck != nil && (healthCheck.DocumentName != "" || len(healthCheck.Commands) > 0)) {
+				ssmClient, err := client.GetSSMClient(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get SSM client: %w", err)
+				}
+				opts = append(opts, ami.WithSSMClient(ssmClient))
+			}
+			if metricsNamespace != "" && !dryRun {
+				cwClient, err := client.GetCloudWatchClient(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get CloudWatch client: %w", err)
+				}
+				opts = append(opts, ami.WithCloudWatchClient(cwClient))
+			}
+			if notifyTopicARN != "" && !dryRun {
+				snsClient, err := client.GetSNSClient(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get SNS client: %w", err)
+				}
+				opts = append(opts, ami.WithSNSClient(snsClient))
+			}
+			return ami.NewService(ec2Client, opts...), nil
+		}
+		svc, err := newService(ctx)
+		if err != nil {
+			return err
+		}
+		services, err := regionServices(ctx, cmd, svc, newService)
 		if err != nil {
-			return fmt.Errorf("failed to get EC2 client: %w", err)
+			return err
 		}
+		amiRegion := newAMIRegion(cmd)
 
-		// Create AMI service
-		svc := ami.NewService(ec2Client)
+		planOpts := ami.MigrateOptions{
+			NewAMI:            newAMI,
+			OldAMI:            oldAMI,
+			EnabledValues:     enabledValues,
+			OnlyState:         onlyState,
+			SnapshotOnly:      snapshotOnly,
+			Cutover:           cutover,
+			TagSelectors:      tagSelectors,
+			ExcludeTags:       excludeTags,
+			NameFilter:        nameFilter,
+			ExcludeTargetAMI:  excludeMigrated,
+			Force:             force,
+			InstanceType:      types.InstanceType(instanceType),
+			LaunchTemplate:    launchTemplate,
+			WaitForSnapshots:  waitForSnapshots,
+			RetainOldInstance: retainOld,
+			SnapshotSelector:  snapshotSelector,
+			PreStopHook:       preStopHook,
+			HealthCheck:       healthCheck,
+			Pricer:            priceTable,
+		}
+		if dryRun {
+			// The plan numbers the batches the run would be split into
+			if err := applyBatchFlags(cmd, &planOpts); err != nil {
+				return err
+			}
+			if services != nil {
+				return printRegionPlans(ctx, cmd, services, amiRegion, planOpts)
+			}
+			return printMigrationPlan(ctx, cmd, svc, instanceID, planOpts)
+		}
+		if noWait, _ := cmd.Flags().GetBool("no-wait"); noWait {
+			return migrateInBackground(ctx, cmd, svc, instanceID, planOpts)
+		}
 
-		// Get instances to migrate
-		var instances []string
 		if instanceID != "" {
-			instances = []string{instanceID}
-		} else if enabled {
-			// Get all instances with ami-migrate=enabled tag
-			taggedInstances, err := svc.ListUserInstances(ctx, "ami-migrate")
+			instanceOpts := ami.MigrateOptions{
+				NewAMI:                        newAMI,
+				SnapshotOnly:                  snapshotOnly,
+				Cutover:                       cutover,
+				Force:                         force,
+				InstanceType:                  types.InstanceType(instanceType),
+				LaunchTemplate:                launchTemplate,
+				UserData:                      userData,
+				Spot:                          spot,
+				KeepSnapshotsOnFailure:        keepSnapshots,
+				WaitForSnapshots:              waitForSnapshots,
+				RetainOldInstance:             retainOld,
+				OverrideTerminationProtection: overrideProtection,
+				NameSuffix:                    nameSuffix,
+				CopyTagKeys:                   copyTagKeys,
+				SkipTagKeys:                   skipTagKeys,
+				SnapshotSelector:              snapshotSelector,
+				KMSKeyID:                      kmsKeyID,
+				EncryptUnencryptedSnapshots:   encryptUnencrypted,
+				PreStopHook:                   preStopHook,
+				HealthCheck:                   healthCheck,
+				MetricsNamespace:              metricsNamespace,
+			}
+			if err := confirmMigration(ctx, cmd, svc, instanceID, instanceOpts); err != nil {
+				return err
+			}
+			instanceResult, err := svc.MigrateInstanceWithOptions(ctx, instanceID, instanceOpts)
 			if err != nil {
-				return fmt.Errorf("failed to list instances: %v", err)
+				return fmt.Errorf("failed to migrate instance %s: %v", instanceID, err)
+			}
+			result := &ami.MigrationResult{
+				Instances: []ami.InstanceResult{*instanceResult},
+				Duration:  instanceResult.Duration,
 			}
-			for _, instance := range taggedInstances {
-				instances = append(instances, instance.InstanceID)
+			result.Summarize()
+			if ok, err := writeOutput(cmd, result); ok {
+				return err
 			}
+			fmt.Fprint(cmd.OutOrStdout(), result.FormatMigrationResult())
+			return nil
 		}
 
-		if len(instances) == 0 {
-			return fmt.Errorf("no instances found to migrate")
+		// Migrate all instances with the ami-migrate=enabled tag
+		maxConcurrency, _ := cmd.Flags().GetInt("max-concurrency")
+		startStagger, _ := cmd.Flags().GetDuration("start-stagger")
+		migrateOpts := ami.MigrateOptions{
+			NewAMI:                        newAMI,
+			OldAMI:                        oldAMI,
+			EnabledValues:                 enabledValues,
+			OnlyState:                     onlyState,
+			SnapshotOnly:                  snapshotOnly,
+			Cutover:                       cutover,
+			TagSelectors:                  tagSelectors,
+			ExcludeTags:                   excludeTags,
+			NameFilter:                    nameFilter,
+			ExcludeTargetAMI:              excludeMigrated,
+			Force:                         force,
+			MaxConcurrency:                maxConcurrency,
+			StartStagger:                  startStagger,
+			InstanceType:                  types.InstanceType(instanceType),
+			LaunchTemplate:                launchTemplate,
+			UserData:                      userData,
+			Spot:                          spot,
+			KeepSnapshotsOnFailure:        keepSnapshots,
+			WaitForSnapshots:              waitForSnapshots,
+			RetainOldInstance:             retainOld,
+			OverrideTerminationProtection: overrideProtection,
+			NameSuffix:                    nameSuffix,
+			CopyTagKeys:                   copyTagKeys,
+			SkipTagKeys:                   skipTagKeys,
+			SnapshotSelector:              snapshotSelector,
+			KMSKeyID:                      kmsKeyID,
+			EncryptUnencryptedSnapshots:   encryptUnencrypted,
+			PreStopHook:                   preStopHook,
+			HealthCheck:                   healthCheck,
+			MetricsNamespace:              metricsNamespace,
+			NotifyTopicARN:                notifyTopicARN,
+			NotifyWebhookURL:              notifyWebhookURL,
 		}
-
-		// Migrate each instance
-		for _, instance := range instances {
-			if err := svc.MigrateInstance(ctx, instance, newAMI); err != nil {
-				return fmt.Errorf("failed to migrate instance %s: %v", instance, err)
+		if services != nil {
+			return migrateAllRegions(ctx, cmd, services, amiRegion, migrateOpts)
+		}
+		if err := confirmMigration(ctx, cmd, svc, "", migrateOpts); err != nil {
+			return err
+		}
+		if showProgress, _ := cmd.Flags().GetBool("progress"); showProgress {
+			migrateOpts.Progress = printProgress(cmd)
+		}
+		if outputEvents, _ := cmd.Flags().GetBool("output-events"); outputEvents {
+			migrateOpts.Progress = writeEvents(cmd)
+		}
+		if err := applyBatchFlags(cmd, &migrateOpts); err != nil {
+			return err
+		}
+		result, err := svc.MigrateInstances(ctx, "enabled", migrateOpts)
+		var outErr error
+		if result != nil && result.Summary.Total > 0 {
+			var printed bool
+			if printed, outErr = writeOutput(cmd, result); !printed {
+				fmt.Fprint(summaryWriter(cmd), result.FormatMigrationResult())
 			}
-			logger.Info("Successfully migrated instance", "instanceID", instance)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate instances: %v", err)
+		}
+		if result.Summary.Total == 0 {
+			return fmt.Errorf("no instances found to migrate")
 		}
 
-		return nil
+		return outErr
 	},
 }
 
@@ -89,7 +414,607 @@ func init() {
 	rootCmd.AddCommand(migrateCmd)
 
 	// Add flags
+	addYesFlag(migrateCmd)
 	migrateCmd.Flags().String("instance-id", "", "ID of the instance to migrate")
 	migrateCmd.Flags().String("new-ami", "", "ID of the new AMI to migrate to")
 	migrateCmd.Flags().Bool("enabled", false, "Migrate all instances with ami-migrate=enabled tag")
+	migrateCmd.Flags().String("old-ami", "", "Only migrate --enabled instances currently running this AMI")
+	migrateCmd.Flags().StringSlice("enabled-value", nil, "Migrate the instances tagged ami-migrate with any of these values instead of enabled; repeat or comma-separate for several")
+	migrateCmd.Flags().Bool("only-stopped", false, "Only migrate --enabled instances that are stopped")
+	migrateCmd.Flags().Bool("only-running", false, "Only migrate --enabled instances that are running (they still need the if-running tag or --force)")
+	migrateCmd.Flags().Bool("snapshot-only", false, "Only take the backup snapshots and tag the instances snapshotted, without stopping or replacing them (see --cutover)")
+	migrateCmd.Flags().Bool("cutover", false, "Stop and replace the instances tagged snapshotted by --snapshot-only, using those snapshots")
+	migrateCmd.Flags().Bool("dry-run", false, "Print the migration plan without making any changes")
+	migrateCmd.Flags().Bool("no-wait", false, "Carry on with the migration in a background process and return once it has started")
+	migrateCmd.Flags().String("no-wait-log", "", "Log file for the --no-wait background process (defaults to a new file in the temp directory)")
+	migrateCmd.Flags().String("dry-run-output-file", "", "Also write the --dry-run plan as JSON to this file, e.g. to attach it to a change request")
+	migrateCmd.Flags().String("dry-run-graph-file", "", "Also write the --dry-run plan as a Graphviz DOT graph to this file, grouped by batch and availability zone")
+	migrateCmd.Flags().String("price-table", "", "YAML file of hourly On-Demand prices by instance type (e.g. t3.small: 0.0208) to estimate the monthly cost change in the --dry-run plan")
+	migrateCmd.Flags().StringToString("tag", nil, "Only migrate --enabled instances that also carry these tags (e.g. --tag Environment=staging)")
+	migrateCmd.Flags().StringToString("exclude-tag", nil, "Skip --enabled instances carrying any of these tags (e.g. --exclude-tag maintenance=hold)")
+	migrateCmd.Flags().String("name-filter", "", "Only migrate --enabled instances whose Name tag matches this glob (e.g. web-*) or /regex/")
+	migrateCmd.Flags().Bool("force", false, "Also migrate running instances without the ami-migrate-if-running=enabled tag")
+	migrateCmd.Flags().Bool("exclude-migrated", false, "Leave --enabled instances already on --new-ami out of the run instead of reporting them as skipped")
+	migrateCmd.Flags().Int("max-concurrency", ami.DefaultMaxConcurrency, "Maximum number of instances to migrate at once with --enabled")
+	migrateCmd.Flags().Duration("start-stagger", 0, "With --enabled, space out the start of each instance's migration by a random delay of up to this long, e.g. 2s")
+	migrateCmd.Flags().Bool("verify-status-tags", false, "Re-read each instance after writing its ami-migrate-status tags until they are visible, so list and status see them at once")
+	migrateCmd.Flags().String("instance-type", "", "Launch the replacement instances as this type instead of the original type")
+	migrateCmd.Flags().String("launch-template", "", "Launch the replacements from this launch template ID or name, overriding its AMI with --new-ami")
+	migrateCmd.Flags().String("launch-template-version", "", "Version of --launch-template to use (defaults to the template's default version)")
+	migrateCmd.Flags().String("user-data-file", "", "Launch the replacements with the user data in this file instead of their original instance's")
+	migrateCmd.Flags().Bool("spot", false, "Launch the replacements as Spot instances, except for instances tagged ami-migrate-critical=enabled")
+	migrateCmd.Flags().String("spot-max-price", "", "Maximum hourly price for --spot replacements (defaults to the On-Demand price)")
+	migrateCmd.Flags().String("spot-interruption-behavior", "", "What happens to --spot replacements when interrupted: terminate (the default), stop or hibernate")
+	migrateCmd.Flags().Bool("wait-for-snapshots", false, "Wait for every backup snapshot to complete before terminating the old instance")
+	migrateCmd.Flags().Bool("override-termination-protection", false, "Disable termination protection on protected instances to replace them, and enable it on their replacements")
+	migrateCmd.Flags().Bool("retain-old-instance", false, "Keep the old instance stopped, tagged ami-migrate-replaced-by, instead of terminating it (see cleanup-instances)")
+	migrateCmd.Flags().String("name-suffix", "", "Append this to the Name tag copied to each replacement, e.g. -migrated or -{timestamp} (see verify --strip-name-suffix)")
+	migrateCmd.Flags().StringSlice("copy-tag-keys", nil, "Only copy the tags whose keys match these globs to each replacement, e.g. Name,team-* (the ami-migrate tags are always copied)")
+	migrateCmd.Flags().StringSlice("skip-tag-keys", nil, "Don't copy the tags whose keys match these globs to each replacement, e.g. backup-*")
+	migrateCmd.Flags().Bool("snapshot-root-only", false, "Only snapshot the root volume; data volumes are then not recreated on the replacement")
+	migrateCmd.Flags().String("metrics-namespace", "", "Publish migration counts and durations to CloudWatch under this namespace")
+	migrateCmd.Flags().String("notify-sns-topic", "", "Publish a summary to this SNS topic ARN when an --enabled migration finishes")
+	migrateCmd.Flags().String("notify-webhook", "", "POST a JSON summary to this URL when an --enabled migration finishes")
+	migrateCmd.Flags().Bool("keep-snapshots-on-failure", false, "Keep the backup snapshots of instances whose migration fails instead of deleting them")
+	migrateCmd.Flags().String("kms-key-id", "", "Encrypt the backup snapshots with this KMS key (use the key ARN)")
+	migrateCmd.Flags().Bool("encrypt-unencrypted-snapshots", false, "Also encrypt the backups of unencrypted volumes with --kms-key-id")
+	migrateCmd.Flags().String("pre-stop-script", "", "Run this local shell script on running instances through SSM before stopping them")
+	migrateCmd.Flags().String("pre-stop-document", "", "Run this SSM document on running instances before stopping them (defaults to AWS-RunShellScript with --pre-stop-script)")
+	migrateCmd.Flags().String("health-check-script", "", "Run this local shell script on each replacement through SSM and only complete the migration if it succeeds")
+	migrateCmd.Flags().String("health-check-document", "", "Run this SSM document on each replacement as a health check (defaults to AWS-RunShellScript with --health-check-script)")
+	migrateCmd.Flags().String("health-check-path", "", "Only complete the migration once this HTTP path on the replacement's private IP returns a 2xx status")
+	migrateCmd.Flags().Int("health-check-port", 80, "Port for --health-check-path")
+	migrateCmd.Flags().Duration("health-check-timeout", 0, "How long the health check may take (defaults to --timeout)")
+	migrateCmd.Flags().String("batch-size", "", "Migrate --enabled instances in waves of this many (e.g. 5) or this share of them (e.g. 25%)")
+	migrateCmd.Flags().Duration("batch-delay", 0, "Pause between --batch-size waves")
+	migrateCmd.Flags().Bool("verify-batches", false, "Stop before the next wave if any migration in a wave failed or its replacements aren't healthy")
+	migrateCmd.Flags().Bool("confirm-batches", false, "Ask for confirmation before starting each wave after the first")
+	migrateCmd.Flags().Bool("all-regions", false, "Migrate the --enabled instances of every region enabled for the account")
+	migrateCmd.Flags().String("new-ami-region", "", "Region --new-ami is in when migrating several regions (defaults to the first --region)")
+	migrateCmd.Flags().Bool("progress", false, "Print each instance's progress to stderr as --enabled migrations run")
+	migrateCmd.Flags().Bool("output-events", false, "Write each instance's progress to stdout as a JSON line as --enabled migrations run, e.g. for CI; the summary goes to stderr")
+}
+
+// newAMIRegion returns the region --new-ami is in: --new-ami-region, or else
+// the first --region
+func newAMIRegion(cmd *cobra.Command) string {
+	if amiRegion, _ := cmd.Flags().GetString("new-ami-region"); amiRegion != "" {
+		return amiRegion
+	}
+	if regions := regionList(); len(regions) > 0 {
+		return regions[0]
+	}
+	return ""
+}
+
+// regionServices creates a service per region when --region lists several
+// regions or --all-regions is set, or returns nil to migrate a single region
+// with svc
+func regionServices(ctx context.Context, cmd *cobra.Command, svc *ami.Service, newService func(context.Context) (*ami.Service, error)) (map[string]*ami.Service, error) {
+	regions := regionList()
+	if allRegions, _ := cmd.Flags().GetBool("all-regions"); allRegions {
+		var err error
+		if regions, err = svc.EnabledRegions(ctx); err != nil {
+			return nil, fmt.Errorf("failed to list regions: %v", err)
+		}
+	} else if len(regions) < 2 {
+		return nil, nil
+	}
+
+	services := make(map[string]*ami.Service, len(regions))
+	for _, r := range regions {
+		regionSvc, err := newService(client.WithRegion(ctx, r))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r, err)
+		}
+		services[r] = regionSvc
+	}
+	return services, nil
+}
+
+// planRegions plans the migration of the enabled instances in every region
+// without changing anything
+func planRegions(ctx context.Context, services map[string]*ami.Service, amiRegion string, opts ami.MigrateOptions) (map[string]*ami.MigrationPlan, error) {
+	opts.DryRun = true
+	result, err := ami.MigrateRegions(ctx, services, "enabled", opts, amiRegion)
+	plans := make(map[string]*ami.MigrationPlan)
+	for r, regionResult := range result.Regions {
+		plans[r] = regionResult.Plan
+	}
+	if err != nil {
+		return plans, fmt.Errorf("failed to plan migration: %v", err)
+	}
+	return plans, nil
+}
+
+// printRegionPlans writes the dry-run plan of every region as JSON, keyed by
+// region. The plans of the regions that could be planned are printed even if
+// others failed.
+func printRegionPlans(ctx context.Context, cmd *cobra.Command, services map[string]*ami.Service, amiRegion string, opts ami.MigrateOptions) error {
+	if graphFile, _ := cmd.Flags().GetString("dry-run-graph-file"); graphFile != "" {
+		return fmt.Errorf("--dry-run-graph-file can't be used when migrating several regions")
+	}
+	opts.ValidatePermissions = true
+	plans, err := planRegions(ctx, services, amiRegion, opts)
+	if saveErr := savePlan(cmd, plans); saveErr != nil {
+		return saveErr
+	}
+	if ok, outErr := writeOutput(cmd, plans); ok {
+		if err != nil {
+			return err
+		}
+		return outErr
+	}
+	out, jsonErr := json.MarshalIndent(plans, "", "  ")
+	if jsonErr != nil {
+		return fmt.Errorf("failed to encode migration plan: %v", jsonErr)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(out))
+	return err
+}
+
+// migrateAllRegions confirms and runs the migration of the enabled instances
+// in every region at once
+func migrateAllRegions(ctx context.Context, cmd *cobra.Command, services map[string]*ami.Service, amiRegion string, opts ami.MigrateOptions) error {
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+		plans, err := planRegions(ctx, services, amiRegion, opts)
+		if err != nil {
+			return err
+		}
+		regions := make([]string, 0, len(plans))
+		for r := range plans {
+			regions = append(regions, r)
+		}
+		slices.Sort(regions)
+		var lines []string
+		total := 0
+		for _, r := range regions {
+			for _, instance := range plans[r].Instances {
+				total++
+				if instance.Migrate {
+					lines = append(lines, fmt.Sprintf("  %s %s (%s, %s -> %s)",
+						r, instance.InstanceID, instance.State, instance.CurrentAMI, instance.TargetAMI))
+				}
+			}
+		}
+		if len(lines) > 0 {
+			summary := fmt.Sprintf("%d of %d instance(s) in %d region(s) will be stopped, replaced and terminated:\n%s",
+				len(lines), total, len(regions), strings.Join(lines, "\n"))
+			if err := confirmAction(cmd, summary); err != nil {
+				return err
+			}
+		}
+	}
+	if showProgress, _ := cmd.Flags().GetBool("progress"); showProgress {
+		opts.Progress = printProgress(cmd)
+	}
+	if outputEvents, _ := cmd.Flags().GetBool("output-events"); outputEvents {
+		opts.Progress = writeEvents(cmd)
+	}
+	if err := applyBatchFlags(cmd, &opts); err != nil {
+		return err
+	}
+
+	result, err := ami.MigrateRegions(ctx, services, "enabled", opts, amiRegion)
+	var outErr error
+	if result.Summary.Total > 0 || len(result.RegionErrors) > 0 {
+		var printed bool
+		if printed, outErr = writeOutput(cmd, result); !printed {
+			fmt.Fprint(summaryWriter(cmd), result.FormatMigrationResult())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate instances: %v", err)
+	}
+	if result.Summary.Total == 0 {
+		return fmt.Errorf("no instances found to migrate")
+	}
+	return outErr
+}
+
+// applyBatchFlags sets the batch options from --batch-size, --batch-delay,
+// --verify-batches and --confirm-batches
+func applyBatchFlags(cmd *cobra.Command, opts *ami.MigrateOptions) error {
+	if batchSize, _ := cmd.Flags().GetString("batch-size"); batchSize != "" {
+		value, percent := strings.CutSuffix(batchSize, "%")
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || (percent && n > 100) {
+			return fmt.Errorf("invalid --batch-size %q: use a count such as 5 or a percentage such as 25%%", batchSize)
+		}
+		if percent {
+			opts.BatchPercent = n
+		} else {
+			opts.BatchSize = n
+		}
+	}
+	opts.BatchDelay, _ = cmd.Flags().GetDuration("batch-delay")
+	opts.VerifyBatches, _ = cmd.Flags().GetBool("verify-batches")
+	if confirm, _ := cmd.Flags().GetBool("confirm-batches"); confirm {
+		opts.BeforeBatch = confirmBatch(cmd)
+	}
+	return nil
+}
+
+// confirmBatch returns a batch hook that summarizes the previous wave and
+// asks whether to start the next one
+func confirmBatch(cmd *cobra.Command) ami.BatchHook {
+	return func(ctx context.Context, batch, batches int, previous []ami.InstanceResult) error {
+		done := &ami.MigrationResult{Instances: previous}
+		done.Summarize()
+		if !isTerminal(cmd.InOrStdin()) {
+			return fmt.Errorf("stdin is not a terminal to confirm batches on")
+		}
+		question := fmt.Sprintf("Batch %d of %d finished: %d completed, %d skipped, %d failed. Start batch %d?",
+			batch-1, batches, done.Summary.Completed, done.Summary.Skipped, done.Summary.Failed, batch)
+		if !promptYesNo(cmd, question) {
+			return fmt.Errorf("not confirmed")
+		}
+		return nil
+	}
+}
+
+// preStopHookFromFlags builds the pre-stop hook from --pre-stop-script and
+// --pre-stop-document, or returns nil when neither is set
+func preStopHookFromFlags(cmd *cobra.Command) (*ami.PreStopHook, error) {
+	document, _ := cmd.Flags().GetString("pre-stop-document")
+	commands, err := scriptFlag(cmd, "pre-stop-script")
+	if err != nil || (document == "" && commands == nil) {
+		return nil, err
+	}
+	return &ami.PreStopHook{DocumentName: document, Commands: commands}, nil
+}
+
+// healthCheckFromFlags builds the replacement health check from the
+// --health-check-* flags, or returns nil when no check is asked for
+func healthCheckFromFlags(cmd *cobra.Command) (*ami.HealthCheck, error) {
+	document, _ := cmd.Flags().GetString("health-check-document")
+	path, _ := cmd.Flags().GetString("health-check-path")
+	commands, err := scriptFlag(cmd, "health-check-script")
+	if err != nil || (document == "" && path == "" && commands == nil) {
+		return nil, err
+	}
+	port, _ := cmd.Flags().GetInt("health-check-port")
+	timeout, _ := cmd.Flags().GetDuration("health-check-timeout")
+	return &ami.HealthCheck{
+		DocumentName: document,
+		Commands:     commands,
+		HTTPPath:     path,
+		HTTPPort:     port,
+		Timeout:      timeout,
+	}, nil
+}
+
+// stateScopeFromFlags returns the instance state --only-stopped or
+// --only-running limits the migration to, or "" for every state
+func stateScopeFromFlags(cmd *cobra.Command) types.InstanceStateName {
+	if onlyStopped, _ := cmd.Flags().GetBool("only-stopped"); onlyStopped {
+		return types.InstanceStateNameStopped
+	}
+	if onlyRunning, _ := cmd.Flags().GetBool("only-running"); onlyRunning {
+		return types.InstanceStateNameRunning
+	}
+	return ""
+}
+
+// launchTemplateFromFlags builds the launch template from --launch-template
+// and --launch-template-version, or returns nil when no template is given.
+// Template IDs start with lt-, anything else is taken as a template name.
+func launchTemplateFromFlags(cmd *cobra.Command) *ami.LaunchTemplate {
+	template, _ := cmd.Flags().GetString("launch-template")
+	if template == "" {
+		return nil
+	}
+	version, _ := cmd.Flags().GetString("launch-template-version")
+	if strings.HasPrefix(template, "lt-") {
+		return &ami.LaunchTemplate{ID: template, Version: version}
+	}
+	return &ami.LaunchTemplate{Name: template, Version: version}
+}
+
+// spotFromFlags builds the Spot options from --spot, --spot-max-price and
+// --spot-interruption-behavior, or returns nil without --spot
+func spotFromFlags(cmd *cobra.Command) (*ami.SpotOptions, error) {
+	if spot, _ := cmd.Flags().GetBool("spot"); !spot {
+		return nil, nil
+	}
+	maxPrice, _ := cmd.Flags().GetString("spot-max-price")
+	value, _ := cmd.Flags().GetString("spot-interruption-behavior")
+	behavior := types.InstanceInterruptionBehavior(value)
+	if behavior != "" && !slices.Contains(behavior.Values(), behavior) {
+		return nil, fmt.Errorf("invalid --spot-interruption-behavior %q: use terminate, stop or hibernate", value)
+	}
+	return &ami.SpotOptions{MaxPrice: maxPrice, InterruptionBehavior: behavior}, nil
+}
+
+// priceTableFromFlags loads the price table named by --price-table, or
+// returns nil when it isn't set
+func priceTableFromFlags(cmd *cobra.Command) (ami.InstancePricer, error) {
+	path, _ := cmd.Flags().GetString("price-table")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --price-table: %v", err)
+	}
+	var table ami.PriceTable
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to parse --price-table %s: %v", path, err)
+	}
+	return table, nil
+}
+
+// userDataFromFlags reads the user data file named by --user-data-file, or
+// returns "" when it isn't set
+func userDataFromFlags(cmd *cobra.Command) (string, error) {
+	path, _ := cmd.Flags().GetString("user-data-file")
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read --user-data-file: %v", err)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("--user-data-file %s is empty", path)
+	}
+	return string(data), nil
+}
+
+// scriptFlag reads the local script named by a flag into SSM commands, or
+// returns nil when the flag isn't set
+func scriptFlag(cmd *cobra.Command, name string) ([]string, error) {
+	path, _ := cmd.Flags().GetString(name)
+	if path == "" {
+		return nil, nil
+	}
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --%s: %v", name, err)
+	}
+	return []string{string(script)}, nil
+}
+
+// printProgress returns a progress callback that writes one line per event to stderr
+func printProgress(cmd *cobra.Command) ami.ProgressFunc {
+	return func(event ami.InstanceProgress) {
+		line := fmt.Sprintf("%s  %-20s %s", event.Time.Format("15:04:05"), event.InstanceID, event.Stage)
+		if event.Message != "" {
+			line += ": " + event.Message
+		}
+		fmt.Fprintln(cmd.ErrOrStderr(), line)
+	}
+}
+
+// migrationEvent is a progress event as written by --output-events
+type migrationEvent struct {
+	Timestamp  time.Time `json:"timestamp"`
+	InstanceID string    `json:"instance_id"`
+	Phase      string    `json:"phase"`
+	Status     string    `json:"status"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// eventStatus returns the status of an instance that reached phase: its
+// final status once it ends the migration, in-progress until then
+func eventStatus(phase string) string {
+	switch phase {
+	case ami.ProgressCompleted, ami.ProgressSkipped, ami.ProgressFailed, ami.ProgressCancelled, ami.StatusSnapshotted:
+		return phase
+	default:
+		return "in-progress"
+	}
+}
+
+// writeEvents returns a ProgressFunc that writes each event to stdout as a
+// line of JSON, for --output-events
+func writeEvents(cmd *cobra.Command) ami.ProgressFunc {
+	encoder := json.NewEncoder(cmd.OutOrStdout())
+	return func(event ami.InstanceProgress) {
+		err := encoder.Encode(migrationEvent{
+			Timestamp:  event.Time,
+			InstanceID: event.InstanceID,
+			Phase:      event.Stage,
+			Status:     eventStatus(event.Stage),
+			Message:    event.Message,
+		})
+		if err != nil {
+			logger.Warn("Failed to write migration event", "instanceID", event.InstanceID, "error", err)
+		}
+	}
+}
+
+// summaryWriter returns where the text summary of a migration goes: stderr
+// with --output-events, so stdout only carries the events, stdout otherwise
+func summaryWriter(cmd *cobra.Command) io.Writer {
+	if outputEvents, _ := cmd.Flags().GetBool("output-events"); outputEvents {
+		return cmd.ErrOrStderr()
+	}
+	return cmd.OutOrStdout()
+}
+
+// planMigration plans the migration of instanceID, or of every enabled
+// instance when it is empty, without changing anything
+func planMigration(ctx context.Context, svc *ami.Service, instanceID string, opts ami.MigrateOptions) (*ami.MigrationPlan, error) {
+	opts.DryRun = true
+	if instanceID != "" {
+		instancePlan, err := svc.PlanInstanceMigration(ctx, instanceID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan migration for instance %s: %v", instanceID, err)
+		}
+		plan := &ami.MigrationPlan{
+			TargetAMI: opts.NewAMI,
+			Instances: []ami.InstancePlan{*instancePlan},
+		}
+		plan.SummarizeCost()
+		return plan, nil
+	}
+
+	result, err := svc.MigrateInstances(ctx, "enabled", opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan migration: %v", err)
+	}
+	return result.Plan, nil
+}
+
+// confirmMigration summarizes the instances a migration would replace and
+// asks the user to confirm, unless --yes was given. Nothing is asked when no
+// instance would be migrated.
+func confirmMigration(ctx context.Context, cmd *cobra.Command, svc *ami.Service, instanceID string, opts ami.MigrateOptions) error {
+	// Snapshotting leaves the instances as they are, so there is nothing to confirm
+	if yes, _ := cmd.Flags().GetBool("yes"); yes || opts.SnapshotOnly {
+		return nil
+	}
+	plan, err := planMigration(ctx, svc, instanceID, opts)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, instance := range plan.Instances {
+		if instance.Migrate {
+			lines = append(lines, fmt.Sprintf("  %s (%s, %s -> %s)",
+				instance.InstanceID, instance.State, instance.CurrentAMI, instance.TargetAMI))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	summary := fmt.Sprintf("%d of %d instance(s) will be stopped, replaced and terminated:\n%s",
+		len(lines), len(plan.Instances), strings.Join(lines, "\n"))
+	return confirmAction(cmd, summary)
+}
+
+// printMigrationPlan builds a dry-run plan for the selected instances and writes it as JSON
+func printMigrationPlan(ctx context.Context, cmd *cobra.Command, svc *ami.Service, instanceID string, opts ami.MigrateOptions) error {
+	opts.ValidatePermissions = true
+	plan, err := planMigration(ctx, svc, instanceID, opts)
+	if err != nil {
+		return err
+	}
+	if err := savePlan(cmd, plan); err != nil {
+		return err
+	}
+	if err := savePlanGraph(cmd, plan); err != nil {
+		return err
+	}
+
+	// The plan has no table form, so it is printed as JSON unless YAML was asked for
+	if ok, err := writeOutput(cmd, plan); ok {
+		return err
+	}
+	out, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode migration plan: %v", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(out))
+	return nil
+}
+
+// savePlan writes the dry-run plan as JSON to --dry-run-output-file, if
+// given, e.g. to attach it to a change request
+func savePlan(cmd *cobra.Command, plan interface{}) error {
+	path, _ := cmd.Flags().GetString("dry-run-output-file")
+	if path == "" {
+		return nil
+	}
+	out, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode migration plan: %v", err)
+	}
+	if err := os.WriteFile(path, append(out, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write --dry-run-output-file: %v", err)
+	}
+	return nil
+}
+
+// savePlanGraph writes the dry-run plan as a DOT graph to
+// --dry-run-graph-file, if given, e.g. to render with dot -Tpng
+func savePlanGraph(cmd *cobra.Command, plan *ami.MigrationPlan) error {
+	path, _ := cmd.Flags().GetString("dry-run-graph-file")
+	if path == "" {
+		return nil
+	}
+	var out bytes.Buffer
+	if err := plan.WriteDOT(&out); err != nil {
+		return fmt.Errorf("failed to render migration plan graph: %v", err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write --dry-run-graph-file: %v", err)
+	}
+	return nil
+}
+
+// backgroundMigration describes a migration handed off by --no-wait
+type backgroundMigration struct {
+	PID         int      `json:"pid"`
+	LogFile     string   `json:"log_file"`
+	InstanceIDs []string `json:"instance_ids"`
+}
+
+// migrateInBackground confirms the migration and reruns this command without
+// --no-wait in a separate process, writing its output to --no-wait-log. The
+// migration outlives this process, so unlike an in-process run it isn't
+// stopped by Ctrl-C: follow it with the status command and the log.
+func migrateInBackground(ctx context.Context, cmd *cobra.Command, svc *ami.Service, instanceID string, opts ami.MigrateOptions) error {
+	if err := confirmMigration(ctx, cmd, svc, instanceID, opts); err != nil {
+		return err
+	}
+	plan, err := planMigration(ctx, svc, instanceID, opts)
+	if err != nil {
+		return err
+	}
+	started := backgroundMigration{InstanceIDs: []string{}}
+	for _, instance := range plan.Instances {
+		if instance.Migrate {
+			started.InstanceIDs = append(started.InstanceIDs, instance.InstanceID)
+		}
+	}
+	if len(started.InstanceIDs) == 0 {
+		return fmt.Errorf("no instances found to migrate")
+	}
+
+	started.LogFile, _ = cmd.Flags().GetString("no-wait-log")
+	if started.LogFile == "" {
+		started.LogFile = filepath.Join(os.TempDir(), fmt.Sprintf("ecman-migrate-%s.log", time.Now().Format("20060102-150405")))
+	}
+	logFile, err := os.OpenFile(started.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open --no-wait-log: %v", err)
+	}
+	defer logFile.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the ecman executable: %v", err)
+	}
+	// The confirmation was given here, so the background run must not ask again
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if arg != "--no-wait" && !strings.HasPrefix(arg, "--no-wait=") {
+			args = append(args, arg)
+		}
+	}
+	args = append(args, "--yes")
+	child := exec.Command(executable, args...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start background migration: %v", err)
+	}
+	started.PID = child.Process.Pid
+	if err := child.Process.Release(); err != nil {
+		return fmt.Errorf("failed to detach background migration: %v", err)
+	}
+
+	if ok, err := writeOutput(cmd, started); ok {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Migrating %d instances in the background (pid %d, log %s):\n",
+		len(started.InstanceIDs), started.PID, started.LogFile)
+	for _, id := range started.InstanceIDs {
+		fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", id)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Follow each instance with: ecman status --instance-id <id>")
+	return nil
 }
@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/taemon1337/ec-manager/pkg/ami"
 	"github.com/taemon1337/ec-manager/pkg/client"
 	"github.com/taemon1337/ec-manager/pkg/logger"
@@ -48,6 +53,16 @@ func TestMigrateCmd(t *testing.T) {
 									State: &types.InstanceState{
 										Name: types.InstanceStateNameRunning,
 									},
+									Tags: []types.Tag{
+										{
+											Key:   aws.String("ami-migrate"),
+											Value: aws.String("enabled"),
+										},
+										{
+											Key:   aws.String("ami-migrate-if-running"),
+											Value: aws.String("enabled"),
+										},
+									},
 									BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
 										{
 											DeviceName: aws.String("/dev/xvda"),
@@ -68,6 +83,7 @@ func TestMigrateCmd(t *testing.T) {
 						},
 					},
 				}
+				m.Images = []types.Image{{ImageId: aws.String("ami-456")}}
 			},
 		},
 		{
@@ -112,6 +128,16 @@ func TestMigrateCmd(t *testing.T) {
 									State: &types.InstanceState{
 										Name: types.InstanceStateNameRunning,
 									},
+									Tags: []types.Tag{
+										{
+											Key:   aws.String("ami-migrate"),
+											Value: aws.String("enabled"),
+										},
+										{
+											Key:   aws.String("ami-migrate-if-running"),
+											Value: aws.String("enabled"),
+										},
+									},
 									BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
 										{
 											DeviceName: aws.String("/dev/xvda"),
@@ -125,6 +151,7 @@ func TestMigrateCmd(t *testing.T) {
 						},
 					},
 				}
+				m.Images = []types.Image{{ImageId: aws.String("ami-456")}}
 				m.StopInstancesError = fmt.Errorf("failed to stop instance")
 				m.Instance = &types.Instance{
 					InstanceId:   aws.String("i-123"),
@@ -221,7 +248,7 @@ func NewMigrateCmd() *cobra.Command {
 			}
 
 			// Create AMI service
-			svc := ami.NewService(ec2Client)
+			svc := ami.NewService(ec2Client, serviceOptions()...)
 
 			// Get instances to migrate
 			var instances []string
@@ -244,7 +271,7 @@ func NewMigrateCmd() *cobra.Command {
 
 			// Migrate each instance
 			for _, instance := range instances {
-				if err := svc.MigrateInstance(context.Background(), instance, newAMI); err != nil {
+				if _, err := svc.MigrateInstance(context.Background(), instance, newAMI); err != nil {
 					return fmt.Errorf("failed to migrate instance %s: %v", instance, err)
 				}
 				logger.Info("Successfully migrated instance", "instanceID", instance)
@@ -260,3 +287,43 @@ func NewMigrateCmd() *cobra.Command {
 
 	return cmd
 }
+
+func TestWriteEvents(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	progress := writeEvents(cmd)
+	progress(ami.InstanceProgress{InstanceID: "i-1", Stage: ami.ProgressStarted, Message: "Migrating to ami-new", Time: at})
+	progress(ami.InstanceProgress{InstanceID: "i-1", Stage: ami.ProgressStopped, Time: at.Add(time.Minute)})
+	progress(ami.InstanceProgress{InstanceID: "i-1", Stage: ami.ProgressFailed, Message: "launch failed", Time: at.Add(2 * time.Minute)})
+
+	// One JSON object per line, so CI can act on each as it arrives
+	assert.Equal(t, `{"timestamp":"2024-05-01T12:00:00Z","instance_id":"i-1","phase":"started","status":"in-progress","message":"Migrating to ami-new"}
+{"timestamp":"2024-05-01T12:01:00Z","instance_id":"i-1","phase":"stopped","status":"in-progress"}
+{"timestamp":"2024-05-01T12:02:00Z","instance_id":"i-1","phase":"failed","status":"failed","message":"launch failed"}
+`, out.String())
+}
+
+func TestMigrateOutputEventsStdout(t *testing.T) {
+	stdout, stderr, err := executeRoot(t, enabledInstanceMock(),
+		"migrate", "--enabled", "--new-ami", "ami-new", "--yes", "--output-events")
+	require.NoError(t, err)
+
+	// The logs and the summary go to stderr, leaving only events on stdout
+	assert.Contains(t, stderr, "level=INFO")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		var event migrationEvent
+		assert.NoError(t, json.Unmarshal([]byte(line), &event), line)
+	}
+	var last migrationEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+	assert.Equal(t, "i-1", last.InstanceID)
+	assert.Equal(t, ami.ProgressCompleted, last.Status)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFormat is set by the persistent --output flag
+var outputFormat string
+
+// validateOutputFormat rejects unknown --output values before a command runs
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("invalid --output %q: must be one of table, json, yaml", outputFormat)
+	}
+}
+
+// writeOutput writes v in the --output format when it is json or yaml and
+// reports whether it did, so callers fall back to their own table otherwise.
+// Field names always come from v's json struct tags.
+func writeOutput(cmd *cobra.Command, v interface{}) (bool, error) {
+	if outputFormat != outputJSON && outputFormat != outputYAML {
+		return false, nil
+	}
+
+	// Print empty lists as [] rather than null
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+		v = []interface{}{}
+	}
+
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return true, fmt.Errorf("failed to encode output: %v", err)
+	}
+	if outputFormat == outputYAML {
+		if out, err = jsonToYAML(out); err != nil {
+			return true, fmt.Errorf("failed to encode output: %v", err)
+		}
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), string(bytes.TrimRight(out, "\n")))
+	return true, nil
+}
+
+// jsonToYAML re-encodes JSON as block-style YAML, keeping the key order
+func jsonToYAML(data []byte) ([]byte, error) {
+	// JSON is valid YAML, so decoding it into a node tree keeps key order and
+	// leaves numbers untouched
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearNodeStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// clearNodeStyle drops the JSON flow style and quoting so the encoder picks
+// its defaults, quoting only the strings that need it
+func clearNodeStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearNodeStyle(child)
+	}
+}
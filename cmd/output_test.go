@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+)
+
+func TestWriteOutput(t *testing.T) {
+	// Store original value
+	originalOutputFormat := outputFormat
+
+	// Reset flag after tests
+	t.Cleanup(func() {
+		outputFormat = originalOutputFormat
+	})
+
+	result := &ami.MigrationResult{
+		EnabledValue: "enabled",
+		Instances: []ami.InstanceResult{
+			{
+				InstanceID: "i-123",
+				Status:     ami.StatusCompleted,
+				OldAMI:     "ami-old",
+				NewAMI:     "ami-new",
+				Message:    "true",
+				Duration:   90 * time.Second,
+			},
+		},
+	}
+	result.Summarize()
+
+	tests := []struct {
+		name        string
+		format      string
+		value       interface{}
+		wantPrinted bool
+		want        string
+	}{
+		{
+			name:   "table is left to the caller",
+			format: outputTable,
+			value:  result,
+		},
+		{
+			name:        "json uses the struct tags",
+			format:      outputJSON,
+			value:       result.Summary,
+			wantPrinted: true,
+			want: `{
+  "total": 1,
+  "completed": 1,
+  "skipped": 0,
+  "failed": 0,
+  "cancelled": 0
+}
+`,
+		},
+		{
+			name:        "yaml keeps field order",
+			format:      outputYAML,
+			value:       result,
+			wantPrinted: true,
+			want: `enabled_value: enabled
+instances:
+  - instance_id: i-123
+    status: completed
+    old_ami: ami-old
+    new_ami: ami-new
+    message: "true"
+    duration: 90000000000
+summary:
+  total: 1
+  completed: 1
+  skipped: 0
+  failed: 0
+  cancelled: 0
+duration: 0
+`,
+		},
+		{
+			name:        "empty list",
+			format:      outputJSON,
+			value:       []ami.ManagedInstance(nil),
+			wantPrinted: true,
+			want:        "[]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputFormat = tt.format
+			var out bytes.Buffer
+			cmd := &cobra.Command{}
+			cmd.SetOut(&out)
+
+			printed, err := writeOutput(cmd, tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPrinted, printed)
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	// Store original value
+	originalOutputFormat := outputFormat
+
+	// Reset flag after tests
+	t.Cleanup(func() {
+		outputFormat = originalOutputFormat
+	})
+
+	for _, format := range []string{outputTable, outputJSON, outputYAML} {
+		outputFormat = format
+		assert.NoError(t, validateOutputFormat())
+	}
+
+	outputFormat = "xml"
+	assert.EqualError(t, validateOutputFormat(), `invalid --output "xml": must be one of table, json, yaml`)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// promoteAMICmd represents the promote-ami command
+var promoteAMICmd = &cobra.Command{
+	Use:   "promote-ami",
+	Short: "Tag an AMI as the current one",
+	Long: `promote-ami moves the --tag-key=--tag-value tag (release=current by default) from
+the AMIs that have it to --ami-id. With --previous-value the old AMI is retagged with
+that value instead of losing the tag, e.g. release=previous.
+
+The new AMI is tagged before the old one is untagged, so there is always a current
+AMI. If another AMI is promoted at the same time, nothing is untagged and the command
+fails; run it again to settle on one AMI.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		amiID, _ := cmd.Flags().GetString("ami-id")
+		if amiID == "" {
+			return fmt.Errorf("--ami-id is required")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		amiID, _ := cmd.Flags().GetString("ami-id")
+		tagKey, _ := cmd.Flags().GetString("tag-key")
+		tagValue, _ := cmd.Flags().GetString("tag-value")
+		previousValue, _ := cmd.Flags().GetString("previous-value")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		result, err := svc.PromoteAMIWithOptions(cmd.Context(), amiID, tagKey, tagValue, ami.PromoteOptions{
+			PreviousTagValue: previousValue,
+		})
+		var outErr error
+		if result != nil {
+			var printed bool
+			if printed, outErr = writeOutput(cmd, result); !printed {
+				fmt.Fprintf(cmd.OutOrStdout(), "Promoted AMI %s to %s=%s\n", result.AMIID, tagKey, tagValue)
+				for _, id := range result.Demoted {
+					fmt.Fprintf(cmd.OutOrStdout(), "Demoted AMI %s\n", id)
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to promote AMI: %v", err)
+		}
+		return outErr
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(promoteAMICmd)
+
+	// Add flags
+	promoteAMICmd.Flags().String("ami-id", "", "AMI ID to promote")
+	promoteAMICmd.Flags().String("tag-key", "release", "Tag key marking the current AMI")
+	promoteAMICmd.Flags().String("tag-value", "current", "Tag value marking the current AMI")
+	promoteAMICmd.Flags().String("previous-value", "", "Retag the old AMI with this value instead of removing the tag")
+}
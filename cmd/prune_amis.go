@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// pruneAMIsCmd represents the prune-amis command
+var pruneAMIsCmd = &cobra.Command{
+	Use:   "prune-amis",
+	Short: "Deregister all but the most recent AMIs with a tag",
+	Long: `prune-amis keeps the --keep most recent AMIs tagged --tag-key=--tag-value, by
+creation date, and deregisters the older ones along with their backing snapshots.
+AMIs that an instance which hasn't been terminated was launched from are kept.
+
+Use --dry-run to list the AMIs that would be deregistered without changing anything.
+Otherwise they are listed and confirmation is asked for first; pass --yes to skip
+the prompt.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		tagKey, _ := cmd.Flags().GetString("tag-key")
+		if tagKey == "" {
+			return fmt.Errorf("--tag-key is required")
+		}
+		keep, _ := cmd.Flags().GetInt("keep")
+		if keep < 1 {
+			return fmt.Errorf("--keep must be at least 1")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tagKey, _ := cmd.Flags().GetString("tag-key")
+		tagValue, _ := cmd.Flags().GetString("tag-value")
+		keep, _ := cmd.Flags().GetInt("keep")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		var pruned []ami.PrunedAMI
+		if dryRun {
+			pruned, err = svc.PruneCandidates(cmd.Context(), tagKey, tagValue, keep)
+		} else {
+			if err := confirmPrune(cmd, svc, tagKey, tagValue, keep); err != nil {
+				return err
+			}
+			pruned, err = svc.PruneAMIs(cmd.Context(), tagKey, tagValue, keep)
+		}
+		printed, outErr := writeOutput(cmd, pruned)
+		if !printed {
+			printPrunedAMIs(cmd, pruned)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to prune AMIs: %v", err)
+		}
+		return outErr
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneAMIsCmd)
+
+	// Add flags
+	pruneAMIsCmd.Flags().String("tag-key", "", "Tag key identifying the AMIs to prune")
+	pruneAMIsCmd.Flags().String("tag-value", "", "Tag value identifying the AMIs to prune")
+	pruneAMIsCmd.Flags().Int("keep", 3, "Number of most recent AMIs to keep")
+	pruneAMIsCmd.Flags().Bool("dry-run", false, "List the AMIs that would be deregistered without deregistering them")
+	addYesFlag(pruneAMIsCmd)
+}
+
+// confirmPrune lists the AMIs a prune would deregister and asks the user to
+// confirm, unless --yes was given
+func confirmPrune(cmd *cobra.Command, svc *ami.Service, tagKey, tagValue string, keep int) error {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return nil
+	}
+	candidates, err := svc.PruneCandidates(cmd.Context(), tagKey, tagValue, keep)
+	if err != nil {
+		return fmt.Errorf("failed to list AMIs to prune: %v", err)
+	}
+
+	var lines []string
+	for _, candidate := range candidates {
+		// AMIs that are still in use have a reason and are kept
+		if candidate.Reason == "" {
+			lines = append(lines, fmt.Sprintf("  %s (%s, created %s)", candidate.AMIID, candidate.Name, candidate.CreationDate))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return confirmAction(cmd, fmt.Sprintf("%d AMI(s) will be deregistered and their snapshots deleted:\n%s",
+		len(lines), strings.Join(lines, "\n")))
+}
+
+// printPrunedAMIs writes a table of the AMIs handled by a prune run
+func printPrunedAMIs(cmd *cobra.Command, pruned []ami.PrunedAMI) {
+	if len(pruned) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No AMIs to prune")
+		return
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AMI\tNAME\tCREATED\tDEREGISTERED\tSNAPSHOTS\tREASON")
+	for _, image := range pruned {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%s\n",
+			image.AMIID,
+			image.Name,
+			image.CreationDate,
+			image.Deregistered,
+			strings.Join(image.DeletedSnapshots, ","),
+			image.Reason)
+	}
+	w.Flush()
+}
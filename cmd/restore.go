@@ -3,57 +3,77 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/spf13/cobra"
 	"github.com/taemon1337/ec-manager/pkg/ami"
-)
-
-var (
-	snapshotID string
+	"github.com/taemon1337/ec-manager/pkg/client"
 )
 
 var restoreCmd = &cobra.Command{
 	Use:   "restore",
-	Short: "Restore volumes from snapshots",
-	Long: `Restore volumes from snapshots to an instance.
-Requires the snapshot ID and instance ID to restore to.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		if snapshotID == "" {
-			return fmt.Errorf("--snapshot-id is required")
-		}
-		instanceID, err := cmd.Flags().GetString("instance-id")
-		if err != nil {
-			return fmt.Errorf("failed to get instance-id flag: %w", err)
-		}
-		if instanceID == "" {
-			return fmt.Errorf("--instance-id is required")
+	Short: "Launch a new instance from backup snapshots",
+	Long: `restore launches a new instance with volumes created from backup snapshots, each
+attached at the device it was taken from. Pass the snapshots with --snapshot-ids, or
+pass the original instance with --instance-id to restore its newest backup.
+
+The AMI, instance type, networking and tags default to those of the original instance
+when it still exists. A snapshot of the root device becomes the root volume.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		snapshotIDs, _ := cmd.Flags().GetStringSlice("snapshot-ids")
+		instanceID, _ := cmd.Flags().GetString("instance-id")
+		if len(snapshotIDs) == 0 && instanceID == "" {
+			return fmt.Errorf("--snapshot-ids or --instance-id is required")
 		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		snapshotIDs, _ := cmd.Flags().GetStringSlice("snapshot-ids")
+		instanceID, _ := cmd.Flags().GetString("instance-id")
+		amiID, _ := cmd.Flags().GetString("ami")
+		instanceType, _ := cmd.Flags().GetString("instance-type")
+		subnetID, _ := cmd.Flags().GetString("subnet-id")
+		securityGroupIDs, _ := cmd.Flags().GetStringSlice("security-group-ids")
+		keyName, _ := cmd.Flags().GetString("key-name")
 
-		// Load AWS configuration
-		cfg, err := config.LoadDefaultConfig(cmd.Context())
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
 		if err != nil {
-			return fmt.Errorf("unable to load SDK config: %w", err)
+			return fmt.Errorf("failed to get EC2 client: %w", err)
 		}
 
-		// Create EC2 client
-		ec2Client := ec2.NewFromConfig(cfg)
-
 		// Create AMI service
-		amiService := ami.NewService(ec2Client)
+		svc := ami.NewService(ec2Client, serviceOptions()...)
 
-		fmt.Printf("Starting restore of snapshot %s to instance %s\n", snapshotID, instanceID)
-		if err := amiService.RestoreInstance(cmd.Context(), instanceID, snapshotID); err != nil {
+		if len(snapshotIDs) == 0 {
+			if snapshotIDs, err = svc.BackupSnapshots(cmd.Context(), instanceID); err != nil {
+				return fmt.Errorf("failed to find backup: %v", err)
+			}
+		}
+
+		newInstanceID, err := svc.RestoreInstance(cmd.Context(), snapshotIDs, ami.RestoreSpec{
+			SourceInstanceID: instanceID,
+			ImageID:          amiID,
+			InstanceType:     types.InstanceType(instanceType),
+			SubnetID:         subnetID,
+			SecurityGroupIDs: securityGroupIDs,
+			KeyName:          keyName,
+		})
+		if err != nil {
 			return fmt.Errorf("failed to restore instance: %w", err)
 		}
 
-		fmt.Println("Restore completed successfully")
+		fmt.Fprintf(cmd.OutOrStdout(), "Restored instance %s\n", newInstanceID)
 		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(restoreCmd)
-	restoreCmd.Flags().StringVar(&snapshotID, "snapshot-id", "", "ID of snapshot to restore from")
-	restoreCmd.Flags().String("instance-id", "", "ID of instance to restore to")
+	restoreCmd.Flags().StringSlice("snapshot-ids", nil, "Snapshots to restore")
+	restoreCmd.Flags().String("instance-id", "", "Original instance whose newest backup is restored")
+	restoreCmd.Flags().String("ami", "", "AMI to launch from (defaults to the original instance's AMI)")
+	restoreCmd.Flags().String("instance-type", "", "EC2 instance type (defaults to the original instance's type)")
+	restoreCmd.Flags().String("subnet-id", "", "Subnet to launch the instance in")
+	restoreCmd.Flags().StringSlice("security-group-ids", nil, "Security group IDs to attach")
+	restoreCmd.Flags().String("key-name", "", "EC2 key pair name")
 }
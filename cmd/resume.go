@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume interrupted or failed migrations",
+	Long: `resume finishes the migrations that were interrupted or failed, going by the
+ami-migrate-status tag (migrating, in-progress or failed). Completed migrations are
+left alone.
+
+Instances that are still around are migrated to --new-ami again. A replacement left
+behind by the earlier attempt is terminated first, as the original instance is still
+the one in use. Instances that were already terminated are finished off instead: their
+replacement gets their tags and they are marked completed. Terminated instances without
+a replacement are reported as failed; recreate them with rollback.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		newAMI, _ := cmd.Flags().GetString("new-ami")
+		if newAMI == "" {
+			return fmt.Errorf("--new-ami flag must be specified")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger.Info("Starting resume process")
+
+		newAMI, _ := cmd.Flags().GetString("new-ami")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		if err := confirmAction(cmd, fmt.Sprintf("Interrupted and failed migrations will be migrated to %s again.", newAMI)); err != nil {
+			return err
+		}
+		result, err := svc.ResumeMigration(cmd.Context(), newAMI)
+		var outErr error
+		if result != nil && result.Summary.Total > 0 {
+			var printed bool
+			if printed, outErr = writeOutput(cmd, result); !printed {
+				fmt.Fprint(cmd.OutOrStdout(), result.FormatMigrationResult())
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resume migrations: %v", err)
+		}
+		if result.Summary.Total == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No interrupted migrations found")
+		}
+		return outErr
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+
+	// Add flags
+	resumeCmd.Flags().String("new-ami", "", "ID of the AMI to migrate the instances to")
+	addYesFlag(resumeCmd)
+}
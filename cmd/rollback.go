@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back a migrated instance to its original AMI",
+	Long: `rollback recreates an instance that was migrated to a new AMI. The instance is
+relaunched from its original AMI with its root and data volumes restored from the
+snapshots taken during the migration. If the replacement instance is still running it is
+terminated first and its tags are carried over.
+
+The --instance-id flag is the ID of the original (pre-migration) instance.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		instanceID, _ := cmd.Flags().GetString("instance-id")
+		if instanceID == "" {
+			return fmt.Errorf("--instance-id flag must be specified")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger.Info("Starting rollback process")
+
+		instanceID, _ := cmd.Flags().GetString("instance-id")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		newInstanceID, err := svc.RollbackInstance(cmd.Context(), instanceID)
+		if err != nil {
+			return fmt.Errorf("failed to roll back instance %s: %v", instanceID, err)
+		}
+
+		fmt.Printf("Rolled back instance %s as %s\n", instanceID, newInstanceID)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+
+	// Add flags
+	rollbackCmd.Flags().String("instance-id", "", "ID of the original instance to roll back")
+}
@@ -3,9 +3,16 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
 	"github.com/taemon1337/ec-manager/pkg/config"
 	"github.com/taemon1337/ec-manager/pkg/logger"
 )
@@ -18,7 +25,15 @@ var (
 	userID     string
 	logLevel   string
 	timeout    time.Duration
+	region     string
+	// Cross-account access
+	assumeRoleARN  string
+	externalID     string
 	defaultTimeout = 5 * time.Minute
+	// Tagging conventions
+	tagPrefix string
+	// Config file with defaults for the flags above
+	configFile string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -31,7 +46,27 @@ It provides commands for:
 - Listing and checking instance status
 - Migrating instances to new AMIs
 - Managing instance backups
-- Cleaning up unused instances`,
+- Cleaning up unused instances
+
+Global flags not given on the command line are read from ECMAN_* environment
+variables (e.g. ECMAN_REGION, ECMAN_LOG_LEVEL) and then from the config file,
+~/.ecman/config.yaml unless --config or ECMAN_CONFIG names another. Its keys
+are the flag names: region, timeout, log-level, tag-prefix, assume-role-arn,
+external-id, output and user.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		// Only commands that can fan out across regions take a list
+		if len(regionList()) > 1 && cmd.Flags().Lookup("all-regions") == nil {
+			return fmt.Errorf("--region takes a single region for %s", cmd.Name())
+		}
+		// Initialize logger, operation timeout and AWS client settings
+		initLogger(cmd)
+		initTimeout()
+		initClient()
+		return validateOutputFormat()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
@@ -71,7 +106,10 @@ If no command is specified, shows help for all commands.`,
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
-	return rootCmd.Execute()
+	// Cancel the command's context on Ctrl-C so migrations stop starting new work
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
@@ -85,14 +123,106 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&userID, "user", "", "Your AWS username (defaults to current AWS user)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", defaultTimeout, "Timeout for AWS operations")
+	rootCmd.PersistentFlags().StringVar(&region, "region", "", "AWS region to use (overrides AWS_REGION); migrate also takes a comma-separated list")
+	rootCmd.PersistentFlags().StringVar(&assumeRoleARN, "assume-role-arn", "", "IAM role to assume for all AWS calls (e.g. to work in another account)")
+	rootCmd.PersistentFlags().StringVar(&externalID, "external-id", "", "External ID to pass when assuming --assume-role-arn")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format (table, json, yaml)")
+	rootCmd.PersistentFlags().StringVar(&tagPrefix, "tag-prefix", "ami-migrate", "Prefix of the instance tags that enable migration and record its status")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file with defaults for the global flags (defaults to ~/.ecman/config.yaml)")
+}
+
+// applyConfig fills in the global flags not given on the command line, first
+// from their ECMAN_* environment variables and then from the config file. The
+// default config file may be missing, but one named by --config or
+// ECMAN_CONFIG must exist.
+func applyConfig(cmd *cobra.Command) error {
+	path := configFile
+	if path == "" {
+		path = os.Getenv(config.EnvVar("config"))
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("failed to read config file: %v", err)
+		}
+	} else if defaultPath, err := config.DefaultFilePath(); err == nil {
+		path = defaultPath
+	}
+
+	var settings map[string]string
+	if path != "" {
+		var err error
+		if settings, err = config.LoadFile(path); err != nil {
+			return fmt.Errorf("failed to load config: %v", err)
+		}
+	}
+
+	for _, key := range config.FileKeys {
+		if cmd.Flags().Lookup(key) == nil || cmd.Flags().Changed(key) {
+			continue
+		}
+		source := config.EnvVar(key)
+		value, ok := os.LookupEnv(source)
+		if !ok {
+			source = path
+			value, ok = settings[key]
+		}
+		if !ok {
+			continue
+		}
+		if err := cmd.Flags().Set(key, value); err != nil {
+			return fmt.Errorf("invalid %s from %s: %v", key, source, err)
+		}
+	}
+	return nil
+}
 
-	// Initialize logger
-	cobra.OnInitialize(initLogger)
+// initLogger initializes the logger with the specified log level. Logs go
+// to stdout, or to stderr when stdout carries json or yaml output, or the
+// events of migrate --output-events, so it can still be parsed.
+func initLogger(cmd *cobra.Command) {
+	w := cmd.OutOrStdout()
+	outputEvents, _ := cmd.Flags().GetBool("output-events")
+	if outputFormat != outputTable || outputEvents {
+		w = cmd.ErrOrStderr()
+	}
+	logger.InitWithWriter(logger.LogLevel(logLevel), w)
+}
+
+// initTimeout applies the --timeout flag to the AWS waiters used by the services
+func initTimeout() {
+	config.SetTimeout(timeout)
 }
 
-// initLogger initializes the logger with the specified log level
-func initLogger() {
-	logger.Init(logger.LogLevel(logLevel))
+// initClient applies the --region and --assume-role-arn flags to the AWS clients.
+// With a list of regions the clients default to the first.
+func initClient() {
+	var home string
+	if regions := regionList(); len(regions) > 0 {
+		home = regions[0]
+	}
+	client.SetRegion(home)
+	client.SetAssumeRole(assumeRoleARN, externalID)
+}
+
+// regionList splits --region into its comma-separated regions, dropping
+// blanks and repeats
+func regionList() []string {
+	var regions []string
+	for _, r := range strings.Split(region, ",") {
+		if r = strings.TrimSpace(r); r != "" && !slices.Contains(regions, r) {
+			regions = append(regions, r)
+		}
+	}
+	return regions
+}
+
+// serviceOptions returns the options every command builds its AMI service with
+func serviceOptions() []ami.ServiceOption {
+	opts := []ami.ServiceOption{ami.WithLogger(logger.Get())}
+	if tagPrefix != "" {
+		opts = append(opts, ami.WithTagScheme(ami.TagSchemeWithPrefix(tagPrefix)))
+	}
+	return opts
 }
 
 // getUserID returns the user ID, either from flag or AWS credentials
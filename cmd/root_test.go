@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestApplyConfig(t *testing.T) {
+	// Store original value
+	originalConfigFile := configFile
+
+	// Reset flag after tests
+	t.Cleanup(func() {
+		configFile = originalConfigFile
+	})
+
+	newCmd := func() *cobra.Command {
+		cmd := &cobra.Command{Use: "test"}
+		cmd.Flags().String("region", "", "AWS region")
+		cmd.Flags().Duration("timeout", 5*time.Minute, "Timeout")
+		cmd.Flags().String("log-level", "info", "Log level")
+		return cmd
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("region: eu-west-1\ntimeout: 10m\nlog-level: warn\n"), 0600))
+	configFile = path
+
+	t.Run("flags beat env beat file", func(t *testing.T) {
+		t.Setenv("ECMAN_LOG_LEVEL", "debug")
+		t.Setenv("ECMAN_TIMEOUT", "20m")
+		cmd := newCmd()
+		require.NoError(t, cmd.ParseFlags([]string{"--timeout", "30m"}))
+
+		require.NoError(t, applyConfig(cmd))
+		region, _ := cmd.Flags().GetString("region")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		level, _ := cmd.Flags().GetString("log-level")
+		assert.Equal(t, "eu-west-1", region)
+		assert.Equal(t, 30*time.Minute, timeout)
+		assert.Equal(t, "debug", level)
+	})
+
+	t.Run("invalid value names its source", func(t *testing.T) {
+		t.Setenv("ECMAN_TIMEOUT", "soon")
+		err := applyConfig(newCmd())
+		assert.EqualError(t, err, `invalid timeout from ECMAN_TIMEOUT: invalid argument "soon" for "--timeout" flag: time: invalid duration "soon"`)
+	})
+
+	t.Run("named config file must exist", func(t *testing.T) {
+		configFile = filepath.Join(t.TempDir(), "missing.yaml")
+		t.Cleanup(func() { configFile = path })
+		err := applyConfig(newCmd())
+		assert.ErrorContains(t, err, "failed to read config file")
+	})
+
+	t.Run("default config file may be missing", func(t *testing.T) {
+		configFile = ""
+		t.Cleanup(func() { configFile = path })
+		t.Setenv("HOME", t.TempDir())
+		cmd := newCmd()
+		require.NoError(t, applyConfig(cmd))
+		region, _ := cmd.Flags().GetString("region")
+		assert.Empty(t, region)
+	})
+}
+
+func TestRegionList(t *testing.T) {
+	// Store original value
+	originalRegion := region
+
+	// Reset flag after tests
+	t.Cleanup(func() {
+		region = originalRegion
+	})
+
+	tests := []struct {
+		name   string
+		region string
+		want   []string
+	}{
+		{name: "unset", region: "", want: nil},
+		{name: "single region", region: "us-east-1", want: []string{"us-east-1"}},
+		{name: "list", region: "us-east-1, us-west-2,,us-east-1", want: []string{"us-east-1", "us-west-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region = tt.region
+			assert.Equal(t, tt.want, regionList())
+		})
+	}
+}
+
+// executeRoot runs ecman with args against mockClient, using the real
+// logger, and returns what it wrote to stdout and stderr. The flags it sets
+// are reset afterwards.
+func executeRoot(t *testing.T, mockClient *apitypes.MockEC2Client, args ...string) (string, string, error) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	require.NoError(t, client.SetEC2Client(mockClient))
+
+	originalOutputFormat := outputFormat
+	logger.Reset()
+	t.Cleanup(func() {
+		outputFormat = originalOutputFormat
+		logger.Reset()
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		for _, c := range append(rootCmd.Commands(), rootCmd) {
+			for _, flags := range []*pflag.FlagSet{c.Flags(), c.PersistentFlags()} {
+				flags.VisitAll(func(f *pflag.Flag) {
+					if slice, ok := f.Value.(pflag.SliceValue); ok {
+						slice.Replace(nil)
+					} else {
+						f.Value.Set(f.DefValue)
+					}
+					f.Changed = false
+				})
+			}
+		}
+	})
+
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+// enabledInstanceMock returns a mock with one stopped instance enabled for migration
+func enabledInstanceMock() *apitypes.MockEC2Client {
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{{ImageId: aws.String("ami-new"), State: types.ImageStateAvailable}}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{
+			InstanceId: aws.String("i-1"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+		}}}},
+	}
+	return mockClient
+}
+
+func TestMachineReadableOutputKeepsLogsOffStdout(t *testing.T) {
+	for _, format := range []string{outputJSON, outputYAML} {
+		t.Run(format, func(t *testing.T) {
+			stdout, stderr, err := executeRoot(t, enabledInstanceMock(),
+				"migrate", "--enabled", "--new-ami", "ami-new", "--dry-run", "--output", format)
+			require.NoError(t, err)
+			assert.Contains(t, stderr, "level=INFO")
+			assert.NotContains(t, stdout, "level=")
+			if format == outputJSON {
+				assert.True(t, json.Valid([]byte(stdout)), stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the migration status of an instance",
+	Long: `status prints the migration status, message and timestamp recorded on an
+instance's ami-migrate-* tags, along with its current AMI and state. Instances
+without the ami-migrate tag are reported as not enrolled.
+
+With --since, status fails unless the instance was migrated, or a migration
+was attempted, after that time, e.g. to check a rollout from a script.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		instanceID, _ := cmd.Flags().GetString("instance-id")
+		if instanceID == "" {
+			return fmt.Errorf("--instance-id is required")
+		}
+		_, err := sinceFromFlags(cmd)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instanceID, _ := cmd.Flags().GetString("instance-id")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		status, err := svc.GetInstanceStatus(cmd.Context(), instanceID)
+		if err != nil {
+			return fmt.Errorf("failed to get instance status: %v", err)
+		}
+		since, err := sinceFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if !since.IsZero() && !status.MigratedSince(since) {
+			logger.Debug("Instance has no migration timestamp after --since", "instanceID", instanceID, "timestamp", status.Timestamp)
+			return fmt.Errorf("instance %s has no migration recorded since %s", instanceID, since.Format(time.RFC3339))
+		}
+		if ok, err := writeOutput(cmd, status); ok {
+			return err
+		}
+		printInstanceStatus(cmd, status)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	// Add flags
+	statusCmd.Flags().String("instance-id", "", "Instance ID to show the migration status of")
+	statusCmd.Flags().String("since", "", "Fail unless the instance was migrated after this time (RFC3339, or a duration ago such as 24h)")
+}
+
+// printInstanceStatus writes the migration status of an instance
+func printInstanceStatus(cmd *cobra.Command, status *ami.InstanceStatus) {
+	migrationStatus := status.Status
+	switch {
+	case !status.Enrolled:
+		migrationStatus = "not enrolled"
+	case migrationStatus == "":
+		migrationStatus = "never migrated"
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Instance:\t%s\n", status.InstanceID)
+	if status.Name != "" {
+		fmt.Fprintf(w, "Name:\t%s\n", status.Name)
+	}
+	fmt.Fprintf(w, "State:\t%s\n", status.State)
+	fmt.Fprintf(w, "Current AMI:\t%s\n", status.CurrentAMI)
+	fmt.Fprintf(w, "Status:\t%s\n", migrationStatus)
+	if status.Message != "" {
+		fmt.Fprintf(w, "Message:\t%s\n", status.Message)
+	}
+	if !status.Timestamp.IsZero() {
+		fmt.Fprintf(w, "Updated:\t%s\n", status.Timestamp.Format(time.RFC3339))
+	}
+	w.Flush()
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/ec-manager/pkg/ami"
+	"github.com/taemon1337/ec-manager/pkg/client"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify enrolled instances run the expected AMI",
+	Long: `verify lists every instance tagged ami-migrate=enabled and compares the AMI it
+runs with --new-ami. Terminated instances are ignored.
+
+The command exits non-zero when any instance is not running --new-ami, so it
+can gate a CI pipeline after a migration.
+
+Add --strip-name-suffix to take the suffix added by migrate --name-suffix off
+the Name tag of the instances found running --new-ami.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if value, _ := cmd.Flags().GetString("new-ami"); value == "" {
+			return fmt.Errorf("--new-ami is required")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		newAMI, _ := cmd.Flags().GetString("new-ami")
+		stripNameSuffix, _ := cmd.Flags().GetBool("strip-name-suffix")
+
+		// Create AWS clients
+		ec2Client, err := client.GetEC2Client(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get EC2 client: %w", err)
+		}
+
+		// Create AMI service
+		svc := ami.NewService(ec2Client, serviceOptions()...)
+
+		report, err := svc.VerifyMigrationWithOptions(cmd.Context(), newAMI, "enabled", ami.VerifyOptions{
+			StripNameSuffix: stripNameSuffix,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to verify migration: %v", err)
+		}
+
+		printed, err := writeOutput(cmd, report)
+		if err != nil {
+			return err
+		}
+		if !printed {
+			fmt.Fprint(cmd.OutOrStdout(), report.FormatVerificationReport())
+		}
+
+		if report.Lagging > 0 {
+			return fmt.Errorf("%d of %d instances are not running %s", report.Lagging, len(report.Instances), newAMI)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	// Add flags
+	verifyCmd.Flags().String("new-ami", "", "AMI ID the instances are expected to run")
+	verifyCmd.Flags().Bool("strip-name-suffix", false, "Take the migrate --name-suffix off the Name tag of the instances running --new-ami")
+}
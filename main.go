@@ -18,7 +18,7 @@ const usage = `Usage: ec-manager <action> [options]
 Actions:
   migrate     Migrate EC2 instances to a new AMI
   backup      Create a backup AMI from an EC2 instance
-  restore     Restore volumes from snapshots
+  restore     Launch a new instance from a snapshot
   list        List your EC2 instances
   check       Check instance status
   delete      Delete an EC2 instance
@@ -76,12 +76,12 @@ func main() {
 			}
 
 			ec2Client := ec2.NewFromConfig(cfg)
-			amiService := ami.NewService(ec2Client)
+			amiService := ami.NewService(ec2Client, ami.WithTimeout(timeoutValue))
 
 			ctx, cancel := context.WithTimeout(context.Background(), timeoutValue)
 			defer cancel()
 
-			if err := amiService.MigrateInstances(ctx, enabledValue); err != nil {
+			if _, err := amiService.MigrateInstances(ctx, enabledValue, ami.MigrateOptions{NewAMI: newAMI}); err != nil {
 				log.Fatalf("Failed to migrate instances: %v", err)
 			}
 
@@ -108,12 +108,12 @@ func main() {
 			}
 
 			ec2Client := ec2.NewFromConfig(cfg)
-			amiService := ami.NewService(ec2Client)
+			amiService := ami.NewService(ec2Client, ami.WithTimeout(timeoutValue))
 
 			ctx, cancel := context.WithTimeout(context.Background(), timeoutValue)
 			defer cancel()
 
-			if err := amiService.BackupInstance(ctx, instanceID); err != nil {
+			if _, err := amiService.BackupInstance(ctx, instanceID, ami.BackupOptions{}); err != nil {
 				log.Fatalf("Failed to backup instance: %v", err)
 			}
 
@@ -123,7 +123,7 @@ func main() {
 
 	restoreCmd := &cobra.Command{
 		Use:   "restore",
-		Short: "Restore volumes from snapshots",
+		Short: "Launch a new instance from a snapshot",
 		Run: func(cmd *cobra.Command, args []string) {
 			instanceID, _ := cmd.Flags().GetString("instance-id")
 			snapshotID, _ := cmd.Flags().GetString("snapshot-id")
@@ -133,7 +133,7 @@ func main() {
 				log.Fatal("Error: both -instance-id and -snapshot-id are required for restore action")
 			}
 
-			fmt.Printf("Starting restore of snapshot %s to instance %s\n", snapshotID, instanceID)
+			fmt.Printf("Starting restore of snapshot %s from instance %s\n", snapshotID, instanceID)
 
 			cfg, err := client.LoadAWSConfig(context.Background())
 			if err != nil {
@@ -141,16 +141,17 @@ func main() {
 			}
 
 			ec2Client := ec2.NewFromConfig(cfg)
-			amiService := ami.NewService(ec2Client)
+			amiService := ami.NewService(ec2Client, ami.WithTimeout(timeoutValue))
 
 			ctx, cancel := context.WithTimeout(context.Background(), timeoutValue)
 			defer cancel()
 
-			if err := amiService.RestoreInstance(ctx, instanceID, snapshotID); err != nil {
+			newInstanceID, err := amiService.RestoreInstance(ctx, []string{snapshotID}, ami.RestoreSpec{SourceInstanceID: instanceID})
+			if err != nil {
 				log.Fatalf("Failed to restore instance: %v", err)
 			}
 
-			fmt.Println("Restore completed successfully")
+			fmt.Printf("Restore completed successfully: %s\n", newInstanceID)
 		},
 	}
 
@@ -168,7 +169,7 @@ func main() {
 			}
 
 			ec2Client := ec2.NewFromConfig(cfg)
-			amiService := ami.NewService(ec2Client)
+			amiService := ami.NewService(ec2Client, ami.WithTimeout(timeoutValue))
 
 			ctx, cancel := context.WithTimeout(context.Background(), timeoutValue)
 			defer cancel()
@@ -220,7 +221,7 @@ func main() {
 			}
 
 			ec2Client := ec2.NewFromConfig(cfg)
-			amiService := ami.NewService(ec2Client)
+			amiService := ami.NewService(ec2Client, ami.WithTimeout(timeoutValue))
 
 			ctx, cancel := context.WithTimeout(context.Background(), timeoutValue)
 			defer cancel()
@@ -2,7 +2,12 @@ package ami
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -10,7 +15,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/taemon1337/ec-manager/pkg/client"
 	"github.com/taemon1337/ec-manager/pkg/config"
 	"github.com/taemon1337/ec-manager/pkg/logger"
 	apitypes "github.com/taemon1337/ec-manager/pkg/types"
@@ -21,29 +25,166 @@ type EC2ClientAPI interface {
 	DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
 	CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
 	DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
+	CopyImage(ctx context.Context, params *ec2.CopyImageInput, optFns ...func(*ec2.Options)) (*ec2.CopyImageOutput, error)
 	CreateSnapshot(ctx context.Context, params *ec2.CreateSnapshotInput, optFns ...func(*ec2.Options)) (*ec2.CreateSnapshotOutput, error)
 	StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
 	StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error)
 	RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error)
 	TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
 	DescribeSnapshots(ctx context.Context, params *ec2.DescribeSnapshotsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSnapshotsOutput, error)
+	DeleteSnapshot(ctx context.Context, params *ec2.DeleteSnapshotInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSnapshotOutput, error)
+	DeregisterImage(ctx context.Context, params *ec2.DeregisterImageInput, optFns ...func(*ec2.Options)) (*ec2.DeregisterImageOutput, error)
+	EnableImageDeprecation(ctx context.Context, params *ec2.EnableImageDeprecationInput, optFns ...func(*ec2.Options)) (*ec2.EnableImageDeprecationOutput, error)
 	CreateVolume(ctx context.Context, params *ec2.CreateVolumeInput, optFns ...func(*ec2.Options)) (*ec2.CreateVolumeOutput, error)
 	DescribeVolumes(ctx context.Context, params *ec2.DescribeVolumesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error)
 	AttachVolume(ctx context.Context, params *ec2.AttachVolumeInput, optFns ...func(*ec2.Options)) (*ec2.AttachVolumeOutput, error)
+	DescribeInstanceStatus(ctx context.Context, params *ec2.DescribeInstanceStatusInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceStatusOutput, error)
+	DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error)
+	CopySnapshot(ctx context.Context, params *ec2.CopySnapshotInput, optFns ...func(*ec2.Options)) (*ec2.CopySnapshotOutput, error)
+	RegisterImage(ctx context.Context, params *ec2.RegisterImageInput, optFns ...func(*ec2.Options)) (*ec2.RegisterImageOutput, error)
+	DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error)
+	AssociateAddress(ctx context.Context, params *ec2.AssociateAddressInput, optFns ...func(*ec2.Options)) (*ec2.AssociateAddressOutput, error)
+	DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput, optFns ...func(*ec2.Options)) (*ec2.DisassociateAddressOutput, error)
 }
 
 // Service provides AMI management operations
 type Service struct {
-	client apitypes.EC2ClientAPI
+	client  apitypes.EC2ClientAPI
+	timeout time.Duration
+	logger  *slog.Logger
+	region  string
+	tags    TagScheme
+	ssm     apitypes.SSMClientAPI
+	metrics apitypes.CloudWatchClientAPI
+	sns     apitypes.SNSClientAPI
+	waiter  InstanceStateWaiter
+	// Status tags are re-read this many times, interval apart, until
+	// visible. Zero attempts skips the check.
+	tagVerifyAttempts int
+	tagVerifyInterval time.Duration
+	// now returns the current time, which maintenance windows are checked against
+	now func() time.Time
+}
+
+// ServiceOption configures optional Service behavior
+type ServiceOption func(*Service)
+
+// WithTimeout sets how long the service waits for instances, volumes and
+// snapshots to reach a state. Without it the global config timeout is used.
+// The waits of a single instance migration share the timeout between them.
+func WithTimeout(timeout time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.timeout = timeout
+	}
+}
+
+// WithRegion sets the region the service's client is in. It is only needed
+// for clients other than *ec2.Client, which report their own region.
+func WithRegion(region string) ServiceOption {
+	return func(s *Service) {
+		s.region = region
+	}
 }
 
 // NewService creates a new AMI service
-func NewService(client apitypes.EC2ClientAPI) *Service {
-	return &Service{
+func NewService(client apitypes.EC2ClientAPI, opts ...ServiceOption) *Service {
+	s := &Service{
 		client: client,
+		tags:   DefaultTagScheme(),
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// SDK clients know their region, which snapshot copies need
+	if c, ok := client.(interface{ Options() ec2.Options }); ok && s.region == "" {
+		s.region = c.Options().Region
+	}
+	if s.logger != nil {
+		s.client = &loggingClient{EC2ClientAPI: s.client, logger: s.logger}
+	}
+	if s.waiter == nil {
+		s.waiter = &sdkInstanceStateWaiter{client: s.client}
+	}
+	return s
+}
+
+// waitTimeout returns the maximum time to wait for a resource to reach a state
+func (s *Service) waitTimeout() time.Duration {
+	if s.timeout > 0 {
+		return s.timeout
+	}
+	return config.GetTimeout()
+}
+
+// ErrWaitBudgetExhausted is returned when a wait can't start because ctx's
+// deadline, the time left for the whole operation, has already passed
+var ErrWaitBudgetExhausted = errors.New("timeout budget exhausted")
+
+// waitTimeoutKey carries the timeout withWaitBudget gave an instance, so
+// the waits made for it are bounded by the same timeout
+type waitTimeoutKey struct{}
+
+// instanceWaitTimeout returns the timeout of the instance's waits: its
+// timeout tag, e.g. ami-migrate-timeout=45m, or the service timeout when the
+// tag is not set or can't be parsed
+func (s *Service) instanceWaitTimeout(instance types.Instance) time.Duration {
+	value := tagValue(instance.Tags, s.tags.Timeout)
+	if value == "" {
+		return s.waitTimeout()
+	}
+	timeout, err := time.ParseDuration(value)
+	if err == nil && timeout <= 0 {
+		err = fmt.Errorf("timeout must be positive")
+	}
+	if err != nil {
+		logger.Warn("Ignoring invalid timeout tag, using the service timeout",
+			"instanceID", aws.ToString(instance.InstanceId),
+			"tag", s.tags.Timeout,
+			"value", value,
+			"error", err)
+		return s.waitTimeout()
+	}
+	return timeout
+}
+
+// contextWaitTimeout returns the timeout withWaitBudget set on ctx, or the
+// service timeout outside of one
+func (s *Service) contextWaitTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(waitTimeoutKey{}).(time.Duration); ok {
+		return timeout
+	}
+	return s.waitTimeout()
+}
+
+// withWaitBudget bounds ctx by the instance's timeout, see
+// instanceWaitTimeout, so the waits of an operation made with it share one
+// deadline instead of each getting the full timeout
+func (s *Service) withWaitBudget(ctx context.Context, instance types.Instance) (context.Context, context.CancelFunc) {
+	timeout := s.instanceWaitTimeout(instance)
+	return context.WithTimeout(context.WithValue(ctx, waitTimeoutKey{}, timeout), timeout)
+}
+
+// remainingWait returns the maximum time a wait may take: the timeout of
+// the instance being migrated, or the service timeout, cut short by ctx's
+// deadline
+func (s *Service) remainingWait(ctx context.Context) (time.Duration, error) {
+	maxWaitTime := s.contextWaitTimeout(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < maxWaitTime {
+			maxWaitTime = remaining
+		}
+	}
+	if maxWaitTime <= 0 {
+		return 0, ErrWaitBudgetExhausted
 	}
+	return maxWaitTime, nil
 }
 
+// ErrAMINotFound is returned when no AMI matches a lookup. Use errors.Is to
+// tell it apart from API errors.
+var ErrAMINotFound = errors.New("no AMI found")
+
 // GetAMIWithTag gets an AMI by its tag
 func (s *Service) GetAMIWithTag(ctx context.Context, tagKey, tagValue string) (string, error) {
 	logger.Debug("Looking for AMI", "tagKey", tagKey, "tagValue", tagValue)
@@ -57,19 +198,46 @@ func (s *Service) GetAMIWithTag(ctx context.Context, tagKey, tagValue string) (s
 		},
 	}
 
-	result, err := s.client.DescribeImages(ctx, input)
+	images, err := s.describeImages(ctx, input)
 	if err != nil {
 		logger.Error("Failed to describe images", "error", err)
 		return "", fmt.Errorf("describe images: %w", err)
 	}
 
-	if len(result.Images) == 0 {
+	if len(images) == 0 {
 		logger.Warn("No AMI found with tag", "tagKey", tagKey, "tagValue", tagValue)
-		return "", fmt.Errorf("no AMI found with tag %s=%s", tagKey, tagValue)
+		return "", fmt.Errorf("%w with tag %s=%s", ErrAMINotFound, tagKey, tagValue)
 	}
 
-	logger.Info("Found AMI", "amiID", *result.Images[0].ImageId)
-	return aws.ToString(result.Images[0].ImageId), nil
+	// Several AMIs can share a tag, so pick the newest
+	image := newestImage(images)
+	logger.Info("Found AMI", "amiID", aws.ToString(image.ImageId), "matched", len(images))
+	return aws.ToString(image.ImageId), nil
+}
+
+// newestImage returns the image with the latest CreationDate. Images created
+// at the same time are ordered by image ID, so the choice doesn't depend on the
+// order the API returns them in.
+func newestImage(images []types.Image) types.Image {
+	newest := images[0]
+	for _, image := range images[1:] {
+		created, newestCreated := imageCreationTime(image), imageCreationTime(newest)
+		if created.After(newestCreated) ||
+			(created.Equal(newestCreated) && aws.ToString(image.ImageId) < aws.ToString(newest.ImageId)) {
+			newest = image
+		}
+	}
+	return newest
+}
+
+// imageCreationTime parses an image's CreationDate, treating a missing or
+// malformed date as the oldest possible time
+func imageCreationTime(image types.Image) time.Time {
+	created, err := time.Parse(time.RFC3339, aws.ToString(image.CreationDate))
+	if err != nil {
+		return time.Time{}
+	}
+	return created
 }
 
 // TagAMI tags an AMI with the specified key and value
@@ -88,110 +256,683 @@ func (s *Service) TagAMI(ctx context.Context, amiID, tagKey, tagValue string) er
 	return err
 }
 
+// MigrateOptions controls how MigrateInstances processes the matching instances
+type MigrateOptions struct {
+	// NewAMI is the AMI to migrate every instance to. When empty the latest
+	// AMI for each instance's OS type is used.
+	NewAMI string
+	// OldAMI, when set, limits MigrateInstances to the enabled instances
+	// currently running this AMI. When empty every enabled instance is
+	// selected.
+	OldAMI string
+	// EnabledValues, when set, selects the instances whose enabled tag has
+	// any of these values instead of MigrateInstances' enabledValue, e.g.
+	// canary and prod to migrate both in one run.
+	EnabledValues []string
+	// OnlyState, when set, limits MigrateInstances to the enabled instances
+	// in this state, e.g. stopped to migrate those overnight and leave the
+	// running ones for a maintenance window. Running instances still need
+	// the if-running tag or Force.
+	OnlyState types.InstanceStateName
+	// SnapshotOnly takes each instance's migration snapshots and tags it
+	// snapshotted without stopping or replacing it, e.g. during a quiet
+	// window. A later run with Cutover finishes the migrations from those
+	// snapshots.
+	SnapshotOnly bool
+	// Cutover migrates only the instances tagged snapshotted by a
+	// SnapshotOnly run, launching their replacements from the staged
+	// snapshots instead of taking new ones. Data written to the volumes
+	// since the snapshots were taken is not carried over. It expects the
+	// same SnapshotSelector as the SnapshotOnly run.
+	Cutover bool
+	// Force migrates running instances that lack the if-running tag, e.g.
+	// during a maintenance window. Instances still need the enabled tag.
+	Force bool
+	// DryRun builds a plan of the actions that would be taken without calling
+	// any mutating EC2 APIs
+	DryRun bool
+	// PlanOutput, when set, also gets the DryRun plan written to it as
+	// indented JSON, e.g. a file to attach to a change request
+	PlanOutput io.Writer
+	// ValidatePermissions sends native EC2 DryRun requests while planning so
+	// missing IAM permissions show up in the plan
+	ValidatePermissions bool
+	// Pricer, when set, adds the estimated monthly On-Demand cost change of
+	// each planned migration to the plan, e.g. from a PriceTable
+	Pricer InstancePricer
+	// PreservePrivateIP launches the replacement with the old instance's
+	// private IP. The old instance has to be terminated before the
+	// replacement is launched, so it is no longer kept around if the new
+	// instance fails its health checks. If the address can't be reused the
+	// replacement gets a new one.
+	PreservePrivateIP bool
+	// RetainOldInstance keeps the old instance stopped once its replacement
+	// is healthy instead of terminating it, tagged with the replacement's ID,
+	// so it can be booted back up. CleanupReplacedInstances terminates
+	// retained instances later. A retained instance keeps its private IP, so
+	// PreservePrivateIP is ignored.
+	RetainOldInstance bool
+	// NameSuffix is appended to the Name tag copied to each replacement, e.g.
+	// -migrated, to tell it apart while it is verified. NameSuffixTimestamp
+	// in it is replaced with the time of the migration. StripNameSuffix, or
+	// VerifyOptions.StripNameSuffix, takes it off again. Empty copies the
+	// Name unchanged.
+	NameSuffix string
+	// CopyTagKeys, when set, only copies the old instance's tags whose keys
+	// match one of these globs, e.g. "Name" or "team-*", to the
+	// replacement. The enabled, if-running, critical and timeout tags are
+	// always copied so the replacement stays enrolled.
+	CopyTagKeys []string
+	// SkipTagKeys leaves the tags whose keys match one of these globs off
+	// the replacement. Tags in the aws: namespace are never copied, since
+	// EC2 refuses to set them.
+	SkipTagKeys []string
+	// TagSelectors narrows the enabled instances to those carrying all of
+	// these tag key/value pairs, e.g. {"Environment": "staging"}
+	TagSelectors map[string]string
+	// ExcludeTags holds back the selected instances carrying any of these
+	// tags, e.g. maintenance=hold. They are tagged and reported as skipped
+	// and are never stopped or replaced.
+	ExcludeTags map[string]string
+	// Filters are extra DescribeInstances filters merged with the
+	// ami-migrate tag filter
+	Filters []types.Filter
+	// NameFilter narrows the enabled instances to those whose Name tag
+	// matches it: a glob such as "web-*", or a regular expression between
+	// slashes such as "/^web-[0-9]+$/". EC2 can't match tag values by
+	// pattern, so it is applied to the described instances.
+	NameFilter string
+	// ExcludeTargetAMI leaves instances already running NewAMI out of the
+	// run entirely instead of reporting each one as skipped, which saves
+	// work when re-running a large migration. EC2 filters can't exclude an
+	// image, so they are dropped after describing.
+	ExcludeTargetAMI bool
+	// MaxConcurrency limits how many instances are migrated at once.
+	// Zero or less uses DefaultMaxConcurrency.
+	MaxConcurrency int
+	// StartStagger spaces out the start of the instances in a batch by a
+	// random delay between half of it and all of it, so their snapshots
+	// aren't all requested from EC2 at the same moment. Zero starts them
+	// all at once.
+	StartStagger time.Duration
+	// BatchSize migrates the instances in waves of this many, each finishing
+	// before the next starts. Zero migrates them all in one batch.
+	BatchSize int
+	// BatchPercent sizes the waves as a percentage of the instances
+	// instead, rounded up. It takes precedence over BatchSize.
+	BatchPercent int
+	// BatchDelay pauses between batches
+	BatchDelay time.Duration
+	// VerifyBatches stops the run after a batch in which any migration
+	// failed or whose replacements are no longer running and passing their
+	// status checks
+	VerifyBatches bool
+	// BeforeBatch, when set, is called before every batch after the first,
+	// e.g. to ask for confirmation
+	BeforeBatch BatchHook
+	// Progress, when set, is called as each instance moves through the
+	// migration so callers can show live progress
+	Progress ProgressFunc
+	// InstanceType launches the replacements as this type instead of the
+	// original instance's type
+	InstanceType types.InstanceType
+	// InstanceTypes overrides the replacement type per source instance ID,
+	// taking precedence over InstanceType
+	InstanceTypes map[string]types.InstanceType
+	// LaunchTemplate, when set, launches the replacements from this template
+	// instead of copying the original instance's subnet, security groups and
+	// instance profile. The target AMI and instance type still override the
+	// template's.
+	LaunchTemplate *LaunchTemplate
+	// OverrideTerminationProtection disables the termination protection of
+	// protected instances just before they are terminated and enables it on
+	// their replacements. Without it such instances fail the migration with
+	// ErrTerminationProtected before they are stopped.
+	OverrideTerminationProtection bool
+	// UserData, when set, is the user data of the replacements, as the
+	// plain script or cloud-config; it is base64-encoded for the launch.
+	// Otherwise each replacement gets its original instance's user data, or
+	// the LaunchTemplate's when one is given.
+	UserData string
+	// Spot, when set, launches the replacements as Spot instances, except
+	// for instances carrying the critical tag, which stay On-Demand
+	Spot *SpotOptions
+	// SnapshotSelector, when set, limits the volumes snapshotted before each
+	// instance is migrated, e.g. to RootVolumeOnly. Data volumes that
+	// aren't snapshotted are not recreated on the replacement. By default
+	// every EBS volume is snapshotted.
+	SnapshotSelector SnapshotSelector
+	// KeepSnapshotsOnFailure keeps the snapshots taken before a migration
+	// that fails, e.g. to restore from them by hand. By default they are
+	// deleted while the old instance is still in place; once it has been
+	// terminated they are always kept.
+	KeepSnapshotsOnFailure bool
+	// WaitForSnapshots waits for every migration snapshot to complete before
+	// the old instance is terminated, so the backup is usable even if the
+	// volumes are deleted with the instance. Only the data volume snapshots
+	// are waited for otherwise, which is faster on instances with large root
+	// volumes.
+	WaitForSnapshots bool
+	// KMSKeyID encrypts the backup snapshots with this KMS key. Snapshots the
+	// volume's own encryption doesn't cover are replaced by an encrypted copy.
+	KMSKeyID string
+	// EncryptUnencryptedSnapshots also replaces the snapshots of unencrypted
+	// volumes with a copy encrypted with KMSKeyID. Without it they are kept
+	// unencrypted.
+	EncryptUnencryptedSnapshots bool
+	// PreStopHook, when set, is run on running instances before they are
+	// stopped. It needs a service created WithSSMClient.
+	PreStopHook *PreStopHook
+	// HealthCheck, when set, is run against each replacement once it passes
+	// its status checks, and the migration fails unless it passes. SSM
+	// commands need a service created WithSSMClient.
+	HealthCheck *HealthCheck
+	// MetricsNamespace, when set, publishes the migration outcomes and
+	// durations to CloudWatch under this namespace. It needs a service
+	// created WithCloudWatchClient.
+	MetricsNamespace string
+	// NotifyTopicARN, when set, publishes a summary of the run to this SNS
+	// topic once MigrateInstances finishes. It needs a service created
+	// WithSNSClient.
+	NotifyTopicARN string
+	// NotifyWebhookURL, when set, POSTs the same summary as JSON to this URL
+	NotifyWebhookURL string
+}
+
+// DefaultMaxConcurrency is the number of instances migrated at once when
+// MigrateOptions.MaxConcurrency is unset, keeping large fleets under the EC2
+// API rate limits
+const DefaultMaxConcurrency = 10
+
+// statusTagTimeout bounds the status tag writes made after a run's context
+// has been cancelled
+const statusTagTimeout = 30 * time.Second
+
 // MigrateInstances migrates instances to new AMI if they have the enabled tag
-func (s *Service) MigrateInstances(ctx context.Context, enabledValue string) error {
-	logger.Info("Starting migration of enabled instances", "enabledValue", enabledValue)
+// set to enabledValue, or to any of opts.EnabledValues when set, none of
+// which may be empty (ErrEmptyEnabledValue).
+// Running instances are skipped unless they also carry the if-running tag.
+// The returned result records the outcome for every instance and is returned
+// alongside the error when some migrations fail. The other instances are
+// still migrated, and the error joins the failed instances' errors, so
+// errors.Is finds their categories. When opts.DryRun is set
+// nothing is modified and the result's Plan describes what would have been done.
+// If ctx is cancelled, instances not yet started are tagged and recorded as
+// cancelled and the error wraps ctx.Err().
+func (s *Service) MigrateInstances(ctx context.Context, enabledValue string, opts MigrateOptions) (*MigrationResult, error) {
+	logger.Info("Starting migration of enabled instances", "enabledValue", enabledValue, "dryRun", opts.DryRun)
+	start := time.Now()
 
 	// Get enabled instances
-	instances, err := s.fetchEnabledInstances(ctx, enabledValue)
+	instances, err := s.fetchEnabledInstances(ctx, enabledValue, opts)
 	if err != nil {
 		logger.Error("Failed to fetch enabled instances", "error", err)
-		return fmt.Errorf("fetch enabled instances: %w", err)
+		return nil, classifyError(fmt.Errorf("fetch enabled instances: %w", err))
 	}
+	return s.migrateSelected(ctx, start, strings.Join(opts.enabledValues(enabledValue), ","), instances, opts)
+}
 
-	if len(instances) == 0 {
-		logger.Info("No instances found with enabled tag")
-		return nil
+// enabledValues returns the values of the enabled tag to select instances
+// by: the EnabledValues, each listed once, or else enabledValue
+func (o MigrateOptions) enabledValues(enabledValue string) []string {
+	if len(o.EnabledValues) == 0 {
+		return []string{enabledValue}
+	}
+	var values []string
+	for _, value := range o.EnabledValues {
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
 	}
+	return values
+}
 
-	// Process instances concurrently
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(instances))
+// migrateSelected runs the migration of the selected instances for
+// MigrateInstances and MigrateByAMI. enabledValue is recorded in the result
+// and is empty when the instances weren't selected by tag.
+func (s *Service) migrateSelected(ctx context.Context, start time.Time, enabledValue string, instances []types.Instance, opts MigrateOptions) (*MigrationResult, error) {
+	// Catch a mistyped or unusable AMI before anything is snapshotted
+	if opts.NewAMI != "" && len(instances) > 0 {
+		if err := s.validateTargetAMI(ctx, opts.NewAMI, instances, opts); err != nil {
+			logger.Error("Invalid target AMI", "newAMI", opts.NewAMI, "error", err)
+			return nil, fmt.Errorf("validate target AMI: %w", err)
+		}
+	}
 
-	for _, instance := range instances {
-		wg.Add(1)
-		go func(inst types.Instance) {
-			defer wg.Done()
+	result := &MigrationResult{
+		EnabledValue: enabledValue,
+		Instances:    []InstanceResult{},
+	}
 
-			// Get the OS type
-			osType, err := s.GetInstanceOSType(ctx, aws.ToString(inst.InstanceId))
-			if err != nil {
-				errChan <- fmt.Errorf("get instance OS type %s: %w", aws.ToString(inst.InstanceId), err)
-				return
+	if opts.DryRun {
+		plan := &MigrationPlan{
+			EnabledValue: enabledValue,
+			TargetAMI:    opts.NewAMI,
+			Instances:    []InstancePlan{},
+		}
+		// Number the batches the run would be split into, as it splits them
+		batches := splitBatches(instances, opts.batchSize(len(instances)))
+		for i, batch := range batches {
+			for _, instance := range batch {
+				instancePlan := s.planInstance(ctx, instance, opts)
+				if len(batches) > 1 {
+					instancePlan.Batch = i + 1
+				}
+				plan.Instances = append(plan.Instances, instancePlan)
+			}
+		}
+		plan.SummarizeCost()
+		result.Plan = plan
+		result.Duration = time.Since(start)
+		if opts.PlanOutput != nil {
+			if err := plan.WriteJSON(opts.PlanOutput); err != nil {
+				return result, fmt.Errorf("write plan: %w", err)
 			}
+		}
+		return result, nil
+	}
 
-			// Get the latest AMI
-			latestAMI, err := s.GetLatestAMI(ctx, osType)
-			if err != nil {
-				errChan <- fmt.Errorf("get latest AMI for instance %s: %w", aws.ToString(inst.InstanceId), err)
-				return
+	if len(instances) == 0 {
+		logger.Info("No instances found to migrate")
+		return result, nil
+	}
+
+	// Process instances concurrently, at most maxConcurrency at a time
+	opts.Progress = serializeProgress(opts.Progress)
+	maxConcurrency := opts.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	sem := make(chan struct{}, maxConcurrency)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	record := func(instanceResult InstanceResult) {
+		// Every outcome ends up here, so this is where the final stage is reported
+		opts.reportProgress(instanceResult.InstanceID, instanceResult.Status, instanceResult.Message)
+		mu.Lock()
+		defer mu.Unlock()
+		result.Instances = append(result.Instances, instanceResult)
+	}
+	// The errors of the instances that failed, joined into the run's error
+	var instanceErrs []error
+	fail := func(instanceID string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		instanceErrs = append(instanceErrs, fmt.Errorf("%s: %w", instanceID, err))
+	}
+
+	// Each batch finishes before the next one starts
+	batches := splitBatches(instances, opts.batchSize(len(instances)))
+	var haltErr error
+	for i, batch := range batches {
+		if i > 0 && haltErr == nil && ctx.Err() == nil {
+			// The previous batch's results are the last ones recorded
+			previous := result.Instances[len(result.Instances)-len(batches[i-1]):]
+			haltErr = s.betweenBatches(ctx, i+1, len(batches), previous, opts)
+		}
+		if haltErr != nil {
+			message := fmt.Sprintf("Migration not started: %v", haltErr)
+			for _, inst := range batch {
+				record(s.cancelInstance(ctx, inst, message))
 			}
+			continue
+		}
+		if len(batches) > 1 {
+			logger.Info("Starting batch", "batch", i+1, "batches", len(batches), "instances", len(batch))
+		}
 
-			if err := s.MigrateInstance(ctx, aws.ToString(inst.InstanceId), latestAMI); err != nil {
-				errChan <- fmt.Errorf("migrate instance %s: %w", aws.ToString(inst.InstanceId), err)
+		for j, instance := range batch {
+			if j > 0 && opts.StartStagger > 0 {
+				staggerStart(ctx, opts.StartStagger)
 			}
-		}(instance)
+			wg.Add(1)
+			go func(inst types.Instance) {
+				defer wg.Done()
+
+				// Don't start new work once the run has been cancelled
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+				}
+				if ctx.Err() != nil {
+					record(s.cancelInstance(ctx, inst, fmt.Sprintf("Migration cancelled before it started: %v", ctx.Err())))
+					return
+				}
+
+				instanceStart := time.Now()
+				instanceID := aws.ToString(inst.InstanceId)
+
+				if excluded, reason := excludedByTag(inst, opts.ExcludeTags); excluded {
+					s.tagInstanceStatus(ctx, inst, StatusSkipped, reason)
+					record(InstanceResult{
+						InstanceID: instanceID,
+						Status:     StatusSkipped,
+						OldAMI:     aws.ToString(inst.ImageId),
+						Message:    reason,
+					})
+					return
+				}
+
+				targetAMI, err := s.resolveTargetAMI(ctx, instanceID, opts)
+				if err != nil {
+					fail(instanceID, err)
+					record(InstanceResult{
+						InstanceID: instanceID,
+						Status:     StatusFailed,
+						OldAMI:     aws.ToString(inst.ImageId),
+						Message:    err.Error(),
+						Duration:   time.Since(instanceStart),
+					})
+					return
+				}
+
+				if migrate, reason := s.shouldMigrateInstance(inst, targetAMI, opts.Force); !migrate {
+					if group := autoScalingGroup(inst); group != "" {
+						logger.Warn("Skipping instance managed by an Auto Scaling group", "instanceID", instanceID, "autoScalingGroup", group)
+					}
+					s.tagInstanceStatus(ctx, inst, StatusSkipped, reason)
+					record(InstanceResult{
+						InstanceID: instanceID,
+						Status:     StatusSkipped,
+						OldAMI:     aws.ToString(inst.ImageId),
+						NewAMI:     targetAMI,
+						Message:    reason,
+					})
+					return
+				}
+
+				instanceResult, err := s.migrateInstance(ctx, inst, targetAMI, opts)
+				if err != nil {
+					logger.Error("Failed to migrate instance", "instanceID", instanceID, "error", err)
+					fail(instanceID, err)
+				}
+				record(*instanceResult)
+			}(instance)
+		}
+
+		// Wait for the batch to finish
+		wg.Wait()
 	}
 
-	// Wait for all goroutines to finish
-	wg.Wait()
-	close(errChan)
+	sort.Slice(result.Instances, func(i, j int) bool {
+		return result.Instances[i].InstanceID < result.Instances[j].InstanceID
+	})
+	result.Summarize()
+	result.Duration = time.Since(start)
+	s.putMigrationMetrics(ctx, result.Instances, opts)
+
+	var runErr error
+	switch {
+	case ctx.Err() != nil:
+		runErr = fmt.Errorf("migration cancelled with %d of %d instances not started: %w",
+			result.Summary.Cancelled, result.Summary.Total, ctx.Err())
+	case haltErr != nil:
+		runErr = fmt.Errorf("migration stopped with %d of %d instances not started: %w",
+			result.Summary.Cancelled, result.Summary.Total, haltErr)
+	case result.Summary.Failed > 0:
+		runErr = fmt.Errorf("failed to migrate %d of %d instances", result.Summary.Failed, result.Summary.Total)
+		if len(instanceErrs) > 0 {
+			// Errors start with the instance ID, so this orders them by instance
+			sort.Slice(instanceErrs, func(i, j int) bool {
+				return instanceErrs[i].Error() < instanceErrs[j].Error()
+			})
+			runErr = fmt.Errorf("failed to migrate %d of %d instances: %w",
+				result.Summary.Failed, result.Summary.Total, errors.Join(instanceErrs...))
+		}
+	}
 
-	// Check for any errors
-	var errs []error
-	for err := range errChan {
-		errs = append(errs, err)
+	s.notifyMigrationComplete(ctx, result, runErr, opts)
+	return result, runErr
+}
+
+// cancelInstance tags an instance that was never started, because ctx is
+// done or the run was stopped between batches, and returns its cancelled
+// result. The tag is written on a context detached from ctx, since ctx may no
+// longer be usable for API calls.
+func (s *Service) cancelInstance(ctx context.Context, instance types.Instance, message string) InstanceResult {
+	tagCtx, cancel := detachedContext(ctx)
+	defer cancel()
+	if err := s.tagInstanceStatus(tagCtx, instance, StatusCancelled, message); err != nil {
+		logger.Error("Failed to tag cancelled instance", "instanceID", aws.ToString(instance.InstanceId), "error", err)
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to migrate some instances: %v", errs)
+	return InstanceResult{
+		InstanceID: aws.ToString(instance.InstanceId),
+		Status:     StatusCancelled,
+		OldAMI:     aws.ToString(instance.ImageId),
+		Message:    message,
 	}
+}
 
-	return nil
+// detachedContext returns a context that survives ctx being cancelled, for
+// the status tags written after a run is aborted
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), statusTagTimeout)
+}
+
+// fetchEnabledInstances returns the instances whose enabled tag is
+// enabledValue, or any of opts.EnabledValues when set, narrowed by opts. An
+// empty value is refused rather than matching the instances tagged with an
+// empty value.
+func (s *Service) fetchEnabledInstances(ctx context.Context, enabledValue string, opts MigrateOptions) ([]types.Instance, error) {
+	values := opts.enabledValues(enabledValue)
+	if slices.Contains(values, "") {
+		return nil, fmt.Errorf("%w: pass the value instances are tagged %s with, e.g. enabled", ErrEmptyEnabledValue, s.tags.Enabled)
+	}
+	var scope []types.Filter
+	if opts.OldAMI != "" {
+		scope = append(scope, types.Filter{
+			Name:   aws.String("image-id"),
+			Values: []string{opts.OldAMI},
+		})
+	}
+	// Terminated instances keep their tags and image ID for a while, so they
+	// have to be left out explicitly
+	states := migratableStates
+	if opts.OnlyState != "" {
+		states = []string{string(opts.OnlyState)}
+	}
+	scope = append(scope, types.Filter{
+		Name:   aws.String("instance-state-name"),
+		Values: states,
+	})
+	if opts.Cutover {
+		scope = append(scope, types.Filter{
+			Name:   aws.String("tag:" + s.tags.Status),
+			Values: []string{StatusSnapshotted},
+		})
+	}
+	opts.Filters = append(scope, opts.Filters...)
+	instances, err := s.fetchInstances(ctx, types.Filter{
+		Name:   aws.String("tag:" + s.tags.Enabled),
+		Values: values,
+	}, opts)
+	if err != nil || opts.OnlyState == "" {
+		return instances, err
+	}
+	return filterByState(instances, opts.OnlyState), nil
 }
 
-func (s *Service) fetchEnabledInstances(ctx context.Context, enabledValue string) ([]types.Instance, error) {
+// fetchInstances returns the instances matching filter, narrowed by the tag
+// selectors, filters and name filter in opts
+func (s *Service) fetchInstances(ctx context.Context, filter types.Filter, opts MigrateOptions) ([]types.Instance, error) {
 	input := &ec2.DescribeInstancesInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("tag:ami-migrate"),
-				Values: []string{enabledValue},
-			},
-		},
+		Filters: []types.Filter{filter},
+	}
+
+	// Narrow the selection with any tag selectors and extra filters
+	keys := make([]string, 0, len(opts.TagSelectors))
+	for key := range opts.TagSelectors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		input.Filters = append(input.Filters, types.Filter{
+			Name:   aws.String("tag:" + key),
+			Values: []string{opts.TagSelectors[key]},
+		})
 	}
+	input.Filters = append(input.Filters, opts.Filters...)
 
-	resp, err := s.client.DescribeInstances(ctx, input)
+	instances, err := s.describeInstances(ctx, input)
 	if err != nil {
 		return nil, err
 	}
+	if opts.ExcludeTargetAMI && opts.NewAMI != "" {
+		instances = excludeImage(instances, opts.NewAMI)
+	}
+	if opts.NameFilter == "" {
+		return instances, nil
+	}
+	return filterByName(instances, opts.NameFilter)
+}
+
+// excludeImage returns the instances not running imageID
+func excludeImage(instances []types.Instance, imageID string) []types.Instance {
+	var remaining []types.Instance
+	for _, instance := range instances {
+		if aws.ToString(instance.ImageId) != imageID {
+			remaining = append(remaining, instance)
+		}
+	}
+	if excluded := len(instances) - len(remaining); excluded > 0 {
+		logger.Info("Excluding instances already on target AMI", "imageID", imageID, "count", excluded)
+	}
+	return remaining
+}
+
+// filterByState returns the instances in state, backing up the
+// instance-state-name filter so the selection never rests on it alone
+func filterByState(instances []types.Instance, state types.InstanceStateName) []types.Instance {
+	var matched []types.Instance
+	for _, instance := range instances {
+		if instance.State != nil && instance.State.Name == state {
+			matched = append(matched, instance)
+		}
+	}
+	if excluded := len(instances) - len(matched); excluded > 0 {
+		logger.Info("Excluding instances not in the selected state", "state", state, "count", excluded)
+	}
+	return matched
+}
 
+// describeInstances returns the instances matching input across all result pages
+func (s *Service) describeInstances(ctx context.Context, input *ec2.DescribeInstancesInput) ([]types.Instance, error) {
 	var instances []types.Instance
-	for _, reservation := range resp.Reservations {
-		instances = append(instances, reservation.Instances...)
+	paginator := ec2.NewDescribeInstancesPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, reservation := range page.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
 	}
 	return instances, nil
 }
 
-func (s *Service) shouldMigrateInstance(instance types.Instance) (bool, bool) {
-	isRunning := string(instance.State.Name) == string(types.InstanceStateNameRunning)
-	hasIfRunningTag := false
-
-	// Check for if-running tag
-	for _, tag := range instance.Tags {
-		if aws.ToString(tag.Key) == "ami-migrate-if-running" &&
-			aws.ToString(tag.Value) == "enabled" {
-			hasIfRunningTag = true
-			break
+// describeImages returns the images matching input across all result pages.
+// Lookups of specific image IDs fit in one page and can call DescribeImages
+// directly.
+func (s *Service) describeImages(ctx context.Context, input *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) ([]types.Image, error) {
+	var images []types.Image
+	paginator := ec2.NewDescribeImagesPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, optFns...)
+		if err != nil {
+			return nil, err
 		}
+		images = append(images, page.Images...)
+	}
+	return images, nil
+}
+
+// skipReasonAlreadyMigrated is the reason shouldMigrateInstance gives for
+// skipping an instance already on the target AMI
+const skipReasonAlreadyMigrated = "already-migrated"
+
+// transitionalStates are the instance states that settle by themselves.
+// Stopping or replacing an instance in one of them would race EC2, so it is
+// left for a later run.
+var transitionalStates = map[types.InstanceStateName]bool{
+	types.InstanceStateNamePending:      true,
+	types.InstanceStateNameStopping:     true,
+	types.InstanceStateNameShuttingDown: true,
+}
+
+// transitionalState returns the instance's state if it is transitional, or ""
+func transitionalState(instance types.Instance) types.InstanceStateName {
+	if instance.State != nil && transitionalStates[instance.State.Name] {
+		return instance.State.Name
+	}
+	return ""
+}
+
+// autoScalingGroupTagKey is the tag EC2 Auto Scaling puts on the instances it manages
+const autoScalingGroupTagKey = "aws:autoscaling:groupName"
+
+// autoScalingGroup returns the name of the Auto Scaling group managing the
+// instance, or "" if there is none. The group would replace an instance the
+// migration terminates, so its members are rolled out through the group's
+// launch template instead.
+func autoScalingGroup(instance types.Instance) string {
+	return tagValue(instance.Tags, autoScalingGroupTagKey)
+}
+
+// shouldMigrateInstance reports whether the instance should be migrated to
+// targetAMI, and the reason when it shouldn't. An instance already on
+// targetAMI is skipped so re-running a partially failed migration leaves the
+// finished instances alone; an empty targetAMI only checks the tags. A
+// terminated instance, or one in a transitional state or in an Auto Scaling
+// group, is skipped even with force. With force a running instance is migrated even without
+// the if-running tag.
+func (s *Service) shouldMigrateInstance(instance types.Instance, targetAMI string, force bool) (bool, string) {
+	if targetAMI != "" && aws.ToString(instance.ImageId) == targetAMI {
+		return false, skipReasonAlreadyMigrated
+	}
+	if instance.State != nil && instance.State.Name == types.InstanceStateNameTerminated {
+		return false, "Terminated instance"
+	}
+	if replacedBy := tagValue(instance.Tags, replacedByTagKey); replacedBy != "" {
+		return false, fmt.Sprintf("Retained after migration to %s", replacedBy)
+	}
+	if state := transitionalState(instance); state != "" {
+		return false, fmt.Sprintf("Transitional state: instance is %s", state)
+	}
+	if group := autoScalingGroup(instance); group != "" {
+		return false, fmt.Sprintf("Auto Scaling group: instance is managed by %s, update the group's launch template instead", group)
+	}
+	if reason := s.windowSkipReason(instance); reason != "" {
+		return false, reason
 	}
 
 	// If instance is running, we need both tags
-	if isRunning {
-		return hasIfRunningTag, false
+	if s.runningWithoutIfRunningTag(instance) && !force {
+		return false, fmt.Sprintf("Running instance without %s tag", s.tags.IfRunning)
 	}
 
-	// If instance is stopped, we only need ami-migrate tag (which is already checked in fetchEnabledInstances)
-	return true, false
+	// If instance is stopped, we only need the enabled tag (which is already checked in fetchEnabledInstances)
+	return true, ""
+}
+
+// runningWithoutIfRunningTag reports whether the instance is running without
+// the if-running tag, so it may only be migrated with MigrateOptions.Force
+func (s *Service) runningWithoutIfRunningTag(instance types.Instance) bool {
+	isRunning := instance.State != nil && instance.State.Name == types.InstanceStateNameRunning
+	return isRunning && !hasTag(instance.Tags, s.tags.IfRunning, "enabled")
+}
+
+// migrationMessage returns the status message for migrating the instance to
+// newAMI, prefixed with verb, noting when Force overrode the if-running tag
+func (s *Service) migrationMessage(instance types.Instance, verb, newAMI string, opts MigrateOptions) string {
+	message := fmt.Sprintf("%s to AMI: %s", verb, newAMI)
+	if opts.Force && s.runningWithoutIfRunningTag(instance) {
+		message = fmt.Sprintf("Force-%s to AMI: %s (running without %s tag)", strings.ToLower(verb), newAMI, s.tags.IfRunning)
+	}
+	return message
 }
 
 func (s *Service) startInstance(ctx context.Context, instance types.Instance) error {
@@ -204,7 +945,7 @@ func (s *Service) startInstance(ctx context.Context, instance types.Instance) er
 	}
 
 	// Wait for instance to start
-	return waitForInstanceState(ctx, aws.ToString(instance.InstanceId), types.InstanceStateNameRunning)
+	return s.waitForInstanceState(ctx, aws.ToString(instance.InstanceId), types.InstanceStateNameRunning)
 }
 
 func (s *Service) stopInstance(ctx context.Context, instance types.Instance) error {
@@ -217,68 +958,283 @@ func (s *Service) stopInstance(ctx context.Context, instance types.Instance) err
 	}
 
 	// Wait for instance to stop
-	return waitForInstanceState(ctx, aws.ToString(instance.InstanceId), types.InstanceStateNameStopped)
+	return s.waitForInstanceState(ctx, aws.ToString(instance.InstanceId), types.InstanceStateNameStopped)
 }
 
-func (s *Service) upgradeInstance(ctx context.Context, instance types.Instance, newAMI string) error {
-	// Create snapshot of the instance's volumes
-	for _, mapping := range instance.BlockDeviceMappings {
-		if mapping.Ebs != nil {
-			_, err := s.client.CreateSnapshot(ctx, &ec2.CreateSnapshotInput{
-				VolumeId: mapping.Ebs.VolumeId,
-				Description: aws.String(fmt.Sprintf("Backup before AMI migration for instance %s",
-					aws.ToString(instance.InstanceId))),
-			})
+func (s *Service) upgradeInstance(ctx context.Context, instance types.Instance, newAMI string, opts MigrateOptions) (string, []MigrationSnapshot, error) {
+	// Stop the instance first so the snapshots capture a consistent copy of the data volumes
+	if string(instance.State.Name) == string(types.InstanceStateNameRunning) {
+		if err := s.stopInstance(ctx, instance); err != nil {
+			return "", nil, fmt.Errorf("stop instance: %w", err)
+		}
+	}
+
+	var snapshotIDs map[string]string
+	var createdSnapshots []string
+	oldTerminated := false
+	// address is the old instance's Elastic IP while it is associated with neither instance
+	var address *types.Address
+	// protectionRemoved is set once the old instance's termination protection
+	// is disabled, to be restored on the replacement
+	protectionRemoved := false
+	// fail cleans up after a failure once snapshots may have been taken
+	fail := func(err error) (string, []MigrationSnapshot, error) {
+		if address != nil {
+			err = fmt.Errorf("%w (Elastic IP %s is not associated with any instance)", err, aws.ToString(address.PublicIp))
+		}
+		return "", nil, s.failedMigration(ctx, instance, createdSnapshots, oldTerminated, opts, err)
+	}
+	// terminateOld terminates the old instance, moving its Elastic IP off it
+	// first. If termination fails the address is put back.
+	terminateOld := func() error {
+		if opts.WaitForSnapshots {
+			if err := s.waitForMigrationSnapshots(ctx, instance, snapshotIDs); err != nil {
+				return err
+			}
+		}
+		if opts.OverrideTerminationProtection {
+			protected, err := s.terminationProtected(ctx, aws.ToString(instance.InstanceId))
 			if err != nil {
-				return fmt.Errorf("create snapshot: %w", err)
+				return err
+			}
+			if protected {
+				if err := s.setTerminationProtection(ctx, aws.ToString(instance.InstanceId), false); err != nil {
+					return err
+				}
+				protectionRemoved = true
 			}
 		}
+		var err error
+		if address, err = s.detachElasticIP(ctx, instance); err != nil {
+			return err
+		}
+		oldTerminated = true
+		if err := s.terminateInstance(ctx, instance); err != nil {
+			if s.attachElasticIP(ctx, address, aws.ToString(instance.InstanceId)) == nil {
+				address = nil
+			}
+			if protectionRemoved && s.setTerminationProtection(ctx, aws.ToString(instance.InstanceId), true) == nil {
+				protectionRemoved = false
+			}
+			return err
+		}
+		return nil
+	}
+	var err error
+	if opts.Cutover {
+		// The snapshots were taken ahead of time by a SnapshotOnly run, and a
+		// failed cutover keeps them rather than deleting the staged backup
+		opts.KeepSnapshotsOnFailure = true
+		snapshotIDs, createdSnapshots, err = s.stagedSnapshots(ctx, instance, opts)
+	} else {
+		snapshotIDs, createdSnapshots, err = s.takeMigrationSnapshots(ctx, instance, opts)
+	}
+	if err != nil {
+		return fail(err)
 	}
 
-	// Stop the instance
-	if string(instance.State.Name) == string(types.InstanceStateNameRunning) {
-		if err := s.stopInstance(ctx, instance); err != nil {
-			return fmt.Errorf("stop instance: %w", err)
-		}
+	// Recreate the data volumes on the replacement from the snapshots
+	blockDevices, err := s.dataVolumeMappings(ctx, instance, snapshotIDs)
+	if err != nil {
+		return fail(fmt.Errorf("map data volumes: %w", err))
+	}
+	// An operator may have grown the root volume beyond the AMI's default
+	rootVolume, err := s.rootVolumeMapping(ctx, instance, newAMI)
+	if err != nil {
+		return fail(fmt.Errorf("map root volume: %w", err))
+	}
+	if rootVolume != nil {
+		blockDevices = append([]types.BlockDeviceMapping{*rootVolume}, blockDevices...)
+	}
+	// Read the volume tags while the old volumes still exist
+	volumeTags, err := s.volumeTags(ctx, instance)
+	if err != nil {
+		return fail(fmt.Errorf("read volume tags: %w", err))
+	}
+	// Read the user data while the old instance still exists
+	userData, err := s.userDataFor(ctx, instance, opts)
+	if err != nil {
+		return fail(fmt.Errorf("read user data: %w", err))
 	}
 
 	// Create new instance with new AMI
 	runInput := &ec2.RunInstancesInput{
-		ImageId:      aws.String(newAMI),
-		InstanceType: instance.InstanceType,
-		MinCount:     aws.Int32(1),
-		MaxCount:     aws.Int32(1),
+		ImageId:               aws.String(newAMI),
+		InstanceType:          opts.instanceTypeFor(instance),
+		MinCount:              aws.Int32(1),
+		MaxCount:              aws.Int32(1),
+		BlockDeviceMappings:   blockDevices,
+		InstanceMarketOptions: s.spotMarketOptions(instance, opts.Spot),
+		UserData:              userData,
+		TagSpecifications: []types.TagSpecification{
+			{
+				// Record where the replacement came from so it can be rolled back
+				ResourceType: types.ResourceTypeInstance,
+				Tags: []types.Tag{
+					{
+						Key:   aws.String(sourceInstanceTagKey),
+						Value: instance.InstanceId,
+					},
+					{
+						Key:   aws.String(sourceAMITagKey),
+						Value: instance.ImageId,
+					},
+				},
+			},
+		},
+	}
+	if opts.LaunchTemplate != nil {
+		runInput.LaunchTemplate = opts.LaunchTemplate.specification()
+	} else {
+		applyNetworking(runInput, instance)
+		runInput.Placement = placementFor(instance)
+	}
+
+	// A private IP is only released once its instance is gone, so reusing it
+	// means the old instance has to be terminated before the replacement exists
+	reuseIP := opts.PreservePrivateIP && !opts.RetainOldInstance && instance.SubnetId != nil && instance.PrivateIpAddress != nil
+	if reuseIP {
+		// Once termination is requested the snapshots may be the only copy of the data
+		if err := terminateOld(); err != nil {
+			return fail(err)
+		}
+		if err := s.waitForInstanceState(ctx, aws.ToString(instance.InstanceId), types.InstanceStateNameTerminated); err != nil {
+			return fail(fmt.Errorf("wait for old instance termination: %w", err))
+		}
+		opts.reportProgress(aws.ToString(instance.InstanceId), ProgressTerminated, "")
+		runInput.PrivateIpAddress = instance.PrivateIpAddress
 	}
 
 	runResult, err := s.client.RunInstances(ctx, runInput)
+	if err != nil && reuseIP {
+		logger.Warn("Could not reuse private IP, launching with a new address",
+			"instanceID", aws.ToString(instance.InstanceId),
+			"privateIP", aws.ToString(instance.PrivateIpAddress),
+			"error", err)
+		runInput.PrivateIpAddress = nil
+		runResult, err = s.client.RunInstances(ctx, runInput)
+	}
+	if unpinned := unpinnedHost(runInput.Placement); err != nil && unpinned != nil && errors.Is(classifyError(err), ErrInsufficientCapacity) {
+		logger.Warn("Dedicated host has no capacity, launching on another host",
+			"instanceID", aws.ToString(instance.InstanceId),
+			"hostID", aws.ToString(runInput.Placement.HostId),
+			"error", err)
+		runInput.Placement = unpinned
+		runResult, err = s.client.RunInstances(ctx, runInput)
+	}
 	if err != nil {
-		return fmt.Errorf("run instances: %w", err)
+		return fail(fmt.Errorf("run instances: %w", err))
+	}
+	if len(runResult.Instances) == 0 {
+		if reuseIP {
+			return fail(fmt.Errorf("run instances: no instance created"))
+		}
+		return fail(fmt.Errorf("run instances: no instance created, leaving %s in place", aws.ToString(instance.InstanceId)))
+	}
+
+	// Only remove the old instance once the replacement is confirmed healthy
+	newInstanceID := aws.ToString(runResult.Instances[0].InstanceId)
+	opts.reportProgress(aws.ToString(instance.InstanceId), ProgressLaunched, newInstanceID)
+	// discard terminates a replacement that didn't come up healthy so it
+	// isn't left running next to the old instance
+	discard := func(err error) (string, []MigrationSnapshot, error) {
+		logger.Info("Terminating unhealthy replacement instance", "instanceID", newInstanceID)
+		cleanupCtx, cancel := detachedContext(ctx)
+		defer cancel()
+		if termErr := s.terminateInstance(cleanupCtx, runResult.Instances[0]); termErr != nil {
+			return fail(fmt.Errorf("%w (%s is still running: %v)", err, newInstanceID, termErr))
+		}
+		return fail(fmt.Errorf("%w (terminated %s)", err, newInstanceID))
+	}
+	if err := s.waitForInstanceHealthy(ctx, newInstanceID); err != nil {
+		// The old instance is already gone, so the replacement is kept
+		if reuseIP {
+			return fail(fmt.Errorf("new instance %s is not healthy: %w", newInstanceID, err))
+		}
+		return discard(fmt.Errorf("new instance %s is not healthy, leaving %s in place: %w",
+			newInstanceID, aws.ToString(instance.InstanceId), err))
+	}
+	// Without a launch template the profile is already part of the launch request
+	if runInput.IamInstanceProfile == nil {
+		if err := s.restoreInstanceProfile(ctx, instance, runResult.Instances[0]); err != nil {
+			return fail(err)
+		}
 	}
+	if err := s.runHealthCheck(ctx, runResult.Instances[0], opts.HealthCheck); err != nil {
+		if reuseIP {
+			return fail(fmt.Errorf("new instance %s failed its health check: %w", newInstanceID, err))
+		}
+		return discard(fmt.Errorf("new instance %s failed its health check, leaving %s in place: %w",
+			newInstanceID, aws.ToString(instance.InstanceId), err))
+	}
+	if opts.RetainOldInstance {
+		// The old instance was stopped before it was snapshotted
+		if address, err = s.retainOldInstance(ctx, instance, newInstanceID); err != nil {
+			return fail(err)
+		}
+		opts.reportProgress(aws.ToString(instance.InstanceId), ProgressRetained, newInstanceID)
+	} else if !reuseIP {
+		if err := terminateOld(); err != nil {
+			return fail(err)
+		}
+		opts.reportProgress(aws.ToString(instance.InstanceId), ProgressTerminated, "")
+	}
+
+	// Give the replacement the old instance's public address
+	if err := s.attachElasticIP(ctx, address, newInstanceID); err != nil {
+		return fail(err)
+	}
+	address = nil
 
-	// Terminate old instance
-	_, err = s.client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+	// Copy tags to new instance, telling it apart by name if asked to
+	source := instance
+	source.Tags = withNameSuffix(instance.Tags, opts.NameSuffix)
+	if err := s.copyTags(ctx, source, runResult.Instances[0], opts.tagCopyRules()); err != nil {
+		return fail(fmt.Errorf("copy tags: %w", err))
+	}
+	if err := s.copyVolumeTags(ctx, runResult.Instances[0], volumeTags); err != nil {
+		return fail(fmt.Errorf("copy volume tags: %w", err))
+	}
+	if protectionRemoved {
+		if err := s.setTerminationProtection(ctx, newInstanceID, true); err != nil {
+			return fail(fmt.Errorf("restore termination protection: %w", err))
+		}
+	}
+
+	return newInstanceID, migrationSnapshotRecords(instance, snapshotIDs), nil
+}
+
+func (s *Service) terminateInstance(ctx context.Context, instance types.Instance) error {
+	_, err := s.client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
 		InstanceIds: []string{aws.ToString(instance.InstanceId)},
 	})
 	if err != nil {
 		return fmt.Errorf("terminate instance: %w", err)
 	}
-
-	// Copy tags to new instance
-	if err := s.copyTags(ctx, instance, runResult.Instances[0]); err != nil {
-		return fmt.Errorf("copy tags: %w", err)
-	}
-
 	return nil
 }
 
-func (s *Service) copyTags(ctx context.Context, oldInstance, newInstance types.Instance) error {
-	var tags []types.Tag
-	for _, tag := range oldInstance.Tags {
-		// Skip the migration status tag
-		if aws.ToString(tag.Key) == "ami-migrate-status" {
-			continue
+// applyNetworking carries the source instance's subnet, security groups and
+// instance profile over to the replacement's launch request
+func applyNetworking(runInput *ec2.RunInstancesInput, instance types.Instance) {
+	runInput.SubnetId = instance.SubnetId
+	for _, group := range instance.SecurityGroups {
+		if group.GroupId != nil {
+			runInput.SecurityGroupIds = append(runInput.SecurityGroupIds, aws.ToString(group.GroupId))
+		}
+	}
+	if instance.IamInstanceProfile != nil && instance.IamInstanceProfile.Arn != nil {
+		runInput.IamInstanceProfile = &types.IamInstanceProfileSpecification{
+			Arn: instance.IamInstanceProfile.Arn,
 		}
-		tags = append(tags, tag)
+	}
+}
+
+// copyTags puts oldInstance's tags, as narrowed by rules, on newInstance
+func (s *Service) copyTags(ctx context.Context, oldInstance, newInstance types.Instance, rules tagCopyRules) error {
+	tags := s.copiedTags(oldInstance.Tags, rules)
+	if len(tags) == 0 {
+		return nil
 	}
 
 	input := &ec2.CreateTagsInput{
@@ -290,31 +1246,43 @@ func (s *Service) copyTags(ctx context.Context, oldInstance, newInstance types.I
 	return err
 }
 
-func (s *Service) tagInstanceStatus(ctx context.Context, instance types.Instance, status, message string) error {
+// tagInstanceStatus records status and message on the instance, along with
+// any extra tags. With WithStatusTagVerification it returns once the tags
+// can be read back, or the reads run out.
+func (s *Service) tagInstanceStatus(ctx context.Context, instance types.Instance, status, message string, extra ...types.Tag) error {
 	input := &ec2.CreateTagsInput{
 		Resources: []string{aws.ToString(instance.InstanceId)},
 		Tags: []types.Tag{
 			{
-				Key:   aws.String("ami-migrate-status"),
+				Key:   aws.String(s.tags.Status),
 				Value: aws.String(status),
 			},
 			{
-				Key:   aws.String("ami-migrate-message"),
+				Key:   aws.String(s.tags.Message),
 				Value: aws.String(message),
 			},
 			{
-				Key:   aws.String("ami-migrate-timestamp"),
+				Key:   aws.String(s.tags.Timestamp),
 				Value: aws.String(time.Now().UTC().Format(time.RFC3339)),
 			},
 		},
 	}
+	input.Tags = append(input.Tags, extra...)
 
-	_, err := s.client.CreateTags(ctx, input)
-	return err
+	if _, err := s.client.CreateTags(ctx, input); err != nil {
+		return err
+	}
+	if s.tagVerifyAttempts > 0 {
+		// The tags were written, so a slow read doesn't fail the caller
+		if err := s.verifyStatusTags(ctx, aws.ToString(instance.InstanceId), input.Tags); err != nil {
+			logger.Warn("Failed to verify status tags", "instanceID", aws.ToString(instance.InstanceId), "status", status, "error", err)
+		}
+	}
+	return nil
 }
 
 func (s *Service) BackupInstances(ctx context.Context, enabledValue string) error {
-	// Get instances with ami-migrate tag
+	// Get instances with the enabled tag
 	instances, err := s.getInstances(ctx, enabledValue)
 	if err != nil {
 		return fmt.Errorf("failed to get instances: %w", err)
@@ -324,8 +1292,8 @@ func (s *Service) BackupInstances(ctx context.Context, enabledValue string) erro
 		// Check if instance should be backed up based on state
 		if string(instance.State.Name) == string(types.InstanceStateNameRunning) {
 			// Check if running instance has the required tag
-			if !hasTag(instance.Tags, "ami-migrate-if-running", enabledValue) {
-				s.tagInstanceStatus(ctx, instance, "skipped", "Running instance without ami-migrate-if-running tag")
+			if !hasTag(instance.Tags, s.tags.IfRunning, enabledValue) {
+				s.tagInstanceStatus(ctx, instance, "skipped", fmt.Sprintf("Running instance without %s tag", s.tags.IfRunning))
 				continue
 			}
 		}
@@ -333,40 +1301,24 @@ func (s *Service) BackupInstances(ctx context.Context, enabledValue string) erro
 		s.tagInstanceStatus(ctx, instance, "in-progress", "Creating volume snapshots")
 
 		// Create snapshots for each volume
-		for _, device := range instance.BlockDeviceMappings {
-			if device.Ebs == nil {
-				continue
-			}
-
+		_, err := s.snapshotVolumes(ctx, instance, instance.BlockDeviceMappings, func(device types.InstanceBlockDeviceMapping) (string, []types.Tag) {
 			description := fmt.Sprintf("Backup of volume %s from instance %s",
 				aws.ToString(device.Ebs.VolumeId),
 				aws.ToString(instance.InstanceId))
-
-			input := &ec2.CreateSnapshotInput{
-				VolumeId:    device.Ebs.VolumeId,
-				Description: aws.String(description),
-				TagSpecifications: []types.TagSpecification{
-					{
-						ResourceType: types.ResourceTypeSnapshot,
-						Tags: []types.Tag{
-							{
-								Key:   aws.String("ami-migrate-instance"),
-								Value: instance.InstanceId,
-							},
-							{
-								Key:   aws.String("ami-migrate-device"),
-								Value: device.DeviceName,
-							},
-						},
-					},
+			return description, []types.Tag{
+				{
+					Key:   aws.String("ami-migrate-instance"),
+					Value: instance.InstanceId,
+				},
+				{
+					Key:   aws.String("ami-migrate-device"),
+					Value: device.DeviceName,
 				},
 			}
-
-			_, err := s.client.CreateSnapshot(ctx, input)
-			if err != nil {
-				s.tagInstanceStatus(ctx, instance, "failed", fmt.Sprintf("Failed to create snapshot: %v", err))
-				return fmt.Errorf("failed to create snapshot: %w", err)
-			}
+		})
+		if err != nil {
+			s.tagInstanceStatus(ctx, instance, "failed", fmt.Sprintf("Failed to create snapshot: %v", err))
+			return fmt.Errorf("failed to create snapshot: %w", err)
 		}
 
 		s.tagInstanceStatus(ctx, instance, "completed", "Volume snapshots created successfully")
@@ -375,154 +1327,149 @@ func (s *Service) BackupInstances(ctx context.Context, enabledValue string) erro
 	return nil
 }
 
-func (s *Service) RestoreInstance(ctx context.Context, instanceID, snapshotID string) error {
-	// Get instance
+func (s *Service) getInstances(ctx context.Context, enabledValue string) ([]types.Instance, error) {
 	input := &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceID},
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:" + s.tags.Enabled),
+				Values: []string{enabledValue},
+			},
+		},
 	}
-	result, err := s.client.DescribeInstances(ctx, input)
+
+	instances, err := s.describeInstances(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to get instance: %w", err)
-	}
-	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
-		return fmt.Errorf("instance not found: %s", instanceID)
+		return nil, fmt.Errorf("describe instances: %w", err)
 	}
-	instance := result.Reservations[0].Instances[0]
 
-	// Get snapshot
-	snapInput := &ec2.DescribeSnapshotsInput{
-		SnapshotIds: []string{snapshotID},
-	}
-	snapResult, err := s.client.DescribeSnapshots(ctx, snapInput)
+	return instances, nil
+}
+
+// MigrateInstance migrates a single instance to newAMI. The instance must carry
+// the enabled tag (ami-migrate=enabled by default), and a running instance also
+// needs the if-running tag set to enabled. An instance already on newAMI is reported as
+// skipped.
+func (s *Service) MigrateInstance(ctx context.Context, instanceID string, newAMI string) (*InstanceResult, error) {
+	instance, err := s.getInstance(ctx, instanceID)
 	if err != nil {
-		return fmt.Errorf("failed to get snapshot: %w", err)
+		return nil, fmt.Errorf("get instance: %w", err)
 	}
-	if len(snapResult.Snapshots) == 0 {
-		return fmt.Errorf("snapshot not found: %s", snapshotID)
-	}
-	snapshot := snapResult.Snapshots[0]
 
-	// Create volume from snapshot
-	createVolumeInput := &ec2.CreateVolumeInput{
-		AvailabilityZone: instance.Placement.AvailabilityZone,
-		SnapshotId:       aws.String(snapshotID),
-		VolumeType:       types.VolumeTypeGp2, // Use GP2 by default
-	}
-	volume, err := s.client.CreateVolume(ctx, createVolumeInput)
-	if err != nil {
-		return fmt.Errorf("failed to create volume: %w", err)
+	if err := s.checkMigrationTags(instance, false); err != nil {
+		return nil, err
 	}
 
-	// Get EC2 client for waiter
-	ec2Client, err := client.GetEC2Client(ctx)
+	return s.migrateInstance(ctx, instance, newAMI, MigrateOptions{})
+}
+
+// MigrateInstanceWithOptions migrates a single instance like MigrateInstance,
+// applying opts. When opts.NewAMI is empty the latest AMI for the instance's OS
+// type is used.
+func (s *Service) MigrateInstanceWithOptions(ctx context.Context, instanceID string, opts MigrateOptions) (*InstanceResult, error) {
+	instance, err := s.getInstance(ctx, instanceID)
 	if err != nil {
-		return fmt.Errorf("failed to get EC2 client for waiter: %w", err)
+		return nil, fmt.Errorf("get instance: %w", err)
 	}
 
-	// Wait for volume to be available
-	waiter := ec2.NewVolumeAvailableWaiter(ec2Client)
-	if err := waiter.Wait(ctx, &ec2.DescribeVolumesInput{
-		VolumeIds: []string{aws.ToString(volume.VolumeId)},
-	}, config.GetTimeout()); err != nil {
-		return fmt.Errorf("volume did not become available: %w", err)
+	if err := s.checkMigrationTags(instance, opts.Force); err != nil {
+		return nil, err
 	}
 
-	// Stop instance if running
-	if string(instance.State.Name) == string(types.InstanceStateNameRunning) {
-		stopInput := &ec2.StopInstancesInput{
-			InstanceIds: []string{instanceID},
-		}
-		if _, err := s.client.StopInstances(ctx, stopInput); err != nil {
-			return fmt.Errorf("failed to stop instance: %w", err)
-		}
+	targetAMI, err := s.resolveTargetAMI(ctx, instanceID, opts)
+	if err != nil {
+		return nil, err
+	}
 
-		// Get EC2 client for waiter
-		ec2Client, err := client.GetEC2Client(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get EC2 client for waiter: %w", err)
-		}
+	result, err := s.migrateInstance(ctx, instance, targetAMI, opts)
+	s.putMigrationMetrics(ctx, []InstanceResult{*result}, opts)
+	return result, err
+}
 
-		// Wait for instance to stop
-		stopWaiter := ec2.NewInstanceStoppedWaiter(ec2Client)
-		if err := stopWaiter.Wait(ctx, &ec2.DescribeInstancesInput{
-			InstanceIds: []string{instanceID},
-		}, config.GetTimeout()); err != nil {
-			return fmt.Errorf("instance did not stop: %w", err)
-		}
+// checkMigrationTags returns an error unless the instance is tagged for
+// migration and inside its maintenance window, if it has one. Any non-empty
+// enabled tag value counts, as batch runs may select on values other than
+// enabled. With force a running instance doesn't need the if-running tag.
+func (s *Service) checkMigrationTags(instance types.Instance, force bool) error {
+	instanceID := aws.ToString(instance.InstanceId)
+	if tagValue(instance.Tags, s.tags.Enabled) == "" {
+		return fmt.Errorf("instance %s is not enabled for migration: missing %s tag", instanceID, s.tags.Enabled)
 	}
-
-	// Get device name from snapshot tags
-	var deviceName string
-	for _, tag := range snapshot.Tags {
-		if aws.ToString(tag.Key) == "ami-migrate-device" {
-			deviceName = aws.ToString(tag.Value)
-			break
-		}
+	if instance.State == nil {
+		return fmt.Errorf("instance %s has no state", instanceID)
 	}
-	if deviceName == "" {
-		deviceName = "/dev/xvdf" // default device if not found
+	if state := transitionalState(instance); state != "" {
+		return fmt.Errorf("instance %s is %s, wait for it to settle before migrating", instanceID, state)
 	}
-
-	// Attach volume
-	attachInput := &ec2.AttachVolumeInput{
-		Device:     aws.String(deviceName),
-		InstanceId: aws.String(instanceID),
-		VolumeId:   volume.VolumeId,
+	if group := autoScalingGroup(instance); group != "" {
+		return fmt.Errorf("instance %s is managed by Auto Scaling group %s, which would replace it once terminated: update the group's launch template instead", instanceID, group)
 	}
-	if _, err := s.client.AttachVolume(ctx, attachInput); err != nil {
-		return fmt.Errorf("failed to attach volume: %w", err)
+	if reason := s.windowSkipReason(instance); reason != "" {
+		return fmt.Errorf("instance %s can't be migrated now: %s", instanceID, reason)
+	}
+	if migrate, reason := s.shouldMigrateInstance(instance, "", force); !migrate {
+		return fmt.Errorf("instance %s can't be migrated: %s", instanceID, reason)
 	}
-
 	return nil
 }
 
-func (s *Service) getInstances(ctx context.Context, enabledValue string) ([]types.Instance, error) {
-	input := &ec2.DescribeInstancesInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("tag:ami-migrate"),
-				Values: []string{enabledValue},
-			},
-		},
-	}
+// migrateInstance migrates the instance to newAMI. On failure the returned
+// result records the failure alongside the error.
+func (s *Service) migrateInstance(ctx context.Context, instance types.Instance, newAMI string, opts MigrateOptions) (*InstanceResult, error) {
+	instanceID := aws.ToString(instance.InstanceId)
+	logger.Info("Starting instance migration", "instanceID", instanceID, "newAMI", newAMI)
 
-	result, err := s.client.DescribeInstances(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("describe instances: %w", err)
+	start := time.Now()
+	result := &InstanceResult{
+		InstanceID: instanceID,
+		OldAMI:     aws.ToString(instance.ImageId),
+		NewAMI:     newAMI,
 	}
 
-	var instances []types.Instance
-	for _, reservation := range result.Reservations {
-		instances = append(instances, reservation.Instances...)
+	if result.OldAMI == newAMI {
+		result.Status = StatusSkipped
+		result.Message = skipReasonAlreadyMigrated
+		return result, nil
 	}
 
-	return instances, nil
-}
-
-func (s *Service) MigrateInstance(ctx context.Context, instanceID string, newAMI string) error {
-	logger.Info("Starting instance migration", "instanceID", instanceID, "newAMI", newAMI)
-
-	// Get the instance
-	instance, err := s.getInstance(ctx, instanceID)
+	// Perform the migration, or only its snapshots
+	opts.reportProgress(instanceID, ProgressStarted, s.migrationMessage(instance, "Migrating", newAMI, opts))
+	var newInstanceID string
+	var snapshots []MigrationSnapshot
+	var err error
+	if opts.SnapshotOnly {
+		snapshots, err = s.stageSnapshots(ctx, instance, newAMI, opts)
+	} else {
+		newInstanceID, snapshots, err = s.migrateInstanceToAMI(ctx, instance, newAMI, opts)
+	}
+	result.Duration = time.Since(start)
 	if err != nil {
-		return fmt.Errorf("get instance: %w", err)
+		err = classifyError(err)
+		result.Status = StatusFailed
+		result.Message = err.Error()
+		var snapshotsErr *SnapshotsError
+		if errors.As(err, &snapshotsErr) {
+			result.KeptSnapshots = snapshotsErr.KeptSnapshotIDs()
+		}
+		return result, err
 	}
 
-	// Get the current AMI ID
-	currentAMI := aws.ToString(instance.ImageId)
-	if currentAMI == newAMI {
-		return nil // Already on target AMI
+	result.Snapshots = snapshots
+	if opts.SnapshotOnly {
+		result.Status = StatusSnapshotted
+		result.Message = s.migrationMessage(instance, "Snapshotted for migration", newAMI, opts)
+		return result, nil
 	}
-
-	// Perform the migration
-	return s.migrateInstanceToAMI(ctx, instance, newAMI)
+	result.Status = StatusCompleted
+	result.NewInstanceID = newInstanceID
+	result.Message = s.migrationMessage(instance, "Migrated", newAMI, opts)
+	return result, nil
 }
 
 func (s *Service) GetLatestAMI(ctx context.Context, osType string) (string, error) {
 	input := &ec2.DescribeImagesInput{
 		Filters: []types.Filter{
 			{
-				Name:   aws.String("tag:ami-migrate"),
+				Name:   aws.String("tag:" + s.tags.Enabled),
 				Values: []string{"latest"},
 			},
 			{
@@ -532,22 +1479,17 @@ func (s *Service) GetLatestAMI(ctx context.Context, osType string) (string, erro
 		},
 	}
 
-	result, err := s.client.DescribeImages(ctx, input)
+	images, err := s.describeImages(ctx, input)
 	if err != nil {
 		return "", fmt.Errorf("describe images: %w", err)
 	}
 
-	if len(result.Images) == 0 {
-		return "", fmt.Errorf("no AMI found for OS type: %s", osType)
+	if len(images) == 0 {
+		return "", fmt.Errorf("%w for OS type: %s", ErrAMINotFound, osType)
 	}
 
-	// Sort images by creation date to get the most recent one
-	latestImage := result.Images[0]
-	for _, image := range result.Images[1:] {
-		if aws.ToString(image.CreationDate) > aws.ToString(latestImage.CreationDate) {
-			latestImage = image
-		}
-	}
+	// Pick the most recent image
+	latestImage := newestImage(images)
 
 	return aws.ToString(latestImage.ImageId), nil
 }
@@ -559,11 +1501,11 @@ func (s *Service) GetInstanceOSType(ctx context.Context, instanceID string) (str
 
 	result, err := s.client.DescribeInstances(ctx, input)
 	if err != nil {
-		return "", fmt.Errorf("describe instance: %w", err)
+		return "", classifyError(fmt.Errorf("describe instance: %w", err))
 	}
 
 	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
-		return "", fmt.Errorf("instance not found: %s", instanceID)
+		return "", fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
 	}
 
 	instance := result.Reservations[0].Instances[0]
@@ -618,111 +1560,112 @@ func (s *Service) getInstance(ctx context.Context, instanceID string) (types.Ins
 
 	result, err := s.client.DescribeInstances(ctx, input)
 	if err != nil {
-		return types.Instance{}, fmt.Errorf("describe instance: %w", err)
+		return types.Instance{}, classifyError(fmt.Errorf("describe instance: %w", err))
 	}
 
 	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
-		return types.Instance{}, fmt.Errorf("instance not found: %s", instanceID)
+		return types.Instance{}, fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
 	}
 
 	return result.Reservations[0].Instances[0], nil
 }
 
-func (s *Service) migrateInstanceToAMI(ctx context.Context, instance types.Instance, newAMI string) error {
+func (s *Service) migrateInstanceToAMI(ctx context.Context, instance types.Instance, newAMI string, opts MigrateOptions) (string, []MigrationSnapshot, error) {
+	// Stopping, snapshotting and launching all wait, and together they get
+	// no more than the instance's timeout
+	ctx, cancel := s.withWaitBudget(ctx, instance)
+	defer cancel()
+
+	// Check the replacement's instance type can run the AMI before touching the instance
+	if err := s.checkInstanceTypeArchitecture(ctx, opts.instanceTypeFor(instance), newAMI); err != nil {
+		s.tagMigrationFailed(ctx, instance, err)
+		return "", nil, err
+	}
+	// A protected instance couldn't be terminated once it has been replaced
+	if err := s.checkTerminationProtection(ctx, instance, opts); err != nil {
+		s.tagMigrationFailed(ctx, instance, err)
+		return "", nil, err
+	}
+
 	// Tag the instance to indicate migration is in progress
-	err := s.tagInstanceStatus(ctx, instance, "migrating", fmt.Sprintf("Migrating to AMI: %s", newAMI))
+	err := s.tagInstanceStatus(ctx, instance, "migrating", s.migrationMessage(instance, "Migrating", newAMI, opts))
 	if err != nil {
-		return fmt.Errorf("tag instance status: %w", err)
+		return "", nil, fmt.Errorf("tag instance status: %w", err)
 	}
 
-	// Stop the instance if it's running
+	// Stop the instance if it's running, giving its applications a chance to shut down first
 	if instance.State != nil && instance.State.Name == types.InstanceStateNameRunning {
+		if err := s.runPreStopHook(ctx, instance, opts.PreStopHook); err != nil {
+			err = fmt.Errorf("pre-stop hook: %w", err)
+			s.tagMigrationFailed(ctx, instance, err)
+			return "", nil, err
+		}
 		if err := s.stopInstance(ctx, instance); err != nil {
-			return fmt.Errorf("stop instance: %w", err)
+			err = fmt.Errorf("stop instance: %w", err)
+			s.tagMigrationFailed(ctx, instance, err)
+			return "", nil, err
 		}
+		opts.reportProgress(aws.ToString(instance.InstanceId), ProgressStopped, "")
 	}
 
 	// Perform the upgrade
-	if err := s.upgradeInstance(ctx, instance, newAMI); err != nil {
-		s.tagInstanceStatus(ctx, instance, "failed", fmt.Sprintf("Migration failed: %v", err))
-		return fmt.Errorf("upgrade instance: %w", err)
+	newInstanceID, snapshots, err := s.upgradeInstance(ctx, instance, newAMI, opts)
+	if err != nil {
+		s.tagMigrationFailed(ctx, instance, err)
+		return "", nil, fmt.Errorf("upgrade instance: %w", err)
 	}
 
 	// Tag the instance as successfully migrated
-	return s.tagInstanceStatus(ctx, instance, "completed", fmt.Sprintf("Migrated to AMI: %s", newAMI))
+	if err := s.tagMigrationOutcome(ctx, instance, newInstanceID, StatusCompleted, s.migrationMessage(instance, "Migrated", newAMI, opts)); err != nil {
+		return "", nil, err
+	}
+	return newInstanceID, snapshots, nil
 }
 
-func (s *Service) BackupInstance(ctx context.Context, instanceID string) error {
-	logger.Info("Starting instance backup", "instanceID", instanceID)
-
-	// Get instance details
-	instance, err := s.getInstance(ctx, instanceID)
-	if err != nil {
-		logger.Error("Failed to get instance", "instanceID", instanceID, "error", err)
-		return fmt.Errorf("failed to get instance: %v", err)
+// tagMigrationFailed records a failed migration attempt on the instance. The
+// failure is recorded even when it was caused by ctx being cancelled.
+func (s *Service) tagMigrationFailed(ctx context.Context, instance types.Instance, err error) {
+	tagCtx, cancel := detachedContext(ctx)
+	defer cancel()
+	if tagErr := s.tagMigrationOutcome(tagCtx, instance, "", StatusFailed, fmt.Sprintf("Migration failed: %v", err)); tagErr != nil {
+		logger.Error("Failed to tag failed instance", "instanceID", aws.ToString(instance.InstanceId), "error", tagErr)
 	}
-
-	// Create snapshots for each volume
-	for _, blockDevice := range instance.BlockDeviceMappings {
-		if blockDevice.Ebs != nil {
-			volumeID := aws.ToString(blockDevice.Ebs.VolumeId)
-			deviceName := aws.ToString(blockDevice.DeviceName)
-			logger.Debug("Creating snapshot for volume", "instanceID", instanceID, "volumeID", volumeID, "deviceName", deviceName)
-
-			input := &ec2.CreateSnapshotInput{
-				VolumeId:    aws.String(volumeID),
-				Description: aws.String(fmt.Sprintf("Backup of volume %s from instance %s", volumeID, instanceID)),
-				TagSpecifications: []types.TagSpecification{
-					{
-						ResourceType: types.ResourceTypeSnapshot,
-						Tags: []types.Tag{
-							{
-								Key:   aws.String("Name"),
-								Value: aws.String(fmt.Sprintf("Backup-%s-%s", instanceID, time.Now().Format("2006-01-02"))),
-							},
-							{
-								Key:   aws.String("InstanceID"),
-								Value: aws.String(instanceID),
-							},
-						},
-					},
-				},
-			}
-
-			_, err := s.client.CreateSnapshot(ctx, input)
-			if err != nil {
-				logger.Error("Failed to create snapshot", "instanceID", instanceID, "volumeID", volumeID, "error", err)
-				return fmt.Errorf("failed to create snapshot for volume %s: %v", volumeID, err)
-			}
-			logger.Info("Created snapshot for volume", "instanceID", instanceID, "volumeID", volumeID)
-		}
-	}
-
-	logger.Info("Instance backup completed successfully", "instanceID", instanceID)
-	return nil
 }
 
 // InstanceConfig holds configuration for creating a new instance
 type InstanceConfig struct {
-	Name     string
-	OSType   string
-	Size     string
-	UserID   string
+	Name   string
+	OSType string
+	Size   string
+	UserID string
+	// AMIID launches from this AMI instead of the latest AMI for OSType
+	AMIID string
+	// InstanceType is used instead of Size when set
+	InstanceType     string
+	SubnetID         string
+	SecurityGroupIDs []string
+	KeyName          string
+	// Tags are added alongside the standard Name, Owner and ami-migrate tags
+	Tags map[string]string
+	// LaunchTemplate, when set, launches the instance from this template.
+	// AMIID, OSType, InstanceType, Size and the networking fields override
+	// the template's settings when given; otherwise the template's are used.
+	LaunchTemplate *LaunchTemplate
 }
 
 // InstanceSummary contains information about an instance
 type InstanceSummary struct {
-	InstanceID   string
-	Name         string
-	OSType       string
-	Size         string
-	State        string
-	LaunchTime   time.Time
-	PrivateIP    string
-	PublicIP     string
-	CurrentAMI   string
-	LatestAMI    string
-	NeedsMigrate bool
+	InstanceID   string    `json:"instance_id"`
+	Name         string    `json:"name"`
+	OSType       string    `json:"os_type"`
+	Size         string    `json:"size"`
+	State        string    `json:"state"`
+	LaunchTime   time.Time `json:"launch_time"`
+	PrivateIP    string    `json:"private_ip,omitempty"`
+	PublicIP     string    `json:"public_ip,omitempty"`
+	CurrentAMI   string    `json:"current_ami"`
+	LatestAMI    string    `json:"latest_ami,omitempty"`
+	NeedsMigrate bool      `json:"needs_migrate"`
 }
 
 // ListUserInstances lists all instances owned by the user
@@ -736,97 +1679,136 @@ func (s *Service) ListUserInstances(ctx context.Context, userID string) ([]Insta
 		},
 	}
 
-	result, err := s.client.DescribeInstances(ctx, input)
+	instances, err := s.describeInstances(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("describe instances: %w", err)
 	}
 
 	var summaries []InstanceSummary
-	for _, reservation := range result.Reservations {
-		for _, instance := range reservation.Instances {
-			instanceID := aws.ToString(instance.InstanceId)
-			
-			// Get OS type
-			osType, err := s.GetInstanceOSType(ctx, instanceID)
-			if err != nil {
-				osType = "unknown"
-			}
+	for _, instance := range instances {
+		instanceID := aws.ToString(instance.InstanceId)
 
-			// Get latest AMI
-			latestAMI, err := s.GetLatestAMI(ctx, osType)
-			if err != nil {
-				latestAMI = "unknown"
-			}
+		// Get OS type
+		osType, err := s.GetInstanceOSType(ctx, instanceID)
+		if err != nil {
+			osType = "unknown"
+		}
 
-			// Get instance name from tags
-			name := instanceID
-			for _, tag := range instance.Tags {
-				if aws.ToString(tag.Key) == "Name" {
-					name = aws.ToString(tag.Value)
-					break
-				}
-			}
+		// Get latest AMI
+		latestAMI, err := s.GetLatestAMI(ctx, osType)
+		if errors.Is(err, ErrAMINotFound) {
+			latestAMI = "unknown"
+		} else if err != nil {
+			return nil, fmt.Errorf("get latest AMI for %s: %w", instanceID, err)
+		}
 
-			summary := InstanceSummary{
-				InstanceID:   instanceID,
-				Name:         name,
-				OSType:       osType,
-				Size:         string(instance.InstanceType),
-				State:        string(instance.State.Name),
-				LaunchTime:   aws.ToTime(instance.LaunchTime),
-				PrivateIP:    aws.ToString(instance.PrivateIpAddress),
-				PublicIP:     aws.ToString(instance.PublicIpAddress),
-				CurrentAMI:   aws.ToString(instance.ImageId),
-				LatestAMI:    latestAMI,
-				NeedsMigrate: aws.ToString(instance.ImageId) != latestAMI,
+		// Get instance name from tags
+		name := instanceID
+		for _, tag := range instance.Tags {
+			if aws.ToString(tag.Key) == "Name" {
+				name = aws.ToString(tag.Value)
+				break
 			}
-			summaries = append(summaries, summary)
 		}
+
+		summary := InstanceSummary{
+			InstanceID:   instanceID,
+			Name:         name,
+			OSType:       osType,
+			Size:         string(instance.InstanceType),
+			State:        string(instance.State.Name),
+			LaunchTime:   aws.ToTime(instance.LaunchTime),
+			PrivateIP:    aws.ToString(instance.PrivateIpAddress),
+			PublicIP:     aws.ToString(instance.PublicIpAddress),
+			CurrentAMI:   aws.ToString(instance.ImageId),
+			LatestAMI:    latestAMI,
+			NeedsMigrate: aws.ToString(instance.ImageId) != latestAMI,
+		}
+		summaries = append(summaries, summary)
 	}
 
 	return summaries, nil
 }
 
-// CreateInstance creates a new EC2 instance for the user
+// CreateInstance launches a new instance from config, tagged so it is
+// enrolled in migration, and waits for it to be running
 func (s *Service) CreateInstance(ctx context.Context, config InstanceConfig) (*InstanceSummary, error) {
-	// Get the latest AMI for the OS type
-	amiID, err := s.GetLatestAMI(ctx, config.OSType)
-	if err != nil {
-		return nil, fmt.Errorf("get latest AMI: %w", err)
+	// Get the latest AMI for the OS type unless one was given. A launch
+	// template without either launches the template's own AMI.
+	amiID := config.AMIID
+	if amiID == "" && (config.LaunchTemplate == nil || config.OSType != "") {
+		latestAMI, err := s.GetLatestAMI(ctx, config.OSType)
+		if err != nil {
+			return nil, fmt.Errorf("get latest AMI: %w", err)
+		}
+		amiID = latestAMI
 	}
 
 	// Map size to instance type
-	instanceType, err := s.mapSizeToInstanceType(config.Size)
-	if err != nil {
-		return nil, err
+	instanceType := types.InstanceType(config.InstanceType)
+	if instanceType == "" && (config.LaunchTemplate == nil || config.Size != "") {
+		mapped, err := s.mapSizeToInstanceType(config.Size)
+		if err != nil {
+			return nil, err
+		}
+		instanceType = mapped
+	}
+
+	tags := []types.Tag{
+		{
+			Key:   aws.String("Name"),
+			Value: aws.String(config.Name),
+		},
+		{
+			Key:   aws.String("Owner"),
+			Value: aws.String(config.UserID),
+		},
+		{
+			Key:   aws.String(s.tags.Enabled),
+			Value: aws.String("enabled"),
+		},
+	}
+	keys := make([]string, 0, len(config.Tags))
+	for key := range config.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// The standard tags can't be overridden
+		if tagValue(tags, key) != "" {
+			continue
+		}
+		tags = append(tags, types.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(config.Tags[key]),
+		})
 	}
 
 	// Create the instance
 	input := &ec2.RunInstancesInput{
-		ImageId:      aws.String(amiID),
-		InstanceType: instanceType,
-		MinCount:     aws.Int32(1),
-		MaxCount:     aws.Int32(1),
+		InstanceType:   instanceType,
+		MinCount:       aws.Int32(1),
+		MaxCount:       aws.Int32(1),
+		LaunchTemplate: config.LaunchTemplate.specification(),
 		TagSpecifications: []types.TagSpecification{
 			{
 				ResourceType: types.ResourceTypeInstance,
-				Tags: []types.Tag{
-					{
-						Key:   aws.String("Name"),
-						Value: aws.String(config.Name),
-					},
-					{
-						Key:   aws.String("Owner"),
-						Value: aws.String(config.UserID),
-					},
-					{
-						Key:   aws.String("ami-migrate"),
-						Value: aws.String("enabled"),
-					},
-				},
+				Tags:         tags,
 			},
 		},
 	}
+	if amiID != "" {
+		input.ImageId = aws.String(amiID)
+	}
+	if config.SubnetID != "" {
+		input.SubnetId = aws.String(config.SubnetID)
+	}
+	if len(config.SecurityGroupIDs) > 0 {
+		input.SecurityGroupIds = config.SecurityGroupIDs
+	}
+	if config.KeyName != "" {
+		input.KeyName = aws.String(config.KeyName)
+	}
 
 	result, err := s.client.RunInstances(ctx, input)
 	if err != nil {
@@ -837,13 +1819,30 @@ func (s *Service) CreateInstance(ctx context.Context, config InstanceConfig) (*I
 		return nil, fmt.Errorf("no instance created")
 	}
 
-	instance := result.Instances[0]
+	instanceID := aws.ToString(result.Instances[0].InstanceId)
+	if err := s.waitForInstanceState(ctx, instanceID, types.InstanceStateNameRunning); err != nil {
+		return nil, fmt.Errorf("wait for instance %s to be running: %w", instanceID, err)
+	}
+
+	// Describe the instance again now that it has its addresses
+	instance, err := s.getInstance(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("get instance: %w", err)
+	}
+	// The template's AMI and type are only known once launched
+	if amiID == "" {
+		amiID = aws.ToString(instance.ImageId)
+	}
+	if instanceType == "" {
+		instanceType = instance.InstanceType
+	}
+
 	summary := InstanceSummary{
-		InstanceID:   aws.ToString(instance.InstanceId),
+		InstanceID:   instanceID,
 		Name:         config.Name,
 		OSType:       config.OSType,
-		Size:         string(instance.InstanceType),
-		State:        string(instance.State.Name),
+		Size:         string(instanceType),
+		State:        string(types.InstanceStateNameRunning),
 		LaunchTime:   aws.ToTime(instance.LaunchTime),
 		PrivateIP:    aws.ToString(instance.PrivateIpAddress),
 		PublicIP:     aws.ToString(instance.PublicIpAddress),
@@ -855,7 +1854,6 @@ func (s *Service) CreateInstance(ctx context.Context, config InstanceConfig) (*I
 	return &summary, nil
 }
 
-// mapSizeToInstanceType maps a friendly size name to an EC2 instance type
 func (s *Service) mapSizeToInstanceType(size string) (types.InstanceType, error) {
 	switch strings.ToLower(size) {
 	case "small":
@@ -906,16 +1904,16 @@ func (s *Service) CheckMigrationStatus(ctx context.Context, userID string) (*Mig
 		},
 	}
 
-	result, err := s.client.DescribeInstances(ctx, input)
+	instances, err := s.describeInstances(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("describe instances: %w", err)
 	}
 
-	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+	if len(instances) == 0 {
 		return nil, fmt.Errorf("no instance found for user: %s", userID)
 	}
 
-	instance := result.Reservations[0].Instances[0]
+	instance := instances[0]
 	instanceID := aws.ToString(instance.InstanceId)
 
 	// Get instance OS type
@@ -963,26 +1961,26 @@ func (s *Service) CheckMigrationStatus(ctx context.Context, userID string) (*Mig
 
 // MigrationStatus contains information about an instance's migration status
 type MigrationStatus struct {
-	InstanceID      string
-	OSType          string
-	CurrentAMI      string
-	LatestAMI       string
-	NeedsMigration  bool
-	CurrentAMIInfo  *AMIDetails
-	LatestAMIInfo   *AMIDetails
-	InstanceState   string
-	InstanceType    string
-	LaunchTime      time.Time
-	PrivateIP       string
-	PublicIP        string
+	InstanceID     string      `json:"instance_id"`
+	OSType         string      `json:"os_type"`
+	CurrentAMI     string      `json:"current_ami"`
+	LatestAMI      string      `json:"latest_ami,omitempty"`
+	NeedsMigration bool        `json:"needs_migration"`
+	CurrentAMIInfo *AMIDetails `json:"current_ami_info,omitempty"`
+	LatestAMIInfo  *AMIDetails `json:"latest_ami_info,omitempty"`
+	InstanceState  string      `json:"instance_state"`
+	InstanceType   string      `json:"instance_type"`
+	LaunchTime     time.Time   `json:"launch_time"`
+	PrivateIP      string      `json:"private_ip,omitempty"`
+	PublicIP       string      `json:"public_ip,omitempty"`
 }
 
 // AMIDetails contains information about an AMI
 type AMIDetails struct {
-	Name        string
-	Description string
-	CreatedDate string
-	Tags        map[string]string
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	CreatedDate string            `json:"created_date"`
+	Tags        map[string]string `json:"tags,omitempty"`
 }
 
 func (s *Service) getAMIDetails(ctx context.Context, amiID string) (*AMIDetails, error) {
@@ -1098,56 +2096,90 @@ func hasTag(tags []types.Tag, key, value string) bool {
 	return false
 }
 
-type waiterInterface interface {
-	Wait(ctx context.Context, params *ec2.DescribeInstancesInput, maxWaitDur time.Duration) error
+// tagValue returns the value of the tag with the given key, or "" if it is not set
+func tagValue(tags []types.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
 }
 
-type runningWaiter struct {
-	*ec2.InstanceRunningWaiter
-}
+func (s *Service) waitForInstanceState(ctx context.Context, instanceID string, desiredState types.InstanceStateName) error {
+	switch desiredState {
+	case types.InstanceStateNameRunning, types.InstanceStateNameStopped, types.InstanceStateNameTerminated:
+	default:
+		return fmt.Errorf("unsupported instance state: %s", desiredState)
+	}
 
-func (w *runningWaiter) Wait(ctx context.Context, params *ec2.DescribeInstancesInput, maxWaitDur time.Duration) error {
-	return w.InstanceRunningWaiter.Wait(ctx, params, maxWaitDur)
-}
+	maxWaitTime, err := s.remainingWait(ctx)
+	if err != nil {
+		return fmt.Errorf("wait for instance %s to reach state %s: %w", instanceID, desiredState, err)
+	}
+	logger.Debug("Waiting up to", maxWaitTime, "for instance", instanceID, "to reach state", desiredState)
 
-type stoppedWaiter struct {
-	*ec2.InstanceStoppedWaiter
+	if err := s.waiter.WaitForState(ctx, instanceID, desiredState, maxWaitTime); err != nil {
+		return fmt.Errorf("wait for instance %s to reach state %s: %w%s",
+			instanceID, desiredState, err, s.instanceStateDiagnostic(ctx, instanceID))
+	}
+	return nil
 }
 
-func (w *stoppedWaiter) Wait(ctx context.Context, params *ec2.DescribeInstancesInput, maxWaitDur time.Duration) error {
-	return w.InstanceStoppedWaiter.Wait(ctx, params, maxWaitDur)
-}
+// instanceStateDiagnostic describes why the instance is in its current state,
+// e.g. " (state terminated: Server.InsufficientInstanceCapacity: Insufficient
+// capacity.)", to explain a failed wait. It returns "" when the instance can't
+// be described or EC2 gives no reason.
+func (s *Service) instanceStateDiagnostic(ctx context.Context, instanceID string) string {
+	// The wait may have failed because ctx ran out
+	ctx, cancel := detachedContext(ctx)
+	defer cancel()
+	instance, err := s.getInstance(ctx, instanceID)
+	if err != nil {
+		logger.Debug("Failed to describe instance after failed wait", "instanceID", instanceID, "error", err)
+		return ""
+	}
 
-type terminatedWaiter struct {
-	*ec2.InstanceTerminatedWaiter
+	var reasons []string
+	if instance.StateReason != nil {
+		// The message already starts with the code
+		if message := aws.ToString(instance.StateReason.Message); message != "" {
+			reasons = append(reasons, message)
+		} else if code := aws.ToString(instance.StateReason.Code); code != "" {
+			reasons = append(reasons, code)
+		}
+	}
+	if transition := aws.ToString(instance.StateTransitionReason); transition != "" {
+		reasons = append(reasons, "transition: "+transition)
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+	state := "unknown"
+	if instance.State != nil {
+		state = string(instance.State.Name)
+	}
+	return fmt.Sprintf(" (state %s: %s)", state, strings.Join(reasons, "; "))
 }
 
-func (w *terminatedWaiter) Wait(ctx context.Context, params *ec2.DescribeInstancesInput, maxWaitDur time.Duration) error {
-	return w.InstanceTerminatedWaiter.Wait(ctx, params, maxWaitDur)
-}
+// waitForInstanceHealthy waits for an instance to be running and to pass its EC2 status checks
+func (s *Service) waitForInstanceHealthy(ctx context.Context, instanceID string) error {
+	if err := s.waitForInstanceState(ctx, instanceID, types.InstanceStateNameRunning); err != nil {
+		return fmt.Errorf("wait for running state: %w", err)
+	}
 
-func waitForInstanceState(ctx context.Context, instanceID string, desiredState types.InstanceStateName) error {
-	ec2Client, err := client.GetEC2Client(ctx)
+	maxWaitTime, err := s.remainingWait(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get EC2 client: %w", err)
+		return fmt.Errorf("wait for status checks: %w", err)
 	}
+	logger.Debug("Waiting for status checks", "instanceID", instanceID, "timeout", maxWaitTime)
 
-	var waiter waiterInterface
-	switch desiredState {
-	case types.InstanceStateNameRunning:
-		waiter = &runningWaiter{ec2.NewInstanceRunningWaiter(ec2Client)}
-	case types.InstanceStateNameStopped:
-		waiter = &stoppedWaiter{ec2.NewInstanceStoppedWaiter(ec2Client)}
-	case types.InstanceStateNameTerminated:
-		waiter = &terminatedWaiter{ec2.NewInstanceTerminatedWaiter(ec2Client)}
-	default:
-		return fmt.Errorf("unsupported instance state: %s", desiredState)
+	waiter := ec2.NewInstanceStatusOkWaiter(s.client)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstanceStatusInput{
+		InstanceIds: []string{instanceID},
+	}, maxWaitTime); err != nil {
+		return fmt.Errorf("wait for status checks: %w", err)
 	}
 
-	maxWaitTime := config.GetTimeout()
-	logger.Debug("Waiting up to", maxWaitTime, "for instance", instanceID, "to reach state", desiredState)
-
-	return waiter.Wait(ctx, &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceID},
-	}, maxWaitTime)
+	return nil
 }
@@ -1,15 +1,23 @@
 package ami
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/config"
 	"github.com/taemon1337/ec-manager/pkg/logger"
 	"github.com/taemon1337/ec-manager/pkg/testutil"
 	apitypes "github.com/taemon1337/ec-manager/pkg/types"
@@ -28,6 +36,8 @@ func TestGetAMIWithTag(t *testing.T) {
 		wantAMI     string
 		wantErr     bool
 		errContains string
+		// wantNotFound expects the error to be ErrAMINotFound
+		wantNotFound bool
 	}{
 		{
 			name: "found AMI with tag",
@@ -58,11 +68,53 @@ func TestGetAMIWithTag(t *testing.T) {
 					Images: []types.Image{},
 				}
 			},
+			tagKey:       "Status",
+			tagValue:     "latest",
+			wantAMI:      "",
+			wantErr:      true,
+			errContains:  "no AMI found with tag Status=latest",
+			wantNotFound: true,
+		},
+		{
+			name: "API error is not a not-found error",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeImagesError = fmt.Errorf("throttled")
+			},
 			tagKey:      "Status",
 			tagValue:    "latest",
-			wantAMI:     "",
 			wantErr:     true,
-			errContains: "no AMI found with tag Status=latest",
+			errContains: "describe images: throttled",
+		},
+		{
+			name: "newest of several AMIs with tag",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeImagesOutput = &ec2.DescribeImagesOutput{
+					Images: []types.Image{
+						{ImageId: aws.String("ami-old"), CreationDate: aws.String("2024-01-01T00:00:00.000Z")},
+						{ImageId: aws.String("ami-new"), CreationDate: aws.String("2024-03-01T00:00:00.000Z")},
+						{ImageId: aws.String("ami-undated")},
+						{ImageId: aws.String("ami-mid"), CreationDate: aws.String("2024-02-01T00:00:00.000Z")},
+					},
+				}
+			},
+			tagKey:   "Status",
+			tagValue: "latest",
+			wantAMI:  "ami-new",
+		},
+		{
+			name: "same creation date falls back to image ID",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeImagesOutput = &ec2.DescribeImagesOutput{
+					Images: []types.Image{
+						{ImageId: aws.String("ami-bbb"), CreationDate: aws.String("2024-03-01T00:00:00.000Z")},
+						{ImageId: aws.String("ami-aaa"), CreationDate: aws.String("2024-03-01T00:00:00.000Z")},
+						{ImageId: aws.String("ami-ccc"), CreationDate: aws.String("2024-03-01T00:00:00.000Z")},
+					},
+				}
+			},
+			tagKey:   "Status",
+			tagValue: "latest",
+			wantAMI:  "ami-aaa",
 		},
 	}
 
@@ -91,6 +143,7 @@ func TestGetAMIWithTag(t *testing.T) {
 				if tt.errContains != "" {
 					assert.Contains(t, err.Error(), tt.errContains)
 				}
+				assert.Equal(t, tt.wantNotFound, errors.Is(err, ErrAMINotFound))
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.wantAMI, gotAMI)
@@ -171,6 +224,7 @@ func TestMigrateInstance(t *testing.T) {
 		newAMI      string
 		wantErr     bool
 		errContains string
+		wantStatus  string
 	}{
 		{
 			name: "successful migration",
@@ -191,6 +245,10 @@ func TestMigrateInstance(t *testing.T) {
 											Key:   aws.String("OS"),
 											Value: aws.String("linux"),
 										},
+										{
+											Key:   aws.String("ami-migrate"),
+											Value: aws.String("enabled"),
+										},
 									},
 								},
 							},
@@ -242,6 +300,7 @@ func TestMigrateInstance(t *testing.T) {
 			instanceID: "i-123",
 			newAMI:     "ami-new",
 			wantErr:    false,
+			wantStatus: StatusCompleted,
 		},
 		{
 			name: "instance not found",
@@ -268,6 +327,16 @@ func TestMigrateInstance(t *testing.T) {
 									State: &types.InstanceState{
 										Name: types.InstanceStateNameRunning,
 									},
+									Tags: []types.Tag{
+										{
+											Key:   aws.String("ami-migrate"),
+											Value: aws.String("enabled"),
+										},
+										{
+											Key:   aws.String("ami-migrate-if-running"),
+											Value: aws.String("enabled"),
+										},
+									},
 								},
 							},
 						},
@@ -280,6 +349,89 @@ func TestMigrateInstance(t *testing.T) {
 			wantErr:    true,
 			errContains: "failed to stop instance",
 		},
+		{
+			name: "missing ami-migrate tag",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{
+						{
+							Instances: []types.Instance{
+								{
+									InstanceId: aws.String("i-123"),
+									ImageId:    aws.String("ami-old"),
+									State: &types.InstanceState{
+										Name: types.InstanceStateNameStopped,
+									},
+								},
+							},
+						},
+					},
+				}
+			},
+			instanceID:  "i-123",
+			newAMI:      "ami-new",
+			wantErr:     true,
+			errContains: "missing ami-migrate tag",
+		},
+		{
+			name: "running instance without if-running tag",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{
+						{
+							Instances: []types.Instance{
+								{
+									InstanceId: aws.String("i-123"),
+									ImageId:    aws.String("ami-old"),
+									State: &types.InstanceState{
+										Name: types.InstanceStateNameRunning,
+									},
+									Tags: []types.Tag{
+										{
+											Key:   aws.String("ami-migrate"),
+											Value: aws.String("enabled"),
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+			},
+			instanceID:  "i-123",
+			newAMI:      "ami-new",
+			wantErr:     true,
+			errContains: "Running instance without ami-migrate-if-running tag",
+		},
+		{
+			name: "already on target AMI",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{
+						{
+							Instances: []types.Instance{
+								{
+									InstanceId: aws.String("i-123"),
+									ImageId:    aws.String("ami-new"),
+									State: &types.InstanceState{
+										Name: types.InstanceStateNameStopped,
+									},
+									Tags: []types.Tag{
+										{
+											Key:   aws.String("ami-migrate"),
+											Value: aws.String("enabled"),
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+			},
+			instanceID: "i-123",
+			newAMI:     "ami-new",
+			wantStatus: StatusSkipped,
+		},
 	}
 
 	for _, tt := range tests {
@@ -301,7 +453,7 @@ func TestMigrateInstance(t *testing.T) {
 			svc := NewService(mockClient)
 
 			// Run test
-			err := svc.MigrateInstance(context.Background(), tt.instanceID, tt.newAMI)
+			result, err := svc.MigrateInstance(context.Background(), tt.instanceID, tt.newAMI)
 			if tt.wantErr {
 				assert.Error(t, err)
 				if tt.errContains != "" {
@@ -309,6 +461,10 @@ func TestMigrateInstance(t *testing.T) {
 				}
 			} else {
 				assert.NoError(t, err)
+				if assert.NotNil(t, result) {
+					assert.Equal(t, tt.instanceID, result.InstanceID)
+					assert.Equal(t, tt.wantStatus, result.Status)
+				}
 			}
 		})
 	}
@@ -376,7 +532,7 @@ func TestBackupInstance(t *testing.T) {
 			svc := NewService(mockClient)
 
 			// Run test
-			err := svc.BackupInstance(context.Background(), tt.instanceID)
+			_, err := svc.BackupInstance(context.Background(), tt.instanceID, BackupOptions{})
 			if tt.wantErr {
 				assert.Error(t, err)
 				if tt.errContains != "" {
@@ -473,6 +629,7 @@ func TestCreateInstance(t *testing.T) {
 		config      InstanceConfig
 		wantErr     bool
 		errContains string
+		validate    func(*testing.T, *apitypes.MockEC2Client, *InstanceSummary)
 	}{
 		{
 			name: "successful create",
@@ -527,6 +684,54 @@ func TestCreateInstance(t *testing.T) {
 			wantErr:     true,
 			errContains: "get latest AMI: no AMI found for OS type: linux",
 		},
+		{
+			name: "custom launch spec",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.RunInstancesOutput = &ec2.RunInstancesOutput{
+					Instances: []types.Instance{
+						{
+							InstanceId: aws.String("i-456"),
+							State: &types.InstanceState{
+								Name: types.InstanceStateNamePending,
+							},
+						},
+					},
+				}
+			},
+			config: InstanceConfig{
+				Name:             "web-1",
+				UserID:           "user123",
+				AMIID:            "ami-custom",
+				InstanceType:     "m5.large",
+				SubnetID:         "subnet-123",
+				SecurityGroupIDs: []string{"sg-1", "sg-2"},
+				KeyName:          "deploy",
+				Tags: map[string]string{
+					"team":        "platform",
+					"ami-migrate": "disabled",
+				},
+			},
+			validate: func(t *testing.T, m *apitypes.MockEC2Client, instance *InstanceSummary) {
+				if !assert.Len(t, m.RunInstancesInputs, 1) {
+					return
+				}
+				input := m.RunInstancesInputs[0]
+				assert.Equal(t, "ami-custom", aws.ToString(input.ImageId))
+				assert.Equal(t, types.InstanceType("m5.large"), input.InstanceType)
+				assert.Equal(t, "subnet-123", aws.ToString(input.SubnetId))
+				assert.Equal(t, []string{"sg-1", "sg-2"}, input.SecurityGroupIds)
+				assert.Equal(t, "deploy", aws.ToString(input.KeyName))
+
+				tags := input.TagSpecifications[0].Tags
+				assert.Equal(t, "enabled", tagValue(tags, "ami-migrate"), "standard tags can't be overridden")
+				assert.Equal(t, "user123", tagValue(tags, "Owner"))
+				assert.Equal(t, "platform", tagValue(tags, "team"))
+
+				assert.Equal(t, "i-456", instance.InstanceID)
+				assert.Equal(t, "ami-custom", instance.CurrentAMI)
+				assert.Equal(t, "running", instance.State)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -553,6 +758,9 @@ func TestCreateInstance(t *testing.T) {
 				assert.NoError(t, err)
 				assert.NotNil(t, instance)
 			}
+			if tt.validate != nil {
+				tt.validate(t, mockClient, instance)
+			}
 		})
 	}
 }
@@ -631,3 +839,1726 @@ func TestDeleteInstance(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrateInstancesDryRun(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	runningInstance := types.Instance{
+		InstanceId:   aws.String("i-123"),
+		ImageId:      aws.String("ami-old"),
+		InstanceType: types.InstanceTypeT2Micro,
+		State: &types.InstanceState{
+			Name: types.InstanceStateNameRunning,
+		},
+		Tags: []types.Tag{
+			{
+				Key:   aws.String("ami-migrate"),
+				Value: aws.String("enabled"),
+			},
+			{
+				Key:   aws.String("ami-migrate-if-running"),
+				Value: aws.String("enabled"),
+			},
+		},
+		BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				Ebs: &types.EbsInstanceBlockDevice{
+					VolumeId: aws.String("vol-123"),
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		setupMock     func(*apitypes.MockEC2Client)
+		newAMI        string
+		waitSnapshots bool
+		wantMigrate   bool
+		wantActions   []PlanAction
+		wantReason    string
+		wantPermError string
+	}{
+		{
+			name: "running instance is planned for migration",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{{Instances: []types.Instance{runningInstance}}},
+				}
+			},
+			newAMI:      "ami-new",
+			wantMigrate: true,
+			wantActions: []PlanAction{ActionStop, ActionSnapshot, ActionLaunch, ActionTerminate, ActionCopyTags},
+		},
+		{
+			name: "waits for snapshots before terminating",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{{Instances: []types.Instance{runningInstance}}},
+				}
+			},
+			newAMI:        "ami-new",
+			waitSnapshots: true,
+			wantMigrate:   true,
+			wantActions:   []PlanAction{ActionStop, ActionSnapshot, ActionLaunch, ActionWaitForSnapshots, ActionTerminate, ActionCopyTags},
+		},
+		{
+			name: "instance already on target AMI",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{{Instances: []types.Instance{runningInstance}}},
+				}
+			},
+			newAMI:      "ami-old",
+			wantMigrate: false,
+			wantReason:  "already-migrated",
+		},
+		{
+			name: "missing permission is reported",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{{Instances: []types.Instance{runningInstance}}},
+				}
+				m.TerminateInstancesError = &smithy.GenericAPIError{
+					Code:    "UnauthorizedOperation",
+					Message: "You are not authorized to perform this operation.",
+				}
+			},
+			newAMI:        "ami-new",
+			wantMigrate:   true,
+			wantActions:   []PlanAction{ActionStop, ActionSnapshot, ActionLaunch, ActionTerminate, ActionCopyTags},
+			wantPermError: "terminate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock client
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new"), availableImage("ami-old")}
+			if tt.setupMock != nil {
+				tt.setupMock(mockClient)
+			}
+
+			// Create service with mock client
+			svc := NewService(mockClient)
+
+			// Run test
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+				NewAMI:              tt.newAMI,
+				DryRun:              true,
+				ValidatePermissions: true,
+				WaitForSnapshots:    tt.waitSnapshots,
+			})
+			assert.NoError(t, err)
+			if assert.NotNil(t, result) && assert.NotNil(t, result.Plan) && assert.Len(t, result.Plan.Instances, 1) {
+				plan := result.Plan
+				got := plan.Instances[0]
+				assert.Equal(t, tt.wantMigrate, got.Migrate)
+				assert.Equal(t, tt.wantActions, got.Actions)
+				assert.Equal(t, tt.wantReason, got.Reason)
+				if tt.wantPermError != "" {
+					if assert.Len(t, got.PermissionErrors, 1) {
+						assert.Contains(t, got.PermissionErrors[0], tt.wantPermError)
+					}
+				} else {
+					assert.Empty(t, got.PermissionErrors)
+				}
+			}
+
+			// Nothing should have been stopped, launched or terminated
+			assert.Empty(t, mockClient.InstanceStates)
+		})
+	}
+}
+
+func TestMigrateInstancesPlanOutput(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{
+			InstanceId:   aws.String("i-123"),
+			InstanceType: types.InstanceTypeT3Micro,
+			ImageId:      aws.String("ami-old"),
+			State:        &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags:         []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+		}}}},
+	}
+
+	var out bytes.Buffer
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:     "ami-new",
+		DryRun:     true,
+		PlanOutput: &out,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.Plan)
+
+	var written MigrationPlan
+	require.NoError(t, json.Unmarshal(out.Bytes(), &written))
+	assert.Equal(t, *result.Plan, written)
+	require.Len(t, written.Instances, 1)
+	assert.Equal(t, "i-123", written.Instances[0].InstanceID)
+	assert.Equal(t, "t3.micro", written.Instances[0].InstanceType)
+	assert.Equal(t, "ami-old", written.Instances[0].CurrentAMI)
+	assert.Equal(t, "ami-new", written.TargetAMI)
+	assert.Equal(t, []PlanAction{ActionLaunch, ActionTerminate, ActionCopyTags}, written.Instances[0].Actions)
+}
+
+func TestMigrateInstancesPlanBatches(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	var instances []types.Instance
+	for _, id := range []string{"i-1", "i-2", "i-3"} {
+		instances = append(instances, types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Placement:  &types.Placement{AvailabilityZone: aws.String("us-east-1a")},
+			Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+		})
+	}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: instances}},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:    "ami-new",
+		DryRun:    true,
+		BatchSize: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Plan.Instances, 3)
+	for i, want := range []int{1, 1, 2} {
+		assert.Equal(t, want, result.Plan.Instances[i].Batch)
+		assert.Equal(t, "us-east-1a", result.Plan.Instances[i].AvailabilityZone)
+	}
+}
+
+func TestMigrateInstanceUnhealthyReplacement(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	// Keep the status check wait short
+	originalTimeout := config.GetTimeout()
+	config.SetTimeout(100 * time.Millisecond)
+	t.Cleanup(func() {
+		config.SetTimeout(originalTimeout)
+	})
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-123"),
+						ImageId:    aws.String("ami-old"),
+						State: &types.InstanceState{
+							Name: types.InstanceStateNameStopped,
+						},
+						Tags: []types.Tag{
+							{
+								Key:   aws.String("ami-migrate"),
+								Value: aws.String("enabled"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+		Instances: []types.Instance{
+			{
+				InstanceId: aws.String("i-456"),
+			},
+		},
+	}
+	mockClient.DescribeInstanceStatusOutput = &ec2.DescribeInstanceStatusOutput{
+		InstanceStatuses: []types.InstanceStatus{
+			{
+				InstanceId: aws.String("i-456"),
+				InstanceStatus: &types.InstanceStatusSummary{
+					Status: types.SummaryStatusImpaired,
+				},
+			},
+		},
+	}
+
+	// Set mock client
+	if err := client.SetEC2Client(mockClient); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(mockClient)
+	_, err := svc.MigrateInstance(context.Background(), "i-123", "ami-new")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "new instance i-456 is not healthy")
+	assert.Contains(t, err.Error(), "(terminated i-456)")
+
+	// The original instance must not have been terminated, the replacement must
+	assert.NotEqual(t, types.InstanceStateNameTerminated, mockClient.GetInstanceState("i-123"))
+	assert.Equal(t, types.InstanceStateNameTerminated, mockClient.GetInstanceState("i-456"))
+}
+
+// ipInUseClient rejects launches that request a specific private IP
+type ipInUseClient struct {
+	*apitypes.MockEC2Client
+}
+
+func (c *ipInUseClient) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	if params.PrivateIpAddress != nil {
+		c.Lock()
+		c.RunInstancesInputs = append(c.RunInstancesInputs, params)
+		c.Unlock()
+		return nil, &smithy.GenericAPIError{Code: "InvalidIPAddress.InUse", Message: "Address is in use"}
+	}
+	return c.MockEC2Client.RunInstances(ctx, params, optFns...)
+}
+
+func TestMigrateInstancesNetworking(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := types.Instance{
+		InstanceId:       aws.String("i-123"),
+		ImageId:          aws.String("ami-old"),
+		InstanceType:     types.InstanceTypeT3Small,
+		SubnetId:         aws.String("subnet-123"),
+		PrivateIpAddress: aws.String("10.0.0.10"),
+		SecurityGroups: []types.GroupIdentifier{
+			{GroupId: aws.String("sg-1")},
+			{GroupId: aws.String("sg-2")},
+		},
+		IamInstanceProfile: &types.IamInstanceProfile{
+			Arn: aws.String("arn:aws:iam::123456789012:instance-profile/web"),
+		},
+		State: &types.InstanceState{
+			Name: types.InstanceStateNameStopped,
+		},
+	}
+
+	tests := []struct {
+		name       string
+		opts       MigrateOptions
+		ipInUse    bool
+		wantLaunch int
+		wantIP     string
+	}{
+		{
+			name:       "networking is copied without private IP by default",
+			opts:       MigrateOptions{NewAMI: "ami-new"},
+			wantLaunch: 1,
+		},
+		{
+			name:       "private IP is reused when requested",
+			opts:       MigrateOptions{NewAMI: "ami-new", PreservePrivateIP: true},
+			wantLaunch: 1,
+			wantIP:     "10.0.0.10",
+		},
+		{
+			name:       "falls back to a new IP when reuse fails",
+			opts:       MigrateOptions{NewAMI: "ami-new", PreservePrivateIP: true},
+			ipInUse:    true,
+			wantLaunch: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{instance}}},
+			}
+
+			var ec2Client apitypes.EC2ClientAPI = mockClient
+			if tt.ipInUse {
+				ec2Client = &ipInUseClient{mockClient}
+			}
+			if err := client.SetEC2Client(ec2Client); err != nil {
+				t.Fatal(err)
+			}
+
+			svc := NewService(ec2Client)
+			_, err := svc.MigrateInstances(context.Background(), "enabled", tt.opts)
+			assert.NoError(t, err)
+
+			if assert.Len(t, mockClient.RunInstancesInputs, tt.wantLaunch) {
+				input := mockClient.RunInstancesInputs[len(mockClient.RunInstancesInputs)-1]
+				assert.Equal(t, "subnet-123", aws.ToString(input.SubnetId))
+				assert.Equal(t, []string{"sg-1", "sg-2"}, input.SecurityGroupIds)
+				assert.Equal(t, "arn:aws:iam::123456789012:instance-profile/web", aws.ToString(input.IamInstanceProfile.Arn))
+				assert.Equal(t, tt.wantIP, aws.ToString(input.PrivateIpAddress))
+			}
+			assert.Equal(t, types.InstanceStateNameTerminated, mockClient.GetInstanceState("i-123"))
+		})
+	}
+}
+
+func TestMigrateInstanceNoInstanceCreated(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.InstanceStates["i-1"] = types.InstanceStateNameRunning
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags: []types.Tag{
+							{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+							{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+						},
+					},
+				},
+			},
+		},
+	}
+	// RunInstances succeeds without returning an instance
+	mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "run instances: no instance created, leaving i-1 in place")
+	require.Len(t, result.Instances, 1)
+	assert.Equal(t, StatusFailed, result.Instances[0].Status)
+	assert.Empty(t, result.Instances[0].NewInstanceID)
+
+	// The old instance is kept
+	assert.Len(t, mockClient.RunInstancesInputs, 1)
+	assert.NotEqual(t, types.InstanceStateNameTerminated, mockClient.GetInstanceState("i-1"))
+}
+
+// failingLaunchClient fails to launch replacements for the given source instance
+type failingLaunchClient struct {
+	*apitypes.MockEC2Client
+	sourceInstanceID string
+}
+
+func (c *failingLaunchClient) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	for _, spec := range params.TagSpecifications {
+		if tagValue(spec.Tags, sourceInstanceTagKey) == c.sourceInstanceID {
+			return nil, apiError("InsufficientInstanceCapacity")
+		}
+	}
+	return c.MockEC2Client.RunInstances(ctx, params, optFns...)
+}
+
+func TestMigrateInstancesResult(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	enabledTag := types.Tag{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{enabledTag},
+					},
+					{
+						InstanceId: aws.String("i-2"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags:       []types.Tag{enabledTag},
+					},
+					{
+						InstanceId: aws.String("i-3"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{enabledTag},
+					},
+					{
+						InstanceId: aws.String("i-4"),
+						ImageId:    aws.String("ami-new"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{enabledTag},
+					},
+				},
+			},
+		},
+	}
+	ec2Client := &failingLaunchClient{MockEC2Client: mockClient, sourceInstanceID: "i-3"}
+	if err := client.SetEC2Client(ec2Client); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(ec2Client)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to migrate 1 of 4 instances")
+	// The instance's own error is part of the run's error
+	assert.Contains(t, err.Error(), "i-3: ")
+	assert.ErrorIs(t, err, ErrInsufficientCapacity)
+	if !assert.NotNil(t, result) {
+		return
+	}
+
+	assert.Equal(t, MigrationSummary{Total: 4, Completed: 1, Skipped: 2, Failed: 1}, result.Summary)
+	if assert.Len(t, result.Instances, 4) {
+		assert.Equal(t, "i-1", result.Instances[0].InstanceID)
+		assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+		assert.Equal(t, "ami-old", result.Instances[0].OldAMI)
+		assert.Equal(t, "ami-new", result.Instances[0].NewAMI)
+		assert.Equal(t, "i-456", result.Instances[0].NewInstanceID)
+
+		assert.Equal(t, "i-2", result.Instances[1].InstanceID)
+		assert.Equal(t, StatusSkipped, result.Instances[1].Status)
+		assert.Contains(t, result.Instances[1].Message, "ami-migrate-if-running")
+
+		assert.Equal(t, "i-3", result.Instances[2].InstanceID)
+		assert.Equal(t, StatusFailed, result.Instances[2].Status)
+		assert.Contains(t, result.Instances[2].Message, "InsufficientInstanceCapacity")
+
+		assert.Equal(t, "i-4", result.Instances[3].InstanceID)
+		assert.Equal(t, StatusSkipped, result.Instances[3].Status)
+	}
+
+	// The skipped running instance must not have been touched
+	assert.NotEqual(t, types.InstanceStateNameStopped, mockClient.GetInstanceState("i-2"))
+}
+
+func TestShouldMigrateInstance(t *testing.T) {
+	ifRunningTag := types.Tag{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")}
+
+	tests := []struct {
+		name        string
+		imageID     string
+		state       types.InstanceStateName
+		tags        []types.Tag
+		targetAMI   string
+		force       bool
+		wantMigrate bool
+		wantReason  string
+	}{
+		{
+			name:        "stopped instance",
+			imageID:     "ami-old",
+			state:       types.InstanceStateNameStopped,
+			targetAMI:   "ami-new",
+			wantMigrate: true,
+		},
+		{
+			name:        "running instance with if-running tag",
+			imageID:     "ami-old",
+			state:       types.InstanceStateNameRunning,
+			tags:        []types.Tag{ifRunningTag},
+			targetAMI:   "ami-new",
+			wantMigrate: true,
+		},
+		{
+			name:       "running instance without if-running tag",
+			imageID:    "ami-old",
+			state:      types.InstanceStateNameRunning,
+			targetAMI:  "ami-new",
+			wantReason: "Running instance without ami-migrate-if-running tag",
+		},
+		{
+			name:        "force overrides missing if-running tag",
+			imageID:     "ami-old",
+			state:       types.InstanceStateNameRunning,
+			targetAMI:   "ami-new",
+			force:       true,
+			wantMigrate: true,
+		},
+		{
+			name:       "force still skips instances on target AMI",
+			imageID:    "ami-new",
+			state:      types.InstanceStateNameRunning,
+			targetAMI:  "ami-new",
+			force:      true,
+			wantReason: skipReasonAlreadyMigrated,
+		},
+		{
+			name:       "already on target AMI",
+			imageID:    "ami-new",
+			state:      types.InstanceStateNameRunning,
+			tags:       []types.Tag{ifRunningTag},
+			targetAMI:  "ami-new",
+			wantReason: skipReasonAlreadyMigrated,
+		},
+		{
+			name:        "no target AMI only checks tags",
+			imageID:     "ami-new",
+			state:       types.InstanceStateNameStopped,
+			wantMigrate: true,
+		},
+		{
+			name:       "force still skips terminated instances",
+			imageID:    "ami-old",
+			state:      types.InstanceStateNameTerminated,
+			targetAMI:  "ami-new",
+			force:      true,
+			wantReason: "Terminated instance",
+		},
+		{
+			name:       "pending instance",
+			imageID:    "ami-old",
+			state:      types.InstanceStateNamePending,
+			tags:       []types.Tag{ifRunningTag},
+			targetAMI:  "ami-new",
+			wantReason: "Transitional state: instance is pending",
+		},
+		{
+			name:       "stopping instance",
+			imageID:    "ami-old",
+			state:      types.InstanceStateNameStopping,
+			targetAMI:  "ami-new",
+			wantReason: "Transitional state: instance is stopping",
+		},
+		{
+			name:       "shutting-down instance",
+			imageID:    "ami-old",
+			state:      types.InstanceStateNameShuttingDown,
+			targetAMI:  "ami-new",
+			wantReason: "Transitional state: instance is shutting-down",
+		},
+		{
+			name:       "force still skips transitional states",
+			imageID:    "ami-old",
+			state:      types.InstanceStateNameStopping,
+			targetAMI:  "ami-new",
+			force:      true,
+			wantReason: "Transitional state: instance is stopping",
+		},
+		{
+			name:       "force still skips Auto Scaling group members",
+			imageID:    "ami-old",
+			state:      types.InstanceStateNameStopped,
+			tags:       []types.Tag{{Key: aws.String("aws:autoscaling:groupName"), Value: aws.String("web-asg")}},
+			targetAMI:  "ami-new",
+			force:      true,
+			wantReason: "Auto Scaling group: instance is managed by web-asg, update the group's launch template instead",
+		},
+	}
+
+	svc := NewService(apitypes.NewMockEC2Client())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := types.Instance{
+				InstanceId: aws.String("i-123"),
+				ImageId:    aws.String(tt.imageID),
+				State:      &types.InstanceState{Name: tt.state},
+				Tags:       tt.tags,
+			}
+			migrate, reason := svc.shouldMigrateInstance(instance, tt.targetAMI, tt.force)
+			assert.Equal(t, tt.wantMigrate, migrate)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestMigrateInstancesTransitionalStates(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	states := []types.InstanceStateName{
+		types.InstanceStateNamePending,
+		types.InstanceStateNameStopping,
+		types.InstanceStateNameShuttingDown,
+	}
+	for _, state := range states {
+		t.Run(string(state), func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.InstanceStates["i-1"] = state
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{{
+					InstanceId: aws.String("i-1"),
+					ImageId:    aws.String("ami-old"),
+					State:      &types.InstanceState{Name: state},
+					Tags: []types.Tag{
+						{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+						{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+					},
+				}}}},
+			}
+
+			svc := NewService(mockClient)
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new", Force: true})
+			require.NoError(t, err)
+			require.Len(t, result.Instances, 1)
+			assert.Equal(t, StatusSkipped, result.Instances[0].Status)
+			assert.Equal(t, "Transitional state: instance is "+string(state), result.Instances[0].Message)
+			// The instance is left to settle
+			assert.Equal(t, state, mockClient.GetInstanceState("i-1"))
+			assert.Empty(t, mockClient.RunInstancesInputs)
+			assert.Empty(t, mockClient.Snapshots)
+
+			_, err = svc.MigrateInstanceWithOptions(context.Background(), "i-1", MigrateOptions{NewAMI: "ami-new", Force: true})
+			assert.EqualError(t, err, fmt.Sprintf("instance i-1 is %s, wait for it to settle before migrating", state))
+		})
+	}
+}
+
+func TestMigrateInstancesTerminated(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	enabled := func(id string, state types.InstanceStateName) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: state},
+			Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+		}
+	}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.InstanceStates["i-stopped"] = types.InstanceStateNameStopped
+	mockClient.InstanceStates["i-terminated"] = types.InstanceStateNameTerminated
+	// The mock doesn't filter on state, so the terminated instance still comes back
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{
+			enabled("i-stopped", types.InstanceStateNameStopped),
+			enabled("i-terminated", types.InstanceStateNameTerminated),
+		}}},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new", Force: true})
+	require.NoError(t, err)
+
+	require.NotEmpty(t, mockClient.DescribeInstancesInputs)
+	assert.Contains(t, mockClient.DescribeInstancesInputs[0].Filters, types.Filter{
+		Name:   aws.String("instance-state-name"),
+		Values: []string{"pending", "running", "stopping", "stopped"},
+	})
+
+	require.Len(t, result.Instances, 2)
+	statuses := make(map[string]string)
+	for _, instance := range result.Instances {
+		statuses[instance.InstanceID] = instance.Status + ": " + instance.Message
+	}
+	assert.Equal(t, StatusSkipped+": Terminated instance", statuses["i-terminated"])
+	assert.Len(t, mockClient.RunInstancesInputs, 1)
+}
+
+func TestMigrateInstancesAutoScalingGroup(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.InstanceStates["i-1"] = types.InstanceStateNameStopped
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{
+			InstanceId: aws.String("i-1"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("aws:autoscaling:groupName"), Value: aws.String("web-asg")},
+			},
+		}}}},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+	require.NoError(t, err)
+	require.Len(t, result.Instances, 1)
+	assert.Equal(t, StatusSkipped, result.Instances[0].Status)
+	assert.Contains(t, result.Instances[0].Message, "managed by web-asg")
+	// The group keeps its instance
+	assert.Empty(t, mockClient.RunInstancesInputs)
+	assert.Empty(t, mockClient.Snapshots)
+	assert.Equal(t, types.InstanceStateNameStopped, mockClient.GetInstanceState("i-1"))
+
+	_, err = svc.MigrateInstanceWithOptions(context.Background(), "i-1", MigrateOptions{NewAMI: "ami-new"})
+	assert.ErrorContains(t, err, "instance i-1 is managed by Auto Scaling group web-asg")
+	assert.Empty(t, mockClient.RunInstancesInputs)
+}
+
+func TestMigrateInstanceForce(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name        string
+		tags        []types.Tag
+		force       bool
+		wantErr     string
+		wantMessage string
+	}{
+		{
+			name:    "running instance is refused by default",
+			tags:    []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+			wantErr: "instance i-1 can't be migrated: Running instance without ami-migrate-if-running tag",
+		},
+		{
+			name:        "force migrates running instance",
+			tags:        []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+			force:       true,
+			wantMessage: "Force-migrated to AMI: ami-new (running without ami-migrate-if-running tag)",
+		},
+		{
+			name: "force with if-running tag is a normal migration",
+			tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+			},
+			force:       true,
+			wantMessage: "Migrated to AMI: ami-new",
+		},
+		{
+			name: "any enabled value enrolls",
+			tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("canary")},
+				{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+			},
+			wantMessage: "Migrated to AMI: ami-new",
+		},
+		{
+			name:    "force still needs enrollment",
+			force:   true,
+			wantErr: "instance i-1 is not enabled for migration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-1"),
+								ImageId:    aws.String("ami-old"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+								Tags:       tt.tags,
+							},
+						},
+					},
+				},
+			}
+			client := &tagRecordingClient{MockEC2Client: mockClient, tags: make(map[string][]types.Tag)}
+
+			svc := NewService(client)
+			result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-1", MigrateOptions{
+				NewAMI: "ami-new",
+				Force:  tt.force,
+			})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, mockClient.RunInstancesInputs)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, StatusCompleted, result.Status)
+			assert.Equal(t, tt.wantMessage, result.Message)
+			assert.Equal(t, tt.wantMessage, lastTagValue(client.tags["i-1"], "ami-migrate-message"))
+		})
+	}
+}
+
+func TestMigrateInstancesRerun(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	enabledTag := types.Tag{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						// Replacement launched by the previous run
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-new"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags:       []types.Tag{enabledTag},
+					},
+					{
+						// Instance the previous run failed on
+						InstanceId: aws.String("i-2"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{enabledTag},
+					},
+				},
+			},
+		},
+	}
+	mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+		Instances: []types.Instance{{InstanceId: aws.String("i-3")}},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) && assert.Len(t, result.Instances, 2) {
+		assert.Equal(t, StatusSkipped, result.Instances[0].Status)
+		assert.Equal(t, skipReasonAlreadyMigrated, result.Instances[0].Message)
+		assert.Equal(t, StatusCompleted, result.Instances[1].Status)
+	}
+
+	// Only the unfinished instance is migrated again
+	if assert.Len(t, mockClient.RunInstancesInputs, 1) {
+		assert.Equal(t, "i-2", tagValue(mockClient.RunInstancesInputs[0].TagSpecifications[0].Tags, sourceInstanceTagKey))
+	}
+}
+
+func TestMigrateInstancesExcludeTargetAMI(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	enabledTag := types.Tag{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-new"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{enabledTag},
+					},
+					{
+						InstanceId: aws.String("i-2"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{enabledTag},
+					},
+				},
+			},
+		},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:           "ami-new",
+		ExcludeTargetAMI: true,
+	})
+	assert.NoError(t, err)
+
+	// The instance already on ami-new isn't reported at all
+	if assert.NotNil(t, result) && assert.Len(t, result.Instances, 1) {
+		assert.Equal(t, "i-2", result.Instances[0].InstanceID)
+		assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+	}
+	assert.Equal(t, 1, result.Summary.Total)
+	assert.Equal(t, 0, result.Summary.Skipped)
+}
+
+func TestMigrateInstancesOnlyState(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	enabledTag := types.Tag{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}
+	ifRunningTag := types.Tag{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")}
+	instances := []types.Instance{
+		{
+			InstanceId: aws.String("i-stopped"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags:       []types.Tag{enabledTag},
+		},
+		{
+			InstanceId: aws.String("i-running"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+			Tags:       []types.Tag{enabledTag, ifRunningTag},
+		},
+		{
+			InstanceId: aws.String("i-running-untagged"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+			Tags:       []types.Tag{enabledTag},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		onlyState types.InstanceStateName
+		want      map[string]bool
+	}{
+		{
+			name:      "only stopped",
+			onlyState: types.InstanceStateNameStopped,
+			want:      map[string]bool{"i-stopped": true},
+		},
+		{
+			name:      "only running still needs the if-running tag",
+			onlyState: types.InstanceStateNameRunning,
+			want:      map[string]bool{"i-running": true, "i-running-untagged": false},
+		},
+		{
+			name: "every state",
+			want: map[string]bool{"i-stopped": true, "i-running": true, "i-running-untagged": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: instances}},
+			}
+
+			svc := NewService(mockClient)
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+				NewAMI:    "ami-new",
+				OnlyState: tt.onlyState,
+				DryRun:    true,
+			})
+			require.NoError(t, err)
+			got := make(map[string]bool)
+			for _, instance := range result.Plan.Instances {
+				got[instance.InstanceID] = instance.Migrate
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExcludeImage(t *testing.T) {
+	instances := []types.Instance{
+		{InstanceId: aws.String("i-1"), ImageId: aws.String("ami-new")},
+		{InstanceId: aws.String("i-2"), ImageId: aws.String("ami-old")},
+		{InstanceId: aws.String("i-3")},
+	}
+
+	var got []string
+	for _, instance := range excludeImage(instances, "ami-new") {
+		got = append(got, aws.ToString(instance.InstanceId))
+	}
+	assert.Equal(t, []string{"i-2", "i-3"}, got)
+	assert.Empty(t, excludeImage(instances[:1], "ami-new"))
+}
+
+// concurrencyTrackingClient records how many migrations are in flight at once
+type concurrencyTrackingClient struct {
+	*apitypes.MockEC2Client
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (c *concurrencyTrackingClient) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	c.mu.Lock()
+	switch tagValue(params.Tags, "ami-migrate-status") {
+	case "migrating":
+		c.inFlight++
+		if c.inFlight > c.peak {
+			c.peak = c.inFlight
+		}
+	case StatusCompleted, StatusFailed:
+		c.inFlight--
+	}
+	c.mu.Unlock()
+	return c.MockEC2Client.CreateTags(ctx, params, optFns...)
+}
+
+func (c *concurrencyTrackingClient) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	// Give the other migrations a chance to overlap
+	time.Sleep(10 * time.Millisecond)
+	return c.MockEC2Client.RunInstances(ctx, params, optFns...)
+}
+
+func TestMigrateInstancesMaxConcurrency(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	var instances []types.Instance
+	for i := 0; i < 6; i++ {
+		instances = append(instances, types.Instance{
+			InstanceId: aws.String(fmt.Sprintf("i-%d", i)),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+			},
+		})
+	}
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: instances}},
+	}
+	ec2Client := &concurrencyTrackingClient{MockEC2Client: mockClient}
+	if err := client.SetEC2Client(ec2Client); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(ec2Client)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:         "ami-new",
+		MaxConcurrency: 2,
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, 6, result.Summary.Completed)
+	}
+	assert.LessOrEqual(t, ec2Client.peak, 2)
+	assert.Equal(t, 0, ec2Client.inFlight)
+}
+
+// cancellingClient cancels the run as soon as the first replacement is
+// launched and records the status tags written for each instance
+type cancellingClient struct {
+	*apitypes.MockEC2Client
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	statuses map[string]string
+}
+
+func (c *cancellingClient) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	c.cancel()
+	return c.MockEC2Client.RunInstances(ctx, params, optFns...)
+}
+
+func (c *cancellingClient) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if status := tagValue(params.Tags, "ami-migrate-status"); status != "" {
+		c.mu.Lock()
+		c.statuses[params.Resources[0]] = status
+		c.mu.Unlock()
+	}
+	return c.MockEC2Client.CreateTags(ctx, params, optFns...)
+}
+
+func TestMigrateInstancesCancelled(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	var instances []types.Instance
+	for i := 0; i < 3; i++ {
+		instances = append(instances, types.Instance{
+			InstanceId: aws.String(fmt.Sprintf("i-%d", i)),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+			},
+		})
+	}
+
+	t.Run("cancelled before start", func(t *testing.T) {
+		mockClient := apitypes.NewMockEC2Client()
+		mockClient.Images = []types.Image{availableImage("ami-new")}
+		mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{{Instances: instances}},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		ec2Client := &cancellingClient{MockEC2Client: mockClient, cancel: cancel, statuses: make(map[string]string)}
+		cancel()
+
+		svc := NewService(ec2Client)
+		// The mock ignores ctx, so the instances are still listed
+		result, err := svc.MigrateInstances(ctx, "enabled", MigrateOptions{NewAMI: "ami-new"})
+		assert.ErrorIs(t, err, context.Canceled)
+		if assert.NotNil(t, result) {
+			assert.Equal(t, MigrationSummary{Total: 3, Cancelled: 3}, result.Summary)
+			for _, instance := range result.Instances {
+				assert.Equal(t, StatusCancelled, instance.Status)
+				assert.Contains(t, instance.Message, "context canceled")
+			}
+		}
+		assert.Empty(t, mockClient.RunInstancesInputs)
+		assert.Equal(t, map[string]string{
+			"i-0": StatusCancelled,
+			"i-1": StatusCancelled,
+			"i-2": StatusCancelled,
+		}, ec2Client.statuses)
+	})
+
+	t.Run("cancelled mid-run", func(t *testing.T) {
+		mockClient := apitypes.NewMockEC2Client()
+		mockClient.Images = []types.Image{availableImage("ami-new")}
+		mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{{Instances: instances}},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ec2Client := &cancellingClient{MockEC2Client: mockClient, cancel: cancel, statuses: make(map[string]string)}
+
+		svc := NewService(ec2Client)
+		result, err := svc.MigrateInstances(ctx, "enabled", MigrateOptions{
+			NewAMI:         "ami-new",
+			MaxConcurrency: 1,
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Contains(t, err.Error(), "2 of 3 instances not started")
+		if assert.NotNil(t, result) {
+			assert.Equal(t, 2, result.Summary.Cancelled)
+		}
+
+		// Only the instance already in flight was launched
+		assert.Len(t, mockClient.RunInstancesInputs, 1)
+		cancelled := 0
+		for _, status := range ec2Client.statuses {
+			if status == StatusCancelled {
+				cancelled++
+			}
+		}
+		assert.Equal(t, 2, cancelled)
+		assert.Len(t, ec2Client.statuses, 3)
+	})
+}
+
+func TestServiceWaitTimeout(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	assert.Equal(t, config.GetTimeout(), NewService(apitypes.NewMockEC2Client()).waitTimeout())
+	assert.Equal(t, 42*time.Second, NewService(apitypes.NewMockEC2Client(), WithTimeout(42*time.Second)).waitTimeout())
+
+	// A replacement that never passes its status checks is given up on after the service timeout
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-123"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags: []types.Tag{
+							{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+						},
+					},
+				},
+			},
+		},
+	}
+	mockClient.DescribeInstanceStatusOutput = &ec2.DescribeInstanceStatusOutput{
+		InstanceStatuses: []types.InstanceStatus{
+			{
+				InstanceId: aws.String("i-456"),
+				InstanceStatus: &types.InstanceStatusSummary{
+					Status: types.SummaryStatusInitializing,
+				},
+			},
+		},
+	}
+
+	svc := NewService(mockClient, WithTimeout(100*time.Millisecond))
+	start := time.Now()
+	_, err := svc.MigrateInstance(context.Background(), "i-123", "ami-new")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeded max wait time")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// slowStopClient takes delay to accept a stop request
+type slowStopClient struct {
+	*apitypes.MockEC2Client
+	delay time.Duration
+}
+
+func (c *slowStopClient) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	time.Sleep(c.delay)
+	return c.MockEC2Client.StopInstances(ctx, params, optFns...)
+}
+
+func TestMigrateInstanceSharesWaitBudget(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-123"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags: []types.Tag{
+							{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+							{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// Stopping uses up the whole timeout, leaving nothing to wait for the
+	// instance to stop with
+	ec2Client := &slowStopClient{MockEC2Client: mockClient, delay: 150 * time.Millisecond}
+	svc := NewService(ec2Client, WithTimeout(100*time.Millisecond))
+	start := time.Now()
+	result, err := svc.MigrateInstance(context.Background(), "i-123", "ami-new")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrWaitBudgetExhausted)
+	assert.Contains(t, err.Error(), "stop instance")
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.Empty(t, mockClient.RunInstancesInputs)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestInstanceWaitTimeout(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "no tag", want: time.Minute},
+		{name: "tag overrides", value: "2h", want: 2 * time.Hour},
+		{name: "unparseable tag", value: "forever", want: time.Minute},
+		{name: "non-positive tag", value: "0s", want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := types.Instance{InstanceId: aws.String("i-123")}
+			if tt.value != "" {
+				instance.Tags = []types.Tag{{Key: aws.String("ami-migrate-timeout"), Value: aws.String(tt.value)}}
+			}
+			svc := NewService(apitypes.NewMockEC2Client(), WithTimeout(time.Minute))
+			assert.Equal(t, tt.want, svc.instanceWaitTimeout(instance))
+		})
+	}
+
+	// The tag bounds the waits of the instance's migration in place of the service timeout
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{
+			InstanceId: aws.String("i-123"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("ami-migrate-timeout"), Value: aws.String("2h")},
+			},
+		}}}},
+	}
+	waiter := &fakeWaiter{}
+	svc := NewService(mockClient, WithTimeout(time.Minute), WithInstanceStateWaiter(waiter))
+	_, err := svc.MigrateInstance(context.Background(), "i-123", "ami-new")
+	require.NoError(t, err)
+	require.NotEmpty(t, waiter.maxWaits)
+	for _, maxWait := range waiter.maxWaits {
+		assert.Greater(t, maxWait, time.Minute)
+		assert.LessOrEqual(t, maxWait, 2*time.Hour)
+	}
+}
+
+func TestWaitForInstanceStateReportsStateReason(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name     string
+		instance types.Instance
+		wantErr  string
+	}{
+		{
+			name: "state and transition reasons",
+			instance: types.Instance{
+				InstanceId: aws.String("i-123"),
+				State:      &types.InstanceState{Name: types.InstanceStateNameTerminated},
+				StateReason: &types.StateReason{
+					Code:    aws.String("Server.InsufficientInstanceCapacity"),
+					Message: aws.String("Server.InsufficientInstanceCapacity: Insufficient capacity."),
+				},
+				StateTransitionReason: aws.String("Server.InsufficientInstanceCapacity (2026-10-15 09:00:00 GMT)"),
+			},
+			wantErr: "(state terminated: Server.InsufficientInstanceCapacity: Insufficient capacity.; " +
+				"transition: Server.InsufficientInstanceCapacity (2026-10-15 09:00:00 GMT))",
+		},
+		{
+			name: "transition reason only",
+			instance: types.Instance{
+				InstanceId:            aws.String("i-123"),
+				State:                 &types.InstanceState{Name: types.InstanceStateNameShuttingDown},
+				StateTransitionReason: aws.String("User initiated (2026-10-15 09:00:00 GMT)"),
+			},
+			wantErr: "(state shutting-down: transition: User initiated (2026-10-15 09:00:00 GMT))",
+		},
+		{
+			name: "no reason given",
+			instance: types.Instance{
+				InstanceId: aws.String("i-123"),
+				State:      &types.InstanceState{Name: types.InstanceStateNameTerminated},
+			},
+			wantErr: "wait for instance i-123 to reach state running: waiter state transitioned to Failure",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{tt.instance}}},
+			}
+
+			svc := NewService(mockClient, WithTimeout(time.Second))
+			err := svc.waitForInstanceState(context.Background(), "i-123", types.InstanceStateNameRunning)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			if tt.instance.StateReason == nil && tt.instance.StateTransitionReason == nil {
+				assert.NotContains(t, err.Error(), "(state")
+			}
+		})
+	}
+}
+
+func TestMigrateInstancesFilters(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	// Terminated instances are always left out
+	migratable := types.Filter{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}}
+
+	tests := []struct {
+		name        string
+		opts        MigrateOptions
+		wantFilters []types.Filter
+	}{
+		{
+			name: "defaults to the ami-migrate tag",
+			opts: MigrateOptions{NewAMI: "ami-new", DryRun: true},
+			wantFilters: []types.Filter{
+				{Name: aws.String("tag:ami-migrate"), Values: []string{"enabled"}},
+				migratable,
+			},
+		},
+		{
+			name: "merges tag selectors and filters",
+			opts: MigrateOptions{
+				NewAMI: "ami-new",
+				DryRun: true,
+				TagSelectors: map[string]string{
+					"Team":        "web",
+					"Environment": "staging",
+				},
+				Filters: []types.Filter{
+					{Name: aws.String("instance-type"), Values: []string{"t3.small", "t3.medium"}},
+				},
+			},
+			wantFilters: []types.Filter{
+				{Name: aws.String("tag:ami-migrate"), Values: []string{"enabled"}},
+				{Name: aws.String("tag:Environment"), Values: []string{"staging"}},
+				{Name: aws.String("tag:Team"), Values: []string{"web"}},
+				migratable,
+				{Name: aws.String("instance-type"), Values: []string{"t3.small", "t3.medium"}},
+			},
+		},
+		{
+			name: "scopes to the old AMI",
+			opts: MigrateOptions{
+				NewAMI: "ami-new",
+				OldAMI: "ami-old",
+				DryRun: true,
+				Filters: []types.Filter{
+					{Name: aws.String("instance-type"), Values: []string{"t3.small"}},
+				},
+			},
+			wantFilters: []types.Filter{
+				{Name: aws.String("tag:ami-migrate"), Values: []string{"enabled"}},
+				{Name: aws.String("image-id"), Values: []string{"ami-old"}},
+				migratable,
+				{Name: aws.String("instance-type"), Values: []string{"t3.small"}},
+			},
+		},
+		{
+			name: "scopes to the instance state",
+			opts: MigrateOptions{
+				NewAMI:    "ami-new",
+				OldAMI:    "ami-old",
+				OnlyState: types.InstanceStateNameStopped,
+				DryRun:    true,
+			},
+			wantFilters: []types.Filter{
+				{Name: aws.String("tag:ami-migrate"), Values: []string{"enabled"}},
+				{Name: aws.String("image-id"), Values: []string{"ami-old"}},
+				{Name: aws.String("instance-state-name"), Values: []string{"stopped"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock client
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{}
+
+			// Create service with mock client
+			svc := NewService(mockClient)
+
+			// Run test
+			_, err := svc.MigrateInstances(context.Background(), "enabled", tt.opts)
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, mockClient.DescribeInstancesInputs) {
+				assert.Equal(t, tt.wantFilters, mockClient.DescribeInstancesInputs[0].Filters)
+			}
+		})
+	}
+}
+
+func TestDescribeInstancesPagination(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instanceIDs := func(instances []types.Instance) []string {
+		var ids []string
+		for _, instance := range instances {
+			ids = append(ids, aws.ToString(instance.InstanceId))
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name  string
+		fetch func(*Service) ([]string, error)
+	}{
+		{
+			name: "enabled instances",
+			fetch: func(svc *Service) ([]string, error) {
+				instances, err := svc.fetchEnabledInstances(context.Background(), "enabled", MigrateOptions{})
+				return instanceIDs(instances), err
+			},
+		},
+		{
+			name: "instances to back up",
+			fetch: func(svc *Service) ([]string, error) {
+				instances, err := svc.getInstances(context.Background(), "enabled")
+				return instanceIDs(instances), err
+			},
+		},
+		{
+			name: "user instances",
+			fetch: func(svc *Service) ([]string, error) {
+				summaries, err := svc.ListUserInstances(context.Background(), "alice")
+				var ids []string
+				for _, summary := range summaries {
+					ids = append(ids, summary.InstanceID)
+				}
+				return ids, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := func(id string) types.Instance {
+				return types.Instance{
+					InstanceId: aws.String(id),
+					State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+				}
+			}
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesPages = []*ec2.DescribeInstancesOutput{
+				{
+					Reservations: []types.Reservation{
+						{Instances: []types.Instance{instance("i-1"), instance("i-2")}},
+					},
+				},
+				{
+					Reservations: []types.Reservation{
+						{Instances: []types.Instance{instance("i-3")}},
+						{Instances: []types.Instance{instance("i-4")}},
+					},
+				},
+			}
+
+			svc := NewService(mockClient)
+			ids, err := tt.fetch(svc)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"i-1", "i-2", "i-3", "i-4"}, ids)
+
+			if assert.GreaterOrEqual(t, len(mockClient.DescribeInstancesInputs), 2) {
+				assert.Nil(t, mockClient.DescribeInstancesInputs[0].NextToken)
+				assert.Equal(t, "page-1", aws.ToString(mockClient.DescribeInstancesInputs[1].NextToken))
+			}
+		})
+	}
+}
+
+func TestDescribeImagesPagination(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	image := func(id, created string) types.Image {
+		return types.Image{
+			ImageId:      aws.String(id),
+			State:        types.ImageStateAvailable,
+			CreationDate: aws.String(created),
+			Tags:         []types.Tag{{Key: aws.String("Role"), Value: aws.String("golden")}},
+		}
+	}
+	newClient := func() *describeImagesRecorder {
+		mockClient := apitypes.NewMockEC2Client()
+		mockClient.DescribeImagesPages = []*ec2.DescribeImagesOutput{
+			{Images: []types.Image{image("ami-1", "2024-01-01T00:00:00.000Z"), image("ami-2", "2024-02-01T00:00:00.000Z")}},
+			{Images: []types.Image{image("ami-3", "2024-04-01T00:00:00.000Z")}},
+			{Images: []types.Image{image("ami-4", "2024-03-01T00:00:00.000Z")}},
+		}
+		return &describeImagesRecorder{MockEC2Client: mockClient}
+	}
+	assertPaged := func(t *testing.T, client *describeImagesRecorder) {
+		if assert.Len(t, client.inputs, 3) {
+			assert.Nil(t, client.inputs[0].NextToken)
+			assert.Equal(t, "page-1", aws.ToString(client.inputs[1].NextToken))
+			assert.Equal(t, "page-2", aws.ToString(client.inputs[2].NextToken))
+		}
+	}
+
+	t.Run("describeImages drains every page", func(t *testing.T) {
+		client := newClient()
+		svc := NewService(client)
+		images, err := svc.describeImages(context.Background(), &ec2.DescribeImagesInput{})
+		require.NoError(t, err)
+
+		var ids []string
+		for _, image := range images {
+			ids = append(ids, aws.ToString(image.ImageId))
+		}
+		assert.Equal(t, []string{"ami-1", "ami-2", "ami-3", "ami-4"}, ids)
+		assertPaged(t, client)
+	})
+
+	t.Run("GetAMIWithTag picks the newest on any page", func(t *testing.T) {
+		client := newClient()
+		svc := NewService(client)
+		amiID, err := svc.GetAMIWithTag(context.Background(), "Role", "golden")
+		require.NoError(t, err)
+		assert.Equal(t, "ami-3", amiID)
+		assertPaged(t, client)
+	})
+
+	t.Run("FindAMIs returns every page", func(t *testing.T) {
+		client := newClient()
+		svc := NewService(client)
+		found, err := svc.FindAMIs(context.Background(), AMICriteria{Tags: map[string]string{"Role": "golden"}})
+		require.NoError(t, err)
+		require.Len(t, found, 4)
+		assert.Equal(t, "ami-3", found[0].AMIID)
+		assertPaged(t, client)
+	})
+}
+
+func TestMigrateInstancesEnabledValue(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name          string
+		enabledValue  string
+		enabledValues []string
+		wantErr       error
+	}{
+		{
+			name:    "empty value is refused",
+			wantErr: ErrEmptyEnabledValue,
+		},
+		{
+			name:          "empty extra value is refused",
+			enabledValue:  "yes",
+			enabledValues: []string{""},
+			wantErr:       ErrEmptyEnabledValue,
+		},
+		{
+			name:         "set value filters on it",
+			enabledValue: "yes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			svc := NewService(mockClient)
+
+			opts := MigrateOptions{NewAMI: "ami-new", EnabledValues: tt.enabledValues}
+			result, err := svc.MigrateInstances(context.Background(), tt.enabledValue, opts)
+			_, startErr := svc.StartMigration(context.Background(), tt.enabledValue, opts)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorIs(t, startErr, tt.wantErr)
+				assert.Nil(t, result)
+				assert.Empty(t, mockClient.DescribeInstancesInputs)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoError(t, startErr)
+			assert.Equal(t, tt.enabledValue, result.EnabledValue)
+			require.NotEmpty(t, mockClient.DescribeInstancesInputs)
+			assert.Contains(t, mockClient.DescribeInstancesInputs[0].Filters, types.Filter{
+				Name:   aws.String("tag:ami-migrate"),
+				Values: []string{"yes"},
+			})
+		})
+	}
+}
+
+func TestMigrateInstancesSeveralEnabledValues(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tagged := func(id, value string) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String(value)}},
+		}
+	}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{Instances: []types.Instance{tagged("i-canary", "canary"), tagged("i-prod", "prod")}},
+		},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:        "ami-new",
+		EnabledValues: []string{"canary", "prod", "canary"},
+		DryRun:        true,
+	})
+	require.NoError(t, err)
+
+	// One request ORs the values, each listed once, in place of enabled
+	require.Len(t, mockClient.DescribeInstancesInputs, 1)
+	assert.Contains(t, mockClient.DescribeInstancesInputs[0].Filters, types.Filter{
+		Name:   aws.String("tag:ami-migrate"),
+		Values: []string{"canary", "prod"},
+	})
+	assert.Equal(t, "canary,prod", result.EnabledValue)
+	require.Len(t, result.Plan.Instances, 2)
+	assert.Equal(t, "i-canary", result.Plan.Instances[0].InstanceID)
+	assert.Equal(t, "i-prod", result.Plan.Instances[1].InstanceID)
+	assert.True(t, result.Plan.Instances[0].Migrate)
+	assert.True(t, result.Plan.Instances[1].Migrate)
+}
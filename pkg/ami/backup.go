@@ -0,0 +1,173 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// BackupOptions contains options for backing up an instance
+type BackupOptions struct {
+	// Wait blocks until all snapshots have reached the completed state
+	Wait bool
+}
+
+// volumeSnapshot is a snapshot taken of one of an instance's EBS volumes
+type volumeSnapshot struct {
+	mapping types.InstanceBlockDeviceMapping
+	tags    []types.Tag
+	output  *ec2.CreateSnapshotOutput
+}
+
+// snapshotVolumes snapshots the EBS volumes of instance in mappings. describe
+// returns the description and tags of each volume's snapshot. On error the
+// snapshots taken so far are returned along with it.
+func (s *Service) snapshotVolumes(ctx context.Context, instance types.Instance, mappings []types.InstanceBlockDeviceMapping, describe func(types.InstanceBlockDeviceMapping) (string, []types.Tag)) ([]volumeSnapshot, error) {
+	var snapshots []volumeSnapshot
+	for _, mapping := range mappings {
+		if mapping.Ebs == nil {
+			continue
+		}
+
+		volumeID := aws.ToString(mapping.Ebs.VolumeId)
+		logger.Debug("Creating snapshot for volume",
+			"instanceID", aws.ToString(instance.InstanceId),
+			"volumeID", volumeID,
+			"deviceName", aws.ToString(mapping.DeviceName))
+
+		description, tags := describe(mapping)
+		output, err := s.client.CreateSnapshot(ctx, &ec2.CreateSnapshotInput{
+			VolumeId:    mapping.Ebs.VolumeId,
+			Description: aws.String(description),
+			TagSpecifications: []types.TagSpecification{
+				{
+					ResourceType: types.ResourceTypeSnapshot,
+					Tags:         tags,
+				},
+			},
+		})
+		if err != nil {
+			return snapshots, fmt.Errorf("create snapshot of volume %s: %w", volumeID, err)
+		}
+		snapshots = append(snapshots, volumeSnapshot{mapping: mapping, tags: tags, output: output})
+	}
+	return snapshots, nil
+}
+
+// snapshotIDsOf returns the IDs of snapshots
+func snapshotIDsOf(snapshots []volumeSnapshot) []string {
+	var snapshotIDs []string
+	for _, snapshot := range snapshots {
+		snapshotIDs = append(snapshotIDs, aws.ToString(snapshot.output.SnapshotId))
+	}
+	return snapshotIDs
+}
+
+// migrationSnapshotRecords lists the snapshot of each of the instance's
+// volumes in snapshotIDs, keyed by volume ID, in device order
+func migrationSnapshotRecords(instance types.Instance, snapshotIDs map[string]string) []MigrationSnapshot {
+	var records []MigrationSnapshot
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs == nil {
+			continue
+		}
+		volumeID := aws.ToString(mapping.Ebs.VolumeId)
+		if snapshotID, ok := snapshotIDs[volumeID]; ok {
+			records = append(records, MigrationSnapshot{
+				DeviceName: aws.ToString(mapping.DeviceName),
+				VolumeID:   volumeID,
+				SnapshotID: snapshotID,
+			})
+		}
+	}
+	return records
+}
+
+// takeMigrationSnapshots snapshots the instance's volumes chosen by
+// opts.SnapshotSelector before it is migrated, encrypting them as opts asks.
+// It returns the snapshot of each volume by volume ID and the snapshots that
+// exist, which are also returned on error so they can be cleaned up.
+func (s *Service) takeMigrationSnapshots(ctx context.Context, instance types.Instance, opts MigrateOptions) (map[string]string, []string, error) {
+	mappings, err := s.snapshotMappings(ctx, instance, opts.SnapshotSelector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("select volumes to snapshot: %w", err)
+	}
+	migratedAt := time.Now()
+	snapshots, err := s.snapshotVolumes(ctx, instance, mappings, func(mapping types.InstanceBlockDeviceMapping) (string, []types.Tag) {
+		return fmt.Sprintf("Backup before AMI migration for instance %s", aws.ToString(instance.InstanceId)),
+			migrationSnapshotTags(instance, mapping, migratedAt)
+	})
+	if err != nil {
+		return nil, snapshotIDsOf(snapshots), err
+	}
+
+	snapshotIDs := make(map[string]string)
+	var createdSnapshots []string
+	for i, snapshot := range snapshots {
+		snapshotID, existing, err := s.encryptSnapshot(ctx, snapshot.output, snapshot.tags, opts)
+		createdSnapshots = append(createdSnapshots, existing...)
+		if err != nil {
+			return nil, append(createdSnapshots, snapshotIDsOf(snapshots[i+1:])...), err
+		}
+		volumeID := aws.ToString(snapshot.mapping.Ebs.VolumeId)
+		snapshotIDs[volumeID] = snapshotID
+		opts.reportProgress(aws.ToString(instance.InstanceId), ProgressSnapshotCreated,
+			fmt.Sprintf("%s from %s", snapshotID, volumeID))
+	}
+	return snapshotIDs, createdSnapshots, nil
+}
+
+// BackupInstance snapshots all EBS volumes attached to an instance without
+// migrating it and returns the snapshot IDs. The instance may be running or
+// stopped. With opts.Wait it returns once every snapshot has completed.
+func (s *Service) BackupInstance(ctx context.Context, instanceID string, opts BackupOptions) ([]string, error) {
+	logger.Info("Starting instance backup", "instanceID", instanceID)
+
+	instance, err := s.getInstance(ctx, instanceID)
+	if err != nil {
+		logger.Error("Failed to get instance", "instanceID", instanceID, "error", err)
+		return nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+
+	date := time.Now().Format("2006-01-02")
+	snapshots, err := s.snapshotVolumes(ctx, instance, instance.BlockDeviceMappings, func(mapping types.InstanceBlockDeviceMapping) (string, []types.Tag) {
+		description := fmt.Sprintf("Backup of volume %s from instance %s", aws.ToString(mapping.Ebs.VolumeId), instanceID)
+		return description, []types.Tag{
+			{
+				Key:   aws.String("Name"),
+				Value: aws.String(fmt.Sprintf("Backup-%s-%s", instanceID, date)),
+			},
+			{
+				Key:   aws.String("InstanceID"),
+				Value: aws.String(instanceID),
+			},
+			{
+				Key:   aws.String("ami-migrate-instance"),
+				Value: aws.String(instanceID),
+			},
+			{
+				Key:   aws.String("ami-migrate-device"),
+				Value: mapping.DeviceName,
+			},
+		}
+	})
+	snapshotIDs := snapshotIDsOf(snapshots)
+	if err != nil {
+		logger.Error("Failed to create snapshot", "instanceID", instanceID, "error", err)
+		return snapshotIDs, err
+	}
+
+	if opts.Wait && len(snapshotIDs) > 0 {
+		if err := s.waitForSnapshotsCompleted(ctx, snapshotIDs); err != nil {
+			return snapshotIDs, fmt.Errorf("wait for snapshots: %w", err)
+		}
+	}
+
+	logger.Info("Instance backup completed successfully", "instanceID", instanceID, "snapshotIDs", snapshotIDs)
+	return snapshotIDs, nil
+}
@@ -0,0 +1,114 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestBackupInstanceSnapshots(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name            string
+		state           types.InstanceStateName
+		opts            BackupOptions
+		setupMock       func(*apitypes.MockEC2Client)
+		wantSnapshotIDs []string
+		wantErr         string
+	}{
+		{
+			name:            "running instance",
+			state:           types.InstanceStateNameRunning,
+			wantSnapshotIDs: []string{"snap-1", "snap-2"},
+		},
+		{
+			name:            "stopped instance waits for completion",
+			state:           types.InstanceStateNameStopped,
+			opts:            BackupOptions{Wait: true},
+			wantSnapshotIDs: []string{"snap-1", "snap-2"},
+		},
+		{
+			name:  "snapshot fails while waiting",
+			state: types.InstanceStateNameRunning,
+			opts:  BackupOptions{Wait: true},
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeSnapshotsOutput = &ec2.DescribeSnapshotsOutput{
+					Snapshots: []types.Snapshot{
+						{SnapshotId: aws.String("snap-1"), State: types.SnapshotStateError},
+					},
+				}
+			},
+			wantSnapshotIDs: []string{"snap-1", "snap-2"},
+			wantErr:         "wait for snapshots",
+		},
+		{
+			name:  "snapshot fails",
+			state: types.InstanceStateNameRunning,
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.CreateSnapshotError = fmt.Errorf("volume is busy")
+			},
+			wantErr: "create snapshot of volume vol-1: volume is busy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-123"),
+								State:      &types.InstanceState{Name: tt.state},
+								BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+									{
+										DeviceName: aws.String("/dev/xvda"),
+										Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1")},
+									},
+									{
+										// Instance store volumes can't be snapshotted
+										DeviceName: aws.String("/dev/sdb"),
+									},
+									{
+										DeviceName: aws.String("/dev/sdf"),
+										Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-2")},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+			if tt.setupMock != nil {
+				tt.setupMock(mockClient)
+			}
+
+			svc := NewService(mockClient)
+			snapshotIDs, err := svc.BackupInstance(context.Background(), "i-123", tt.opts)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantSnapshotIDs, snapshotIDs)
+
+			// Snapshots are tagged with their instance and device
+			for _, snapshot := range mockClient.Snapshots {
+				assert.Equal(t, "i-123", tagValue(snapshot.Tags, "InstanceID"))
+				assert.NotEmpty(t, tagValue(snapshot.Tags, "ami-migrate-device"))
+			}
+			// Backups never touch the instance itself
+			assert.Empty(t, mockClient.RunInstancesInputs)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// BatchHook is called before each batch after the first with the number of
+// the batch about to start, counting from 1, and the results of the batch
+// before it. Returning an error stops the run; the instances not yet
+// started are reported as cancelled.
+type BatchHook func(ctx context.Context, batch, batches int, previous []InstanceResult) error
+
+// batchSize returns how many instances each batch of a run over total
+// instances holds
+func (opts MigrateOptions) batchSize(total int) int {
+	size := opts.BatchSize
+	if opts.BatchPercent > 0 {
+		// Round up so a small fleet still gets at least one instance per batch
+		size = (total*opts.BatchPercent + 99) / 100
+	}
+	if size <= 0 || size > total {
+		return total
+	}
+	return size
+}
+
+// splitBatches splits instances into consecutive batches of at most size
+func splitBatches(instances []types.Instance, size int) [][]types.Instance {
+	var batches [][]types.Instance
+	for start := 0; start < len(instances); start += size {
+		end := start + size
+		if end > len(instances) {
+			end = len(instances)
+		}
+		batches = append(batches, instances[start:end])
+	}
+	return batches
+}
+
+// betweenBatches runs the checks configured to happen before batch starts,
+// given the results of the batch before it. A non-nil error means the run
+// should stop.
+func (s *Service) betweenBatches(ctx context.Context, batch, batches int, previous []InstanceResult, opts MigrateOptions) error {
+	if opts.VerifyBatches {
+		if err := s.verifyBatch(ctx, previous); err != nil {
+			return fmt.Errorf("batch %d failed verification: %w", batch-1, err)
+		}
+	}
+
+	if opts.BatchDelay > 0 {
+		logger.Info("Waiting before next batch", "batch", batch, "delay", opts.BatchDelay)
+		select {
+		case <-ctx.Done():
+			// The instances not started yet are reported as cancelled
+			return nil
+		case <-time.After(opts.BatchDelay):
+		}
+	}
+
+	if opts.BeforeBatch != nil {
+		if err := opts.BeforeBatch(ctx, batch, batches, previous); err != nil {
+			return fmt.Errorf("batch %d not started: %w", batch, err)
+		}
+	}
+	return nil
+}
+
+// verifyBatch checks that no migration in a batch failed and that the
+// replacements it launched are still running and passing their status
+// checks
+func (s *Service) verifyBatch(ctx context.Context, results []InstanceResult) error {
+	var failed, replacements []string
+	for _, result := range results {
+		switch {
+		case result.Status == StatusFailed:
+			failed = append(failed, result.InstanceID)
+		case result.NewInstanceID != "":
+			replacements = append(replacements, result.NewInstanceID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("migration failed for %s", strings.Join(failed, ", "))
+	}
+	if len(replacements) == 0 {
+		return nil
+	}
+
+	output, err := s.client.DescribeInstanceStatus(ctx, &ec2.DescribeInstanceStatusInput{
+		InstanceIds:         replacements,
+		IncludeAllInstances: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("describe instance status: %w", err)
+	}
+	healthy := make(map[string]bool)
+	for _, status := range output.InstanceStatuses {
+		healthy[aws.ToString(status.InstanceId)] = status.InstanceState != nil &&
+			status.InstanceState.Name == types.InstanceStateNameRunning &&
+			status.InstanceStatus != nil && status.InstanceStatus.Status == types.SummaryStatusOk &&
+			status.SystemStatus != nil && status.SystemStatus.Status == types.SummaryStatusOk
+	}
+	var unhealthy []string
+	for _, instanceID := range replacements {
+		if !healthy[instanceID] {
+			unhealthy = append(unhealthy, instanceID)
+		}
+	}
+	if len(unhealthy) > 0 {
+		return fmt.Errorf("replacement instances not healthy: %s", strings.Join(unhealthy, ", "))
+	}
+	return nil
+}
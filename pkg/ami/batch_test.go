@@ -0,0 +1,208 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestBatchSize(t *testing.T) {
+	tests := []struct {
+		name string
+		opts MigrateOptions
+		want int
+	}{
+		{name: "unset", want: 5},
+		{name: "count", opts: MigrateOptions{BatchSize: 2}, want: 2},
+		{name: "count larger than fleet", opts: MigrateOptions{BatchSize: 10}, want: 5},
+		{name: "percentage rounds up", opts: MigrateOptions{BatchPercent: 25}, want: 2},
+		{name: "percentage wins over count", opts: MigrateOptions{BatchSize: 1, BatchPercent: 40}, want: 2},
+		{name: "whole fleet", opts: MigrateOptions{BatchPercent: 100}, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.opts.batchSize(5))
+		})
+	}
+}
+
+// batchTestClient fails stopping one instance and can report the
+// replacements as impaired once they have been launched
+type batchTestClient struct {
+	*apitypes.MockEC2Client
+	failStop string
+	impaired bool
+}
+
+func (c *batchTestClient) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	for _, id := range params.InstanceIds {
+		if id == c.failStop {
+			return nil, fmt.Errorf("instance %s is locked", id)
+		}
+	}
+	return c.MockEC2Client.StopInstances(ctx, params, optFns...)
+}
+
+func (c *batchTestClient) DescribeInstanceStatus(ctx context.Context, params *ec2.DescribeInstanceStatusInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceStatusOutput, error) {
+	// Only the batch verification asks for all instances
+	if c.impaired && aws.ToBool(params.IncludeAllInstances) {
+		output := &ec2.DescribeInstanceStatusOutput{}
+		for _, id := range params.InstanceIds {
+			output.InstanceStatuses = append(output.InstanceStatuses, types.InstanceStatus{
+				InstanceId:     aws.String(id),
+				InstanceState:  &types.InstanceState{Name: types.InstanceStateNameRunning},
+				InstanceStatus: &types.InstanceStatusSummary{Status: types.SummaryStatusImpaired},
+				SystemStatus:   &types.InstanceStatusSummary{Status: types.SummaryStatusOk},
+			})
+		}
+		return output, nil
+	}
+	return c.MockEC2Client.DescribeInstanceStatus(ctx, params, optFns...)
+}
+
+func TestMigrateInstancesBatches(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	type hookCall struct {
+		batch, batches int
+		previous       []string
+		launched       int
+	}
+
+	tests := []struct {
+		name          string
+		opts          MigrateOptions
+		failStop      string
+		impaired      bool
+		declineBatch  int
+		wantCalls     []hookCall
+		wantCompleted []string
+		wantCancelled []string
+		wantErr       string
+		wantMessage   string
+	}{
+		{
+			name:          "waves of two",
+			opts:          MigrateOptions{BatchSize: 2},
+			wantCalls:     []hookCall{{2, 3, []string{"i-1", "i-2"}, 2}, {3, 3, []string{"i-3", "i-4"}, 4}},
+			wantCompleted: []string{"i-1", "i-2", "i-3", "i-4", "i-5"},
+		},
+		{
+			name:          "percentage",
+			opts:          MigrateOptions{BatchPercent: 50},
+			wantCalls:     []hookCall{{2, 2, []string{"i-1", "i-2", "i-3"}, 3}},
+			wantCompleted: []string{"i-1", "i-2", "i-3", "i-4", "i-5"},
+		},
+		{
+			name:          "hook stops the run",
+			opts:          MigrateOptions{BatchSize: 2},
+			declineBatch:  2,
+			wantCalls:     []hookCall{{2, 3, []string{"i-1", "i-2"}, 2}},
+			wantCompleted: []string{"i-1", "i-2"},
+			wantCancelled: []string{"i-3", "i-4", "i-5"},
+			wantErr:       "migration stopped with 3 of 5 instances not started: batch 2 not started: operator declined",
+			wantMessage:   "Migration not started: batch 2 not started: operator declined",
+		},
+		{
+			name:          "verification stops on a failed migration",
+			opts:          MigrateOptions{BatchSize: 2, VerifyBatches: true},
+			failStop:      "i-2",
+			wantCompleted: []string{"i-1"},
+			wantCancelled: []string{"i-3", "i-4", "i-5"},
+			wantErr:       "batch 1 failed verification: migration failed for i-2",
+		},
+		{
+			name:          "verification stops on an impaired replacement",
+			opts:          MigrateOptions{BatchSize: 3, VerifyBatches: true},
+			impaired:      true,
+			wantCompleted: []string{"i-1", "i-2", "i-3"},
+			wantCancelled: []string{"i-4", "i-5"},
+			wantErr:       "batch 1 failed verification: replacement instances not healthy: i-456",
+		},
+		{
+			name:          "verification passes",
+			opts:          MigrateOptions{BatchSize: 3, VerifyBatches: true, BatchDelay: 10 * time.Millisecond},
+			wantCalls:     []hookCall{{2, 2, []string{"i-1", "i-2", "i-3"}, 3}},
+			wantCompleted: []string{"i-1", "i-2", "i-3", "i-4", "i-5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			var instances []types.Instance
+			for i := 1; i <= 5; i++ {
+				instances = append(instances, types.Instance{
+					InstanceId: aws.String(fmt.Sprintf("i-%d", i)),
+					ImageId:    aws.String("ami-old"),
+					State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+					Tags: []types.Tag{
+						{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+						{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+					},
+				})
+			}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: instances}},
+			}
+			client := &batchTestClient{MockEC2Client: mockClient, failStop: tt.failStop, impaired: tt.impaired}
+
+			var calls []hookCall
+			opts := tt.opts
+			opts.NewAMI = "ami-new"
+			opts.BeforeBatch = func(ctx context.Context, batch, batches int, previous []InstanceResult) error {
+				call := hookCall{batch: batch, batches: batches, launched: len(mockClient.RunInstancesInputs)}
+				for _, result := range previous {
+					call.previous = append(call.previous, result.InstanceID)
+				}
+				// Results within a batch are recorded as they finish
+				sort.Strings(call.previous)
+				calls = append(calls, call)
+				if batch == tt.declineBatch {
+					return fmt.Errorf("operator declined")
+				}
+				return nil
+			}
+
+			svc := NewService(client)
+			start := time.Now()
+			result, err := svc.MigrateInstances(context.Background(), "enabled", opts)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantCalls, calls)
+			assert.GreaterOrEqual(t, time.Since(start), tt.opts.BatchDelay)
+
+			require.NotNil(t, result)
+			var completed, cancelled []string
+			for _, instance := range result.Instances {
+				switch instance.Status {
+				case StatusCompleted:
+					completed = append(completed, instance.InstanceID)
+				case StatusCancelled:
+					cancelled = append(cancelled, instance.InstanceID)
+					if tt.wantMessage != "" {
+						assert.Equal(t, tt.wantMessage, instance.Message)
+					}
+				}
+			}
+			assert.Equal(t, tt.wantCompleted, completed)
+			assert.Equal(t, tt.wantCancelled, cancelled)
+		})
+	}
+}
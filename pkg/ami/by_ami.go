@@ -0,0 +1,64 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// migratableStates are the instance states MigrateByAMI selects. Terminated
+// instances keep their image ID, so they have to be left out explicitly.
+var migratableStates = []string{
+	string(types.InstanceStateNamePending),
+	string(types.InstanceStateNameRunning),
+	string(types.InstanceStateNameStopping),
+	string(types.InstanceStateNameStopped),
+}
+
+// MigrateByAMI migrates every instance running oldAMI to newAMI, whether or
+// not it carries the enabled tag. Running instances are still skipped unless
+// they carry the if-running tag. The result is reported as for
+// MigrateInstances.
+func (s *Service) MigrateByAMI(ctx context.Context, oldAMI, newAMI string) (*MigrationResult, error) {
+	return s.MigrateByAMIWithOptions(ctx, oldAMI, MigrateOptions{NewAMI: newAMI})
+}
+
+// MigrateByAMIWithOptions migrates the instances running oldAMI like
+// MigrateByAMI, applying opts. The tag selectors, filters and name filter in
+// opts narrow the selection further.
+func (s *Service) MigrateByAMIWithOptions(ctx context.Context, oldAMI string, opts MigrateOptions) (*MigrationResult, error) {
+	if oldAMI == "" {
+		return nil, fmt.Errorf("no AMI to migrate from")
+	}
+	if oldAMI == opts.NewAMI {
+		return nil, fmt.Errorf("AMI to migrate from and to are both %s", oldAMI)
+	}
+	logger.Info("Starting migration of instances on AMI", "oldAMI", oldAMI, "newAMI", opts.NewAMI, "dryRun", opts.DryRun)
+	start := time.Now()
+
+	instances, err := s.fetchInstances(ctx, types.Filter{
+		Name:   aws.String("image-id"),
+		Values: []string{oldAMI},
+	}, withLiveStates(opts))
+	if err != nil {
+		logger.Error("Failed to fetch instances on AMI", "oldAMI", oldAMI, "error", err)
+		return nil, classifyError(fmt.Errorf("fetch instances on AMI %s: %w", oldAMI, err))
+	}
+	return s.migrateSelected(ctx, start, "", instances, opts)
+}
+
+// withLiveStates returns opts with a filter leaving terminated and
+// shutting-down instances out of the selection
+func withLiveStates(opts MigrateOptions) MigrateOptions {
+	filters := make([]types.Filter, 0, len(opts.Filters)+1)
+	filters = append(filters, types.Filter{
+		Name:   aws.String("instance-state-name"),
+		Values: migratableStates,
+	})
+	opts.Filters = append(filters, opts.Filters...)
+	return opts
+}
@@ -0,0 +1,98 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateByAMI(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						// Not enrolled with the ami-migrate tag
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+					},
+					{
+						InstanceId: aws.String("i-2"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+					},
+				},
+			},
+		},
+	}
+	mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+		Instances: []types.Instance{{InstanceId: aws.String("i-3")}},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateByAMI(context.Background(), "ami-old", "ami-new")
+	require.NoError(t, err)
+
+	if assert.NotEmpty(t, mockClient.DescribeInstancesInputs) {
+		assert.Equal(t, []types.Filter{
+			{Name: aws.String("image-id"), Values: []string{"ami-old"}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+		}, mockClient.DescribeInstancesInputs[0].Filters)
+	}
+	assert.Empty(t, result.EnabledValue)
+	if assert.Len(t, result.Instances, 2) {
+		assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+		assert.Equal(t, "ami-new", result.Instances[0].NewAMI)
+		// Running instances still need the if-running tag
+		assert.Equal(t, StatusSkipped, result.Instances[1].Status)
+	}
+	if assert.Len(t, mockClient.RunInstancesInputs, 1) {
+		assert.Equal(t, "ami-new", aws.ToString(mockClient.RunInstancesInputs[0].ImageId))
+	}
+}
+
+func TestMigrateByAMIWithOptions(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{}
+
+	svc := NewService(mockClient)
+	_, err := svc.MigrateByAMIWithOptions(context.Background(), "ami-old", MigrateOptions{
+		NewAMI:       "ami-new",
+		DryRun:       true,
+		TagSelectors: map[string]string{"Environment": "staging"},
+		Filters: []types.Filter{
+			{Name: aws.String("instance-type"), Values: []string{"t3.small"}},
+		},
+	})
+	require.NoError(t, err)
+	if assert.NotEmpty(t, mockClient.DescribeInstancesInputs) {
+		assert.Equal(t, []types.Filter{
+			{Name: aws.String("image-id"), Values: []string{"ami-old"}},
+			{Name: aws.String("tag:Environment"), Values: []string{"staging"}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+			{Name: aws.String("instance-type"), Values: []string{"t3.small"}},
+		}, mockClient.DescribeInstancesInputs[0].Filters)
+	}
+
+	// The AMIs to migrate from and to have to be given and differ
+	_, err = svc.MigrateByAMI(context.Background(), "", "ami-new")
+	assert.EqualError(t, err, "no AMI to migrate from")
+	_, err = svc.MigrateByAMI(context.Background(), "ami-new", "ami-new")
+	assert.EqualError(t, err, "AMI to migrate from and to are both ami-new")
+}
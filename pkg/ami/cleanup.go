@@ -0,0 +1,130 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// SnapshotCleanup describes what happened to a single snapshot during cleanup
+type SnapshotCleanup struct {
+	SnapshotID string    `json:"snapshot_id"`
+	InstanceID string    `json:"instance_id,omitempty"`
+	VolumeID   string    `json:"volume_id,omitempty"`
+	StartTime  time.Time `json:"start_time"`
+	Deleted    bool      `json:"deleted"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// CleanupSnapshots deletes migration snapshots created by ec-manager that are
+// older than olderThan. Only snapshots tagged created-by=ec-manager are
+// considered, and snapshots still referenced by an AMI are kept. When dryRun is
+// set nothing is deleted and the returned list shows what would be removed.
+func (s *Service) CleanupSnapshots(ctx context.Context, olderThan time.Duration, dryRun bool) ([]SnapshotCleanup, error) {
+	logger.Info("Starting snapshot cleanup", "olderThan", olderThan, "dryRun", dryRun)
+
+	result, err := s.client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{
+		OwnerIds: []string{"self"},
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:" + createdByTagKey),
+				Values: []string{createdByTagValue},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe snapshots: %w", err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var candidates []types.Snapshot
+	for _, snapshot := range result.Snapshots {
+		// Never rely on the filter alone to decide what is ours to delete
+		if tagValue(snapshot.Tags, createdByTagKey) != createdByTagValue {
+			continue
+		}
+		if !aws.ToTime(snapshot.StartTime).Before(cutoff) {
+			continue
+		}
+		candidates = append(candidates, snapshot)
+	}
+	if len(candidates) == 0 {
+		logger.Info("No snapshots to clean up")
+		return nil, nil
+	}
+
+	referenced, err := s.snapshotsReferencedByImages(ctx, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("find snapshots referenced by AMIs: %w", err)
+	}
+
+	cleanups := make([]SnapshotCleanup, 0, len(candidates))
+	for _, snapshot := range candidates {
+		snapshotID := aws.ToString(snapshot.SnapshotId)
+		cleanup := SnapshotCleanup{
+			SnapshotID: snapshotID,
+			InstanceID: tagValue(snapshot.Tags, "ami-migrate-instance"),
+			VolumeID:   aws.ToString(snapshot.VolumeId),
+			StartTime:  aws.ToTime(snapshot.StartTime),
+		}
+
+		if imageID, ok := referenced[snapshotID]; ok {
+			cleanup.Reason = fmt.Sprintf("referenced by AMI %s", imageID)
+			cleanups = append(cleanups, cleanup)
+			continue
+		}
+		if dryRun {
+			cleanup.Reason = "dry run"
+			cleanups = append(cleanups, cleanup)
+			continue
+		}
+
+		logger.Info("Deleting snapshot", "snapshotID", snapshotID)
+		if _, err := s.client.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{
+			SnapshotId: snapshot.SnapshotId,
+		}); err != nil {
+			return cleanups, fmt.Errorf("delete snapshot %s: %w", snapshotID, err)
+		}
+		cleanup.Deleted = true
+		cleanups = append(cleanups, cleanup)
+	}
+
+	return cleanups, nil
+}
+
+// snapshotsReferencedByImages maps each of the snapshots that backs one of our
+// AMIs to the ID of that AMI
+func (s *Service) snapshotsReferencedByImages(ctx context.Context, snapshots []types.Snapshot) (map[string]string, error) {
+	snapshotIDs := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		snapshotIDs = append(snapshotIDs, aws.ToString(snapshot.SnapshotId))
+	}
+
+	images, err := s.describeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{"self"},
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("block-device-mapping.snapshot-id"),
+				Values: snapshotIDs,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe images: %w", err)
+	}
+
+	referenced := make(map[string]string)
+	for _, image := range images {
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+				referenced[aws.ToString(mapping.Ebs.SnapshotId)] = aws.ToString(image.ImageId)
+			}
+		}
+	}
+	return referenced, nil
+}
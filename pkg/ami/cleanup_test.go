@@ -0,0 +1,129 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestCleanupSnapshots(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	ours := []types.Tag{
+		{Key: aws.String("created-by"), Value: aws.String("ec-manager")},
+		{Key: aws.String("ami-migrate-instance"), Value: aws.String("i-123")},
+	}
+
+	snapshots := func() []types.Snapshot {
+		return []types.Snapshot{
+			{SnapshotId: aws.String("snap-old"), VolumeId: aws.String("vol-1"), StartTime: aws.Time(old), Tags: ours},
+			{SnapshotId: aws.String("snap-recent"), VolumeId: aws.String("vol-2"), StartTime: aws.Time(recent), Tags: ours},
+			{SnapshotId: aws.String("snap-ami"), VolumeId: aws.String("vol-3"), StartTime: aws.Time(old), Tags: ours},
+			{
+				SnapshotId: aws.String("snap-foreign"),
+				StartTime:  aws.Time(old),
+				Tags: []types.Tag{
+					{Key: aws.String("created-by"), Value: aws.String("someone-else")},
+				},
+			},
+			{SnapshotId: aws.String("snap-untagged"), StartTime: aws.Time(old)},
+		}
+	}
+	images := []types.Image{
+		{
+			ImageId: aws.String("ami-keep"),
+			BlockDeviceMappings: []types.BlockDeviceMapping{
+				{
+					DeviceName: aws.String("/dev/xvda"),
+					Ebs:        &types.EbsBlockDevice{SnapshotId: aws.String("snap-ami")},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		setupMock   func(*apitypes.MockEC2Client)
+		wantDeleted []string
+		wantErr     bool
+		errContains string
+		validate    func(*testing.T, []SnapshotCleanup)
+	}{
+		{
+			name:        "deletes only old snapshots it created",
+			wantDeleted: []string{"snap-old"},
+			validate: func(t *testing.T, cleanups []SnapshotCleanup) {
+				if assert.Len(t, cleanups, 2) {
+					assert.Equal(t, "snap-old", cleanups[0].SnapshotID)
+					assert.True(t, cleanups[0].Deleted)
+					assert.Equal(t, "i-123", cleanups[0].InstanceID)
+					assert.Equal(t, "vol-1", cleanups[0].VolumeID)
+
+					assert.Equal(t, "snap-ami", cleanups[1].SnapshotID)
+					assert.False(t, cleanups[1].Deleted)
+					assert.Equal(t, "referenced by AMI ami-keep", cleanups[1].Reason)
+				}
+			},
+		},
+		{
+			name:   "dry run lists without deleting",
+			dryRun: true,
+			validate: func(t *testing.T, cleanups []SnapshotCleanup) {
+				if assert.Len(t, cleanups, 2) {
+					assert.False(t, cleanups[0].Deleted)
+					assert.Equal(t, "dry run", cleanups[0].Reason)
+				}
+			},
+		},
+		{
+			name: "delete error",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DeleteSnapshotError = fmt.Errorf("snapshot is in use")
+			},
+			wantErr:     true,
+			errContains: "delete snapshot snap-old: snapshot is in use",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock client
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Snapshots = snapshots()
+			mockClient.Images = images
+			if tt.setupMock != nil {
+				tt.setupMock(mockClient)
+			}
+
+			// Create service with mock client
+			svc := NewService(mockClient)
+
+			// Run test
+			cleanups, err := svc.CleanupSnapshots(context.Background(), 30*24*time.Hour, tt.dryRun)
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errContains != "" {
+					assert.Contains(t, err.Error(), tt.errContains)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantDeleted, mockClient.DeletedSnapshots)
+			if tt.validate != nil {
+				tt.validate(t, cleanups)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// clonedFromTagKey records which instance a clone was made from. It differs
+// from sourceInstanceTagKey so a clone is never taken for a replacement.
+const clonedFromTagKey = "ami-migrate-cloned-from"
+
+// CloneInstance launches a copy of the source instance on amiID with the
+// same instance type, subnet, security groups, instance profile, key pair
+// and tags, waits for it to be running and returns its ID. The source
+// instance is left untouched: nothing is stopped, snapshotted or tagged, so
+// the clone's root volume comes from amiID and it gets no data volumes.
+//
+// Tags that identify the source instance or enrol it in migrations, such as
+// the enabled tag and the migration status tags, aren't copied, so the clone
+// isn't picked up by the next migration.
+func (s *Service) CloneInstance(ctx context.Context, sourceInstanceID, amiID string) (string, error) {
+	logger.Info("Cloning instance", "instanceID", sourceInstanceID, "amiID", amiID)
+
+	source, err := s.getInstance(ctx, sourceInstanceID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkInstanceTypeArchitecture(ctx, source.InstanceType, amiID); err != nil {
+		return "", err
+	}
+
+	tags := append(s.cloneTags(source.Tags), types.Tag{
+		Key:   aws.String(clonedFromTagKey),
+		Value: aws.String(sourceInstanceID),
+	})
+	runInput := &ec2.RunInstancesInput{
+		ImageId:      aws.String(amiID),
+		InstanceType: source.InstanceType,
+		KeyName:      source.KeyName,
+		MinCount:     aws.Int32(1),
+		MaxCount:     aws.Int32(1),
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeInstance,
+				Tags:         tags,
+			},
+		},
+	}
+	applyNetworking(runInput, source)
+
+	runResult, err := s.client.RunInstances(ctx, runInput)
+	if err != nil {
+		return "", classifyError(fmt.Errorf("launch clone: %w", err))
+	}
+	if len(runResult.Instances) == 0 {
+		return "", fmt.Errorf("launch clone: no instance was created")
+	}
+	cloneID := aws.ToString(runResult.Instances[0].InstanceId)
+
+	if err := s.waitForInstanceState(ctx, cloneID, types.InstanceStateNameRunning); err != nil {
+		return cloneID, fmt.Errorf("wait for clone %s: %w", cloneID, err)
+	}
+	logger.Info("Cloned instance", "instanceID", sourceInstanceID, "cloneID", cloneID, "amiID", amiID)
+	return cloneID, nil
+}
+
+// cloneTags returns the tags a clone gets from its source: all of them except
+// the AWS-managed ones and those ec-manager keeps per instance
+func (s *Service) cloneTags(tags []types.Tag) []types.Tag {
+	perInstance := map[string]bool{
+		s.tags.Enabled:       true,
+		s.tags.IfRunning:     true,
+		s.tags.Critical:      true,
+		s.tags.Status:        true,
+		s.tags.Message:       true,
+		s.tags.Timestamp:     true,
+		s.tags.History:       true,
+		sourceInstanceTagKey: true,
+		sourceAMITagKey:      true,
+		replacedByTagKey:     true,
+		retainedAtTagKey:     true,
+		nameSuffixTagKey:     true,
+		clonedFromTagKey:     true,
+	}
+
+	var cloned []types.Tag
+	for _, tag := range tags {
+		key := aws.ToString(tag.Key)
+		if perInstance[key] || strings.HasPrefix(key, "aws:") {
+			continue
+		}
+		cloned = append(cloned, tag)
+	}
+	return cloned
+}
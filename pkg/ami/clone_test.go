@@ -0,0 +1,114 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestCloneInstance(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	source := types.Instance{
+		InstanceId:         aws.String("i-123"),
+		ImageId:            aws.String("ami-old"),
+		InstanceType:       types.InstanceTypeT3Small,
+		KeyName:            aws.String("ops"),
+		SubnetId:           aws.String("subnet-1"),
+		SecurityGroups:     []types.GroupIdentifier{{GroupId: aws.String("sg-1")}},
+		IamInstanceProfile: &types.IamInstanceProfile{Arn: aws.String("arn:aws:iam::123456789012:instance-profile/web")},
+		State:              &types.InstanceState{Name: types.InstanceStateNameRunning},
+		BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+			{DeviceName: aws.String("/dev/xvda"), Ebs: &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1")}},
+		},
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String("web-1")},
+			{Key: aws.String("Team"), Value: aws.String("web")},
+			{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+			{Key: aws.String("ami-migrate-status"), Value: aws.String("completed")},
+			{Key: aws.String(sourceInstanceTagKey), Value: aws.String("i-000")},
+			{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("web")},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		image    types.Image
+		sourceID string
+		wantErr  string
+	}{
+		{
+			name:     "clones the instance",
+			image:    availableImage("ami-new"),
+			sourceID: "i-123",
+		},
+		{
+			name:     "source not found",
+			image:    availableImage("ami-new"),
+			sourceID: "i-missing",
+			wantErr:  "instance not found: i-missing",
+		},
+		{
+			name:     "incompatible architecture",
+			image:    types.Image{ImageId: aws.String("ami-new"), Architecture: types.ArchitectureValuesArm64},
+			sourceID: "i-123",
+			wantErr:  "instance type t3.small does not support the arm64 architecture of AMI ami-new",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{source}}},
+			}
+			mockClient.InstanceStates["i-123"] = types.InstanceStateNameRunning
+			mockClient.Images = []types.Image{tt.image}
+			mockClient.InstanceTypes = []types.InstanceTypeInfo{{
+				InstanceType:  types.InstanceTypeT3Small,
+				ProcessorInfo: &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{types.ArchitectureTypeX8664}},
+			}}
+
+			svc := NewService(mockClient)
+			cloneID, err := svc.CloneInstance(context.Background(), tt.sourceID, "ami-new")
+
+			// The source is never touched
+			assert.Equal(t, types.InstanceStateNameRunning, mockClient.GetInstanceState("i-123"))
+			assert.Empty(t, mockClient.CreateTagsInputs)
+			assert.Empty(t, mockClient.Snapshots)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Empty(t, mockClient.RunInstancesInputs)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "i-456", cloneID)
+
+			require.Len(t, mockClient.RunInstancesInputs, 1)
+			input := mockClient.RunInstancesInputs[0]
+			assert.Equal(t, "ami-new", aws.ToString(input.ImageId))
+			assert.Equal(t, types.InstanceTypeT3Small, input.InstanceType)
+			assert.Equal(t, "ops", aws.ToString(input.KeyName))
+			assert.Equal(t, "subnet-1", aws.ToString(input.SubnetId))
+			assert.Equal(t, []string{"sg-1"}, input.SecurityGroupIds)
+			assert.Equal(t, "arn:aws:iam::123456789012:instance-profile/web", aws.ToString(input.IamInstanceProfile.Arn))
+			assert.Empty(t, input.BlockDeviceMappings)
+
+			require.Len(t, input.TagSpecifications, 1)
+			assert.Equal(t, []types.Tag{
+				{Key: aws.String("Name"), Value: aws.String("web-1")},
+				{Key: aws.String("Team"), Value: aws.String("web")},
+				{Key: aws.String(clonedFromTagKey), Value: aws.String("i-123")},
+			}, input.TagSpecifications[0].Tags)
+		})
+	}
+}
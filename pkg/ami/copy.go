@@ -0,0 +1,137 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// CopyAMI copies sourceAMI from sourceRegion to destRegion and waits for the
+// copy to become available, returning the AMI ID in destRegion. The copy keeps
+// the source AMI's name, so when an AMI with that name already exists in
+// destRegion it is reused instead of copying again.
+func (s *Service) CopyAMI(ctx context.Context, sourceAMI, sourceRegion, destRegion string) (string, error) {
+	if sourceRegion == destRegion {
+		return "", fmt.Errorf("source and destination regions must differ: %s", sourceRegion)
+	}
+	logger.Info("Copying AMI", "sourceAMI", sourceAMI, "sourceRegion", sourceRegion, "destRegion", destRegion)
+
+	result, err := s.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []string{sourceAMI},
+	}, withRegion(sourceRegion))
+	if err != nil {
+		return "", fmt.Errorf("describe source image %s: %w", sourceAMI, err)
+	}
+	var source *types.Image
+	for i := range result.Images {
+		if aws.ToString(result.Images[i].ImageId) == sourceAMI {
+			source = &result.Images[i]
+			break
+		}
+	}
+	if source == nil {
+		return "", fmt.Errorf("%w in %s: %s", ErrAMINotFound, sourceRegion, sourceAMI)
+	}
+	name := aws.ToString(source.Name)
+
+	existing, err := s.findImageByName(ctx, name, destRegion)
+	if err != nil {
+		return "", fmt.Errorf("find existing copy in %s: %w", destRegion, err)
+	}
+	if existing != nil {
+		imageID := aws.ToString(existing.ImageId)
+		switch existing.State {
+		case types.ImageStateAvailable:
+			logger.Info("AMI already copied", "name", name, "destAMI", imageID, "destRegion", destRegion)
+			return imageID, nil
+		case types.ImageStatePending:
+			logger.Info("AMI copy already in progress", "name", name, "destAMI", imageID, "destRegion", destRegion)
+			if err := s.waitForImageAvailable(ctx, imageID, destRegion); err != nil {
+				return "", fmt.Errorf("wait for image %s: %w", imageID, err)
+			}
+			return imageID, nil
+		default:
+			return "", fmt.Errorf("AMI %s named %q already exists in %s in state %s", imageID, name, destRegion, existing.State)
+		}
+	}
+
+	copyResult, err := s.client.CopyImage(ctx, &ec2.CopyImageInput{
+		Name:          aws.String(name),
+		SourceImageId: aws.String(sourceAMI),
+		SourceRegion:  aws.String(sourceRegion),
+		Description:   aws.String(fmt.Sprintf("Copied from %s in %s", sourceAMI, sourceRegion)),
+		CopyImageTags: aws.Bool(true),
+	}, withRegion(destRegion))
+	if err != nil {
+		return "", fmt.Errorf("copy image %s to %s: %w", sourceAMI, destRegion, err)
+	}
+
+	imageID := aws.ToString(copyResult.ImageId)
+	if err := s.waitForImageAvailable(ctx, imageID, destRegion); err != nil {
+		return "", fmt.Errorf("wait for image %s: %w", imageID, err)
+	}
+
+	logger.Info("AMI copied", "sourceAMI", sourceAMI, "destAMI", imageID, "destRegion", destRegion)
+	return imageID, nil
+}
+
+// findImageByName returns the AMI we own in region with the given name, or nil
+// when there is none
+func (s *Service) findImageByName(ctx context.Context, name, region string) (*types.Image, error) {
+	images, err := s.describeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{"self"},
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("name"),
+				Values: []string{name},
+			},
+		},
+	}, withRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("describe images: %w", err)
+	}
+	for i := range images {
+		if aws.ToString(images[i].Name) == name {
+			return &images[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// waitForImageAvailable waits for an AMI in region to become available
+func (s *Service) waitForImageAvailable(ctx context.Context, imageID, region string) error {
+	maxWaitTime, err := s.remainingWait(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Waiting for image to be available", "imageID", imageID, "region", region, "timeout", maxWaitTime)
+
+	waiter := ec2.NewImageAvailableWaiter(regionalImagesClient{client: s.client, region: region})
+	return waiter.Wait(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []string{imageID},
+	}, maxWaitTime)
+}
+
+// withRegion sends a single request to region instead of the client's region
+func withRegion(region string) func(*ec2.Options) {
+	return func(o *ec2.Options) {
+		o.Region = region
+	}
+}
+
+// regionalImagesClient sends DescribeImages calls to a fixed region so
+// waiters can poll images outside the client's region
+type regionalImagesClient struct {
+	client apitypes.EC2ClientAPI
+	region string
+}
+
+// DescribeImages implements ec2.DescribeImagesAPIClient
+func (c regionalImagesClient) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	return c.client.DescribeImages(ctx, params, append(optFns, withRegion(c.region))...)
+}
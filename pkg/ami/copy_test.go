@@ -0,0 +1,119 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestCopyAMI(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	source := types.Image{
+		ImageId: aws.String("ami-source"),
+		Name:    aws.String("golden-2024-01"),
+		State:   types.ImageStateAvailable,
+	}
+
+	tests := []struct {
+		name         string
+		sourceRegion string
+		destImages   []types.Image
+		setupMock    func(*apitypes.MockEC2Client)
+		wantAMI      string
+		wantCopies   int
+		wantErr      bool
+		errContains  string
+	}{
+		{
+			name:         "copies to the destination region",
+			sourceRegion: "us-east-1",
+			wantAMI:      "ami-copy-1",
+			wantCopies:   1,
+		},
+		{
+			name:         "reuses an existing copy",
+			sourceRegion: "us-east-1",
+			destImages: []types.Image{
+				{ImageId: aws.String("ami-other"), Name: aws.String("other"), State: types.ImageStateAvailable},
+				{ImageId: aws.String("ami-existing"), Name: aws.String("golden-2024-01"), State: types.ImageStateAvailable},
+			},
+			wantAMI: "ami-existing",
+		},
+		{
+			name:         "existing copy failed",
+			sourceRegion: "us-east-1",
+			destImages: []types.Image{
+				{ImageId: aws.String("ami-existing"), Name: aws.String("golden-2024-01"), State: types.ImageStateFailed},
+			},
+			wantErr:     true,
+			errContains: `AMI ami-existing named "golden-2024-01" already exists in us-west-2 in state failed`,
+		},
+		{
+			name:         "source AMI not found",
+			sourceRegion: "eu-west-1",
+			wantErr:      true,
+			errContains:  "no AMI found in eu-west-1: ami-source",
+		},
+		{
+			name:         "same region",
+			sourceRegion: "us-west-2",
+			wantErr:      true,
+			errContains:  "source and destination regions must differ",
+		},
+		{
+			name:         "copy error",
+			sourceRegion: "us-east-1",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.CopyImageError = fmt.Errorf("image limit exceeded")
+			},
+			wantCopies:  1,
+			wantErr:     true,
+			errContains: "copy image ami-source to us-west-2: image limit exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock client
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.ImagesByRegion = map[string][]types.Image{
+				"us-east-1": {source},
+				"us-west-2": tt.destImages,
+			}
+			if tt.setupMock != nil {
+				tt.setupMock(mockClient)
+			}
+
+			// Create service with mock client
+			svc := NewService(mockClient, WithTimeout(time.Second))
+
+			// Run test
+			amiID, err := svc.CopyAMI(context.Background(), "ami-source", tt.sourceRegion, "us-west-2")
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errContains != "" {
+					assert.Contains(t, err.Error(), tt.errContains)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantAMI, amiID)
+			}
+
+			if assert.Len(t, mockClient.CopyImageInputs, tt.wantCopies) && tt.wantCopies > 0 {
+				input := mockClient.CopyImageInputs[0]
+				assert.Equal(t, "golden-2024-01", aws.ToString(input.Name))
+				assert.Equal(t, "ami-source", aws.ToString(input.SourceImageId))
+				assert.Equal(t, tt.sourceRegion, aws.ToString(input.SourceRegion))
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// HoursPerMonth is the number of hours a monthly cost estimate is based on
+const HoursPerMonth = 730
+
+// InstancePricer returns the hourly On-Demand price of an instance type, in
+// whatever currency its prices are given in
+type InstancePricer interface {
+	HourlyPrice(ctx context.Context, instanceType types.InstanceType) (float64, error)
+}
+
+// PriceTable is an InstancePricer with fixed hourly prices, e.g. loaded from
+// a file, so plans can be costed without calling the pricing API
+type PriceTable map[types.InstanceType]float64
+
+// HourlyPrice returns the table's price for instanceType
+func (t PriceTable) HourlyPrice(ctx context.Context, instanceType types.InstanceType) (float64, error) {
+	price, ok := t[instanceType]
+	if !ok {
+		return 0, fmt.Errorf("no price for instance type %s", instanceType)
+	}
+	return price, nil
+}
+
+// CostEstimate is the monthly On-Demand cost of the instances before and
+// after a migration
+type CostEstimate struct {
+	CurrentMonthly float64 `json:"current_monthly"`
+	NewMonthly     float64 `json:"new_monthly"`
+	MonthlyDelta   float64 `json:"monthly_delta"`
+}
+
+// add adds other to the estimate
+func (e *CostEstimate) add(other CostEstimate) {
+	e.CurrentMonthly = roundCents(e.CurrentMonthly + other.CurrentMonthly)
+	e.NewMonthly = roundCents(e.NewMonthly + other.NewMonthly)
+	e.MonthlyDelta = roundCents(e.NewMonthly - e.CurrentMonthly)
+}
+
+// estimateCost prices running the instance as its current type and as the
+// type it would be replaced with. Spot pricing isn't taken into account.
+func estimateCost(ctx context.Context, pricer InstancePricer, current, replacement types.InstanceType) (*CostEstimate, error) {
+	currentPrice, err := pricer.HourlyPrice(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+	newPrice := currentPrice
+	if replacement != current {
+		if newPrice, err = pricer.HourlyPrice(ctx, replacement); err != nil {
+			return nil, err
+		}
+	}
+
+	estimate := &CostEstimate{}
+	estimate.add(CostEstimate{
+		CurrentMonthly: currentPrice * HoursPerMonth,
+		NewMonthly:     newPrice * HoursPerMonth,
+	})
+	return estimate, nil
+}
+
+// SummarizeCost totals the cost estimates of the instances that would be
+// migrated. Cost is left nil when none of them were costed.
+func (p *MigrationPlan) SummarizeCost() {
+	p.Cost = nil
+	for _, instance := range p.Instances {
+		if !instance.Migrate || instance.Cost == nil {
+			continue
+		}
+		if p.Cost == nil {
+			p.Cost = &CostEstimate{}
+		}
+		p.Cost.add(*instance.Cost)
+	}
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,96 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstancesDryRunCost(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := func(id string, instanceType types.InstanceType, imageID string) types.Instance {
+		return types.Instance{
+			InstanceId:   aws.String(id),
+			ImageId:      aws.String(imageID),
+			InstanceType: instanceType,
+			State:        &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags:         []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+		}
+	}
+	prices := PriceTable{
+		types.InstanceTypeT3Small:  0.02,
+		types.InstanceTypeT3Medium: 0.04,
+	}
+
+	tests := []struct {
+		name          string
+		opts          MigrateOptions
+		wantCosts     map[string]*CostEstimate
+		wantCostError map[string]string
+		wantTotal     *CostEstimate
+	}{
+		{
+			name: "same type costs the same",
+			opts: MigrateOptions{NewAMI: "ami-new", Pricer: prices},
+			wantCosts: map[string]*CostEstimate{
+				"i-small": {CurrentMonthly: 14.6, NewMonthly: 14.6},
+			},
+			wantCostError: map[string]string{"i-large": "no price for instance type m5.large"},
+			wantTotal:     &CostEstimate{CurrentMonthly: 14.6, NewMonthly: 14.6},
+		},
+		{
+			name: "resized instances",
+			opts: MigrateOptions{NewAMI: "ami-new", Pricer: prices, InstanceType: types.InstanceTypeT3Medium},
+			wantCosts: map[string]*CostEstimate{
+				"i-small": {CurrentMonthly: 14.6, NewMonthly: 29.2, MonthlyDelta: 14.6},
+			},
+			wantCostError: map[string]string{"i-large": "no price for instance type m5.large"},
+			wantTotal:     &CostEstimate{CurrentMonthly: 14.6, NewMonthly: 29.2, MonthlyDelta: 14.6},
+		},
+		{
+			name:      "no pricer",
+			opts:      MigrateOptions{NewAMI: "ami-new"},
+			wantCosts: map[string]*CostEstimate{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							instance("i-small", types.InstanceTypeT3Small, "ami-old"),
+							instance("i-large", types.InstanceTypeM5Large, "ami-old"),
+							// Already migrated, so it isn't costed
+							instance("i-done", types.InstanceTypeT3Small, "ami-new"),
+						},
+					},
+				},
+			}
+
+			svc := NewService(mockClient)
+			tt.opts.DryRun = true
+			result, err := svc.MigrateInstances(context.Background(), "enabled", tt.opts)
+			require.NoError(t, err)
+			require.NotNil(t, result.Plan)
+
+			for _, plan := range result.Plan.Instances {
+				assert.Equal(t, tt.wantCosts[plan.InstanceID], plan.Cost, plan.InstanceID)
+				assert.Equal(t, tt.wantCostError[plan.InstanceID], plan.CostError, plan.InstanceID)
+			}
+			assert.Equal(t, tt.wantTotal, result.Plan.Cost)
+		})
+	}
+}
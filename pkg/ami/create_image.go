@@ -0,0 +1,58 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// CreateImageFromInstance bakes a new AMI named name from instanceID, waits
+// for it to become available and tags it with tags, returning the AMI ID.
+// EC2 reboots the instance so its file systems are consistent in the image
+// unless noReboot is set. An image that doesn't become available is left in
+// place, since it may still finish.
+func (s *Service) CreateImageFromInstance(ctx context.Context, instanceID, name string, tags map[string]string, noReboot bool) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("image name is required")
+	}
+	logger.Info("Creating AMI from instance", "instanceID", instanceID, "name", name, "noReboot", noReboot)
+
+	result, err := s.client.CreateImage(ctx, &ec2.CreateImageInput{
+		InstanceId:  aws.String(instanceID),
+		Name:        aws.String(name),
+		Description: aws.String(fmt.Sprintf("Created from %s", instanceID)),
+		NoReboot:    aws.Bool(noReboot),
+	})
+	if err != nil {
+		return "", classifyError(fmt.Errorf("create image from %s: %w", instanceID, err))
+	}
+	imageID := aws.ToString(result.ImageId)
+
+	maxWaitTime, err := s.remainingWait(ctx)
+	if err == nil {
+		err = ec2.NewImageAvailableWaiter(s.client).Wait(ctx, &ec2.DescribeImagesInput{
+			ImageIds: []string{imageID},
+		}, maxWaitTime)
+	}
+	if err != nil {
+		return imageID, fmt.Errorf("wait for image %s: %w", imageID, err)
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := s.TagAMI(ctx, imageID, key, tags[key]); err != nil {
+			return imageID, fmt.Errorf("tag image %s: %w", imageID, err)
+		}
+	}
+
+	logger.Info("AMI created", "instanceID", instanceID, "imageID", imageID)
+	return imageID, nil
+}
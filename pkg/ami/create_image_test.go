@@ -0,0 +1,98 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestCreateImageFromInstance(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name      string
+		imageName string
+		tags      map[string]string
+		noReboot  bool
+		setupMock func(*apitypes.MockEC2Client)
+		wantID    string
+		wantTags  []string
+		wantErr   string
+	}{
+		{
+			name:      "creates and tags the image",
+			imageName: "golden-2024-06-01",
+			tags:      map[string]string{"Role": "golden", "OS": "ubuntu"},
+			wantID:    "ami-created-1",
+			wantTags:  []string{"OS=ubuntu", "Role=golden"},
+		},
+		{
+			name:      "without reboot",
+			imageName: "golden-2024-06-01",
+			noReboot:  true,
+			wantID:    "ami-created-1",
+		},
+		{
+			name:    "name is required",
+			wantErr: "image name is required",
+		},
+		{
+			name:      "create error",
+			imageName: "golden-2024-06-01",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.CreateImageError = fmt.Errorf("instance is not in a valid state")
+			},
+			wantErr: "create image from i-123: instance is not in a valid state",
+		},
+		{
+			name:      "tag error keeps the image",
+			imageName: "golden-2024-06-01",
+			tags:      map[string]string{"Role": "golden"},
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.CreateTagsError = fmt.Errorf("access denied")
+			},
+			wantID:  "ami-created-1",
+			wantErr: "tag image ami-created-1: access denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			if tt.setupMock != nil {
+				tt.setupMock(mockClient)
+			}
+
+			svc := NewService(mockClient)
+			imageID, err := svc.CreateImageFromInstance(context.Background(), "i-123", tt.imageName, tt.tags, tt.noReboot)
+			assert.Equal(t, tt.wantID, imageID)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Len(t, mockClient.CreateImageInputs, 1)
+			input := mockClient.CreateImageInputs[0]
+			assert.Equal(t, "i-123", aws.ToString(input.InstanceId))
+			assert.Equal(t, tt.imageName, aws.ToString(input.Name))
+			assert.Equal(t, tt.noReboot, aws.ToBool(input.NoReboot))
+
+			var tags []string
+			for _, tagInput := range mockClient.CreateTagsInputs {
+				assert.Equal(t, []string{imageID}, tagInput.Resources)
+				for _, tag := range tagInput.Tags {
+					tags = append(tags, aws.ToString(tag.Key)+"="+aws.ToString(tag.Value))
+				}
+			}
+			assert.Equal(t, tt.wantTags, tags)
+		})
+	}
+}
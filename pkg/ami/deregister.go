@@ -0,0 +1,141 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// DeregisterOptions holds options for retiring an AMI
+type DeregisterOptions struct {
+	// DeleteSnapshots also deletes the snapshots backing the AMI once it is deregistered
+	DeleteSnapshots bool
+}
+
+// DeregisterResult describes what was removed when an AMI was deregistered
+type DeregisterResult struct {
+	AMIID            string   `json:"ami_id"`
+	DeletedSnapshots []string `json:"deleted_snapshots,omitempty"`
+}
+
+// DeregisterAMI deregisters an AMI that no instance is running from anymore.
+// It refuses when any pending or running instance still uses the AMI. With
+// opts.DeleteSnapshots the AMI's backing snapshots are deleted afterwards.
+func (s *Service) DeregisterAMI(ctx context.Context, amiID string, opts DeregisterOptions) (*DeregisterResult, error) {
+	logger.Info("Deregistering AMI", "amiID", amiID, "deleteSnapshots", opts.DeleteSnapshots)
+
+	image, err := s.getImage(ctx, amiID)
+	if err != nil {
+		return nil, err
+	}
+
+	inUse, err := s.instancesRunningAMI(ctx, amiID)
+	if err != nil {
+		return nil, fmt.Errorf("check instances using AMI %s: %w", amiID, err)
+	}
+	if len(inUse) > 0 {
+		return nil, fmt.Errorf("AMI %s is still in use by running instances: %s", amiID, strings.Join(inUse, ", "))
+	}
+
+	// Collect the snapshots before they are detached from the deregistered image
+	var snapshotIDs []string
+	for _, mapping := range image.BlockDeviceMappings {
+		if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+			snapshotIDs = append(snapshotIDs, aws.ToString(mapping.Ebs.SnapshotId))
+		}
+	}
+
+	if _, err := s.client.DeregisterImage(ctx, &ec2.DeregisterImageInput{
+		ImageId: aws.String(amiID),
+	}); err != nil {
+		return nil, fmt.Errorf("deregister image %s: %w", amiID, err)
+	}
+
+	result := &DeregisterResult{AMIID: amiID}
+	if !opts.DeleteSnapshots {
+		return result, nil
+	}
+
+	for _, snapshotID := range snapshotIDs {
+		logger.Info("Deleting AMI snapshot", "amiID", amiID, "snapshotID", snapshotID)
+		if _, err := s.client.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{
+			SnapshotId: aws.String(snapshotID),
+		}); err != nil {
+			return result, fmt.Errorf("delete snapshot %s: %w", snapshotID, err)
+		}
+		result.DeletedSnapshots = append(result.DeletedSnapshots, snapshotID)
+	}
+
+	return result, nil
+}
+
+// DeprecateAMI marks an AMI as deprecated from deprecateAt onwards, hiding it
+// from default image listings without deregistering it
+func (s *Service) DeprecateAMI(ctx context.Context, amiID string, deprecateAt time.Time) error {
+	logger.Info("Deprecating AMI", "amiID", amiID, "deprecateAt", deprecateAt)
+
+	if _, err := s.getImage(ctx, amiID); err != nil {
+		return err
+	}
+
+	if _, err := s.client.EnableImageDeprecation(ctx, &ec2.EnableImageDeprecationInput{
+		ImageId:     aws.String(amiID),
+		DeprecateAt: aws.Time(deprecateAt.UTC()),
+	}); err != nil {
+		return fmt.Errorf("enable image deprecation for %s: %w", amiID, err)
+	}
+	return nil
+}
+
+// getImage describes a single AMI
+func (s *Service) getImage(ctx context.Context, amiID string) (*types.Image, error) {
+	result, err := s.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []string{amiID},
+	})
+	if err != nil {
+		return nil, classifyError(fmt.Errorf("describe image %s: %w", amiID, err))
+	}
+	for _, image := range result.Images {
+		if aws.ToString(image.ImageId) == amiID {
+			return &image, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAMINotFound, amiID)
+}
+
+// instancesRunningAMI returns the IDs of pending or running instances launched from amiID
+func (s *Service) instancesRunningAMI(ctx context.Context, amiID string) ([]string, error) {
+	instances, err := s.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("image-id"),
+				Values: []string{amiID},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{string(types.InstanceStateNamePending), string(types.InstanceStateNameRunning)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var instanceIDs []string
+	for _, instance := range instances {
+		if aws.ToString(instance.ImageId) != amiID || instance.State == nil {
+			continue
+		}
+		switch instance.State.Name {
+		case types.InstanceStateNamePending, types.InstanceStateNameRunning:
+			instanceIDs = append(instanceIDs, aws.ToString(instance.InstanceId))
+		}
+	}
+	return instanceIDs, nil
+}
@@ -0,0 +1,159 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestDeregisterAMI(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	image := types.Image{
+		ImageId: aws.String("ami-old"),
+		BlockDeviceMappings: []types.BlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				Ebs:        &types.EbsBlockDevice{SnapshotId: aws.String("snap-root")},
+			},
+			{
+				DeviceName: aws.String("/dev/sdf"),
+				Ebs:        &types.EbsBlockDevice{SnapshotId: aws.String("snap-data")},
+			},
+			{
+				DeviceName:  aws.String("/dev/sdb"),
+				VirtualName: aws.String("ephemeral0"),
+			},
+		},
+	}
+	instances := func(imageID string, state types.InstanceStateName) *ec2.DescribeInstancesOutput {
+		return &ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{
+				{
+					Instances: []types.Instance{
+						{
+							InstanceId: aws.String("i-123"),
+							ImageId:    aws.String(imageID),
+							State:      &types.InstanceState{Name: state},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		opts             DeregisterOptions
+		setupMock        func(*apitypes.MockEC2Client)
+		wantDeregistered []string
+		wantDeleted      []string
+		wantErr          bool
+		errContains      string
+	}{
+		{
+			name:             "deregisters without touching snapshots",
+			wantDeregistered: []string{"ami-old"},
+		},
+		{
+			name:             "deletes backing snapshots",
+			opts:             DeregisterOptions{DeleteSnapshots: true},
+			wantDeregistered: []string{"ami-old"},
+			wantDeleted:      []string{"snap-root", "snap-data"},
+		},
+		{
+			name: "ignores instances on other AMIs",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = instances("ami-new", types.InstanceStateNameRunning)
+			},
+			wantDeregistered: []string{"ami-old"},
+		},
+		{
+			name: "refuses while instances are running",
+			opts: DeregisterOptions{DeleteSnapshots: true},
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = instances("ami-old", types.InstanceStateNameRunning)
+			},
+			wantErr:     true,
+			errContains: "AMI ami-old is still in use by running instances: i-123",
+		},
+		{
+			name: "AMI not found",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.Images = nil
+			},
+			wantErr:     true,
+			errContains: "no AMI found: ami-old",
+		},
+		{
+			name: "deregister error",
+			opts: DeregisterOptions{DeleteSnapshots: true},
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DeregisterImageError = fmt.Errorf("access denied")
+			},
+			wantErr:     true,
+			errContains: "deregister image ami-old: access denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock client
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{image}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{}
+			if tt.setupMock != nil {
+				tt.setupMock(mockClient)
+			}
+
+			// Create service with mock client
+			svc := NewService(mockClient)
+
+			// Run test
+			result, err := svc.DeregisterAMI(context.Background(), "ami-old", tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errContains != "" {
+					assert.Contains(t, err.Error(), tt.errContains)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "ami-old", result.AMIID)
+				assert.Equal(t, tt.wantDeleted, result.DeletedSnapshots)
+			}
+
+			assert.Equal(t, tt.wantDeregistered, mockClient.DeregisteredImages)
+			assert.Equal(t, tt.wantDeleted, mockClient.DeletedSnapshots)
+		})
+	}
+}
+
+func TestDeprecateAMI(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{{ImageId: aws.String("ami-old")}}
+	svc := NewService(mockClient)
+
+	deprecateAt := time.Date(2030, 1, 2, 3, 4, 0, 0, time.UTC)
+	assert.NoError(t, svc.DeprecateAMI(context.Background(), "ami-old", deprecateAt))
+	if assert.Len(t, mockClient.EnableImageDeprecationInputs, 1) {
+		input := mockClient.EnableImageDeprecationInputs[0]
+		assert.Equal(t, "ami-old", aws.ToString(input.ImageId))
+		assert.Equal(t, deprecateAt, aws.ToTime(input.DeprecateAt))
+	}
+	assert.Empty(t, mockClient.DeregisteredImages)
+
+	err := svc.DeprecateAMI(context.Background(), "ami-missing", deprecateAt)
+	assert.ErrorContains(t, err, "no AMI found: ami-missing")
+}
@@ -0,0 +1,207 @@
+package ami
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Kinds of AMIChange
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// AMIChange is a field that differs between two AMIs. Block device fields
+// are named after their device, e.g. /dev/sdf.volume_size, and tags are
+// named tag:Key.
+type AMIChange struct {
+	Field  string `json:"field"`
+	Change string `json:"change"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// AMIDiff lists how the AMI To differs from the AMI From
+type AMIDiff struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Changes []AMIChange `json:"changes"`
+}
+
+// DiffAMIs compares the metadata of amiB against amiA: architecture, root
+// device, virtualization, boot mode, block device mappings and tags, e.g. to
+// catch an architecture or volume size change before a fleet-wide rollout.
+// Snapshot IDs always differ between AMIs and aren't compared.
+func (s *Service) DiffAMIs(ctx context.Context, amiA, amiB string) (*AMIDiff, error) {
+	from, err := s.getImage(ctx, amiA)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getImage(ctx, amiB)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &AMIDiff{From: amiA, To: amiB, Changes: []AMIChange{}}
+	diff.Changes = appendChanges(diff.Changes, "", imageFields(*from), imageFields(*to))
+
+	fromDevices, toDevices := blockDeviceFields(*from), blockDeviceFields(*to)
+	for _, device := range unionKeys(fromDevices, toDevices) {
+		fromFields, inFrom := fromDevices[device]
+		toFields, inTo := toDevices[device]
+		switch {
+		case !inFrom:
+			diff.Changes = append(diff.Changes, AMIChange{Field: device, Change: ChangeAdded, To: summarizeFields(toFields)})
+		case !inTo:
+			diff.Changes = append(diff.Changes, AMIChange{Field: device, Change: ChangeRemoved, From: summarizeFields(fromFields)})
+		default:
+			diff.Changes = appendChanges(diff.Changes, device+".", fromFields, toFields)
+		}
+	}
+
+	diff.Changes = appendChanges(diff.Changes, "tag:", tagFields(from.Tags), tagFields(to.Tags))
+	return diff, nil
+}
+
+// amiField is a named value compared by DiffAMIs. Fields keep their order so
+// related ones are reported together.
+type amiField struct {
+	name  string
+	value string
+}
+
+// appendChanges adds the differences between the fields from and to,
+// prefixing their names. Fields only present, or only set, on one side are
+// added or removed.
+func appendChanges(changes []AMIChange, prefix string, from, to []amiField) []AMIChange {
+	fromValues := make(map[string]string, len(from))
+	for _, f := range from {
+		fromValues[f.name] = f.value
+	}
+	toValues := make(map[string]string, len(to))
+	for _, f := range to {
+		toValues[f.name] = f.value
+	}
+
+	// Fields in from's order, then those only in to
+	names := make([]string, 0, len(from)+len(to))
+	for _, f := range from {
+		names = append(names, f.name)
+	}
+	for _, f := range to {
+		if _, ok := fromValues[f.name]; !ok {
+			names = append(names, f.name)
+		}
+	}
+
+	for _, name := range names {
+		fromValue, toValue := fromValues[name], toValues[name]
+		change := AMIChange{Field: prefix + name, From: fromValue, To: toValue}
+		switch {
+		case fromValue == toValue:
+			continue
+		case fromValue == "":
+			change.Change = ChangeAdded
+		case toValue == "":
+			change.Change = ChangeRemoved
+		default:
+			change.Change = ChangeChanged
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// imageFields returns the image-level fields DiffAMIs compares
+func imageFields(image types.Image) []amiField {
+	return []amiField{
+		{"architecture", string(image.Architecture)},
+		{"root_device_type", string(image.RootDeviceType)},
+		{"root_device_name", aws.ToString(image.RootDeviceName)},
+		{"virtualization_type", string(image.VirtualizationType)},
+		{"boot_mode", string(image.BootMode)},
+		{"ena_support", formatBool(image.EnaSupport)},
+	}
+}
+
+// blockDeviceFields returns the compared fields of each block device
+// mapping by device name
+func blockDeviceFields(image types.Image) map[string][]amiField {
+	devices := make(map[string][]amiField, len(image.BlockDeviceMappings))
+	for _, mapping := range image.BlockDeviceMappings {
+		fields := []amiField{{"virtual_name", aws.ToString(mapping.VirtualName)}}
+		if mapping.Ebs != nil {
+			fields = append(fields,
+				amiField{"volume_size", formatInt32(mapping.Ebs.VolumeSize)},
+				amiField{"volume_type", string(mapping.Ebs.VolumeType)},
+				amiField{"iops", formatInt32(mapping.Ebs.Iops)},
+				amiField{"throughput", formatInt32(mapping.Ebs.Throughput)},
+				amiField{"encrypted", formatBool(mapping.Ebs.Encrypted)},
+				amiField{"delete_on_termination", formatBool(mapping.Ebs.DeleteOnTermination)},
+			)
+		}
+		devices[aws.ToString(mapping.DeviceName)] = fields
+	}
+	return devices
+}
+
+// summarizeFields joins the set fields of a block device, e.g.
+// "volume_size=8 volume_type=gp3", for a device only one AMI has
+func summarizeFields(fields []amiField) string {
+	var parts []string
+	for _, f := range fields {
+		if f.value != "" {
+			parts = append(parts, f.name+"="+f.value)
+		}
+	}
+	if len(parts) == 0 {
+		return "present"
+	}
+	return strings.Join(parts, " ")
+}
+
+// tagFields returns the tags sorted by key
+func tagFields(tags []types.Tag) []amiField {
+	fields := make([]amiField, 0, len(tags))
+	for _, tag := range tags {
+		fields = append(fields, amiField{aws.ToString(tag.Key), aws.ToString(tag.Value)})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+// unionKeys returns the keys of a and b, sorted
+func unionKeys(a, b map[string][]amiField) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, m := range []map[string][]amiField{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatInt32(v *int32) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func formatBool(v *bool) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatBool(*v)
+}
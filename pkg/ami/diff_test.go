@@ -0,0 +1,125 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestDiffAMIs(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	image := func(id string) types.Image {
+		return types.Image{
+			ImageId:            aws.String(id),
+			State:              types.ImageStateAvailable,
+			Architecture:       types.ArchitectureValuesX8664,
+			RootDeviceType:     types.DeviceTypeEbs,
+			RootDeviceName:     aws.String("/dev/xvda"),
+			VirtualizationType: types.VirtualizationTypeHvm,
+			EnaSupport:         aws.Bool(true),
+			BlockDeviceMappings: []types.BlockDeviceMapping{
+				{
+					DeviceName: aws.String("/dev/xvda"),
+					Ebs: &types.EbsBlockDevice{
+						SnapshotId:          aws.String("snap-" + id),
+						VolumeSize:          aws.Int32(20),
+						VolumeType:          types.VolumeTypeGp3,
+						DeleteOnTermination: aws.Bool(true),
+					},
+				},
+			},
+			Tags: []types.Tag{{Key: aws.String("Role"), Value: aws.String("golden")}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(*types.Image)
+		want    []AMIChange
+		wantErr string
+	}{
+		{
+			name: "identical apart from snapshots",
+			want: []AMIChange{},
+		},
+		{
+			name: "architecture and root volume size",
+			change: func(image *types.Image) {
+				image.Architecture = types.ArchitectureValuesArm64
+				image.BlockDeviceMappings[0].Ebs.VolumeSize = aws.Int32(8)
+			},
+			want: []AMIChange{
+				{Field: "architecture", Change: ChangeChanged, From: "x86_64", To: "arm64"},
+				{Field: "/dev/xvda.volume_size", Change: ChangeChanged, From: "20", To: "8"},
+			},
+		},
+		{
+			name: "block devices added and removed",
+			change: func(image *types.Image) {
+				image.BlockDeviceMappings = []types.BlockDeviceMapping{
+					{
+						DeviceName: aws.String("/dev/sda1"),
+						Ebs:        &types.EbsBlockDevice{VolumeSize: aws.Int32(20), VolumeType: types.VolumeTypeGp3},
+					},
+					{DeviceName: aws.String("/dev/sdb"), VirtualName: aws.String("ephemeral0")},
+				}
+			},
+			want: []AMIChange{
+				{Field: "/dev/sda1", Change: ChangeAdded, To: "volume_size=20 volume_type=gp3"},
+				{Field: "/dev/sdb", Change: ChangeAdded, To: "virtual_name=ephemeral0"},
+				{Field: "/dev/xvda", Change: ChangeRemoved, From: "volume_size=20 volume_type=gp3 delete_on_termination=true"},
+			},
+		},
+		{
+			name: "tags",
+			change: func(image *types.Image) {
+				image.Tags = []types.Tag{
+					{Key: aws.String("Version"), Value: aws.String("2")},
+					{Key: aws.String("OS"), Value: aws.String("ubuntu")},
+				}
+			},
+			want: []AMIChange{
+				{Field: "tag:Role", Change: ChangeRemoved, From: "golden"},
+				{Field: "tag:OS", Change: ChangeAdded, To: "ubuntu"},
+				{Field: "tag:Version", Change: ChangeAdded, To: "2"},
+			},
+		},
+		{
+			name: "unknown AMI",
+			change: func(image *types.Image) {
+				image.ImageId = aws.String("ami-other")
+			},
+			wantErr: "no AMI found: ami-b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := image("ami-b")
+			if tt.change != nil {
+				tt.change(&b)
+			}
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{image("ami-a"), b}
+
+			svc := NewService(mockClient)
+			diff, err := svc.DiffAMIs(context.Background(), "ami-a", "ami-b")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "ami-a", diff.From)
+			assert.Equal(t, "ami-b", diff.To)
+			assert.Equal(t, tt.want, diff.Changes)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// detachElasticIP disassociates the Elastic IP on the instance's primary
+// private IP so it survives the instance being terminated, and returns it
+// for attachElasticIP. It returns nil when the instance has no Elastic IP.
+// Other Elastic IPs on the instance can't follow it to a replacement with
+// different private IPs, so they are only reported.
+func (s *Service) detachElasticIP(ctx context.Context, instance types.Instance) (*types.Address, error) {
+	instanceID := aws.ToString(instance.InstanceId)
+	output, err := s.client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("instance-id"),
+				Values: []string{instanceID},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe addresses: %w", err)
+	}
+
+	var primary *types.Address
+	for i, address := range output.Addresses {
+		if aws.ToString(address.InstanceId) != instanceID {
+			continue
+		}
+		// EC2-Classic addresses have no private IP and there is only one per instance
+		if primary == nil && (address.PrivateIpAddress == nil ||
+			aws.ToString(address.PrivateIpAddress) == aws.ToString(instance.PrivateIpAddress)) {
+			primary = &output.Addresses[i]
+			continue
+		}
+		logger.Warn("Elastic IP is not on the primary private IP and won't be moved to the new instance",
+			"instanceID", instanceID, "publicIP", aws.ToString(address.PublicIp))
+	}
+	if primary == nil {
+		return nil, nil
+	}
+
+	input := &ec2.DisassociateAddressInput{}
+	if primary.Domain == types.DomainTypeVpc {
+		input.AssociationId = primary.AssociationId
+	} else {
+		input.PublicIp = primary.PublicIp
+	}
+	if _, err := s.client.DisassociateAddress(ctx, input); err != nil {
+		return nil, fmt.Errorf("disassociate Elastic IP %s: %w", aws.ToString(primary.PublicIp), err)
+	}
+	logger.Info("Disassociated Elastic IP", "instanceID", instanceID, "publicIP", aws.ToString(primary.PublicIp))
+	return primary, nil
+}
+
+// attachElasticIP associates an address returned by detachElasticIP with
+// the instance. VPC addresses are associated by allocation ID and
+// EC2-Classic ones by public IP.
+func (s *Service) attachElasticIP(ctx context.Context, address *types.Address, instanceID string) error {
+	if address == nil {
+		return nil
+	}
+
+	input := &ec2.AssociateAddressInput{InstanceId: aws.String(instanceID)}
+	if address.Domain == types.DomainTypeVpc {
+		input.AllocationId = address.AllocationId
+	} else {
+		input.PublicIp = address.PublicIp
+	}
+	if _, err := s.client.AssociateAddress(ctx, input); err != nil {
+		return fmt.Errorf("associate Elastic IP %s with %s: %w", aws.ToString(address.PublicIp), instanceID, err)
+	}
+	logger.Info("Associated Elastic IP", "instanceID", instanceID, "publicIP", aws.ToString(address.PublicIp))
+	return nil
+}
@@ -0,0 +1,168 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstancesElasticIP(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	vpcAddress := types.Address{
+		AllocationId:     aws.String("eipalloc-1"),
+		AssociationId:    aws.String("eipassoc-old"),
+		PublicIp:         aws.String("203.0.113.10"),
+		PrivateIpAddress: aws.String("10.0.0.5"),
+		InstanceId:       aws.String("i-1"),
+		Domain:           types.DomainTypeVpc,
+	}
+	classicAddress := types.Address{
+		PublicIp:   aws.String("198.51.100.7"),
+		InstanceId: aws.String("i-1"),
+		Domain:     types.DomainTypeStandard,
+	}
+	secondaryAddress := types.Address{
+		AllocationId:     aws.String("eipalloc-2"),
+		AssociationId:    aws.String("eipassoc-secondary"),
+		PublicIp:         aws.String("203.0.113.11"),
+		PrivateIpAddress: aws.String("10.0.0.6"),
+		InstanceId:       aws.String("i-1"),
+		Domain:           types.DomainTypeVpc,
+	}
+
+	tests := []struct {
+		name              string
+		addresses         []types.Address
+		preservePrivateIP bool
+		associateErr      error
+		terminateErr      error
+		wantDisassociate  *ec2.DisassociateAddressInput
+		wantAssociate     []*ec2.AssociateAddressInput
+		wantOwners        map[string]string
+		wantErr           string
+	}{
+		{
+			name:             "VPC address moves by allocation ID",
+			addresses:        []types.Address{vpcAddress},
+			wantDisassociate: &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-old")},
+			wantAssociate:    []*ec2.AssociateAddressInput{{InstanceId: aws.String("i-new"), AllocationId: aws.String("eipalloc-1")}},
+			wantOwners:       map[string]string{"203.0.113.10": "i-new"},
+		},
+		{
+			name:             "EC2-Classic address moves by public IP",
+			addresses:        []types.Address{classicAddress},
+			wantDisassociate: &ec2.DisassociateAddressInput{PublicIp: aws.String("198.51.100.7")},
+			wantAssociate:    []*ec2.AssociateAddressInput{{InstanceId: aws.String("i-new"), PublicIp: aws.String("198.51.100.7")}},
+			wantOwners:       map[string]string{"198.51.100.7": "i-new"},
+		},
+		{
+			name: "no Elastic IP",
+		},
+		{
+			name:             "only the primary address moves",
+			addresses:        []types.Address{secondaryAddress, vpcAddress},
+			wantDisassociate: &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-old")},
+			wantAssociate:    []*ec2.AssociateAddressInput{{InstanceId: aws.String("i-new"), AllocationId: aws.String("eipalloc-1")}},
+			wantOwners:       map[string]string{"203.0.113.10": "i-new", "203.0.113.11": "i-1"},
+		},
+		{
+			name:              "moves after the private IP is reused",
+			addresses:         []types.Address{vpcAddress},
+			preservePrivateIP: true,
+			wantDisassociate:  &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-old")},
+			wantAssociate:     []*ec2.AssociateAddressInput{{InstanceId: aws.String("i-new"), AllocationId: aws.String("eipalloc-1")}},
+			wantOwners:        map[string]string{"203.0.113.10": "i-new"},
+		},
+		{
+			name:             "association fails",
+			addresses:        []types.Address{vpcAddress},
+			associateErr:     fmt.Errorf("address limit exceeded"),
+			wantDisassociate: &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-old")},
+			wantAssociate:    []*ec2.AssociateAddressInput{{InstanceId: aws.String("i-new"), AllocationId: aws.String("eipalloc-1")}},
+			wantOwners:       map[string]string{"203.0.113.10": ""},
+			wantErr:          "associate Elastic IP 203.0.113.10 with i-new: address limit exceeded (Elastic IP 203.0.113.10 is not associated with any instance)",
+		},
+		{
+			name:             "termination fails",
+			addresses:        []types.Address{vpcAddress},
+			terminateErr:     fmt.Errorf("termination protection"),
+			wantDisassociate: &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-old")},
+			wantAssociate:    []*ec2.AssociateAddressInput{{InstanceId: aws.String("i-1"), AllocationId: aws.String("eipalloc-1")}},
+			wantOwners:       map[string]string{"203.0.113.10": "i-1"},
+			wantErr:          "terminate instance: termination protection",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId:       aws.String("i-1"),
+								ImageId:          aws.String("ami-old"),
+								SubnetId:         aws.String("subnet-1"),
+								PrivateIpAddress: aws.String("10.0.0.5"),
+								State:            &types.InstanceState{Name: types.InstanceStateNameStopped},
+								Tags: []types.Tag{
+									{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+								},
+							},
+						},
+					},
+				},
+			}
+			mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+				Instances: []types.Instance{{InstanceId: aws.String("i-new")}},
+			}
+			mockClient.Addresses = append([]types.Address(nil), tt.addresses...)
+			mockClient.AssociateAddressError = tt.associateErr
+			mockClient.TerminateInstancesError = tt.terminateErr
+
+			svc := NewService(mockClient)
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+				NewAMI:            "ami-new",
+				PreservePrivateIP: tt.preservePrivateIP,
+			})
+			require.NotNil(t, result)
+			require.Len(t, result.Instances, 1)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, result.Instances[0].Message, tt.wantErr)
+			}
+
+			if tt.wantDisassociate == nil {
+				assert.Empty(t, mockClient.DisassociateAddressInputs)
+			} else if assert.Len(t, mockClient.DisassociateAddressInputs, 1) {
+				assert.Equal(t, tt.wantDisassociate, mockClient.DisassociateAddressInputs[0])
+			}
+			assert.Equal(t, tt.wantAssociate, mockClient.AssociateAddressInputs)
+
+			owners := make(map[string]string)
+			for _, address := range mockClient.Addresses {
+				owners[aws.ToString(address.PublicIp)] = aws.ToString(address.InstanceId)
+			}
+			if tt.wantOwners == nil {
+				assert.Empty(t, owners)
+			} else {
+				assert.Equal(t, tt.wantOwners, owners)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// encryptSnapshot makes sure a migration backup is encrypted with
+// opts.KMSKeyID. A snapshot that isn't is replaced by an encrypted copy
+// carrying the same tags, and the original is deleted once the copy has
+// completed. Snapshots of unencrypted volumes are only copied with
+// opts.EncryptUnencryptedSnapshots. It returns the snapshot to use and the
+// snapshots that exist afterwards, which include the original when the copy
+// fails.
+func (s *Service) encryptSnapshot(ctx context.Context, snapshot *ec2.CreateSnapshotOutput, tags []types.Tag, opts MigrateOptions) (string, []string, error) {
+	snapshotID := aws.ToString(snapshot.SnapshotId)
+	if !needsEncryptedCopy(snapshot, opts) {
+		return snapshotID, []string{snapshotID}, nil
+	}
+	if s.region == "" {
+		return snapshotID, []string{snapshotID}, fmt.Errorf("encrypt snapshot %s: client region unknown", snapshotID)
+	}
+
+	logger.Info("Encrypting migration snapshot", "snapshotID", snapshotID, "kmsKeyID", opts.KMSKeyID)
+
+	// Only completed snapshots can be copied
+	if err := s.waitForSnapshotsCompleted(ctx, []string{snapshotID}); err != nil {
+		return snapshotID, []string{snapshotID}, fmt.Errorf("wait for snapshot %s: %w", snapshotID, err)
+	}
+
+	copied, err := s.client.CopySnapshot(ctx, &ec2.CopySnapshotInput{
+		SourceSnapshotId: aws.String(snapshotID),
+		SourceRegion:     aws.String(s.region),
+		Encrypted:        aws.Bool(true),
+		KmsKeyId:         aws.String(opts.KMSKeyID),
+		Description:      snapshot.Description,
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeSnapshot,
+				Tags:         tags,
+			},
+		},
+	})
+	if err != nil {
+		return snapshotID, []string{snapshotID}, fmt.Errorf("copy snapshot %s: %w", snapshotID, err)
+	}
+	copyID := aws.ToString(copied.SnapshotId)
+
+	if err := s.waitForSnapshotsCompleted(ctx, []string{copyID}); err != nil {
+		return copyID, []string{snapshotID, copyID}, fmt.Errorf("wait for encrypted copy %s: %w", copyID, err)
+	}
+
+	// The unencrypted original must not outlive its encrypted copy
+	if _, err := s.client.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{
+		SnapshotId: aws.String(snapshotID),
+	}); err != nil {
+		return copyID, []string{snapshotID, copyID}, fmt.Errorf("delete snapshot %s: %w", snapshotID, err)
+	}
+
+	return copyID, []string{copyID}, nil
+}
+
+// needsEncryptedCopy reports whether a snapshot has to be copied to encrypt
+// it with opts.KMSKeyID. EC2 reports snapshot keys as ARNs, so a key given by
+// alias or ID causes a copy even when the snapshot already uses it.
+func needsEncryptedCopy(snapshot *ec2.CreateSnapshotOutput, opts MigrateOptions) bool {
+	if opts.KMSKeyID == "" {
+		return false
+	}
+	if !aws.ToBool(snapshot.Encrypted) {
+		return opts.EncryptUnencryptedSnapshots
+	}
+	return aws.ToString(snapshot.KmsKeyId) != opts.KMSKeyID
+}
@@ -0,0 +1,128 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestNeedsEncryptedCopy(t *testing.T) {
+	const keyARN = "arn:aws:kms:us-east-1:123456789012:key/backup"
+
+	tests := []struct {
+		name     string
+		snapshot *ec2.CreateSnapshotOutput
+		opts     MigrateOptions
+		want     bool
+	}{
+		{
+			name:     "no key",
+			snapshot: &ec2.CreateSnapshotOutput{Encrypted: aws.Bool(true), KmsKeyId: aws.String("arn:aws:kms:us-east-1:123456789012:key/other")},
+		},
+		{
+			name:     "already encrypted with the key",
+			snapshot: &ec2.CreateSnapshotOutput{Encrypted: aws.Bool(true), KmsKeyId: aws.String(keyARN)},
+			opts:     MigrateOptions{KMSKeyID: keyARN},
+		},
+		{
+			name:     "encrypted with another key",
+			snapshot: &ec2.CreateSnapshotOutput{Encrypted: aws.Bool(true), KmsKeyId: aws.String("arn:aws:kms:us-east-1:123456789012:key/other")},
+			opts:     MigrateOptions{KMSKeyID: keyARN},
+			want:     true,
+		},
+		{
+			name:     "unencrypted volume left alone",
+			snapshot: &ec2.CreateSnapshotOutput{Encrypted: aws.Bool(false)},
+			opts:     MigrateOptions{KMSKeyID: keyARN},
+		},
+		{
+			name:     "unencrypted volume encrypted on request",
+			snapshot: &ec2.CreateSnapshotOutput{Encrypted: aws.Bool(false)},
+			opts:     MigrateOptions{KMSKeyID: keyARN, EncryptUnencryptedSnapshots: true},
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, needsEncryptedCopy(tt.snapshot, tt.opts))
+		})
+	}
+}
+
+func TestMigrateInstanceEncryptedSnapshots(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	newMockClient := func() *apitypes.MockEC2Client {
+		mockClient := apitypes.NewMockEC2Client()
+		mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{
+				{
+					Instances: []types.Instance{
+						{
+							InstanceId: aws.String("i-123"),
+							ImageId:    aws.String("ami-old"),
+							State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+							Tags: []types.Tag{
+								{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+							},
+							BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+								{
+									DeviceName: aws.String("/dev/xvda"),
+									Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1")},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+			Instances: []types.Instance{{InstanceId: aws.String("i-456")}},
+		}
+		return mockClient
+	}
+	opts := MigrateOptions{
+		NewAMI:                      "ami-new",
+		KMSKeyID:                    "arn:aws:kms:us-east-1:123456789012:key/backup",
+		EncryptUnencryptedSnapshots: true,
+	}
+
+	t.Run("unencrypted backup replaced by encrypted copy", func(t *testing.T) {
+		mockClient := newMockClient()
+		svc := NewService(mockClient, WithRegion("us-east-1"))
+
+		_, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", opts)
+		assert.NoError(t, err)
+
+		if assert.Len(t, mockClient.CopySnapshotInputs, 1) {
+			input := mockClient.CopySnapshotInputs[0]
+			assert.Equal(t, "snap-1", aws.ToString(input.SourceSnapshotId))
+			assert.Equal(t, "us-east-1", aws.ToString(input.SourceRegion))
+			assert.True(t, aws.ToBool(input.Encrypted))
+			assert.Equal(t, opts.KMSKeyID, aws.ToString(input.KmsKeyId))
+			// The copy keeps the tags rollback and cleanup look for
+			assert.Equal(t, "i-123", tagValue(input.TagSpecifications[0].Tags, "ami-migrate-instance"))
+		}
+		assert.Equal(t, []string{"snap-1"}, mockClient.DeletedSnapshots)
+		if assert.Len(t, mockClient.Snapshots, 1) {
+			assert.Equal(t, "snap-copy-1", aws.ToString(mockClient.Snapshots[0].SnapshotId))
+		}
+	})
+
+	t.Run("region required for the copy", func(t *testing.T) {
+		mockClient := newMockClient()
+		svc := NewService(mockClient)
+
+		_, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", opts)
+		assert.ErrorContains(t, err, "encrypt snapshot snap-1: client region unknown")
+		assert.Empty(t, mockClient.RunInstancesInputs)
+	})
+}
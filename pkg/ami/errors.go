@@ -0,0 +1,74 @@
+package ami
+
+import (
+	"errors"
+
+	"github.com/aws/smithy-go"
+)
+
+// Failure categories for the AWS errors a migration commonly runs into. The
+// errors returned by the service wrap the original AWS error, so use
+// errors.Is to branch on the category and errors.As with smithy.APIError to
+// get at the error code.
+var (
+	// ErrInsufficientCapacity means EC2 had no capacity for the instance type
+	// in the requested Availability Zone
+	ErrInsufficientCapacity = errors.New("insufficient capacity")
+	// ErrInvalidAMI means the AMI doesn't exist, is malformed or can't be
+	// launched
+	ErrInvalidAMI = errors.New("invalid AMI")
+	// ErrInstanceNotFound means the instance doesn't exist
+	ErrInstanceNotFound = errors.New("instance not found")
+	// ErrThrottled means EC2 rate limited the request even after the SDK's
+	// retries
+	ErrThrottled = errors.New("request throttled")
+	// ErrEmptyEnabledValue means MigrateInstances was asked for the instances
+	// whose enabled tag is empty, which is almost always a mistake
+	ErrEmptyEnabledValue = errors.New("enabled tag value is empty")
+)
+
+// errorCodeCategories maps EC2 API error codes to their failure category
+var errorCodeCategories = map[string]error{
+	"InsufficientInstanceCapacity":          ErrInsufficientCapacity,
+	"InsufficientHostCapacity":              ErrInsufficientCapacity,
+	"InsufficientReservedInstanceCapacity":  ErrInsufficientCapacity,
+	"InsufficientCapacity":                  ErrInsufficientCapacity,
+	"InvalidAMIID.NotFound":                 ErrInvalidAMI,
+	"InvalidAMIID.Malformed":                ErrInvalidAMI,
+	"InvalidAMIID.Unavailable":              ErrInvalidAMI,
+	"InvalidInstanceID.NotFound":            ErrInstanceNotFound,
+	"InvalidInstanceID.Malformed":           ErrInstanceNotFound,
+	"RequestLimitExceeded":                  ErrThrottled,
+	"Throttling":                            ErrThrottled,
+	"ThrottlingException":                   ErrThrottled,
+	"SnapshotCreationPerVolumeRateExceeded": ErrThrottled,
+}
+
+// categorizedError adds a failure category to an error without changing its
+// message
+type categorizedError struct {
+	category error
+	err      error
+}
+
+func (e *categorizedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categorizedError) Unwrap() []error {
+	return []error{e.category, e.err}
+}
+
+// classifyError wraps err with the failure category of the AWS API error in
+// its chain, if the error code has one
+func classifyError(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	category, ok := errorCodeCategories[apiErr.ErrorCode()]
+	if !ok || errors.Is(err, category) {
+		return err
+	}
+	return &categorizedError{category: category, err: err}
+}
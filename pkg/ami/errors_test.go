@@ -0,0 +1,182 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func apiError(code string) error {
+	return &smithy.GenericAPIError{Code: code, Message: "injected " + code}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "capacity", err: apiError("InsufficientInstanceCapacity"), want: ErrInsufficientCapacity},
+		{name: "invalid AMI", err: apiError("InvalidAMIID.NotFound"), want: ErrInvalidAMI},
+		{name: "unknown instance", err: apiError("InvalidInstanceID.NotFound"), want: ErrInstanceNotFound},
+		{name: "throttled", err: apiError("RequestLimitExceeded"), want: ErrThrottled},
+		{name: "wrapped", err: fmt.Errorf("run instances: %w", apiError("Throttling")), want: ErrThrottled},
+		{name: "unmapped code", err: apiError("UnauthorizedOperation")},
+		{name: "not an API error", err: fmt.Errorf("boom")},
+	}
+
+	categories := []error{ErrInsufficientCapacity, ErrInvalidAMI, ErrInstanceNotFound, ErrThrottled}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(tt.err)
+			// The message and the original error are kept
+			assert.Equal(t, tt.err.Error(), err.Error())
+			assert.ErrorIs(t, err, tt.err)
+			for _, category := range categories {
+				assert.Equal(t, category == tt.want, errors.Is(err, category), category.Error())
+			}
+		})
+	}
+}
+
+func TestMigrateInstanceErrorCategories(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name      string
+		setupMock func(*apitypes.MockEC2Client)
+		want      error
+		wantCode  string
+	}{
+		{
+			name: "no capacity for the replacement",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.RunInstancesError = apiError("InsufficientInstanceCapacity")
+			},
+			want:     ErrInsufficientCapacity,
+			wantCode: "InsufficientInstanceCapacity",
+		},
+		{
+			name: "snapshot throttled",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.CreateSnapshotError = apiError("SnapshotCreationPerVolumeRateExceeded")
+			},
+			want:     ErrThrottled,
+			wantCode: "SnapshotCreationPerVolumeRateExceeded",
+		},
+		{
+			name: "AMI can't be launched",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.RunInstancesError = apiError("InvalidAMIID.Unavailable")
+			},
+			want:     ErrInvalidAMI,
+			wantCode: "InvalidAMIID.Unavailable",
+		},
+		{
+			name: "instance lookup fails",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesError = apiError("InvalidInstanceID.NotFound")
+			},
+			want:     ErrInstanceNotFound,
+			wantCode: "InvalidInstanceID.NotFound",
+		},
+		{
+			name: "instance doesn't exist",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{}
+			},
+			want: ErrInstanceNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-1"),
+								ImageId:    aws.String("ami-old"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+								BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+									{
+										DeviceName: aws.String("/dev/xvda"),
+										Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1")},
+									},
+								},
+								Tags: []types.Tag{
+									{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+								},
+							},
+						},
+					},
+				},
+			}
+			tt.setupMock(mockClient)
+
+			svc := NewService(mockClient)
+			_, err := svc.MigrateInstanceWithOptions(context.Background(), "i-1", MigrateOptions{NewAMI: "ami-new"})
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.want)
+
+			var apiErr smithy.APIError
+			if tt.wantCode == "" {
+				assert.False(t, errors.As(err, &apiErr))
+				return
+			}
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, tt.wantCode, apiErr.ErrorCode())
+			}
+		})
+	}
+}
+
+func TestMigrateInstancesInvalidTargetAMI(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name   string
+		images []types.Image
+	}{
+		{name: "missing"},
+		{name: "not available", images: []types.Image{{ImageId: aws.String("ami-new"), State: types.ImageStatePending}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = tt.images
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-1"),
+								ImageId:    aws.String("ami-old"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+							},
+						},
+					},
+				},
+			}
+
+			svc := NewService(mockClient)
+			_, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+			assert.ErrorIs(t, err, ErrInvalidAMI)
+		})
+	}
+}
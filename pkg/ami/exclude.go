@@ -0,0 +1,25 @@
+package ami
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// excludedByTag reports whether the instance carries any of the tags in
+// excludeTags, which are held back from a run by MigrateOptions.ExcludeTags,
+// and the skip reason naming the first matching tag
+func excludedByTag(instance types.Instance, excludeTags map[string]string) (bool, string) {
+	keys := make([]string, 0, len(excludeTags))
+	for key := range excludeTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if hasTag(instance.Tags, key, excludeTags[key]) {
+			return true, fmt.Sprintf("excluded by tag %s=%s", key, excludeTags[key])
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,107 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstancesExcludeTags(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := func(id string, state types.InstanceStateName, tags ...types.Tag) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: state},
+			Tags: append([]types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+			}, tags...),
+		}
+	}
+	tag := func(key, value string) types.Tag {
+		return types.Tag{Key: aws.String(key), Value: aws.String(value)}
+	}
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					instance("i-held", types.InstanceStateNameRunning, tag("maintenance", "hold")),
+					instance("i-frozen", types.InstanceStateNameStopped, tag("change-freeze", "true")),
+					instance("i-other-value", types.InstanceStateNameStopped, tag("maintenance", "done")),
+					instance("i-plain", types.InstanceStateNameStopped),
+				},
+			},
+		},
+	}
+	mockClient.InstanceStates = map[string]types.InstanceStateName{
+		"i-held":        types.InstanceStateNameRunning,
+		"i-frozen":      types.InstanceStateNameStopped,
+		"i-other-value": types.InstanceStateNameStopped,
+		"i-plain":       types.InstanceStateNameStopped,
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:      "ami-new",
+		ExcludeTags: map[string]string{"maintenance": "hold", "change-freeze": "true"},
+	})
+	require.NoError(t, err)
+
+	statuses := make(map[string]InstanceResult)
+	for _, r := range result.Instances {
+		statuses[r.InstanceID] = r
+	}
+	assert.Equal(t, StatusSkipped, statuses["i-held"].Status)
+	assert.Equal(t, "excluded by tag maintenance=hold", statuses["i-held"].Message)
+	assert.Equal(t, StatusSkipped, statuses["i-frozen"].Status)
+	assert.Equal(t, "excluded by tag change-freeze=true", statuses["i-frozen"].Message)
+	assert.Equal(t, StatusCompleted, statuses["i-other-value"].Status)
+	assert.Equal(t, StatusCompleted, statuses["i-plain"].Status)
+
+	// Excluded instances are never stopped or terminated
+	assert.Equal(t, types.InstanceStateNameRunning, mockClient.InstanceStates["i-held"])
+	assert.Equal(t, types.InstanceStateNameStopped, mockClient.InstanceStates["i-frozen"])
+	assert.Equal(t, types.InstanceStateNameTerminated, mockClient.InstanceStates["i-plain"])
+
+	// They're tagged as skipped
+	var skippedTags []string
+	for _, input := range mockClient.CreateTagsInputs {
+		for _, tag := range input.Tags {
+			if aws.ToString(tag.Key) == "ami-migrate-status" && aws.ToString(tag.Value) == StatusSkipped {
+				skippedTags = append(skippedTags, input.Resources...)
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{"i-held", "i-frozen"}, skippedTags)
+}
+
+func TestPlanInstanceExcludeTags(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	svc := NewService(apitypes.NewMockEC2Client())
+	plan := svc.planInstance(context.Background(), types.Instance{
+		InstanceId: aws.String("i-held"),
+		ImageId:    aws.String("ami-old"),
+		State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+		Tags:       []types.Tag{{Key: aws.String("maintenance"), Value: aws.String("hold")}},
+	}, MigrateOptions{
+		NewAMI:      "ami-new",
+		ExcludeTags: map[string]string{"maintenance": "hold"},
+	})
+	assert.False(t, plan.Migrate)
+	assert.Equal(t, "excluded by tag maintenance=hold", plan.Reason)
+}
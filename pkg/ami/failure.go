@@ -0,0 +1,95 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// SnapshotsError is returned when a migration fails after snapshots of the
+// instance's volumes were taken. It records the snapshots so the operator can
+// decide what to do with the ones that were kept.
+type SnapshotsError struct {
+	Err error
+	// SnapshotIDs are the snapshots the failed migration created
+	SnapshotIDs []string
+	// DeletedSnapshotIDs are the snapshots cleaned up after the failure
+	DeletedSnapshotIDs []string
+}
+
+// KeptSnapshotIDs returns the snapshots that still exist
+func (e *SnapshotsError) KeptSnapshotIDs() []string {
+	deleted := make(map[string]bool, len(e.DeletedSnapshotIDs))
+	for _, snapshotID := range e.DeletedSnapshotIDs {
+		deleted[snapshotID] = true
+	}
+	var kept []string
+	for _, snapshotID := range e.SnapshotIDs {
+		if !deleted[snapshotID] {
+			kept = append(kept, snapshotID)
+		}
+	}
+	return kept
+}
+
+func (e *SnapshotsError) Error() string {
+	var parts []string
+	if kept := e.KeptSnapshotIDs(); len(kept) > 0 {
+		parts = append(parts, "snapshots kept: "+strings.Join(kept, ", "))
+	}
+	if len(e.DeletedSnapshotIDs) > 0 {
+		parts = append(parts, "snapshots deleted: "+strings.Join(e.DeletedSnapshotIDs, ", "))
+	}
+	return fmt.Sprintf("%v (%s)", e.Err, strings.Join(parts, "; "))
+}
+
+func (e *SnapshotsError) Unwrap() error {
+	return e.Err
+}
+
+// failedMigration handles the snapshots left behind when migrating instance
+// fails with err. They are deleted unless opts.KeepSnapshotsOnFailure is set
+// or the old instance is already gone, in which case they are the only copy
+// of its data. Cleanup runs on a context detached from ctx so a cancelled run
+// still cleans up.
+func (s *Service) failedMigration(ctx context.Context, instance types.Instance, snapshotIDs []string, oldTerminated bool, opts MigrateOptions, err error) error {
+	if len(snapshotIDs) == 0 {
+		return err
+	}
+	snapshotsErr := &SnapshotsError{Err: err, SnapshotIDs: snapshotIDs}
+	if opts.KeepSnapshotsOnFailure || oldTerminated {
+		return snapshotsErr
+	}
+
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.contextWaitTimeout(ctx))
+	defer cancel()
+
+	// Snapshots that are still being taken can't always be deleted yet
+	if waitErr := s.waitForSnapshotsCompleted(cleanupCtx, snapshotIDs); waitErr != nil {
+		logger.Warn("Snapshots of failed migration did not complete, keeping them",
+			"instanceID", aws.ToString(instance.InstanceId),
+			"snapshotIDs", snapshotIDs,
+			"error", waitErr)
+		return snapshotsErr
+	}
+
+	for _, snapshotID := range snapshotIDs {
+		if _, deleteErr := s.client.DeleteSnapshot(cleanupCtx, &ec2.DeleteSnapshotInput{
+			SnapshotId: aws.String(snapshotID),
+		}); deleteErr != nil {
+			logger.Warn("Failed to delete snapshot of failed migration, keeping the rest",
+				"instanceID", aws.ToString(instance.InstanceId),
+				"snapshotID", snapshotID,
+				"error", deleteErr)
+			return snapshotsErr
+		}
+		snapshotsErr.DeletedSnapshotIDs = append(snapshotsErr.DeletedSnapshotIDs, snapshotID)
+	}
+
+	return snapshotsErr
+}
@@ -0,0 +1,117 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrationFailureSnapshots(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name        string
+		opts        MigrateOptions
+		deleteErr   error
+		wantDeleted []string
+		wantKept    []string
+		wantErr     string
+	}{
+		{
+			name:        "snapshots deleted by default",
+			wantDeleted: []string{"snap-1", "snap-2"},
+			wantErr:     "upgrade instance: run instances: insufficient capacity (snapshots deleted: snap-1, snap-2)",
+		},
+		{
+			name:     "snapshots kept on request",
+			opts:     MigrateOptions{KeepSnapshotsOnFailure: true},
+			wantKept: []string{"snap-1", "snap-2"},
+			wantErr:  "upgrade instance: run instances: insufficient capacity (snapshots kept: snap-1, snap-2)",
+		},
+		{
+			name:     "snapshots kept once the old instance is terminated",
+			opts:     MigrateOptions{PreservePrivateIP: true},
+			wantKept: []string{"snap-1", "snap-2"},
+			wantErr:  "upgrade instance: run instances: insufficient capacity (snapshots kept: snap-1, snap-2)",
+		},
+		{
+			name:      "snapshots kept when they can't be deleted",
+			deleteErr: fmt.Errorf("snapshot is in use"),
+			wantKept:  []string{"snap-1", "snap-2"},
+			wantErr:   "upgrade instance: run instances: insufficient capacity (snapshots kept: snap-1, snap-2)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId:       aws.String("i-123"),
+								ImageId:          aws.String("ami-old"),
+								SubnetId:         aws.String("subnet-1"),
+								PrivateIpAddress: aws.String("10.0.0.5"),
+								State:            &types.InstanceState{Name: types.InstanceStateNameStopped},
+								Tags: []types.Tag{
+									{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+								},
+								BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+									{
+										DeviceName: aws.String("/dev/xvda"),
+										Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1")},
+									},
+									{
+										DeviceName: aws.String("/dev/sdf"),
+										Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-2")},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+			mockClient.RunInstancesError = fmt.Errorf("insufficient capacity")
+			mockClient.DeleteSnapshotError = tt.deleteErr
+
+			svc := NewService(mockClient)
+			opts := tt.opts
+			opts.NewAMI = "ami-new"
+			result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", opts)
+			assert.EqualError(t, err, tt.wantErr)
+
+			var snapshotsErr *SnapshotsError
+			if assert.True(t, errors.As(err, &snapshotsErr)) {
+				assert.Equal(t, []string{"snap-1", "snap-2"}, snapshotsErr.SnapshotIDs)
+			}
+			if assert.NotNil(t, result) {
+				assert.Equal(t, StatusFailed, result.Status)
+				assert.Equal(t, tt.wantKept, result.KeptSnapshots)
+			}
+			if tt.deleteErr == nil {
+				assert.Equal(t, tt.wantDeleted, mockClient.DeletedSnapshots)
+			}
+		})
+	}
+}
+
+func TestMigrationFailureWithoutSnapshots(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	// Nothing was snapshotted, so the error is returned as is
+	err := fmt.Errorf("stop instance: instance is locked")
+	svc := NewService(apitypes.NewMockEC2Client())
+	assert.Same(t, err, svc.failedMigration(context.Background(), types.Instance{}, nil, false, MigrateOptions{}, err))
+}
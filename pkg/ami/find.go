@@ -0,0 +1,108 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// AMICriteria selects the AMIs FindAMIs returns. Empty fields match any AMI.
+type AMICriteria struct {
+	// NamePattern matches the AMI name, with * and ? wildcards as in the EC2
+	// name filter, e.g. "golden-ubuntu-*"
+	NamePattern string
+	// Owners are the account IDs, or self, amazon or aws-marketplace, whose
+	// AMIs are searched. Empty searches the account's own AMIs, since every
+	// public AMI would match otherwise.
+	Owners []string
+	// Architecture, e.g. x86_64 or arm64
+	Architecture string
+	// Tags the AMIs must all carry
+	Tags map[string]string
+}
+
+// FoundAMI describes an AMI matched by FindAMIs
+type FoundAMI struct {
+	AMIID        string            `json:"ami_id"`
+	Name         string            `json:"name,omitempty"`
+	OwnerID      string            `json:"owner_id"`
+	Architecture string            `json:"architecture"`
+	State        string            `json:"state"`
+	CreationDate string            `json:"creation_date"`
+	Description  string            `json:"description,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+// FindAMIs returns the AMIs matching criteria, newest first
+func (s *Service) FindAMIs(ctx context.Context, criteria AMICriteria) ([]FoundAMI, error) {
+	input := &ec2.DescribeImagesInput{
+		Owners: criteria.Owners,
+	}
+	if len(input.Owners) == 0 {
+		input.Owners = []string{"self"}
+	}
+	if criteria.NamePattern != "" {
+		input.Filters = append(input.Filters, types.Filter{
+			Name:   aws.String("name"),
+			Values: []string{criteria.NamePattern},
+		})
+	}
+	if criteria.Architecture != "" {
+		input.Filters = append(input.Filters, types.Filter{
+			Name:   aws.String("architecture"),
+			Values: []string{criteria.Architecture},
+		})
+	}
+	keys := make([]string, 0, len(criteria.Tags))
+	for key := range criteria.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		input.Filters = append(input.Filters, types.Filter{
+			Name:   aws.String("tag:" + key),
+			Values: []string{criteria.Tags[key]},
+		})
+	}
+
+	logger.Debug("Searching AMIs", "owners", input.Owners, "name", criteria.NamePattern, "architecture", criteria.Architecture)
+	images, err := s.describeImages(ctx, input)
+	if err != nil {
+		return nil, classifyError(fmt.Errorf("describe images: %w", err))
+	}
+
+	// CreationDate is an ISO 8601 timestamp, so it sorts as a string
+	sort.SliceStable(images, func(i, j int) bool {
+		left, right := aws.ToString(images[i].CreationDate), aws.ToString(images[j].CreationDate)
+		if left != right {
+			return left > right
+		}
+		return aws.ToString(images[i].ImageId) < aws.ToString(images[j].ImageId)
+	})
+
+	found := make([]FoundAMI, 0, len(images))
+	for _, image := range images {
+		ami := FoundAMI{
+			AMIID:        aws.ToString(image.ImageId),
+			Name:         aws.ToString(image.Name),
+			OwnerID:      aws.ToString(image.OwnerId),
+			Architecture: string(image.Architecture),
+			State:        string(image.State),
+			CreationDate: aws.ToString(image.CreationDate),
+			Description:  aws.ToString(image.Description),
+		}
+		if len(image.Tags) > 0 {
+			ami.Tags = make(map[string]string, len(image.Tags))
+			for _, tag := range image.Tags {
+				ami.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		}
+		found = append(found, ami)
+	}
+	return found, nil
+}
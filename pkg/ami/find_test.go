@@ -0,0 +1,106 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// describeImagesRecorder records the DescribeImages requests it is sent
+type describeImagesRecorder struct {
+	*apitypes.MockEC2Client
+	inputs []*ec2.DescribeImagesInput
+}
+
+func (c *describeImagesRecorder) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	c.inputs = append(c.inputs, params)
+	return c.MockEC2Client.DescribeImages(ctx, params, optFns...)
+}
+
+func TestFindAMIs(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	image := func(id, created string) types.Image {
+		return types.Image{
+			ImageId:      aws.String(id),
+			Name:         aws.String("golden-" + id),
+			OwnerId:      aws.String("123456789012"),
+			Architecture: types.ArchitectureValuesX8664,
+			State:        types.ImageStateAvailable,
+			CreationDate: aws.String(created),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		criteria    AMICriteria
+		wantOwners  []string
+		wantFilters []types.Filter
+	}{
+		{
+			name:       "defaults to the account's own AMIs",
+			wantOwners: []string{"self"},
+		},
+		{
+			name: "every criterion",
+			criteria: AMICriteria{
+				NamePattern:  "golden-*",
+				Owners:       []string{"self", "099720109477"},
+				Architecture: "arm64",
+				Tags:         map[string]string{"Team": "web", "Role": "golden"},
+			},
+			wantOwners: []string{"self", "099720109477"},
+			wantFilters: []types.Filter{
+				{Name: aws.String("name"), Values: []string{"golden-*"}},
+				{Name: aws.String("architecture"), Values: []string{"arm64"}},
+				{Name: aws.String("tag:Role"), Values: []string{"golden"}},
+				{Name: aws.String("tag:Team"), Values: []string{"web"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			tagged := image("ami-b", "2024-03-01T00:00:00.000Z")
+			tagged.Tags = []types.Tag{{Key: aws.String("Role"), Value: aws.String("golden")}}
+			mockClient.Images = []types.Image{
+				image("ami-a", "2024-01-01T00:00:00.000Z"),
+				tagged,
+				image("ami-c", "2024-03-01T00:00:00.000Z"),
+			}
+			client := &describeImagesRecorder{MockEC2Client: mockClient}
+
+			svc := NewService(client)
+			found, err := svc.FindAMIs(context.Background(), tt.criteria)
+			require.NoError(t, err)
+
+			require.Len(t, client.inputs, 1)
+			assert.Equal(t, tt.wantOwners, client.inputs[0].Owners)
+			assert.Equal(t, tt.wantFilters, client.inputs[0].Filters)
+
+			// Newest first, ties broken by AMI ID
+			require.Len(t, found, 3)
+			assert.Equal(t, "ami-b", found[0].AMIID)
+			assert.Equal(t, "ami-c", found[1].AMIID)
+			assert.Equal(t, "ami-a", found[2].AMIID)
+			assert.Equal(t, FoundAMI{
+				AMIID:        "ami-b",
+				Name:         "golden-ami-b",
+				OwnerID:      "123456789012",
+				Architecture: "x86_64",
+				State:        "available",
+				CreationDate: "2024-03-01T00:00:00.000Z",
+				Tags:         map[string]string{"Role": "golden"},
+			}, found[0])
+		})
+	}
+}
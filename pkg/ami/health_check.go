@@ -0,0 +1,146 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// healthCheckInterval is how long to wait between attempts while a new
+// instance's SSM agent or HTTP endpoint comes up
+const healthCheckInterval = 5 * time.Second
+
+// HealthCheck is an application smoke test run against a replacement
+// instance once it passes its EC2 status checks. The migration only
+// completes if every check given passes; otherwise the old instance is left
+// in place as it is for an unhealthy replacement.
+type HealthCheck struct {
+	// DocumentName is the SSM document to run on the new instance. It
+	// defaults to AWS-RunShellScript when Commands are given.
+	DocumentName string
+	// Commands are passed as the document's commands parameter, which is
+	// the script AWS-RunShellScript runs
+	Commands []string
+	// HTTPPath, when set, is requested on the new instance's private IP
+	// until it answers with a 2xx status
+	HTTPPath string
+	// HTTPPort is the port HTTPPath is requested on. It defaults to 80.
+	HTTPPort int
+	// Timeout bounds the whole check, including waiting for the SSM agent
+	// and the HTTP endpoint to come up. Zero uses the service's wait timeout.
+	Timeout time.Duration
+}
+
+// runHealthCheck runs check against the new instance and returns an error
+// if any part of it fails
+func (s *Service) runHealthCheck(ctx context.Context, instance types.Instance, check *HealthCheck) error {
+	if check == nil {
+		return nil
+	}
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = s.contextWaitTimeout(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	instanceID := aws.ToString(instance.InstanceId)
+	if check.DocumentName != "" || len(check.Commands) > 0 {
+		if s.ssm == nil {
+			return fmt.Errorf("no SSM client to run the health check with")
+		}
+		if err := s.waitForSSMAgent(ctx, instanceID); err != nil {
+			return err
+		}
+		if err := s.runCommand(ctx, instanceID, check.DocumentName, check.Commands, "ami-migrate health check"); err != nil {
+			return err
+		}
+	}
+
+	if check.HTTPPath != "" {
+		privateIP := aws.ToString(instance.PrivateIpAddress)
+		if privateIP == "" {
+			// Launch responses normally carry it, but the instance has it by now
+			if current, err := s.getInstance(ctx, instanceID); err == nil {
+				privateIP = aws.ToString(current.PrivateIpAddress)
+			}
+		}
+		if privateIP == "" {
+			return fmt.Errorf("instance %s has no private IP for the HTTP health check", instanceID)
+		}
+		port := check.HTTPPort
+		if port == 0 {
+			port = 80
+		}
+		url := "http://" + net.JoinHostPort(privateIP, strconv.Itoa(port)) + check.HTTPPath
+		if err := waitForHTTP(ctx, url); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Health check passed", "instanceID", instanceID)
+	return nil
+}
+
+// waitForSSMAgent waits for a freshly launched instance's SSM agent to come online
+func (s *Service) waitForSSMAgent(ctx context.Context, instanceID string) error {
+	for {
+		online, err := s.ssmAgentOnline(ctx, instanceID)
+		if err != nil {
+			return fmt.Errorf("check SSM agent: %w", err)
+		}
+		if online {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("SSM agent on %s did not come online: %w", instanceID, ctx.Err())
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
+
+// waitForHTTP requests url until it answers with a 2xx status
+func waitForHTTP(ctx context.Context, url string) error {
+	var lastErr error
+	for {
+		err := getHealthy(ctx, url)
+		if err == nil {
+			return nil
+		}
+		// A request cut off by the timeout says less than the one before it
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+		logger.Debug("HTTP health check not passing yet", "url", url, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("HTTP health check %s: %w", url, lastErr)
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
+
+// getHealthy makes a single health check request
+func getHealthy(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package ami
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// lastTagValue returns the value key was last written with
+func lastTagValue(tags []types.Tag, key string) string {
+	value := ""
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			value = aws.ToString(tag.Value)
+		}
+	}
+	return value
+}
+
+func TestMigrateInstancesHealthCheck(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+	port := func(server *httptest.Server) int {
+		u, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		_, p, err := net.SplitHostPort(u.Host)
+		require.NoError(t, err)
+		n, err := strconv.Atoi(p)
+		require.NoError(t, err)
+		return n
+	}
+
+	tests := []struct {
+		name          string
+		check         *HealthCheck
+		noSSMClient   bool
+		agentOffline  bool
+		commandStatus ssmtypes.CommandInvocationStatus
+		wantErr       string
+		wantCommands  int
+	}{
+		{
+			name:  "HTTP check passes",
+			check: &HealthCheck{HTTPPath: "/healthz", HTTPPort: port(healthy)},
+		},
+		{
+			name:    "HTTP check fails",
+			check:   &HealthCheck{HTTPPath: "/healthz", HTTPPort: port(unhealthy), Timeout: 50 * time.Millisecond},
+			wantErr: "status 503 Service Unavailable",
+		},
+		{
+			name:         "SSM command passes",
+			check:        &HealthCheck{Commands: []string{"curl -fs localhost:8080/ready"}},
+			wantCommands: 1,
+		},
+		{
+			name:          "SSM command fails",
+			check:         &HealthCheck{Commands: []string{"curl -fs localhost:8080/ready"}},
+			commandStatus: ssmtypes.CommandInvocationStatusFailed,
+			wantErr:       "command cmd-1 failed: connection refused",
+			wantCommands:  1,
+		},
+		{
+			name:         "SSM agent never comes online",
+			check:        &HealthCheck{Commands: []string{"true"}, Timeout: 50 * time.Millisecond},
+			agentOffline: true,
+			wantErr:      "SSM agent on i-new did not come online",
+		},
+		{
+			name:        "no SSM client",
+			check:       &HealthCheck{Commands: []string{"true"}},
+			noSSMClient: true,
+			wantErr:     "no SSM client to run the health check with",
+		},
+		{
+			name: "both checks",
+			check: &HealthCheck{
+				Commands: []string{"true"},
+				HTTPPath: "/healthz",
+				HTTPPort: port(healthy),
+			},
+			wantCommands: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-1"),
+								ImageId:    aws.String("ami-old"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+								Tags: []types.Tag{
+									{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+								},
+							},
+						},
+					},
+				},
+			}
+			mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+				Instances: []types.Instance{{InstanceId: aws.String("i-new"), PrivateIpAddress: aws.String("127.0.0.1")}},
+			}
+			ssmClient := apitypes.NewMockSSMClient()
+			if !tt.agentOffline {
+				ssmClient.ManagedInstances = []string{"i-new"}
+			}
+			ssmClient.CommandStatus = tt.commandStatus
+			ssmClient.CommandOutput = "connection refused"
+			client := &tagRecordingClient{MockEC2Client: mockClient, tags: make(map[string][]types.Tag)}
+
+			var opts []ServiceOption
+			if !tt.noSSMClient {
+				opts = append(opts, WithSSMClient(ssmClient))
+			}
+			svc := NewService(client, opts...)
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+				NewAMI:      "ami-new",
+				HealthCheck: tt.check,
+			})
+			require.NotNil(t, result)
+			require.Len(t, result.Instances, 1)
+
+			assert.Len(t, ssmClient.SendCommandInputs, tt.wantCommands)
+			for _, input := range ssmClient.SendCommandInputs {
+				assert.Equal(t, []string{"i-new"}, input.InstanceIds)
+			}
+
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+				assert.Equal(t, StatusCompleted, lastTagValue(client.tags["i-1"], "ami-migrate-status"))
+				assert.Equal(t, types.InstanceStateNameTerminated, mockClient.InstanceStates["i-1"])
+				return
+			}
+
+			assert.Error(t, err)
+			assert.Equal(t, StatusFailed, result.Instances[0].Status)
+			assert.Contains(t, result.Instances[0].Message, "new instance i-new failed its health check, leaving i-1 in place")
+			assert.Contains(t, result.Instances[0].Message, tt.wantErr)
+			assert.Equal(t, StatusFailed, lastTagValue(client.tags["i-1"], "ami-migrate-status"))
+			// The old instance is kept since the replacement isn't trusted
+			assert.NotEqual(t, types.InstanceStateNameTerminated, mockClient.InstanceStates["i-1"])
+			assert.Equal(t, types.InstanceStateNameTerminated, mockClient.InstanceStates["i-new"])
+		})
+	}
+}
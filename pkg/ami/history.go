@@ -0,0 +1,101 @@
+package ami
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// maxTagValueLength is the longest value EC2 accepts for a tag
+const maxTagValueLength = 256
+
+// HistoryEntry records one past migration attempt in the history tag. The
+// tag holds a JSON array of entries, oldest first, and the oldest entries
+// are dropped once the array no longer fits in a tag value.
+type HistoryEntry struct {
+	// AMI is the image the instance ran before the attempt
+	AMI string `json:"ami"`
+	// At is when the attempt finished, in Unix seconds to keep entries short
+	At int64 `json:"at"`
+	// Status is the outcome of the attempt, completed or failed
+	Status string `json:"status"`
+}
+
+// ParseHistory decodes the value of the history tag
+func ParseHistory(value string) ([]HistoryEntry, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var entries []HistoryEntry
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// encodeHistory encodes entries for the history tag, dropping the oldest
+// ones until the value fits within maxTagValueLength. It returns an empty
+// string when not even the newest entry fits.
+func encodeHistory(entries []HistoryEntry) string {
+	for len(entries) > 0 {
+		value, err := json.Marshal(entries)
+		if err == nil && len(value) <= maxTagValueLength {
+			return string(value)
+		}
+		entries = entries[1:]
+	}
+	return ""
+}
+
+// historyTag returns the instance's history tag with the outcome of the
+// current attempt appended. A history that can't be parsed, e.g. after being
+// edited by hand, is started over.
+func (s *Service) historyTag(instance types.Instance, status string) (types.Tag, bool) {
+	entries, err := ParseHistory(tagValue(instance.Tags, s.tags.History))
+	if err != nil {
+		logger.Warn("Discarding unreadable migration history", "instanceID", aws.ToString(instance.InstanceId), "error", err)
+		entries = nil
+	}
+	entries = append(entries, HistoryEntry{
+		AMI:    aws.ToString(instance.ImageId),
+		At:     time.Now().Unix(),
+		Status: status,
+	})
+
+	value := encodeHistory(entries)
+	if value == "" {
+		return types.Tag{}, false
+	}
+	return types.Tag{Key: aws.String(s.tags.History), Value: aws.String(value)}, true
+}
+
+// tagMigrationOutcome records the final status of a migration attempt on
+// the instance along with its history entry. The history is also written to
+// the replacement instance, if any, so it follows the instance across
+// migrations.
+func (s *Service) tagMigrationOutcome(ctx context.Context, instance types.Instance, newInstanceID, status, message string) error {
+	history, ok := s.historyTag(instance, status)
+	if !ok {
+		return s.tagInstanceStatus(ctx, instance, status, message)
+	}
+	if err := s.tagInstanceStatus(ctx, instance, status, message, history); err != nil {
+		return err
+	}
+	if newInstanceID == "" {
+		return nil
+	}
+
+	if _, err := s.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{newInstanceID},
+		Tags:      []types.Tag{history},
+	}); err != nil {
+		// The history is only informational, so it doesn't fail the migration
+		logger.Warn("Failed to copy migration history to new instance", "instanceID", newInstanceID, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestEncodeHistory(t *testing.T) {
+	var entries []HistoryEntry
+	for i := 0; i < 10; i++ {
+		entries = append(entries, HistoryEntry{
+			AMI:    fmt.Sprintf("ami-%017d", i),
+			At:     1700000000 + int64(i),
+			Status: StatusCompleted,
+		})
+	}
+
+	value := encodeHistory(entries)
+	assert.LessOrEqual(t, len(value), maxTagValueLength)
+
+	// The oldest entries are dropped first
+	decoded, err := ParseHistory(value)
+	require.NoError(t, err)
+	require.NotEmpty(t, decoded)
+	assert.Less(t, len(decoded), len(entries))
+	assert.Equal(t, entries[len(entries)-len(decoded):], decoded)
+
+	assert.Empty(t, encodeHistory(nil))
+}
+
+func TestMigrateInstancesHistory(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name         string
+		history      string
+		terminateErr error
+		wantStatus   string
+		wantEntries  []HistoryEntry
+	}{
+		{
+			name:        "first migration",
+			wantStatus:  StatusCompleted,
+			wantEntries: []HistoryEntry{{AMI: "ami-old", Status: StatusCompleted}},
+		},
+		{
+			name:       "appends to existing history",
+			history:    `[{"ami":"ami-older","at":1700000000,"status":"failed"}]`,
+			wantStatus: StatusCompleted,
+			wantEntries: []HistoryEntry{
+				{AMI: "ami-older", At: 1700000000, Status: StatusFailed},
+				{AMI: "ami-old", Status: StatusCompleted},
+			},
+		},
+		{
+			name:        "unreadable history is started over",
+			history:     "not json",
+			wantStatus:  StatusCompleted,
+			wantEntries: []HistoryEntry{{AMI: "ami-old", Status: StatusCompleted}},
+		},
+		{
+			name:         "failed migration",
+			terminateErr: fmt.Errorf("termination protection"),
+			wantStatus:   StatusFailed,
+			wantEntries:  []HistoryEntry{{AMI: "ami-old", Status: StatusFailed}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}}
+			if tt.history != "" {
+				tags = append(tags, types.Tag{Key: aws.String("ami-migrate-history"), Value: aws.String(tt.history)})
+			}
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-1"),
+								ImageId:    aws.String("ami-old"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+								Tags:       tags,
+							},
+						},
+					},
+				},
+			}
+			mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+				Instances: []types.Instance{{InstanceId: aws.String("i-new")}},
+			}
+			mockClient.TerminateInstancesError = tt.terminateErr
+			client := &tagRecordingClient{MockEC2Client: mockClient, tags: make(map[string][]types.Tag)}
+
+			svc := NewService(client)
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+			require.NotNil(t, result)
+			if tt.wantStatus == StatusFailed {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantStatus, lastTagValue(client.tags["i-1"], "ami-migrate-status"))
+
+			owners := []string{"i-1"}
+			if tt.wantStatus == StatusCompleted {
+				// The replacement carries the history forward
+				owners = append(owners, "i-new")
+			}
+			for _, owner := range owners {
+				entries, err := ParseHistory(lastTagValue(client.tags[owner], "ami-migrate-history"))
+				require.NoError(t, err, owner)
+				require.Len(t, entries, len(tt.wantEntries), owner)
+				for i := range entries {
+					if tt.wantEntries[i].At == 0 {
+						assert.NotZero(t, entries[i].At)
+						entries[i].At = 0
+					}
+				}
+				assert.Equal(t, tt.wantEntries, entries, owner)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// restoreInstanceProfile associates the source instance's IAM instance
+// profile with a replacement that launched without one, e.g. from a launch
+// template that doesn't set a profile. Nothing is done when the source
+// instance had no profile or the replacement already has one.
+func (s *Service) restoreInstanceProfile(ctx context.Context, instance, replacement types.Instance) error {
+	if instance.IamInstanceProfile == nil || instance.IamInstanceProfile.Arn == nil {
+		return nil
+	}
+	if replacement.IamInstanceProfile != nil {
+		if aws.ToString(replacement.IamInstanceProfile.Arn) != aws.ToString(instance.IamInstanceProfile.Arn) {
+			logger.Warn("Replacement launched with a different instance profile",
+				"instanceID", aws.ToString(replacement.InstanceId),
+				"profile", aws.ToString(replacement.IamInstanceProfile.Arn),
+				"originalProfile", aws.ToString(instance.IamInstanceProfile.Arn))
+		}
+		return nil
+	}
+
+	_, err := s.client.AssociateIamInstanceProfile(ctx, &ec2.AssociateIamInstanceProfileInput{
+		InstanceId: replacement.InstanceId,
+		IamInstanceProfile: &types.IamInstanceProfileSpecification{
+			Arn: instance.IamInstanceProfile.Arn,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("associate instance profile %s: %w", aws.ToString(instance.IamInstanceProfile.Arn), err)
+	}
+	logger.Info("Associated instance profile with replacement",
+		"instanceID", aws.ToString(replacement.InstanceId), "profile", aws.ToString(instance.IamInstanceProfile.Arn))
+	return nil
+}
@@ -0,0 +1,102 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstanceKeepsInstanceProfile(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	const profileARN = "arn:aws:iam::123456789012:instance-profile/web"
+	template := &LaunchTemplate{Name: "web"}
+
+	tests := []struct {
+		name              string
+		profile           bool
+		launchTemplate    *LaunchTemplate
+		launchedProfile   string
+		wantLaunchProfile string
+		wantAssociated    bool
+	}{
+		{
+			name:              "requested at launch",
+			profile:           true,
+			wantLaunchProfile: profileARN,
+		},
+		{
+			name:           "associated when the template has none",
+			profile:        true,
+			launchTemplate: template,
+			wantAssociated: true,
+		},
+		{
+			name:            "template's profile is kept",
+			profile:         true,
+			launchTemplate:  template,
+			launchedProfile: "arn:aws:iam::123456789012:instance-profile/template",
+		},
+		{
+			name:           "no profile to keep",
+			launchTemplate: template,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := types.Instance{
+				InstanceId: aws.String("i-123"),
+				ImageId:    aws.String("ami-old"),
+				State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+				Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+			}
+			if tt.profile {
+				instance.IamInstanceProfile = &types.IamInstanceProfile{Arn: aws.String(profileARN)}
+			}
+			launched := types.Instance{InstanceId: aws.String("i-456")}
+			if tt.launchedProfile != "" {
+				launched.IamInstanceProfile = &types.IamInstanceProfile{Arn: aws.String(tt.launchedProfile)}
+			}
+
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{instance}}},
+			}
+			mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{Instances: []types.Instance{launched}}
+
+			svc := NewService(mockClient)
+			_, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", MigrateOptions{
+				NewAMI:         "ami-new",
+				LaunchTemplate: tt.launchTemplate,
+			})
+			require.NoError(t, err)
+
+			require.Len(t, mockClient.RunInstancesInputs, 1)
+			if tt.wantLaunchProfile != "" {
+				require.NotNil(t, mockClient.RunInstancesInputs[0].IamInstanceProfile)
+				assert.Equal(t, tt.wantLaunchProfile, aws.ToString(mockClient.RunInstancesInputs[0].IamInstanceProfile.Arn))
+			} else {
+				assert.Nil(t, mockClient.RunInstancesInputs[0].IamInstanceProfile)
+			}
+
+			if !tt.wantAssociated {
+				assert.Empty(t, mockClient.AssociateIamInstanceProfileInputs)
+				return
+			}
+			require.Len(t, mockClient.AssociateIamInstanceProfileInputs, 1)
+			input := mockClient.AssociateIamInstanceProfileInputs[0]
+			assert.Equal(t, "i-456", aws.ToString(input.InstanceId))
+			assert.Equal(t, profileARN, aws.ToString(input.IamInstanceProfile.Arn))
+		})
+	}
+}
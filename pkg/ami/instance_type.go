@@ -0,0 +1,64 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// instanceTypeFor returns the type the replacement for instance is launched
+// as: its per-instance override, then InstanceType, then the original type
+func (o MigrateOptions) instanceTypeFor(instance types.Instance) types.InstanceType {
+	if instanceType := o.InstanceTypes[aws.ToString(instance.InstanceId)]; instanceType != "" {
+		return instanceType
+	}
+	if o.InstanceType != "" {
+		return o.InstanceType
+	}
+	return instance.InstanceType
+}
+
+// checkInstanceTypeArchitecture returns an error unless instanceType supports
+// the architecture of amiID, so an arm64 AMI isn't launched as an x86 type or
+// the other way round. AMIs that don't report an architecture are accepted.
+func (s *Service) checkInstanceTypeArchitecture(ctx context.Context, instanceType types.InstanceType, amiID string) error {
+	if instanceType == "" {
+		return nil
+	}
+	image, err := s.getImage(ctx, amiID)
+	if err != nil {
+		return err
+	}
+	if image.Architecture == "" {
+		return nil
+	}
+
+	result, err := s.client.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{instanceType},
+	})
+	if err != nil {
+		return classifyError(fmt.Errorf("describe instance type %s: %w", instanceType, err))
+	}
+
+	for _, info := range result.InstanceTypes {
+		if info.InstanceType != instanceType {
+			continue
+		}
+		if info.ProcessorInfo != nil {
+			for _, arch := range info.ProcessorInfo.SupportedArchitectures {
+				if string(arch) == string(image.Architecture) {
+					return nil
+				}
+			}
+		}
+		return &categorizedError{
+			category: ErrInvalidAMI,
+			err: fmt.Errorf("instance type %s does not support the %s architecture of AMI %s",
+				instanceType, image.Architecture, amiID),
+		}
+	}
+	return fmt.Errorf("unknown instance type %s", instanceType)
+}
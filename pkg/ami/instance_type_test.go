@@ -0,0 +1,246 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestInstanceTypeFor(t *testing.T) {
+	instance := types.Instance{
+		InstanceId:   aws.String("i-123"),
+		InstanceType: types.InstanceTypeT3Micro,
+	}
+
+	tests := []struct {
+		name string
+		opts MigrateOptions
+		want types.InstanceType
+	}{
+		{
+			name: "original type",
+			want: types.InstanceTypeT3Micro,
+		},
+		{
+			name: "target type",
+			opts: MigrateOptions{InstanceType: types.InstanceTypeM6iLarge},
+			want: types.InstanceTypeM6iLarge,
+		},
+		{
+			name: "per-instance override wins",
+			opts: MigrateOptions{
+				InstanceType:  types.InstanceTypeM6iLarge,
+				InstanceTypes: map[string]types.InstanceType{"i-123": types.InstanceTypeC6iXlarge},
+			},
+			want: types.InstanceTypeC6iXlarge,
+		},
+		{
+			name: "override for another instance",
+			opts: MigrateOptions{
+				InstanceTypes: map[string]types.InstanceType{"i-456": types.InstanceTypeC6iXlarge},
+			},
+			want: types.InstanceTypeT3Micro,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.opts.instanceTypeFor(instance))
+		})
+	}
+}
+
+func TestMigrateInstanceWithOptionsInstanceType(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name         string
+		instanceType types.InstanceType
+		wantLaunched types.InstanceType
+		wantErr      string
+	}{
+		{
+			name:         "original type",
+			wantLaunched: types.InstanceTypeT3Micro,
+		},
+		{
+			name:         "compatible type",
+			instanceType: types.InstanceTypeM6iLarge,
+			wantLaunched: types.InstanceTypeM6iLarge,
+		},
+		{
+			name:         "incompatible architecture",
+			instanceType: types.InstanceTypeM7gLarge,
+			wantErr:      "instance type m7g.large does not support the x86_64 architecture of AMI ami-new",
+		},
+		{
+			name:         "unknown type",
+			instanceType: "x9.huge",
+			wantErr:      "unknown instance type x9.huge",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId:   aws.String("i-123"),
+								ImageId:      aws.String("ami-old"),
+								InstanceType: types.InstanceTypeT3Micro,
+								State:        &types.InstanceState{Name: types.InstanceStateNameStopped},
+								Tags: []types.Tag{
+									{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+								},
+							},
+						},
+					},
+				},
+			}
+			mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+				Instances: []types.Instance{{InstanceId: aws.String("i-456")}},
+			}
+			mockClient.Images = []types.Image{
+				{ImageId: aws.String("ami-new"), Architecture: types.ArchitectureValuesX8664},
+			}
+			mockClient.InstanceTypes = []types.InstanceTypeInfo{
+				{
+					InstanceType:  types.InstanceTypeT3Micro,
+					ProcessorInfo: &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{types.ArchitectureTypeX8664}},
+				},
+				{
+					InstanceType:  types.InstanceTypeM6iLarge,
+					ProcessorInfo: &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{types.ArchitectureTypeX8664}},
+				},
+				{
+					InstanceType:  types.InstanceTypeM7gLarge,
+					ProcessorInfo: &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{types.ArchitectureTypeArm64}},
+				},
+			}
+
+			svc := NewService(mockClient)
+			result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", MigrateOptions{
+				NewAMI:       "ami-new",
+				InstanceType: tt.instanceType,
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				if assert.NotNil(t, result) {
+					assert.Equal(t, StatusFailed, result.Status)
+				}
+				// Nothing is launched for a type that can't run the AMI
+				assert.Empty(t, mockClient.RunInstancesInputs)
+				return
+			}
+
+			assert.NoError(t, err)
+			if assert.Len(t, mockClient.RunInstancesInputs, 1) {
+				assert.Equal(t, tt.wantLaunched, mockClient.RunInstancesInputs[0].InstanceType)
+			}
+		})
+	}
+}
+
+func TestPlanInstanceMigrationInstanceType(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId:   aws.String("i-123"),
+						ImageId:      aws.String("ami-old"),
+						InstanceType: types.InstanceTypeT3Micro,
+						State:        &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags: []types.Tag{
+							{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+						},
+					},
+				},
+			},
+		},
+	}
+	mockClient.Images = []types.Image{
+		{ImageId: aws.String("ami-new"), Architecture: types.ArchitectureValuesArm64},
+	}
+	mockClient.InstanceTypes = []types.InstanceTypeInfo{
+		{
+			InstanceType:  types.InstanceTypeM6iLarge,
+			ProcessorInfo: &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{types.ArchitectureTypeX8664}},
+		},
+	}
+
+	svc := NewService(mockClient)
+	plan, err := svc.PlanInstanceMigration(context.Background(), "i-123", MigrateOptions{
+		NewAMI:       "ami-new",
+		DryRun:       true,
+		InstanceType: types.InstanceTypeM6iLarge,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "m6i.large", plan.NewInstanceType)
+	assert.False(t, plan.Migrate)
+	assert.Contains(t, plan.Reason, "does not support the arm64 architecture")
+}
+
+func TestMigrateInstanceChecksOriginalInstanceType(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId:   aws.String("i-123"),
+						ImageId:      aws.String("ami-old"),
+						InstanceType: types.InstanceTypeM6iLarge,
+						State:        &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags: []types.Tag{
+							{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+						},
+					},
+				},
+			},
+		},
+	}
+	// A Graviton AMI can't run on the instance's own x86 type
+	mockClient.Images = []types.Image{
+		{ImageId: aws.String("ami-new"), Architecture: types.ArchitectureValuesArm64},
+	}
+	mockClient.InstanceTypes = []types.InstanceTypeInfo{
+		{
+			InstanceType:  types.InstanceTypeM6iLarge,
+			ProcessorInfo: &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{types.ArchitectureTypeX8664}},
+		},
+	}
+
+	svc := NewService(mockClient)
+	plan, err := svc.PlanInstanceMigration(context.Background(), "i-123", MigrateOptions{NewAMI: "ami-new", DryRun: true})
+	assert.NoError(t, err)
+	assert.False(t, plan.Migrate)
+	assert.Empty(t, plan.NewInstanceType)
+	assert.Equal(t, "instance type m6i.large does not support the arm64 architecture of AMI ami-new", plan.Reason)
+
+	result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", MigrateOptions{NewAMI: "ami-new"})
+	assert.EqualError(t, err, "instance type m6i.large does not support the arm64 architecture of AMI ami-new")
+	assert.ErrorIs(t, err, ErrInvalidAMI)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, StatusFailed, result.Status)
+	}
+	// Nothing is launched for a type that can't run the AMI
+	assert.Empty(t, mockClient.RunInstancesInputs)
+}
@@ -0,0 +1,38 @@
+package ami
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// LaunchTemplate identifies an EC2 launch template to launch instances from.
+// The template supplies the networking, IAM instance profile and tags, while
+// an AMI given with the launch request overrides the template's own.
+type LaunchTemplate struct {
+	// ID is the template's ID, e.g. lt-0123456789abcdef0. It takes
+	// precedence over Name.
+	ID string
+	// Name is the template's name
+	Name string
+	// Version is a version number, "$Latest" or "$Default". Empty launches
+	// the template's default version.
+	Version string
+}
+
+// specification returns the launch request's reference to the template, or
+// nil when t is nil
+func (t *LaunchTemplate) specification() *types.LaunchTemplateSpecification {
+	if t == nil {
+		return nil
+	}
+	spec := &types.LaunchTemplateSpecification{}
+	if t.ID != "" {
+		spec.LaunchTemplateId = aws.String(t.ID)
+	} else {
+		spec.LaunchTemplateName = aws.String(t.Name)
+	}
+	if t.Version != "" {
+		spec.Version = aws.String(t.Version)
+	}
+	return spec
+}
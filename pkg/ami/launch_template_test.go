@@ -0,0 +1,146 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestLaunchTemplateSpecification(t *testing.T) {
+	var template *LaunchTemplate
+	assert.Nil(t, template.specification())
+
+	assert.Equal(t, &types.LaunchTemplateSpecification{
+		LaunchTemplateId: aws.String("lt-123"),
+		Version:          aws.String("3"),
+	}, (&LaunchTemplate{ID: "lt-123", Name: "web", Version: "3"}).specification())
+
+	assert.Equal(t, &types.LaunchTemplateSpecification{
+		LaunchTemplateName: aws.String("web"),
+	}, (&LaunchTemplate{Name: "web"}).specification())
+}
+
+func TestMigrateInstancesLaunchTemplate(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId:   aws.String("i-123"),
+						ImageId:      aws.String("ami-old"),
+						InstanceType: types.InstanceTypeT3Small,
+						SubnetId:     aws.String("subnet-123"),
+						SecurityGroups: []types.GroupIdentifier{
+							{GroupId: aws.String("sg-1")},
+						},
+						State: &types.InstanceState{Name: types.InstanceStateNameStopped},
+					},
+				},
+			},
+		},
+	}
+
+	svc := NewService(mockClient)
+	_, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:         "ami-new",
+		LaunchTemplate: &LaunchTemplate{Name: "web", Version: "$Latest"},
+	})
+	require.NoError(t, err)
+
+	// The template's networking is used, while the new AMI and the
+	// original type override the template's
+	if assert.Len(t, mockClient.RunInstancesInputs, 1) {
+		input := mockClient.RunInstancesInputs[0]
+		assert.Equal(t, &types.LaunchTemplateSpecification{
+			LaunchTemplateName: aws.String("web"),
+			Version:            aws.String("$Latest"),
+		}, input.LaunchTemplate)
+		assert.Equal(t, "ami-new", aws.ToString(input.ImageId))
+		assert.Equal(t, types.InstanceTypeT3Small, input.InstanceType)
+		assert.Nil(t, input.SubnetId)
+		assert.Empty(t, input.SecurityGroupIds)
+	}
+}
+
+func TestCreateInstanceLaunchTemplate(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name     string
+		config   InstanceConfig
+		wantAMI  string
+		wantType types.InstanceType
+	}{
+		{
+			name: "template's AMI and type",
+			config: InstanceConfig{
+				Name:           "web-1",
+				UserID:         "user123",
+				LaunchTemplate: &LaunchTemplate{ID: "lt-123"},
+			},
+		},
+		{
+			name: "AMI and type override the template's",
+			config: InstanceConfig{
+				Name:           "web-1",
+				UserID:         "user123",
+				AMIID:          "ami-override",
+				InstanceType:   "m5.large",
+				LaunchTemplate: &LaunchTemplate{ID: "lt-123"},
+			},
+			wantAMI:  "ami-override",
+			wantType: types.InstanceTypeM5Large,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+				Instances: []types.Instance{{InstanceId: aws.String("i-456")}},
+			}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId:   aws.String("i-456"),
+								ImageId:      aws.String("ami-template"),
+								InstanceType: types.InstanceTypeT3Micro,
+								State:        &types.InstanceState{Name: types.InstanceStateNameRunning},
+							},
+						},
+					},
+				},
+			}
+
+			svc := NewService(mockClient)
+			summary, err := svc.CreateInstance(context.Background(), tt.config)
+			require.NoError(t, err)
+
+			if assert.Len(t, mockClient.RunInstancesInputs, 1) {
+				input := mockClient.RunInstancesInputs[0]
+				assert.Equal(t, "lt-123", aws.ToString(input.LaunchTemplate.LaunchTemplateId))
+				assert.Equal(t, tt.wantAMI, aws.ToString(input.ImageId))
+				assert.Equal(t, tt.wantType, input.InstanceType)
+			}
+			if tt.wantAMI == "" {
+				assert.Equal(t, "ami-template", summary.CurrentAMI)
+				assert.Equal(t, string(types.InstanceTypeT3Micro), summary.Size)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// ManagedInstance describes an instance enrolled in migration and its last recorded migration status
+type ManagedInstance struct {
+	InstanceID string `json:"instance_id"`
+	Name       string `json:"name,omitempty"`
+	State      string `json:"state"`
+	CurrentAMI string `json:"current_ami"`
+	Status     string `json:"status,omitempty"`
+	Message    string `json:"message,omitempty"`
+	// Timestamp is when the status was recorded. It is the zero time,
+	// 0001-01-01T00:00:00Z, for instances without a valid timestamp tag.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ListInstances returns every instance carrying the enabled tag along with the
+// status recorded in its status, message and timestamp tags (ami-migrate and
+// ami-migrate-status, -message and -timestamp by default). Instances that
+// have never been migrated have an empty status.
+func (s *Service) ListInstances(ctx context.Context) ([]ManagedInstance, error) {
+	described, err := s.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []string{s.tags.Enabled},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe instances: %w", err)
+	}
+
+	var instances []ManagedInstance
+	for _, instance := range described {
+		instances = append(instances, s.managedInstance(instance))
+	}
+
+	return instances, nil
+}
+
+// managedInstance reads the migration status tags of an instance
+func (s *Service) managedInstance(instance types.Instance) ManagedInstance {
+	managed := ManagedInstance{
+		InstanceID: aws.ToString(instance.InstanceId),
+		Name:       tagValue(instance.Tags, "Name"),
+		CurrentAMI: aws.ToString(instance.ImageId),
+		Status:     tagValue(instance.Tags, s.tags.Status),
+		Message:    tagValue(instance.Tags, s.tags.Message),
+	}
+	if instance.State != nil {
+		managed.State = string(instance.State.Name)
+	}
+	if timestamp := tagValue(instance.Tags, s.tags.Timestamp); timestamp != "" {
+		parsed, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			logger.Debug("Ignoring malformed migration timestamp", "instanceID", managed.InstanceID, "timestamp", timestamp, "error", err)
+		} else {
+			managed.Timestamp = parsed
+		}
+	}
+	return managed
+}
+
+// MigratedSince reports whether the instance's last migration, or migration
+// attempt, was recorded after since. Instances without a valid timestamp tag
+// never match.
+func (m ManagedInstance) MigratedSince(since time.Time) bool {
+	return !m.Timestamp.IsZero() && m.Timestamp.After(since)
+}
+
+// FilterMigratedSince returns the instances whose last migration was recorded
+// after since, in their original order
+func FilterMigratedSince(instances []ManagedInstance, since time.Time) []ManagedInstance {
+	var filtered []ManagedInstance
+	for _, instance := range instances {
+		if instance.Timestamp.IsZero() {
+			logger.Debug("Excluding instance without a migration timestamp", "instanceID", instance.InstanceID)
+			continue
+		}
+		if instance.MigratedSince(since) {
+			filtered = append(filtered, instance)
+		}
+	}
+	return filtered
+}
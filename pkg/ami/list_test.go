@@ -0,0 +1,150 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestListInstances(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name        string
+		setupMock   func(*apitypes.MockEC2Client)
+		want        []ManagedInstance
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "reports migration status tags",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{
+						{
+							Instances: []types.Instance{
+								{
+									InstanceId: aws.String("i-123"),
+									ImageId:    aws.String("ami-new"),
+									State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+									Tags: []types.Tag{
+										{Key: aws.String("Name"), Value: aws.String("web-1")},
+										{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+										{Key: aws.String("ami-migrate-status"), Value: aws.String("completed")},
+										{Key: aws.String("ami-migrate-message"), Value: aws.String("Migrated to AMI: ami-new")},
+										{Key: aws.String("ami-migrate-timestamp"), Value: aws.String("2024-06-01T12:00:00Z")},
+									},
+								},
+								{
+									InstanceId: aws.String("i-456"),
+									ImageId:    aws.String("ami-old"),
+									State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+									Tags: []types.Tag{
+										{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+									},
+								},
+							},
+						},
+					},
+				}
+			},
+			want: []ManagedInstance{
+				{
+					InstanceID: "i-123",
+					Name:       "web-1",
+					State:      "running",
+					CurrentAMI: "ami-new",
+					Status:     "completed",
+					Message:    "Migrated to AMI: ami-new",
+					Timestamp:  time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+				},
+				{
+					InstanceID: "i-456",
+					State:      "stopped",
+					CurrentAMI: "ami-old",
+				},
+			},
+		},
+		{
+			name: "describe error",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.DescribeInstancesError = fmt.Errorf("access denied")
+			},
+			wantErr:     true,
+			errContains: "describe instances: access denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock client
+			mockClient := apitypes.NewMockEC2Client()
+			if tt.setupMock != nil {
+				tt.setupMock(mockClient)
+			}
+
+			// Create service with mock client
+			svc := NewService(mockClient)
+
+			// Run test
+			instances, err := svc.ListInstances(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errContains != "" {
+					assert.Contains(t, err.Error(), tt.errContains)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, instances)
+		})
+	}
+}
+
+func TestFilterMigratedSince(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	since := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	instance := func(id, timestamp string) types.Instance {
+		tags := []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}}
+		if timestamp != "" {
+			tags = append(tags, types.Tag{Key: aws.String("ami-migrate-timestamp"), Value: aws.String(timestamp)})
+		}
+		return types.Instance{InstanceId: aws.String(id), Tags: tags}
+	}
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{
+			instance("i-after", "2024-06-02T08:00:00Z"),
+			instance("i-before", "2024-05-31T23:59:59Z"),
+			instance("i-exact", "2024-06-01T00:00:00Z"),
+			instance("i-missing", ""),
+			instance("i-malformed", "last tuesday"),
+			instance("i-offset", "2024-06-01T01:00:00+02:00"),
+		}}},
+	}
+
+	svc := NewService(mockClient)
+	instances, err := svc.ListInstances(context.Background())
+	assert.NoError(t, err)
+
+	var ids []string
+	for _, instance := range FilterMigratedSince(instances, since) {
+		ids = append(ids, instance.InstanceID)
+	}
+	// 01:00+02:00 is 23:00 UTC the day before
+	assert.Equal(t, []string{"i-after"}, ids)
+	assert.Empty(t, FilterMigratedSince(instances, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,99 @@
+package ami
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// WithLogger logs every mutating EC2 call the service makes to logger: the call
+// and the IDs involved at debug level, and failures at error level. Without it
+// these calls aren't logged.
+func WithLogger(logger *slog.Logger) ServiceOption {
+	return func(s *Service) {
+		s.logger = logger
+	}
+}
+
+// loggingClient logs the mutating calls made through an EC2 client. Calls that
+// only describe resources go straight to the wrapped client.
+type loggingClient struct {
+	apitypes.EC2ClientAPI
+	logger *slog.Logger
+}
+
+// logCall logs a finished EC2 call. DryRun calls fail by design when they
+// would have succeeded, so their errors stay at debug level.
+func (c *loggingClient) logCall(ctx context.Context, operation string, dryRun *bool, err error, args ...any) {
+	if aws.ToBool(dryRun) {
+		args = append(args, "dryRun", true)
+	}
+	if err != nil && !aws.ToBool(dryRun) {
+		c.logger.ErrorContext(ctx, "EC2 "+operation+" failed", append(args, "error", err)...)
+		return
+	}
+	if err != nil {
+		args = append(args, "error", err)
+	}
+	c.logger.DebugContext(ctx, "EC2 "+operation, args...)
+}
+
+// CreateSnapshot implements EC2ClientAPI
+func (c *loggingClient) CreateSnapshot(ctx context.Context, params *ec2.CreateSnapshotInput, optFns ...func(*ec2.Options)) (*ec2.CreateSnapshotOutput, error) {
+	output, err := c.EC2ClientAPI.CreateSnapshot(ctx, params, optFns...)
+	args := []any{"volumeID", aws.ToString(params.VolumeId)}
+	if output != nil {
+		args = append(args, "snapshotID", aws.ToString(output.SnapshotId))
+	}
+	c.logCall(ctx, "CreateSnapshot", params.DryRun, err, args...)
+	return output, err
+}
+
+// RunInstances implements EC2ClientAPI
+func (c *loggingClient) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	output, err := c.EC2ClientAPI.RunInstances(ctx, params, optFns...)
+	args := []any{
+		"imageID", aws.ToString(params.ImageId),
+		"instanceType", string(params.InstanceType),
+	}
+	if params.SubnetId != nil {
+		args = append(args, "subnetID", aws.ToString(params.SubnetId))
+	}
+	if output != nil {
+		instanceIDs := make([]string, 0, len(output.Instances))
+		for _, instance := range output.Instances {
+			instanceIDs = append(instanceIDs, aws.ToString(instance.InstanceId))
+		}
+		args = append(args, "instanceIDs", instanceIDs)
+	}
+	c.logCall(ctx, "RunInstances", params.DryRun, err, args...)
+	return output, err
+}
+
+// StopInstances implements EC2ClientAPI
+func (c *loggingClient) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	output, err := c.EC2ClientAPI.StopInstances(ctx, params, optFns...)
+	c.logCall(ctx, "StopInstances", params.DryRun, err, "instanceIDs", params.InstanceIds)
+	return output, err
+}
+
+// TerminateInstances implements EC2ClientAPI
+func (c *loggingClient) TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+	output, err := c.EC2ClientAPI.TerminateInstances(ctx, params, optFns...)
+	c.logCall(ctx, "TerminateInstances", params.DryRun, err, "instanceIDs", params.InstanceIds)
+	return output, err
+}
+
+// CreateTags implements EC2ClientAPI
+func (c *loggingClient) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	output, err := c.EC2ClientAPI.CreateTags(ctx, params, optFns...)
+	tagKeys := make([]string, 0, len(params.Tags))
+	for _, tag := range params.Tags {
+		tagKeys = append(tagKeys, aws.ToString(tag.Key))
+	}
+	c.logCall(ctx, "CreateTags", params.DryRun, err, "resourceIDs", params.Resources, "tagKeys", tagKeys)
+	return output, err
+}
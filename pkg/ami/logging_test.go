@@ -0,0 +1,56 @@
+package ami
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestWithLogger(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	t.Run("logs calls and failures", func(t *testing.T) {
+		var buf bytes.Buffer
+		mockClient := apitypes.NewMockEC2Client()
+		mockClient.StopInstancesError = fmt.Errorf("instance is locked")
+		svc := NewService(mockClient, WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+		assert.NoError(t, svc.TagAMI(context.Background(), "ami-123", "Status", "latest"))
+		_, err := svc.client.StopInstances(context.Background(), &ec2.StopInstancesInput{InstanceIds: []string{"i-123"}})
+		assert.Error(t, err)
+
+		out := buf.String()
+		assert.Contains(t, out, `level=DEBUG msg="EC2 CreateTags" resourceIDs=[ami-123] tagKeys=[Status]`)
+		assert.Contains(t, out, `level=ERROR msg="EC2 StopInstances failed" instanceIDs=[i-123] error="instance is locked"`)
+	})
+
+	t.Run("dry run errors stay at debug", func(t *testing.T) {
+		var buf bytes.Buffer
+		svc := NewService(apitypes.NewMockEC2Client(), WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+		_, err := svc.client.TerminateInstances(context.Background(), &ec2.TerminateInstancesInput{
+			InstanceIds: []string{"i-123"},
+			DryRun:      aws.Bool(true),
+		})
+		assert.Error(t, err)
+
+		out := buf.String()
+		assert.Contains(t, out, `level=DEBUG msg="EC2 TerminateInstances" instanceIDs=[i-123] dryRun=true`)
+		assert.NotContains(t, out, "level=ERROR")
+	})
+
+	t.Run("no logger leaves the client alone", func(t *testing.T) {
+		mockClient := apitypes.NewMockEC2Client()
+		svc := NewService(mockClient)
+		assert.Same(t, mockClient, svc.client)
+	})
+}
@@ -0,0 +1,103 @@
+package ami
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// Metric names published for MigrateOptions.MetricsNamespace
+const (
+	MetricMigrationsSucceeded      = "MigrationsSucceeded"
+	MetricMigrationsFailed         = "MigrationsFailed"
+	MetricMigrationDurationSeconds = "MigrationDurationSeconds"
+)
+
+// metricDimensionAMI is the dimension holding the target AMI of a migration
+const metricDimensionAMI = "AMI"
+
+// maxMetricDataPerCall is the most data points PutMetricData accepts at once
+const maxMetricDataPerCall = 1000
+
+// WithCloudWatchClient sets the CloudWatch client migration metrics are
+// published with
+func WithCloudWatchClient(client apitypes.CloudWatchClientAPI) ServiceOption {
+	return func(s *Service) {
+		s.metrics = client
+	}
+}
+
+// putMigrationMetrics publishes a count and, for completed migrations, the
+// duration of every completed or failed result to opts.MetricsNamespace.
+// Metrics are best effort: errors are logged and never fail the migration.
+func (s *Service) putMigrationMetrics(ctx context.Context, results []InstanceResult, opts MigrateOptions) {
+	if opts.MetricsNamespace == "" {
+		return
+	}
+	if s.metrics == nil {
+		logger.Warn("No CloudWatch client, not publishing migration metrics", "namespace", opts.MetricsNamespace)
+		return
+	}
+
+	data := migrationMetricData(results)
+	if len(data) == 0 {
+		return
+	}
+
+	// The run may have been cancelled, but what did finish is still reported
+	metricsCtx, cancel := detachedContext(ctx)
+	defer cancel()
+	for start := 0; start < len(data); start += maxMetricDataPerCall {
+		end := min(start+maxMetricDataPerCall, len(data))
+		_, err := s.metrics.PutMetricData(metricsCtx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(opts.MetricsNamespace),
+			MetricData: data[start:end],
+		})
+		if err != nil {
+			logger.Warn("Failed to publish migration metrics", "namespace", opts.MetricsNamespace, "error", err)
+			return
+		}
+	}
+}
+
+// migrationMetricData returns the metric data points for results. Skipped
+// and cancelled instances weren't migrated and aren't counted.
+func migrationMetricData(results []InstanceResult) []cwtypes.MetricDatum {
+	var data []cwtypes.MetricDatum
+	for _, result := range results {
+		var dimensions []cwtypes.Dimension
+		// The target AMI is unknown when it couldn't be resolved
+		if result.NewAMI != "" {
+			dimensions = []cwtypes.Dimension{{Name: aws.String(metricDimensionAMI), Value: aws.String(result.NewAMI)}}
+		}
+
+		switch result.Status {
+		case StatusCompleted:
+			data = append(data,
+				cwtypes.MetricDatum{
+					MetricName: aws.String(MetricMigrationsSucceeded),
+					Dimensions: dimensions,
+					Unit:       cwtypes.StandardUnitCount,
+					Value:      aws.Float64(1),
+				},
+				cwtypes.MetricDatum{
+					MetricName: aws.String(MetricMigrationDurationSeconds),
+					Dimensions: dimensions,
+					Unit:       cwtypes.StandardUnitSeconds,
+					Value:      aws.Float64(result.Duration.Seconds()),
+				})
+		case StatusFailed:
+			data = append(data, cwtypes.MetricDatum{
+				MetricName: aws.String(MetricMigrationsFailed),
+				Dimensions: dimensions,
+				Unit:       cwtypes.StandardUnitCount,
+				Value:      aws.Float64(1),
+			})
+		}
+	}
+	return data
+}
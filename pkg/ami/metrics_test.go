@@ -0,0 +1,200 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrationMetricData(t *testing.T) {
+	type point struct {
+		name  string
+		ami   string
+		value float64
+	}
+
+	tests := []struct {
+		name    string
+		results []InstanceResult
+		want    []point
+	}{
+		{
+			name:    "completed",
+			results: []InstanceResult{{InstanceID: "i-1", Status: StatusCompleted, NewAMI: "ami-new", Duration: 90 * time.Second}},
+			want: []point{
+				{name: MetricMigrationsSucceeded, ami: "ami-new", value: 1},
+				{name: MetricMigrationDurationSeconds, ami: "ami-new", value: 90},
+			},
+		},
+		{
+			name:    "failed",
+			results: []InstanceResult{{InstanceID: "i-1", Status: StatusFailed, NewAMI: "ami-new", Duration: time.Minute}},
+			want:    []point{{name: MetricMigrationsFailed, ami: "ami-new", value: 1}},
+		},
+		{
+			name:    "failed before the target AMI was known",
+			results: []InstanceResult{{InstanceID: "i-1", Status: StatusFailed}},
+			want:    []point{{name: MetricMigrationsFailed, value: 1}},
+		},
+		{
+			name: "skipped and cancelled aren't counted",
+			results: []InstanceResult{
+				{InstanceID: "i-1", Status: StatusSkipped, NewAMI: "ami-new"},
+				{InstanceID: "i-2", Status: StatusCancelled},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []point
+			for _, datum := range migrationMetricData(tt.results) {
+				p := point{name: aws.ToString(datum.MetricName), value: aws.ToFloat64(datum.Value)}
+				for _, dimension := range datum.Dimensions {
+					if aws.ToString(dimension.Name) == metricDimensionAMI {
+						p.ami = aws.ToString(dimension.Value)
+					}
+				}
+				got = append(got, p)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMigrateInstanceMetrics(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name        string
+		namespace   string
+		runErr      error
+		putErr      error
+		wantMetrics []string
+		wantErr     bool
+	}{
+		{
+			name:        "completed migration",
+			namespace:   "ECManager",
+			wantMetrics: []string{MetricMigrationsSucceeded, MetricMigrationDurationSeconds},
+		},
+		{
+			name:        "failed migration",
+			namespace:   "ECManager",
+			runErr:      fmt.Errorf("boom"),
+			wantMetrics: []string{MetricMigrationsFailed},
+			wantErr:     true,
+		},
+		{
+			name: "metrics disabled",
+		},
+		{
+			name:        "metrics error doesn't fail the migration",
+			namespace:   "ECManager",
+			putErr:      fmt.Errorf("access denied"),
+			wantMetrics: []string{MetricMigrationsSucceeded, MetricMigrationDurationSeconds},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.RunInstancesError = tt.runErr
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-1"),
+								ImageId:    aws.String("ami-old"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+								Tags: []types.Tag{
+									{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+								},
+							},
+						},
+					},
+				},
+			}
+			metricsClient := apitypes.NewMockCloudWatchClient()
+			metricsClient.PutMetricDataError = tt.putErr
+
+			svc := NewService(mockClient, WithCloudWatchClient(metricsClient))
+			result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-1", MigrateOptions{
+				NewAMI:           "ami-new",
+				MetricsNamespace: tt.namespace,
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, StatusCompleted, result.Status)
+			}
+
+			if len(tt.wantMetrics) == 0 {
+				assert.Empty(t, metricsClient.PutMetricDataInputs)
+				return
+			}
+			require.Len(t, metricsClient.PutMetricDataInputs, 1)
+			input := metricsClient.PutMetricDataInputs[0]
+			assert.Equal(t, tt.namespace, aws.ToString(input.Namespace))
+			var names []string
+			for _, datum := range input.MetricData {
+				names = append(names, aws.ToString(datum.MetricName))
+			}
+			assert.Equal(t, tt.wantMetrics, names)
+		})
+	}
+}
+
+func TestMigrateInstancesMetrics(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+					},
+					{
+						InstanceId: aws.String("i-2"),
+						ImageId:    aws.String("ami-new"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+					},
+				},
+			},
+		},
+	}
+	metricsClient := apitypes.NewMockCloudWatchClient()
+
+	svc := NewService(mockClient, WithCloudWatchClient(metricsClient))
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:           "ami-new",
+		MetricsNamespace: "ECManager",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Summary.Completed)
+	assert.Equal(t, 1, result.Summary.Skipped)
+
+	// The skipped instance isn't counted
+	require.Len(t, metricsClient.PutMetricDataInputs, 1)
+	assert.Len(t, metricsClient.PutMetricDataInputs[0].MetricData, 2)
+}
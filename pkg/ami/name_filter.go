@@ -0,0 +1,52 @@
+package ami
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// compileNameFilter turns a MigrateOptions.NameFilter into a regular
+// expression. A pattern between slashes is used as is; anything else is a
+// glob where * matches any run of characters and ? a single one, anchored to
+// the whole name.
+func compileNameFilter(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid name filter %q: %w", pattern, err)
+		}
+		return re, nil
+	}
+
+	return compileGlob(pattern), nil
+}
+
+// compileGlob turns a glob, where * matches any run of characters and ? a
+// single one, into a regular expression anchored to the whole string
+func compileGlob(pattern string) *regexp.Regexp {
+	glob := regexp.QuoteMeta(pattern)
+	glob = strings.ReplaceAll(glob, `\*`, ".*")
+	glob = strings.ReplaceAll(glob, `\?`, ".")
+	return regexp.MustCompile("^" + glob + "$")
+}
+
+// filterByName returns the instances whose Name tag matches pattern.
+// Instances without a Name tag never match.
+func filterByName(instances []types.Instance, pattern string) ([]types.Instance, error) {
+	re, err := compileNameFilter(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []types.Instance
+	for _, instance := range instances {
+		name := tagValue(instance.Tags, "Name")
+		if name != "" && re.MatchString(name) {
+			matched = append(matched, instance)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,130 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func namedInstance(instanceID, name string) types.Instance {
+	instance := types.Instance{
+		InstanceId: aws.String(instanceID),
+		ImageId:    aws.String("ami-old"),
+		State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+		Tags: []types.Tag{
+			{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+		},
+	}
+	if name != "" {
+		instance.Tags = append(instance.Tags, types.Tag{Key: aws.String("Name"), Value: aws.String(name)})
+	}
+	return instance
+}
+
+func TestFilterByName(t *testing.T) {
+	instances := []types.Instance{
+		namedInstance("i-1", "web-1"),
+		namedInstance("i-2", "web-canary"),
+		namedInstance("i-3", "db-prod"),
+		namedInstance("i-4", "web-2-prod"),
+		namedInstance("i-5", ""),
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "prefix",
+			pattern: "web-*",
+			want:    []string{"i-1", "i-2", "i-4"},
+		},
+		{
+			name:    "suffix",
+			pattern: "*-prod",
+			want:    []string{"i-3", "i-4"},
+		},
+		{
+			name:    "single character",
+			pattern: "web-?",
+			want:    []string{"i-1"},
+		},
+		{
+			name:    "exact name",
+			pattern: "db-prod",
+			want:    []string{"i-3"},
+		},
+		{
+			name:    "glob metacharacters are literal",
+			pattern: "web.1",
+		},
+		{
+			name:    "regex",
+			pattern: "/^web-[0-9]+(-prod)?$/",
+			want:    []string{"i-1", "i-4"},
+		},
+		{
+			name:    "unanchored regex",
+			pattern: "/canary/",
+			want:    []string{"i-2"},
+		},
+		{
+			name:    "invalid regex",
+			pattern: "/web-(/",
+			wantErr: `invalid name filter "/web-(/"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := filterByName(instances, tt.pattern)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			var got []string
+			for _, instance := range matched {
+				got = append(got, aws.ToString(instance.InstanceId))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMigrateInstancesNameFilter(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					namedInstance("i-1", "web-1"),
+					namedInstance("i-2", "db-1"),
+				},
+			},
+		},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:     "ami-new",
+		NameFilter: "web-*",
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) && assert.Len(t, result.Instances, 1) {
+		assert.Equal(t, "i-1", result.Instances[0].InstanceID)
+	}
+}
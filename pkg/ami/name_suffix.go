@@ -0,0 +1,80 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+const (
+	// NameSuffixTimestamp in MigrateOptions.NameSuffix is replaced with the
+	// UTC time the replacement is tagged, e.g. -{timestamp}
+	NameSuffixTimestamp = "{timestamp}"
+	// nameSuffixTagKey records the suffix added to a replacement's Name tag
+	// so StripNameSuffix can take it off again
+	nameSuffixTagKey = "ami-migrate-name-suffix"
+)
+
+// withNameSuffix returns a copy of tags with suffix appended to the Name tag
+// and recorded in nameSuffixTagKey. Tags without a Name are returned as is.
+func withNameSuffix(tags []types.Tag, suffix string) []types.Tag {
+	name := tagValue(tags, "Name")
+	if suffix == "" || name == "" {
+		return tags
+	}
+	suffix = strings.ReplaceAll(suffix, NameSuffixTimestamp, time.Now().UTC().Format("20060102-150405"))
+
+	suffixed := make([]types.Tag, 0, len(tags)+1)
+	for _, tag := range tags {
+		switch aws.ToString(tag.Key) {
+		case "Name":
+			tag.Value = aws.String(name + suffix)
+		case nameSuffixTagKey:
+			continue
+		}
+		suffixed = append(suffixed, tag)
+	}
+	return append(suffixed, types.Tag{
+		Key:   aws.String(nameSuffixTagKey),
+		Value: aws.String(suffix),
+	})
+}
+
+// StripNameSuffix takes the suffix added by MigrateOptions.NameSuffix off the
+// instance's Name tag and reports whether there was one. A Name that was
+// changed since and no longer ends with the suffix is left alone.
+func (s *Service) StripNameSuffix(ctx context.Context, instance types.Instance) (bool, error) {
+	suffix := tagValue(instance.Tags, nameSuffixTagKey)
+	if suffix == "" {
+		return false, nil
+	}
+	instanceID := aws.ToString(instance.InstanceId)
+
+	name := tagValue(instance.Tags, "Name")
+	stripped, ok := strings.CutSuffix(name, suffix)
+	if ok && stripped != "" {
+		logger.Info("Stripping name suffix", "instanceID", instanceID, "name", name, "suffix", suffix)
+		if _, err := s.client.CreateTags(ctx, &ec2.CreateTagsInput{
+			Resources: []string{instanceID},
+			Tags: []types.Tag{{
+				Key:   aws.String("Name"),
+				Value: aws.String(stripped),
+			}},
+		}); err != nil {
+			return false, fmt.Errorf("rename instance %s: %w", instanceID, err)
+		}
+	}
+	if _, err := s.client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: []string{instanceID},
+		Tags:      []types.Tag{{Key: aws.String(nameSuffixTagKey)}},
+	}); err != nil {
+		return false, fmt.Errorf("untag instance %s: %w", instanceID, err)
+	}
+	return ok && stripped != "", nil
+}
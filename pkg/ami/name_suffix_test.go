@@ -0,0 +1,182 @@
+package ami
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestWithNameSuffix(t *testing.T) {
+	tags := []types.Tag{
+		{Key: aws.String("Name"), Value: aws.String("web-1")},
+		{Key: aws.String("Team"), Value: aws.String("web")},
+	}
+
+	t.Run("no suffix", func(t *testing.T) {
+		assert.Equal(t, tags, withNameSuffix(tags, ""))
+	})
+
+	t.Run("no Name tag", func(t *testing.T) {
+		assert.Equal(t, tags[1:], withNameSuffix(tags[1:], "-migrated"))
+	})
+
+	t.Run("suffix", func(t *testing.T) {
+		suffixed := withNameSuffix(tags, "-migrated")
+		assert.Equal(t, "web-1-migrated", tagValue(suffixed, "Name"))
+		assert.Equal(t, "web", tagValue(suffixed, "Team"))
+		assert.Equal(t, "-migrated", tagValue(suffixed, nameSuffixTagKey))
+		// The source tags are left alone
+		assert.Equal(t, "web-1", tagValue(tags, "Name"))
+	})
+
+	t.Run("timestamp", func(t *testing.T) {
+		suffixed := withNameSuffix(tags, "-"+NameSuffixTimestamp)
+		assert.Regexp(t, regexp.MustCompile(`^web-1-\d{8}-\d{6}$`), tagValue(suffixed, "Name"))
+		assert.Equal(t, "web-1"+tagValue(suffixed, nameSuffixTagKey), tagValue(suffixed, "Name"))
+	})
+}
+
+func TestMigrateInstanceNameSuffix(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{
+			InstanceId: aws.String("i-123"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("Name"), Value: aws.String("web-1")},
+			},
+		}}}},
+	}
+
+	svc := NewService(mockClient)
+	_, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", MigrateOptions{
+		NewAMI:     "ami-new",
+		NameSuffix: "-migrated",
+	})
+	require.NoError(t, err)
+
+	var newTags []types.Tag
+	for _, input := range mockClient.CreateTagsInputs {
+		if input.Resources[0] == "i-456" {
+			newTags = append(newTags, input.Tags...)
+		}
+	}
+	assert.Equal(t, "web-1-migrated", tagValue(newTags, "Name"))
+	assert.Equal(t, "-migrated", tagValue(newTags, nameSuffixTagKey))
+}
+
+func TestStripNameSuffix(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := func(name, suffix string) types.Instance {
+		instance := types.Instance{InstanceId: aws.String("i-456")}
+		if name != "" {
+			instance.Tags = append(instance.Tags, types.Tag{Key: aws.String("Name"), Value: aws.String(name)})
+		}
+		if suffix != "" {
+			instance.Tags = append(instance.Tags, types.Tag{Key: aws.String(nameSuffixTagKey), Value: aws.String(suffix)})
+		}
+		return instance
+	}
+
+	tests := []struct {
+		name         string
+		instance     types.Instance
+		want         bool
+		wantName     string
+		wantUntagged bool
+	}{
+		{
+			name:         "strips the suffix",
+			instance:     instance("web-1-migrated", "-migrated"),
+			want:         true,
+			wantName:     "web-1",
+			wantUntagged: true,
+		},
+		{
+			name:         "renamed since",
+			instance:     instance("web-blue", "-migrated"),
+			wantUntagged: true,
+		},
+		{
+			name:     "no suffix recorded",
+			instance: instance("web-1-migrated", ""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			svc := NewService(mockClient)
+
+			stripped, err := svc.StripNameSuffix(context.Background(), tt.instance)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, stripped)
+
+			if tt.wantName != "" {
+				require.Len(t, mockClient.CreateTagsInputs, 1)
+				assert.Equal(t, tt.wantName, tagValue(mockClient.CreateTagsInputs[0].Tags, "Name"))
+			} else {
+				assert.Empty(t, mockClient.CreateTagsInputs)
+			}
+			if tt.wantUntagged {
+				require.Len(t, mockClient.DeleteTagsInputs, 1)
+				assert.Equal(t, nameSuffixTagKey, aws.ToString(mockClient.DeleteTagsInputs[0].Tags[0].Key))
+			} else {
+				assert.Empty(t, mockClient.DeleteTagsInputs)
+			}
+		})
+	}
+}
+
+func TestVerifyMigrationStripNameSuffix(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := func(id, imageID string) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String(imageID),
+			State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("Name"), Value: aws.String(id + "-migrated")},
+				{Key: aws.String(nameSuffixTagKey), Value: aws.String("-migrated")},
+			},
+		}
+	}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{
+			instance("i-1", "ami-new"),
+			instance("i-2", "ami-old"),
+		}}},
+	}
+
+	svc := NewService(mockClient)
+	report, err := svc.VerifyMigrationWithOptions(context.Background(), "ami-new", "enabled", VerifyOptions{StripNameSuffix: true})
+	require.NoError(t, err)
+	require.Len(t, report.Instances, 2)
+	assert.True(t, report.Instances[0].NameSuffixStripped)
+	// Instances not on the new AMI keep their suffix
+	assert.False(t, report.Instances[1].NameSuffixStripped)
+	require.Len(t, mockClient.CreateTagsInputs, 1)
+	assert.Equal(t, []string{"i-1"}, mockClient.CreateTagsInputs[0].Resources)
+	assert.Equal(t, "i-1", tagValue(mockClient.CreateTagsInputs[0].Tags, "Name"))
+	assert.Contains(t, report.FormatVerificationReport(), "Stripped the name suffix from 1 instances\n")
+}
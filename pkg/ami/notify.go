@@ -0,0 +1,123 @@
+package ami
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// WithSNSClient sets the SNS client migration notifications are published with
+func WithSNSClient(client apitypes.SNSClientAPI) ServiceOption {
+	return func(s *Service) {
+		s.sns = client
+	}
+}
+
+// MigrationNotification is the payload sent to MigrateOptions.NotifyTopicARN
+// and NotifyWebhookURL when a run finishes
+type MigrationNotification struct {
+	EnabledValue    string           `json:"enabled_value"`
+	TargetAMI       string           `json:"target_ami,omitempty"`
+	Summary         MigrationSummary `json:"summary"`
+	Duration        time.Duration    `json:"duration"`
+	FailedInstances []string         `json:"failed_instances,omitempty"`
+	Error           string           `json:"error,omitempty"`
+}
+
+// newMigrationNotification builds the notification for a finished run.
+// runErr is the error MigrateInstances returns, if any.
+func newMigrationNotification(result *MigrationResult, runErr error, opts MigrateOptions) MigrationNotification {
+	notification := MigrationNotification{
+		EnabledValue: result.EnabledValue,
+		TargetAMI:    opts.NewAMI,
+		Summary:      result.Summary,
+		Duration:     result.Duration,
+	}
+	for _, instance := range result.Instances {
+		if instance.Status == StatusFailed {
+			notification.FailedInstances = append(notification.FailedInstances, instance.InstanceID)
+		}
+	}
+	if runErr != nil {
+		notification.Error = runErr.Error()
+	}
+	return notification
+}
+
+// subject is the one line summary used as the SNS message subject
+func (n MigrationNotification) subject() string {
+	return fmt.Sprintf("ec-manager migration finished: %d completed, %d failed, %d skipped, %d cancelled",
+		n.Summary.Completed, n.Summary.Failed, n.Summary.Skipped, n.Summary.Cancelled)
+}
+
+// notifyMigrationComplete sends the run's summary to the configured SNS topic
+// and webhook. Notifications are best effort: errors are logged and never
+// change the run's outcome.
+func (s *Service) notifyMigrationComplete(ctx context.Context, result *MigrationResult, runErr error, opts MigrateOptions) {
+	if opts.NotifyTopicARN == "" && opts.NotifyWebhookURL == "" {
+		return
+	}
+
+	notification := newMigrationNotification(result, runErr, opts)
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		logger.Warn("Failed to encode migration notification", "error", err)
+		return
+	}
+
+	// Cancelled runs are worth notifying about too
+	notifyCtx, cancel := detachedContext(ctx)
+	defer cancel()
+
+	if opts.NotifyTopicARN != "" {
+		if err := s.publishNotification(notifyCtx, opts.NotifyTopicARN, notification.subject(), payload); err != nil {
+			logger.Warn("Failed to publish migration notification", "topicARN", opts.NotifyTopicARN, "error", err)
+		}
+	}
+	if opts.NotifyWebhookURL != "" {
+		if err := postNotification(notifyCtx, opts.NotifyWebhookURL, payload); err != nil {
+			logger.Warn("Failed to send migration notification", "webhook", opts.NotifyWebhookURL, "error", err)
+		}
+	}
+}
+
+// publishNotification publishes payload to an SNS topic
+func (s *Service) publishNotification(ctx context.Context, topicARN, subject string, payload []byte) error {
+	if s.sns == nil {
+		return fmt.Errorf("no SNS client")
+	}
+	_, err := s.sns.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(topicARN),
+		Subject:  aws.String(subject),
+		Message:  aws.String(string(payload)),
+	})
+	return err
+}
+
+// postNotification POSTs payload as JSON to a webhook, failing unless it
+// answers with a 2xx status
+func postNotification(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package ami
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstancesNotifications(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name          string
+		topic         bool
+		webhook       bool
+		webhookStatus int
+		publishErr    error
+		runErr        error
+		wantFailed    []string
+		wantError     string
+	}{
+		{
+			name:    "SNS and webhook",
+			topic:   true,
+			webhook: true,
+		},
+		{
+			name:       "failures are listed",
+			topic:      true,
+			webhook:    true,
+			runErr:     fmt.Errorf("boom"),
+			wantFailed: []string{"i-1"},
+			wantError:  "failed to migrate 1 of 2 instances: i-1: upgrade instance: run instances: boom",
+		},
+		{
+			name: "unconfigured",
+		},
+		{
+			name:       "SNS error isn't fatal",
+			topic:      true,
+			webhook:    true,
+			publishErr: fmt.Errorf("access denied"),
+		},
+		{
+			name:          "webhook error isn't fatal",
+			webhook:       true,
+			webhookStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var webhookBodies [][]byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				body, _ := io.ReadAll(r.Body)
+				webhookBodies = append(webhookBodies, body)
+				if tt.webhookStatus != 0 {
+					w.WriteHeader(tt.webhookStatus)
+				}
+			}))
+			defer server.Close()
+
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.RunInstancesError = tt.runErr
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-1"),
+								ImageId:    aws.String("ami-old"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+								Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+							},
+							{
+								InstanceId: aws.String("i-2"),
+								ImageId:    aws.String("ami-new"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+								Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+							},
+						},
+					},
+				},
+			}
+			snsClient := apitypes.NewMockSNSClient()
+			snsClient.PublishError = tt.publishErr
+
+			opts := MigrateOptions{NewAMI: "ami-new"}
+			if tt.topic {
+				opts.NotifyTopicARN = "arn:aws:sns:us-east-1:123456789012:migrations"
+			}
+			if tt.webhook {
+				opts.NotifyWebhookURL = server.URL
+			}
+
+			svc := NewService(mockClient, WithSNSClient(snsClient))
+			result, err := svc.MigrateInstances(context.Background(), "enabled", opts)
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+			} else {
+				require.NoError(t, err)
+			}
+
+			var payloads [][]byte
+			if tt.topic {
+				require.Len(t, snsClient.PublishInputs, 1)
+				input := snsClient.PublishInputs[0]
+				assert.Equal(t, opts.NotifyTopicARN, aws.ToString(input.TopicArn))
+				assert.Equal(t, fmt.Sprintf("ec-manager migration finished: %d completed, %d failed, 1 skipped, 0 cancelled",
+					result.Summary.Completed, result.Summary.Failed), aws.ToString(input.Subject))
+				payloads = append(payloads, []byte(aws.ToString(input.Message)))
+			} else {
+				assert.Empty(t, snsClient.PublishInputs)
+			}
+			if tt.webhook {
+				require.Len(t, webhookBodies, 1)
+				payloads = append(payloads, webhookBodies[0])
+			} else {
+				assert.Empty(t, webhookBodies)
+			}
+
+			for _, payload := range payloads {
+				var notification MigrationNotification
+				require.NoError(t, json.Unmarshal(payload, &notification))
+				assert.Equal(t, "enabled", notification.EnabledValue)
+				assert.Equal(t, "ami-new", notification.TargetAMI)
+				assert.Equal(t, result.Summary, notification.Summary)
+				assert.Equal(t, tt.wantFailed, notification.FailedInstances)
+				assert.Equal(t, tt.wantError, notification.Error)
+			}
+		})
+	}
+}
+
+func TestMigrateInstancesNoNotificationWithoutInstances(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{}
+	snsClient := apitypes.NewMockSNSClient()
+
+	svc := NewService(mockClient, WithSNSClient(snsClient))
+	_, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:         "ami-new",
+		NotifyTopicARN: "arn:aws:sns:us-east-1:123456789012:migrations",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, snsClient.PublishInputs)
+}
@@ -0,0 +1,48 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// OutdatedReport lists the enrolled instances that aren't running the latest AMI
+type OutdatedReport struct {
+	LatestAMI string `json:"latest_ami"`
+	// Checked is the number of enrolled instances compared with LatestAMI
+	Checked  int               `json:"checked"`
+	Outdated []ManagedInstance `json:"outdated"`
+}
+
+// CheckOutdated resolves the latest AMI as the newest one tagged
+// tagKey=tagValue and reports the enrolled instances running any other image.
+// Terminated instances are left out. Nothing is modified, so it can run as a
+// regular audit before migrating.
+func (s *Service) CheckOutdated(ctx context.Context, tagKey, tagValue string) (*OutdatedReport, error) {
+	latestAMI, err := s.GetAMIWithTag(ctx, tagKey, tagValue)
+	if err != nil {
+		return nil, fmt.Errorf("get latest AMI: %w", err)
+	}
+
+	instances, err := s.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OutdatedReport{
+		LatestAMI: latestAMI,
+		Outdated:  []ManagedInstance{},
+	}
+	for _, instance := range instances {
+		if instance.State == string(types.InstanceStateNameTerminated) ||
+			instance.State == string(types.InstanceStateNameShuttingDown) {
+			continue
+		}
+		report.Checked++
+		if instance.CurrentAMI != latestAMI {
+			report.Outdated = append(report.Outdated, instance)
+		}
+	}
+	return report, nil
+}
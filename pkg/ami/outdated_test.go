@@ -0,0 +1,74 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestCheckOutdated(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	enabledTag := types.Tag{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeImagesOutput = &ec2.DescribeImagesOutput{
+		Images: []types.Image{
+			{
+				ImageId:      aws.String("ami-new"),
+				CreationDate: aws.String("2024-06-01T00:00:00Z"),
+				Tags:         []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("latest")}},
+			},
+		},
+	}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-new"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags:       []types.Tag{enabledTag},
+					},
+					{
+						InstanceId: aws.String("i-2"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       []types.Tag{enabledTag},
+					},
+					{
+						// Terminated instances keep their image but don't count
+						InstanceId: aws.String("i-3"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameTerminated},
+						Tags:       []types.Tag{enabledTag},
+					},
+				},
+			},
+		},
+	}
+
+	svc := NewService(mockClient)
+	report, err := svc.CheckOutdated(context.Background(), "ami-migrate", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "ami-new", report.LatestAMI)
+	assert.Equal(t, 2, report.Checked)
+	if assert.Len(t, report.Outdated, 1) {
+		assert.Equal(t, "i-2", report.Outdated[0].InstanceID)
+		assert.Equal(t, "ami-old", report.Outdated[0].CurrentAMI)
+	}
+
+	// Without a latest AMI there is nothing to compare with
+	mockClient.DescribeImagesOutput = &ec2.DescribeImagesOutput{}
+	_, err = svc.CheckOutdated(context.Background(), "ami-migrate", "latest")
+	assert.True(t, errors.Is(err, ErrAMINotFound))
+}
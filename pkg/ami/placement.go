@@ -0,0 +1,48 @@
+package ami
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// placementFor returns the placement a replacement for the instance is
+// launched with: the same availability zone, placement group and partition,
+// tenancy and dedicated host settings, so it doesn't move zones or leave its
+// group. It returns nil when the instance has no placement.
+func placementFor(instance types.Instance) *types.Placement {
+	current := instance.Placement
+	if current == nil {
+		return nil
+	}
+
+	placement := &types.Placement{
+		AvailabilityZone: current.AvailabilityZone,
+		PartitionNumber:  current.PartitionNumber,
+		Tenancy:          current.Tenancy,
+		Affinity:         current.Affinity,
+	}
+	// RunInstances takes the placement group by ID or by name, not both
+	if current.GroupId != nil {
+		placement.GroupId = current.GroupId
+	} else {
+		placement.GroupName = current.GroupName
+	}
+	// A host resource group picks the dedicated host itself
+	if current.HostResourceGroupArn != nil {
+		placement.HostResourceGroupArn = current.HostResourceGroupArn
+	} else {
+		placement.HostId = current.HostId
+	}
+	return placement
+}
+
+// unpinnedHost returns a copy of placement that no longer pins the instance
+// to a dedicated host, so EC2 can pick another host in the same zone when
+// that one is out of capacity. It returns nil when no host is pinned.
+func unpinnedHost(placement *types.Placement) *types.Placement {
+	if placement == nil || placement.HostId == nil {
+		return nil
+	}
+	unpinned := *placement
+	unpinned.HostId = nil
+	return &unpinned
+}
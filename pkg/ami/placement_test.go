@@ -0,0 +1,182 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestPlacementFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		placement *types.Placement
+		want      *types.Placement
+	}{
+		{
+			name: "no placement",
+		},
+		{
+			name: "zone and placement group",
+			placement: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				GroupName:        aws.String("web"),
+				GroupId:          aws.String("pg-123"),
+				PartitionNumber:  aws.Int32(2),
+				Tenancy:          types.TenancyDefault,
+			},
+			want: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				GroupId:          aws.String("pg-123"),
+				PartitionNumber:  aws.Int32(2),
+				Tenancy:          types.TenancyDefault,
+			},
+		},
+		{
+			name: "group by name",
+			placement: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				GroupName:        aws.String("web"),
+			},
+			want: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				GroupName:        aws.String("web"),
+			},
+		},
+		{
+			name: "dedicated host",
+			placement: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				Tenancy:          types.TenancyHost,
+				Affinity:         aws.String("host"),
+				HostId:           aws.String("h-123"),
+			},
+			want: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				Tenancy:          types.TenancyHost,
+				Affinity:         aws.String("host"),
+				HostId:           aws.String("h-123"),
+			},
+		},
+		{
+			name: "host resource group picks the host",
+			placement: &types.Placement{
+				AvailabilityZone:     aws.String("us-east-1b"),
+				Tenancy:              types.TenancyHost,
+				HostId:               aws.String("h-123"),
+				HostResourceGroupArn: aws.String("arn:aws:resource-groups:us-east-1:123456789012:group/hosts"),
+			},
+			want: &types.Placement{
+				AvailabilityZone:     aws.String("us-east-1b"),
+				Tenancy:              types.TenancyHost,
+				HostResourceGroupArn: aws.String("arn:aws:resource-groups:us-east-1:123456789012:group/hosts"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, placementFor(types.Instance{Placement: tt.placement}))
+		})
+	}
+}
+
+// hostCapacityClient fails launches pinned to a dedicated host
+type hostCapacityClient struct {
+	*apitypes.MockEC2Client
+	refused []types.Placement
+}
+
+func (c *hostCapacityClient) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	if params.Placement != nil && params.Placement.HostId != nil {
+		c.refused = append(c.refused, *params.Placement)
+		return nil, apiError("InsufficientHostCapacity")
+	}
+	return c.MockEC2Client.RunInstances(ctx, params, optFns...)
+}
+
+func TestMigrateInstancePlacement(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	dedicated := &types.Placement{
+		AvailabilityZone: aws.String("us-east-1b"),
+		Tenancy:          types.TenancyHost,
+		Affinity:         aws.String("host"),
+		HostId:           aws.String("h-123"),
+	}
+
+	tests := []struct {
+		name        string
+		placement   *types.Placement
+		hostFull    bool
+		want        *types.Placement
+		wantRefused []types.Placement
+	}{
+		{
+			name: "keeps the zone and placement group",
+			placement: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				GroupName:        aws.String("web"),
+				Tenancy:          types.TenancyDedicated,
+			},
+			want: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				GroupName:        aws.String("web"),
+				Tenancy:          types.TenancyDedicated,
+			},
+		},
+		{
+			name:      "keeps the dedicated host",
+			placement: dedicated,
+			want:      dedicated,
+		},
+		{
+			name:      "full dedicated host falls back to another host",
+			placement: dedicated,
+			hostFull:  true,
+			want: &types.Placement{
+				AvailabilityZone: aws.String("us-east-1b"),
+				Tenancy:          types.TenancyHost,
+				Affinity:         aws.String("host"),
+			},
+			wantRefused: []types.Placement{*dedicated},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{{
+					InstanceId: aws.String("i-123"),
+					ImageId:    aws.String("ami-old"),
+					State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+					Placement:  tt.placement,
+					Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+				}}}},
+			}
+			client := &hostCapacityClient{MockEC2Client: mockClient}
+			var ec2Client apitypes.EC2ClientAPI = mockClient
+			if tt.hostFull {
+				ec2Client = client
+			}
+
+			svc := NewService(ec2Client)
+			result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", MigrateOptions{NewAMI: "ami-new"})
+			require.NoError(t, err)
+			assert.Equal(t, StatusCompleted, result.Status)
+
+			require.Len(t, mockClient.RunInstancesInputs, 1)
+			assert.Equal(t, tt.want, mockClient.RunInstancesInputs[0].Placement)
+			assert.Equal(t, tt.wantRefused, client.refused)
+		})
+	}
+}
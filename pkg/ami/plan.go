@@ -0,0 +1,284 @@
+package ami
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// PlanAction is a single step the migration would perform on an instance
+type PlanAction string
+
+const (
+	// ActionSnapshot creates a backup snapshot of an attached EBS volume
+	ActionSnapshot PlanAction = "snapshot"
+	// ActionPreStop runs the pre-stop hook on the running source instance
+	ActionPreStop PlanAction = "pre-stop"
+	// ActionStop stops the running source instance
+	ActionStop PlanAction = "stop"
+	// ActionLaunch launches the replacement instance from the target AMI
+	ActionLaunch PlanAction = "launch"
+	// ActionHealthCheck runs the health check against the replacement
+	ActionHealthCheck PlanAction = "health-check"
+	// ActionWaitForSnapshots waits for the backup snapshots to complete
+	ActionWaitForSnapshots PlanAction = "wait-for-snapshots"
+	// ActionTerminate terminates the source instance
+	ActionTerminate PlanAction = "terminate"
+	// ActionRetain keeps the stopped source instance, tagged with its replacement
+	ActionRetain PlanAction = "retain"
+	// ActionCopyTags copies the source instance tags to the replacement
+	ActionCopyTags PlanAction = "copy-tags"
+)
+
+// MigrationPlan describes the actions a migration run would take
+type MigrationPlan struct {
+	EnabledValue string         `json:"enabled_value"`
+	TargetAMI    string         `json:"target_ami,omitempty"`
+	Instances    []InstancePlan `json:"instances"`
+	// Cost totals the instances' cost estimates when MigrateOptions.Pricer is set
+	Cost *CostEstimate `json:"cost,omitempty"`
+}
+
+// WriteJSON writes the plan to w as indented JSON
+func (p *MigrationPlan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// InstancePlan describes the planned actions for a single instance
+type InstancePlan struct {
+	InstanceID       string       `json:"instance_id"`
+	State            string       `json:"state"`
+	InstanceType     string       `json:"instance_type"`
+	NewInstanceType  string       `json:"new_instance_type,omitempty"`
+	AvailabilityZone string       `json:"availability_zone,omitempty"`
+	CurrentAMI       string       `json:"current_ami"`
+	TargetAMI        string       `json:"target_ami,omitempty"`
+	Migrate          bool         `json:"migrate"`
+	Reason           string       `json:"reason,omitempty"`
+	Actions          []PlanAction `json:"actions,omitempty"`
+	SnapshotVolumes  []string     `json:"snapshot_volumes,omitempty"`
+	PermissionErrors []string     `json:"permission_errors,omitempty"`
+	// Cost is the estimated monthly cost change when MigrateOptions.Pricer
+	// is set. CostError says why it couldn't be estimated.
+	Cost      *CostEstimate `json:"cost,omitempty"`
+	CostError string        `json:"cost_error,omitempty"`
+	// Batch is the wave the instance would be migrated in, counting from 1,
+	// when the run is split into batches
+	Batch int `json:"batch,omitempty"`
+}
+
+// PlanInstanceMigration builds the migration plan for a single instance without modifying it
+func (s *Service) PlanInstanceMigration(ctx context.Context, instanceID string, opts MigrateOptions) (*InstancePlan, error) {
+	instance, err := s.getInstance(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("get instance: %w", err)
+	}
+
+	plan := s.planInstance(ctx, instance, opts)
+	return &plan, nil
+}
+
+// planInstance works out what migrating the instance would involve. It only
+// issues read-only calls, plus native EC2 DryRun requests when
+// opts.ValidatePermissions is set.
+func (s *Service) planInstance(ctx context.Context, instance types.Instance, opts MigrateOptions) InstancePlan {
+	instanceID := aws.ToString(instance.InstanceId)
+	plan := InstancePlan{
+		InstanceID:   instanceID,
+		InstanceType: string(instance.InstanceType),
+		CurrentAMI:   aws.ToString(instance.ImageId),
+	}
+	if instance.State != nil {
+		plan.State = string(instance.State.Name)
+	}
+	if instance.Placement != nil {
+		plan.AvailabilityZone = aws.ToString(instance.Placement.AvailabilityZone)
+	}
+
+	if excluded, reason := excludedByTag(instance, opts.ExcludeTags); excluded {
+		plan.Reason = reason
+		return plan
+	}
+
+	targetAMI, err := s.resolveTargetAMI(ctx, instanceID, opts)
+	if err != nil {
+		plan.Reason = err.Error()
+		return plan
+	}
+	plan.TargetAMI = targetAMI
+
+	if migrate, reason := s.shouldMigrateInstance(instance, targetAMI, opts.Force); !migrate {
+		plan.Reason = reason
+		return plan
+	}
+
+	instanceType := opts.instanceTypeFor(instance)
+	if instanceType != instance.InstanceType {
+		plan.NewInstanceType = string(instanceType)
+	}
+	if err := s.checkInstanceTypeArchitecture(ctx, instanceType, targetAMI); err != nil {
+		plan.Reason = err.Error()
+		return plan
+	}
+
+	mappings, err := s.snapshotMappings(ctx, instance, opts.SnapshotSelector)
+	if err != nil {
+		plan.Reason = err.Error()
+		return plan
+	}
+
+	plan.Migrate = true
+	// A SnapshotOnly run only takes the snapshots, leaving the instance as it
+	// is, and a Cutover launches from those instead of taking new ones
+	if plan.State == string(types.InstanceStateNameRunning) && !opts.SnapshotOnly {
+		if opts.PreStopHook != nil {
+			plan.Actions = append(plan.Actions, ActionPreStop)
+		}
+		plan.Actions = append(plan.Actions, ActionStop)
+	}
+	for _, mapping := range mappings {
+		if !opts.Cutover {
+			plan.Actions = append(plan.Actions, ActionSnapshot)
+		}
+		plan.SnapshotVolumes = append(plan.SnapshotVolumes, aws.ToString(mapping.Ebs.VolumeId))
+	}
+	if !opts.SnapshotOnly {
+		s.planReplacement(ctx, &plan, instance, opts)
+	}
+
+	if opts.ValidatePermissions {
+		plan.PermissionErrors = s.validatePermissions(ctx, instance, plan)
+	}
+
+	return plan
+}
+
+// planReplacement adds the launch of the replacement and the termination or
+// retention of the instance to plan, with its cost when opts has a Pricer
+func (s *Service) planReplacement(ctx context.Context, plan *InstancePlan, instance types.Instance, opts MigrateOptions) {
+	launch := []PlanAction{ActionLaunch}
+	if opts.HealthCheck != nil {
+		launch = append(launch, ActionHealthCheck)
+	}
+	var terminate []PlanAction
+	if opts.WaitForSnapshots && len(plan.SnapshotVolumes) > 0 {
+		terminate = append(terminate, ActionWaitForSnapshots)
+	}
+	if opts.RetainOldInstance {
+		terminate = []PlanAction{ActionRetain}
+	} else {
+		terminate = append(terminate, ActionTerminate)
+	}
+	if opts.PreservePrivateIP && !opts.RetainOldInstance && instance.SubnetId != nil && instance.PrivateIpAddress != nil {
+		// The private IP can only be reused once the old instance is gone
+		plan.Actions = append(plan.Actions, terminate...)
+		plan.Actions = append(plan.Actions, launch...)
+	} else {
+		plan.Actions = append(plan.Actions, launch...)
+		plan.Actions = append(plan.Actions, terminate...)
+	}
+	plan.Actions = append(plan.Actions, ActionCopyTags)
+
+	if opts.Pricer != nil {
+		cost, err := estimateCost(ctx, opts.Pricer, instance.InstanceType, opts.instanceTypeFor(instance))
+		if err != nil {
+			plan.CostError = err.Error()
+		}
+		plan.Cost = cost
+	}
+}
+
+// resolveTargetAMI returns the AMI an instance should be migrated to
+func (s *Service) resolveTargetAMI(ctx context.Context, instanceID string, opts MigrateOptions) (string, error) {
+	if opts.NewAMI != "" {
+		return opts.NewAMI, nil
+	}
+
+	osType, err := s.GetInstanceOSType(ctx, instanceID)
+	if err != nil {
+		return "", fmt.Errorf("get instance OS type %s: %w", instanceID, err)
+	}
+
+	latestAMI, err := s.GetLatestAMI(ctx, osType)
+	if err != nil {
+		return "", fmt.Errorf("get latest AMI for instance %s: %w", instanceID, err)
+	}
+
+	return latestAMI, nil
+}
+
+// validatePermissions sends native EC2 DryRun requests for each mutating call in the plan
+func (s *Service) validatePermissions(ctx context.Context, instance types.Instance, plan InstancePlan) []string {
+	var problems []string
+	check := func(action PlanAction, err error) {
+		if err := dryRunResult(err); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", action, err))
+		}
+	}
+
+	if slices.Contains(plan.Actions, ActionSnapshot) {
+		_, err := s.client.CreateSnapshot(ctx, &ec2.CreateSnapshotInput{
+			DryRun:   aws.Bool(true),
+			VolumeId: aws.String(plan.SnapshotVolumes[0]),
+		})
+		check(ActionSnapshot, err)
+	}
+
+	if slices.Contains(plan.Actions, ActionStop) {
+		_, err := s.client.StopInstances(ctx, &ec2.StopInstancesInput{
+			DryRun:      aws.Bool(true),
+			InstanceIds: []string{plan.InstanceID},
+		})
+		check(ActionStop, err)
+	}
+
+	if slices.Contains(plan.Actions, ActionLaunch) {
+		instanceType := instance.InstanceType
+		if plan.NewInstanceType != "" {
+			instanceType = types.InstanceType(plan.NewInstanceType)
+		}
+		_, err := s.client.RunInstances(ctx, &ec2.RunInstancesInput{
+			DryRun:       aws.Bool(true),
+			ImageId:      aws.String(plan.TargetAMI),
+			InstanceType: instanceType,
+			MinCount:     aws.Int32(1),
+			MaxCount:     aws.Int32(1),
+		})
+		check(ActionLaunch, err)
+	}
+
+	if slices.Contains(plan.Actions, ActionTerminate) {
+		_, err := s.client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+			DryRun:      aws.Bool(true),
+			InstanceIds: []string{plan.InstanceID},
+		})
+		check(ActionTerminate, err)
+	}
+
+	return problems
+}
+
+// dryRunResult interprets the error from a request sent with DryRun set.
+// EC2 reports a permitted request as a DryRunOperation error.
+func dryRunResult(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "DryRunOperation" {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,139 @@
+package ami
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the plan to w as a Graphviz DOT graph, e.g. to render with
+// dot -Tpng. Instances are grouped by batch, in migration order, and by
+// availability zone within a batch. Each instance has an edge from its
+// current AMI and, when it would be migrated, one to its target AMI;
+// instances that would be skipped are drawn dashed with the reason.
+func (p *MigrationPlan) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph migration {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	// AMIs are drawn once, however many instances use them
+	amis := make(map[string]bool)
+	for _, instance := range p.Instances {
+		if instance.CurrentAMI != "" {
+			amis[instance.CurrentAMI] = true
+		}
+		if instance.Migrate && instance.TargetAMI != "" {
+			amis[instance.TargetAMI] = true
+		}
+	}
+	amiIDs := make([]string, 0, len(amis))
+	for amiID := range amis {
+		amiIDs = append(amiIDs, amiID)
+	}
+	sort.Strings(amiIDs)
+	for _, amiID := range amiIDs {
+		fmt.Fprintf(&b, "\t%s [label=%s, shape=ellipse];\n", dotID("ami:"+amiID), dotID(amiID))
+	}
+
+	// Group the instances by batch, then zone, keeping their order within a group
+	groups := make(map[int]map[string][]InstancePlan)
+	for _, instance := range p.Instances {
+		if groups[instance.Batch] == nil {
+			groups[instance.Batch] = make(map[string][]InstancePlan)
+		}
+		groups[instance.Batch][instance.AvailabilityZone] = append(groups[instance.Batch][instance.AvailabilityZone], instance)
+	}
+	batches := make([]int, 0, len(groups))
+	for batch := range groups {
+		batches = append(batches, batch)
+	}
+	sort.Ints(batches)
+
+	for _, batch := range batches {
+		indent := "\t"
+		// Batch 0 means the run isn't split into batches
+		if batch > 0 {
+			fmt.Fprintf(&b, "\tsubgraph %s {\n", dotID(fmt.Sprintf("cluster_batch_%d", batch)))
+			fmt.Fprintf(&b, "\t\tlabel=%s;\n", dotID(fmt.Sprintf("Batch %d", batch)))
+			fmt.Fprintf(&b, "\t\t%s [label=%s, shape=plaintext];\n", dotID(fmt.Sprintf("batch:%d", batch)), dotID(fmt.Sprintf("Batch %d", batch)))
+			indent = "\t\t"
+		}
+		zones := make([]string, 0, len(groups[batch]))
+		for zone := range groups[batch] {
+			zones = append(zones, zone)
+		}
+		sort.Strings(zones)
+		for _, zone := range zones {
+			zoneIndent := indent
+			if zone != "" {
+				fmt.Fprintf(&b, "%ssubgraph %s {\n", indent, dotID(fmt.Sprintf("cluster_batch_%d_%s", batch, zone)))
+				fmt.Fprintf(&b, "%s\tlabel=%s;\n", indent, dotID(zone))
+				zoneIndent = indent + "\t"
+			}
+			for _, instance := range groups[batch][zone] {
+				fmt.Fprintf(&b, "%s%s;\n", zoneIndent, instanceDOTNode(instance))
+			}
+			if zone != "" {
+				fmt.Fprintf(&b, "%s}\n", indent)
+			}
+		}
+		if batch > 0 {
+			b.WriteString("\t}\n")
+		}
+	}
+
+	// Chain the batches so the graph reads in migration order
+	for i := 1; i < len(batches); i++ {
+		if batches[i-1] > 0 {
+			fmt.Fprintf(&b, "\t%s -> %s [style=bold];\n",
+				dotID(fmt.Sprintf("batch:%d", batches[i-1])), dotID(fmt.Sprintf("batch:%d", batches[i])))
+		}
+	}
+
+	for _, instance := range p.Instances {
+		if instance.CurrentAMI != "" {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", dotID("ami:"+instance.CurrentAMI), dotID(instance.InstanceID))
+		}
+		if instance.Migrate && instance.TargetAMI != "" {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", dotID(instance.InstanceID), dotID("ami:"+instance.TargetAMI))
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// instanceDOTNode returns the DOT node statement of the instance, without
+// the trailing semicolon
+func instanceDOTNode(instance InstancePlan) string {
+	lines := []string{instance.InstanceID}
+	instanceType := instance.InstanceType
+	if instance.NewInstanceType != "" {
+		instanceType += " -> " + instance.NewInstanceType
+	}
+	if instanceType != "" {
+		lines = append(lines, instanceType)
+	}
+	if !instance.Migrate {
+		lines = append(lines, "skipped: "+instance.Reason)
+		return fmt.Sprintf("%s [label=%s, style=dashed]", dotID(instance.InstanceID), dotID(strings.Join(lines, "\n")))
+	}
+	actions := make([]string, 0, len(instance.Actions))
+	for _, action := range instance.Actions {
+		actions = append(actions, string(action))
+	}
+	if len(actions) > 0 {
+		lines = append(lines, strings.Join(actions, ", "))
+	}
+	return fmt.Sprintf("%s [label=%s]", dotID(instance.InstanceID), dotID(strings.Join(lines, "\n")))
+}
+
+// dotID quotes s as a DOT ID. Newlines become \n, which DOT renders as line
+// breaks in labels.
+func dotID(s string) string {
+	return strconv.Quote(s)
+}
@@ -0,0 +1,84 @@
+package ami
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationPlanWriteDOT(t *testing.T) {
+	plan := &MigrationPlan{
+		TargetAMI: "ami-new",
+		Instances: []InstancePlan{
+			{
+				InstanceID:       "i-1",
+				InstanceType:     "t3.small",
+				AvailabilityZone: "us-east-1a",
+				CurrentAMI:       "ami-old",
+				TargetAMI:        "ami-new",
+				Migrate:          true,
+				Actions:          []PlanAction{ActionSnapshot, ActionLaunch, ActionTerminate},
+				Batch:            1,
+			},
+			{
+				InstanceID:       "i-2",
+				InstanceType:     "t3.small",
+				AvailabilityZone: "us-east-1b",
+				CurrentAMI:       "ami-new",
+				TargetAMI:        "ami-new",
+				Reason:           skipReasonAlreadyMigrated,
+				Batch:            1,
+			},
+			{
+				InstanceID:       "i-3",
+				InstanceType:     "t3.small",
+				NewInstanceType:  "t3.medium",
+				AvailabilityZone: "us-east-1a",
+				CurrentAMI:       "ami-old",
+				TargetAMI:        "ami-new",
+				Migrate:          true,
+				Actions:          []PlanAction{ActionStop, ActionLaunch, ActionTerminate},
+				Batch:            2,
+			},
+		},
+	}
+
+	var b strings.Builder
+	require.NoError(t, plan.WriteDOT(&b))
+	out := b.String()
+
+	assert.True(t, strings.HasPrefix(out, "digraph migration {\n"))
+	assert.True(t, strings.HasSuffix(out, "}\n"))
+	// Every AMI is drawn once
+	assert.Equal(t, 1, strings.Count(out, `"ami:ami-old" [label="ami-old", shape=ellipse];`))
+	assert.Equal(t, 1, strings.Count(out, `"ami:ami-new" [label="ami-new", shape=ellipse];`))
+	// Instances are grouped by batch and zone
+	assert.Contains(t, out, "\tsubgraph \"cluster_batch_1\" {\n\t\tlabel=\"Batch 1\";\n")
+	assert.Contains(t, out, "\t\tsubgraph \"cluster_batch_1_us-east-1a\" {\n\t\t\tlabel=\"us-east-1a\";\n"+
+		"\t\t\t\"i-1\" [label=\"i-1\\nt3.small\\nsnapshot, launch, terminate\"];\n\t\t}\n")
+	assert.Contains(t, out, `"i-2" [label="i-2\nt3.small\nskipped: already-migrated", style=dashed];`)
+	assert.Contains(t, out, `"i-3" [label="i-3\nt3.small -> t3.medium\nstop, launch, terminate"];`)
+	// Batches are chained in order, and only migrated instances point at the target
+	assert.Contains(t, out, `"batch:1" -> "batch:2" [style=bold];`)
+	assert.Contains(t, out, `"ami:ami-old" -> "i-1";`)
+	assert.Contains(t, out, `"i-1" -> "ami:ami-new";`)
+	assert.Contains(t, out, `"ami:ami-new" -> "i-2";`)
+	assert.NotContains(t, out, `"i-2" -> "ami:ami-new";`)
+	// Braces balance, so the graph parses
+	assert.Equal(t, strings.Count(out, "{"), strings.Count(out, "}"))
+}
+
+func TestMigrationPlanWriteDOTWithoutBatches(t *testing.T) {
+	plan := &MigrationPlan{
+		Instances: []InstancePlan{
+			{InstanceID: "i-1", CurrentAMI: "ami-old", TargetAMI: "ami-new", Migrate: true},
+		},
+	}
+
+	var b strings.Builder
+	require.NoError(t, plan.WriteDOT(&b))
+	assert.NotContains(t, b.String(), "subgraph")
+	assert.Contains(t, b.String(), "\t\"i-1\" [label=\"i-1\"];\n")
+}
@@ -0,0 +1,46 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// PreStopHook is run on a running instance through AWS Systems Manager before
+// a migration stops it, e.g. to shut a database down cleanly. The migration
+// waits for it to finish and fails if it does.
+type PreStopHook struct {
+	// DocumentName is the SSM document to run. It defaults to
+	// AWS-RunShellScript.
+	DocumentName string
+	// Commands are passed as the document's commands parameter, which is
+	// the script AWS-RunShellScript runs
+	Commands []string
+}
+
+// runPreStopHook runs hook on the instance and waits for it to complete. An
+// instance without an online SSM agent can't run it, so it is stopped anyway
+// with a warning.
+func (s *Service) runPreStopHook(ctx context.Context, instance types.Instance, hook *PreStopHook) error {
+	if hook == nil {
+		return nil
+	}
+	if s.ssm == nil {
+		return fmt.Errorf("no SSM client to run the pre-stop hook with")
+	}
+
+	instanceID := aws.ToString(instance.InstanceId)
+	online, err := s.ssmAgentOnline(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("check SSM agent: %w", err)
+	}
+	if !online {
+		logger.Warn("Instance has no online SSM agent, stopping it without running the pre-stop hook", "instanceID", instanceID)
+		return nil
+	}
+
+	return s.runCommand(ctx, instanceID, hook.DocumentName, hook.Commands, "ami-migrate pre-stop hook")
+}
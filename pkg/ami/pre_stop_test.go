@@ -0,0 +1,180 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// stopOrderClient records how many SSM commands had been sent when the
+// instance was stopped
+type stopOrderClient struct {
+	*apitypes.MockEC2Client
+	ssm                *apitypes.MockSSMClient
+	commandsBeforeStop []int
+}
+
+func (c *stopOrderClient) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	c.ssm.Lock()
+	c.commandsBeforeStop = append(c.commandsBeforeStop, len(c.ssm.SendCommandInputs))
+	c.ssm.Unlock()
+	return c.MockEC2Client.StopInstances(ctx, params, optFns...)
+}
+
+func TestMigrateInstancesPreStopHook(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := func(state types.InstanceStateName) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String("i-1"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: state},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+			},
+		}
+	}
+	hook := &PreStopHook{Commands: []string{"systemctl stop postgresql"}}
+
+	tests := []struct {
+		name          string
+		instance      types.Instance
+		hook          *PreStopHook
+		noSSMClient   bool
+		agentOffline  bool
+		commandStatus ssmtypes.CommandInvocationStatus
+		wantStatus    string
+		wantMessage   string
+		wantCommands  int
+		wantStopped   bool
+	}{
+		{
+			name:         "runs before stopping",
+			instance:     instance(types.InstanceStateNameRunning),
+			hook:         hook,
+			wantStatus:   StatusCompleted,
+			wantCommands: 1,
+			wantStopped:  true,
+		},
+		{
+			name:          "failed hook leaves the instance running",
+			instance:      instance(types.InstanceStateNameRunning),
+			hook:          hook,
+			commandStatus: ssmtypes.CommandInvocationStatusFailed,
+			wantStatus:    StatusFailed,
+			wantMessage:   "pre-stop hook: command cmd-1 failed: postgresql did not stop",
+			wantCommands:  1,
+		},
+		{
+			name:         "no SSM agent stops anyway",
+			instance:     instance(types.InstanceStateNameRunning),
+			hook:         hook,
+			agentOffline: true,
+			wantStatus:   StatusCompleted,
+			wantStopped:  true,
+		},
+		{
+			name:        "no SSM client",
+			instance:    instance(types.InstanceStateNameRunning),
+			hook:        hook,
+			noSSMClient: true,
+			wantStatus:  StatusFailed,
+			wantMessage: "pre-stop hook: no SSM client to run the pre-stop hook with",
+		},
+		{
+			name:       "stopped instance",
+			instance:   instance(types.InstanceStateNameStopped),
+			hook:       hook,
+			wantStatus: StatusCompleted,
+		},
+		{
+			name:        "no hook",
+			instance:    instance(types.InstanceStateNameRunning),
+			wantStatus:  StatusCompleted,
+			wantStopped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{tt.instance}}},
+			}
+			ssmClient := apitypes.NewMockSSMClient()
+			if !tt.agentOffline {
+				ssmClient.ManagedInstances = []string{"i-1"}
+			}
+			ssmClient.CommandStatus = tt.commandStatus
+			ssmClient.CommandOutput = "postgresql did not stop\n"
+			client := &stopOrderClient{MockEC2Client: mockClient, ssm: ssmClient}
+
+			var opts []ServiceOption
+			if !tt.noSSMClient {
+				opts = append(opts, WithSSMClient(ssmClient))
+			}
+			svc := NewService(client, opts...)
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+				NewAMI:      "ami-new",
+				PreStopHook: tt.hook,
+			})
+			if tt.wantStatus == StatusFailed {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if assert.NotNil(t, result) && assert.Len(t, result.Instances, 1) {
+				assert.Equal(t, tt.wantStatus, result.Instances[0].Status)
+				if tt.wantMessage != "" {
+					assert.Contains(t, result.Instances[0].Message, tt.wantMessage)
+				}
+			}
+
+			assert.Len(t, ssmClient.SendCommandInputs, tt.wantCommands)
+			if tt.wantStopped {
+				// Every stop happens after the hook has been sent
+				if assert.NotEmpty(t, client.commandsBeforeStop) {
+					for _, sent := range client.commandsBeforeStop {
+						assert.Equal(t, tt.wantCommands, sent)
+					}
+				}
+			} else if tt.instance.State.Name == types.InstanceStateNameRunning {
+				assert.Empty(t, client.commandsBeforeStop)
+			}
+		})
+	}
+}
+
+func TestRunPreStopHookDocument(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := types.Instance{InstanceId: aws.String("i-1")}
+	ssmClient := apitypes.NewMockSSMClient()
+	ssmClient.ManagedInstances = []string{"i-1"}
+	svc := NewService(apitypes.NewMockEC2Client(), WithSSMClient(ssmClient))
+
+	// The shell script document is used unless another one is named
+	err := svc.runPreStopHook(context.Background(), instance, &PreStopHook{Commands: []string{"sync"}})
+	assert.NoError(t, err)
+	err = svc.runPreStopHook(context.Background(), instance, &PreStopHook{DocumentName: "Acme-DrainNode"})
+	assert.NoError(t, err)
+
+	if assert.Len(t, ssmClient.SendCommandInputs, 2) {
+		assert.Equal(t, "AWS-RunShellScript", aws.ToString(ssmClient.SendCommandInputs[0].DocumentName))
+		assert.Equal(t, map[string][]string{"commands": {"sync"}}, ssmClient.SendCommandInputs[0].Parameters)
+		assert.Equal(t, []string{"i-1"}, ssmClient.SendCommandInputs[0].InstanceIds)
+		assert.Equal(t, "Acme-DrainNode", aws.ToString(ssmClient.SendCommandInputs[1].DocumentName))
+		assert.Nil(t, ssmClient.SendCommandInputs[1].Parameters)
+	}
+}
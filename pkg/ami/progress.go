@@ -0,0 +1,64 @@
+package ami
+
+import (
+	"sync"
+	"time"
+)
+
+// Progress stages reported through MigrateOptions.Progress. An instance moves
+// through started, stopped, snapshot-created (once per volume), launched and
+// terminated (or retained, with MigrateOptions.RetainOldInstance) before
+// ending in completed, skipped or failed. Instances that never start because
+// the run was cancelled only report cancelled.
+const (
+	ProgressStarted         = "started"
+	ProgressStopped         = "stopped"
+	ProgressSnapshotCreated = "snapshot-created"
+	ProgressLaunched        = "launched"
+	ProgressTerminated      = "terminated"
+	ProgressRetained        = "retained"
+	ProgressCompleted       = StatusCompleted
+	ProgressSkipped         = StatusSkipped
+	ProgressFailed          = StatusFailed
+	ProgressCancelled       = StatusCancelled
+)
+
+// InstanceProgress describes a single step of an instance's migration
+type InstanceProgress struct {
+	InstanceID string    `json:"instance_id"`
+	Stage      string    `json:"stage"`
+	Message    string    `json:"message,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// ProgressFunc receives migration progress events. MigrateInstances never
+// calls it concurrently, so it doesn't need its own locking, but it should
+// return quickly since migrations wait for it.
+type ProgressFunc func(InstanceProgress)
+
+// serializeProgress wraps progress so calls from concurrent migrations are
+// made one at a time
+func serializeProgress(progress ProgressFunc) ProgressFunc {
+	if progress == nil {
+		return nil
+	}
+	var mu sync.Mutex
+	return func(event InstanceProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress(event)
+	}
+}
+
+// reportProgress sends a progress event when a ProgressFunc is set
+func (o MigrateOptions) reportProgress(instanceID, stage, message string) {
+	if o.Progress == nil {
+		return
+	}
+	o.Progress(InstanceProgress{
+		InstanceID: instanceID,
+		Stage:      stage,
+		Message:    message,
+		Time:       time.Now(),
+	})
+}
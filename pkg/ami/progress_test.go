@@ -0,0 +1,90 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstancesProgress(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tags := []types.Tag{
+		{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+		{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+	}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags:       tags,
+						BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+							{
+								DeviceName: aws.String("/dev/xvda"),
+								Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1")},
+							},
+						},
+					},
+					{
+						InstanceId: aws.String("i-2"),
+						ImageId:    aws.String("ami-new"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       tags,
+					},
+					{
+						InstanceId: aws.String("i-3"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+						Tags:       tags,
+					},
+				},
+			},
+		},
+	}
+	ec2Client := &failingLaunchClient{MockEC2Client: mockClient, sourceInstanceID: "i-3"}
+	if err := client.SetEC2Client(ec2Client); err != nil {
+		t.Fatal(err)
+	}
+
+	// The callback deliberately doesn't lock: MigrateInstances must serialize calls
+	stages := make(map[string][]string)
+	var messages []string
+	progress := func(event InstanceProgress) {
+		assert.False(t, event.Time.IsZero())
+		stages[event.InstanceID] = append(stages[event.InstanceID], event.Stage)
+		messages = append(messages, event.Message)
+	}
+
+	svc := NewService(ec2Client)
+	_, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:   "ami-new",
+		Progress: progress,
+	})
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{
+		ProgressStarted,
+		ProgressStopped,
+		ProgressSnapshotCreated,
+		ProgressLaunched,
+		ProgressTerminated,
+		ProgressCompleted,
+	}, stages["i-1"])
+	assert.Equal(t, []string{ProgressSkipped}, stages["i-2"])
+	assert.Equal(t, []string{ProgressStarted, ProgressFailed}, stages["i-3"])
+	assert.Contains(t, messages, "snap-1 from vol-1")
+}
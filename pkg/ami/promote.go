@@ -0,0 +1,165 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// ErrConcurrentPromotion is returned by PromoteAMI when another AMI was
+// promoted while it ran. Both AMIs are left tagged, so GetAMIWithTag resolves
+// to the newer one, until a promotion is run again.
+var ErrConcurrentPromotion = errors.New("another AMI was promoted concurrently")
+
+// PromoteOptions controls what PromoteAMIWithOptions does with the AMIs that
+// were current before
+type PromoteOptions struct {
+	// PreviousTagValue, when set, retags the AMIs that were current with the
+	// same key and this value instead of removing the tag. The AMIs that had
+	// this value before lose the tag, so only the last current AMI has it.
+	PreviousTagValue string
+}
+
+// PromoteResult describes a promotion
+type PromoteResult struct {
+	AMIID string `json:"ami_id"`
+	// Demoted are the AMIs that were current before the promotion
+	Demoted []string `json:"demoted,omitempty"`
+	// Unmarked are the AMIs that lost the previous tag
+	Unmarked []string `json:"unmarked,omitempty"`
+}
+
+// PromoteAMI makes amiID the AMI tagged currentTagKey=currentTagValue,
+// removing the tag from the AMIs that had it before
+func (s *Service) PromoteAMI(ctx context.Context, amiID, currentTagKey, currentTagValue string) (*PromoteResult, error) {
+	return s.PromoteAMIWithOptions(ctx, amiID, currentTagKey, currentTagValue, PromoteOptions{})
+}
+
+// PromoteAMIWithOptions promotes amiID like PromoteAMI, applying opts.
+//
+// The new AMI is tagged before the old ones are demoted, so GetAMIWithTag
+// always finds a current AMI. The current AMIs are read again after tagging
+// the new one; if an AMI that wasn't current at the start turned up, another
+// promotion raced this one and ErrConcurrentPromotion is returned without
+// demoting anything.
+func (s *Service) PromoteAMIWithOptions(ctx context.Context, amiID, currentTagKey, currentTagValue string, opts PromoteOptions) (*PromoteResult, error) {
+	if currentTagKey == "" || currentTagValue == "" {
+		return nil, fmt.Errorf("the current tag key and value must be set")
+	}
+	if opts.PreviousTagValue == currentTagValue {
+		return nil, fmt.Errorf("the previous tag value must differ from the current one")
+	}
+	logger.Info("Promoting AMI", "amiID", amiID, "tagKey", currentTagKey, "tagValue", currentTagValue)
+
+	image, err := s.getImage(ctx, amiID)
+	if err != nil {
+		return nil, err
+	}
+	if image.State != types.ImageStateAvailable {
+		return nil, fmt.Errorf("AMI %s is %s, not available", amiID, image.State)
+	}
+
+	before, err := s.imagesWithTag(ctx, currentTagKey, currentTagValue)
+	if err != nil {
+		return nil, fmt.Errorf("find current AMIs: %w", err)
+	}
+	if err := s.TagAMI(ctx, amiID, currentTagKey, currentTagValue); err != nil {
+		return nil, fmt.Errorf("tag AMI %s: %w", amiID, err)
+	}
+
+	// Re-read before demoting anything, in case another promotion ran since
+	after, err := s.imagesWithTag(ctx, currentTagKey, currentTagValue)
+	if err != nil {
+		return nil, fmt.Errorf("find current AMIs: %w", err)
+	}
+	for _, id := range after {
+		if id != amiID && !slices.Contains(before, id) {
+			return nil, fmt.Errorf("%w: %s is also tagged %s=%s", ErrConcurrentPromotion, id, currentTagKey, currentTagValue)
+		}
+	}
+
+	result := &PromoteResult{AMIID: amiID}
+	for _, id := range after {
+		if id != amiID {
+			result.Demoted = append(result.Demoted, id)
+		}
+	}
+
+	if opts.PreviousTagValue != "" {
+		previous, err := s.imagesWithTag(ctx, currentTagKey, opts.PreviousTagValue)
+		if err != nil {
+			return result, fmt.Errorf("find previous AMIs: %w", err)
+		}
+		for _, id := range previous {
+			if slices.Contains(result.Demoted, id) {
+				continue
+			}
+			if err := s.untagAMI(ctx, id, currentTagKey, opts.PreviousTagValue); err != nil {
+				return result, fmt.Errorf("untag previous AMI %s: %w", id, err)
+			}
+			result.Unmarked = append(result.Unmarked, id)
+		}
+	}
+
+	for _, id := range result.Demoted {
+		logger.Info("Demoting AMI", "amiID", id, "previousTagValue", opts.PreviousTagValue)
+		if opts.PreviousTagValue != "" {
+			// Overwriting the value moves the AMI from current to previous in one call
+			err = s.TagAMI(ctx, id, currentTagKey, opts.PreviousTagValue)
+		} else {
+			err = s.untagAMI(ctx, id, currentTagKey, currentTagValue)
+		}
+		if err != nil {
+			return result, fmt.Errorf("demote AMI %s: %w", id, err)
+		}
+	}
+
+	return result, nil
+}
+
+// imagesWithTag returns the IDs of the AMIs owned by the account that are
+// tagged tagKey=tagValue, sorted
+func (s *Service) imagesWithTag(ctx context.Context, tagKey, tagValue string) ([]string, error) {
+	images, err := s.describeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{"self"},
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:" + tagKey),
+				Values: []string{tagValue},
+			},
+		},
+	})
+	if err != nil {
+		return nil, classifyError(fmt.Errorf("describe images: %w", err))
+	}
+
+	var imageIDs []string
+	for _, image := range images {
+		if hasTag(image.Tags, tagKey, tagValue) {
+			imageIDs = append(imageIDs, aws.ToString(image.ImageId))
+		}
+	}
+	sort.Strings(imageIDs)
+	return imageIDs, nil
+}
+
+// untagAMI removes tagKey from an AMI, as long as it still has tagValue
+func (s *Service) untagAMI(ctx context.Context, amiID, tagKey, tagValue string) error {
+	_, err := s.client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: []string{amiID},
+		Tags: []types.Tag{
+			{
+				Key:   aws.String(tagKey),
+				Value: aws.String(tagValue),
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,202 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// imageTagClient applies CreateTags and DeleteTags to the mock's images, so
+// promotions can be followed through DescribeImages
+type imageTagClient struct {
+	*apitypes.MockEC2Client
+	// afterTag, when set, runs after every CreateTags call
+	afterTag func(params *ec2.CreateTagsInput)
+}
+
+func (c *imageTagClient) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	out, err := c.MockEC2Client.CreateTags(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range c.Images {
+		if aws.ToString(c.Images[i].ImageId) != params.Resources[0] {
+			continue
+		}
+		for _, tag := range params.Tags {
+			c.Images[i].Tags = setTag(c.Images[i].Tags, aws.ToString(tag.Key), aws.ToString(tag.Value))
+		}
+	}
+	if c.afterTag != nil {
+		c.afterTag(params)
+	}
+	return out, nil
+}
+
+func (c *imageTagClient) DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	out, err := c.MockEC2Client.DeleteTags(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range c.Images {
+		if aws.ToString(c.Images[i].ImageId) != params.Resources[0] {
+			continue
+		}
+		var kept []types.Tag
+		for _, tag := range c.Images[i].Tags {
+			if !hasTag(params.Tags, aws.ToString(tag.Key), aws.ToString(tag.Value)) {
+				kept = append(kept, tag)
+			}
+		}
+		c.Images[i].Tags = kept
+	}
+	return out, nil
+}
+
+// setTag returns tags with key set to value
+func setTag(tags []types.Tag, key, value string) []types.Tag {
+	for i := range tags {
+		if aws.ToString(tags[i].Key) == key {
+			tags[i].Value = aws.String(value)
+			return tags
+		}
+	}
+	return append(tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+}
+
+func TestPromoteAMI(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	image := func(id, release string) types.Image {
+		image := availableImage(id)
+		if release != "" {
+			image.Tags = []types.Tag{{Key: aws.String("release"), Value: aws.String(release)}}
+		}
+		return image
+	}
+
+	tests := []struct {
+		name         string
+		amiID        string
+		opts         PromoteOptions
+		images       []types.Image
+		wantReleases map[string]string
+		wantResult   *PromoteResult
+		wantErr      string
+	}{
+		{
+			name:   "moves the current tag",
+			amiID:  "ami-new",
+			images: []types.Image{image("ami-old", "current"), image("ami-new", "")},
+			wantReleases: map[string]string{
+				"ami-old": "",
+				"ami-new": "current",
+			},
+			wantResult: &PromoteResult{AMIID: "ami-new", Demoted: []string{"ami-old"}},
+		},
+		{
+			name:  "marks the old AMI previous",
+			amiID: "ami-new",
+			opts:  PromoteOptions{PreviousTagValue: "previous"},
+			images: []types.Image{
+				image("ami-older", "previous"),
+				image("ami-old", "current"),
+				image("ami-new", ""),
+			},
+			wantReleases: map[string]string{
+				"ami-older": "",
+				"ami-old":   "previous",
+				"ami-new":   "current",
+			},
+			wantResult: &PromoteResult{AMIID: "ami-new", Demoted: []string{"ami-old"}, Unmarked: []string{"ami-older"}},
+		},
+		{
+			name:         "first promotion",
+			amiID:        "ami-new",
+			opts:         PromoteOptions{PreviousTagValue: "previous"},
+			images:       []types.Image{image("ami-new", "")},
+			wantReleases: map[string]string{"ami-new": "current"},
+			wantResult:   &PromoteResult{AMIID: "ami-new"},
+		},
+		{
+			name:         "already current",
+			amiID:        "ami-new",
+			images:       []types.Image{image("ami-new", "current")},
+			wantReleases: map[string]string{"ami-new": "current"},
+			wantResult:   &PromoteResult{AMIID: "ami-new"},
+		},
+		{
+			name:    "unknown AMI",
+			amiID:   "ami-missing",
+			images:  []types.Image{image("ami-old", "current")},
+			wantErr: "no AMI found: ami-missing",
+		},
+		{
+			name:    "same current and previous value",
+			amiID:   "ami-new",
+			opts:    PromoteOptions{PreviousTagValue: "current"},
+			images:  []types.Image{image("ami-new", "")},
+			wantErr: "the previous tag value must differ from the current one",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = tt.images
+
+			svc := NewService(&imageTagClient{MockEC2Client: mockClient})
+			result, err := svc.PromoteAMIWithOptions(context.Background(), tt.amiID, "release", "current", tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Empty(t, mockClient.CreateTagsInputs)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantResult, result)
+			for _, image := range mockClient.Images {
+				assert.Equal(t, tt.wantReleases[aws.ToString(image.ImageId)], tagValue(image.Tags, "release"), aws.ToString(image.ImageId))
+			}
+		})
+	}
+}
+
+func TestPromoteAMIConcurrentPromotion(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{
+		{
+			ImageId: aws.String("ami-old"),
+			State:   types.ImageStateAvailable,
+			Tags:    []types.Tag{{Key: aws.String("release"), Value: aws.String("current")}},
+		},
+		availableImage("ami-new"),
+		availableImage("ami-other"),
+	}
+	client := &imageTagClient{MockEC2Client: mockClient}
+	// Another promotion tags its AMI right after this one tagged ami-new
+	client.afterTag = func(params *ec2.CreateTagsInput) {
+		if params.Resources[0] == "ami-new" {
+			mockClient.Images[2].Tags = setTag(mockClient.Images[2].Tags, "release", "current")
+		}
+	}
+
+	svc := NewService(client)
+	_, err := svc.PromoteAMI(context.Background(), "ami-new", "release", "current")
+	require.ErrorIs(t, err, ErrConcurrentPromotion)
+	assert.Contains(t, err.Error(), "ami-other is also tagged release=current")
+
+	// Nothing was demoted
+	assert.Empty(t, mockClient.DeleteTagsInputs)
+	assert.Equal(t, "current", tagValue(mockClient.Images[0].Tags, "release"))
+}
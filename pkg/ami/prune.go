@@ -0,0 +1,162 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// PrunedAMI describes an AMI older than the ones kept by a prune run
+type PrunedAMI struct {
+	AMIID            string   `json:"ami_id"`
+	Name             string   `json:"name,omitempty"`
+	CreationDate     string   `json:"creation_date"`
+	Deregistered     bool     `json:"deregistered"`
+	DeletedSnapshots []string `json:"deleted_snapshots,omitempty"`
+	Reason           string   `json:"reason,omitempty"`
+}
+
+// PruneCandidates lists the AMIs tagged tagKey=tagValue that a prune run
+// keeping the keep most recent ones would remove, newest first. Nothing is
+// changed; AMIs still used by an instance have their Reason set and are
+// kept by PruneAMIs.
+func (s *Service) PruneCandidates(ctx context.Context, tagKey, tagValue string, keep int) ([]PrunedAMI, error) {
+	if keep < 0 {
+		return nil, fmt.Errorf("keep must not be negative, got %d", keep)
+	}
+
+	described, err := s.describeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{"self"},
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:" + tagKey),
+				Values: []string{tagValue},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe images: %w", err)
+	}
+
+	var images []types.Image
+	for _, image := range described {
+		// Never rely on the filter alone to decide what is ours to deregister
+		if !hasTag(image.Tags, tagKey, tagValue) {
+			continue
+		}
+		images = append(images, image)
+	}
+	// CreationDate is an ISO 8601 timestamp, so it sorts as a string
+	sort.SliceStable(images, func(i, j int) bool {
+		return aws.ToString(images[i].CreationDate) > aws.ToString(images[j].CreationDate)
+	})
+	if len(images) <= keep {
+		logger.Info("No AMIs to prune", "tagKey", tagKey, "tagValue", tagValue, "images", len(images), "keep", keep)
+		return nil, nil
+	}
+
+	candidates := images[keep:]
+	imageIDs := make([]string, 0, len(candidates))
+	for _, image := range candidates {
+		imageIDs = append(imageIDs, aws.ToString(image.ImageId))
+	}
+	inUse, err := s.instancesUsingAMIs(ctx, imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("check instances using AMIs: %w", err)
+	}
+
+	pruned := make([]PrunedAMI, 0, len(candidates))
+	for _, image := range candidates {
+		imageID := aws.ToString(image.ImageId)
+		candidate := PrunedAMI{
+			AMIID:        imageID,
+			Name:         aws.ToString(image.Name),
+			CreationDate: aws.ToString(image.CreationDate),
+		}
+		if instanceIDs := inUse[imageID]; len(instanceIDs) > 0 {
+			candidate.Reason = fmt.Sprintf("in use by %s", strings.Join(instanceIDs, ", "))
+		}
+		pruned = append(pruned, candidate)
+	}
+	return pruned, nil
+}
+
+// PruneAMIs keeps the keep most recent AMIs tagged tagKey=tagValue, by
+// CreationDate, and deregisters the older ones along with their backing
+// snapshots. AMIs that any pending, running or stopped instance was
+// launched from are skipped.
+func (s *Service) PruneAMIs(ctx context.Context, tagKey, tagValue string, keep int) ([]PrunedAMI, error) {
+	logger.Info("Pruning AMIs", "tagKey", tagKey, "tagValue", tagValue, "keep", keep)
+
+	pruned, err := s.PruneCandidates(ctx, tagKey, tagValue, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pruned {
+		if pruned[i].Reason != "" {
+			logger.Info("Keeping AMI", "amiID", pruned[i].AMIID, "reason", pruned[i].Reason)
+			continue
+		}
+		result, err := s.DeregisterAMI(ctx, pruned[i].AMIID, DeregisterOptions{DeleteSnapshots: true})
+		if result != nil {
+			pruned[i].Deregistered = true
+			pruned[i].DeletedSnapshots = result.DeletedSnapshots
+		}
+		if err != nil {
+			return pruned, fmt.Errorf("prune AMI %s: %w", pruned[i].AMIID, err)
+		}
+	}
+	return pruned, nil
+}
+
+// instancesUsingAMIs maps each of imageIDs to the IDs of the instances
+// launched from it that haven't been terminated
+func (s *Service) instancesUsingAMIs(ctx context.Context, imageIDs []string) (map[string][]string, error) {
+	instances, err := s.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("image-id"),
+				Values: imageIDs,
+			},
+			{
+				Name: aws.String("instance-state-name"),
+				Values: []string{
+					string(types.InstanceStateNamePending),
+					string(types.InstanceStateNameRunning),
+					string(types.InstanceStateNameStopping),
+					string(types.InstanceStateNameStopped),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(imageIDs))
+	for _, imageID := range imageIDs {
+		wanted[imageID] = true
+	}
+	inUse := make(map[string][]string)
+	for _, instance := range instances {
+		if instance.State == nil {
+			continue
+		}
+		switch instance.State.Name {
+		case types.InstanceStateNameShuttingDown, types.InstanceStateNameTerminated:
+			continue
+		}
+		imageID := aws.ToString(instance.ImageId)
+		if wanted[imageID] {
+			inUse[imageID] = append(inUse[imageID], aws.ToString(instance.InstanceId))
+		}
+	}
+	return inUse, nil
+}
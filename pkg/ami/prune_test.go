@@ -0,0 +1,154 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestPruneAMIs(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	image := func(id, created, role string) types.Image {
+		return types.Image{
+			ImageId:      aws.String(id),
+			Name:         aws.String("golden-" + id),
+			CreationDate: aws.String(created),
+			Tags:         []types.Tag{{Key: aws.String("Role"), Value: aws.String(role)}},
+			BlockDeviceMappings: []types.BlockDeviceMapping{
+				{
+					DeviceName: aws.String("/dev/xvda"),
+					Ebs:        &types.EbsBlockDevice{SnapshotId: aws.String("snap-" + id)},
+				},
+			},
+		}
+	}
+	// Listed out of order to check they are sorted by creation date
+	images := []types.Image{
+		image("ami-2", "2024-02-01T00:00:00.000Z", "golden"),
+		image("ami-4", "2024-04-01T00:00:00.000Z", "golden"),
+		image("ami-1", "2024-01-01T00:00:00.000Z", "golden"),
+		image("ami-3", "2024-03-01T00:00:00.000Z", "golden"),
+		image("ami-other", "2023-01-01T00:00:00.000Z", "base"),
+	}
+	instance := func(id, imageID string, state types.InstanceStateName) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String(imageID),
+			State:      &types.InstanceState{Name: state},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		keep             int
+		instances        []types.Instance
+		deregisterErr    error
+		wantPruned       []string
+		wantReasons      map[string]string
+		wantDeregistered []string
+		wantDeleted      []string
+		wantErr          string
+	}{
+		{
+			name:             "keeps the newest",
+			keep:             2,
+			wantPruned:       []string{"ami-2", "ami-1"},
+			wantDeregistered: []string{"ami-2", "ami-1"},
+			wantDeleted:      []string{"snap-ami-2", "snap-ami-1"},
+		},
+		{
+			name: "nothing to prune",
+			keep: 4,
+		},
+		{
+			name:             "skips AMIs in use",
+			keep:             1,
+			instances:        []types.Instance{instance("i-1", "ami-2", types.InstanceStateNameStopped), instance("i-2", "ami-1", types.InstanceStateNameTerminated)},
+			wantPruned:       []string{"ami-3", "ami-2", "ami-1"},
+			wantReasons:      map[string]string{"ami-2": "in use by i-1"},
+			wantDeregistered: []string{"ami-3", "ami-1"},
+			wantDeleted:      []string{"snap-ami-3", "snap-ami-1"},
+		},
+		{
+			name:          "deregister fails",
+			keep:          3,
+			deregisterErr: fmt.Errorf("access denied"),
+			wantPruned:    []string{"ami-1"},
+			wantErr:       "prune AMI ami-1: deregister image ami-1: access denied",
+		},
+		{
+			name:    "negative keep",
+			keep:    -1,
+			wantErr: "keep must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = append([]types.Image(nil), images...)
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: tt.instances}},
+			}
+			mockClient.DeregisterImageError = tt.deregisterErr
+
+			svc := NewService(mockClient)
+			pruned, err := svc.PruneAMIs(context.Background(), "Role", "golden", tt.keep)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+
+			var prunedIDs []string
+			for _, ami := range pruned {
+				prunedIDs = append(prunedIDs, ami.AMIID)
+				assert.Equal(t, tt.wantReasons[ami.AMIID], ami.Reason)
+				assert.Equal(t, containsID(tt.wantDeregistered, ami.AMIID), ami.Deregistered)
+			}
+			assert.Equal(t, tt.wantPruned, prunedIDs)
+			assert.Equal(t, tt.wantDeregistered, mockClient.DeregisteredImages)
+			assert.Equal(t, tt.wantDeleted, mockClient.DeletedSnapshots)
+		})
+	}
+}
+
+func TestPruneCandidates(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{
+		{ImageId: aws.String("ami-1"), CreationDate: aws.String("2024-01-01T00:00:00.000Z"), Tags: []types.Tag{{Key: aws.String("Role"), Value: aws.String("golden")}}},
+		{ImageId: aws.String("ami-2"), CreationDate: aws.String("2024-02-01T00:00:00.000Z"), Tags: []types.Tag{{Key: aws.String("Role"), Value: aws.String("golden")}}},
+	}
+
+	svc := NewService(mockClient)
+	candidates, err := svc.PruneCandidates(context.Background(), "Role", "golden", 1)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, "ami-1", candidates[0].AMIID)
+	assert.False(t, candidates[0].Deregistered)
+	// A dry run changes nothing
+	assert.Empty(t, mockClient.DeregisteredImages)
+	assert.Empty(t, mockClient.DeletedSnapshots)
+}
+
+func containsID(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// MigrateRegions runs MigrateInstances in every region of services at the
+// same time and merges the results. Each service must use clients in its
+// region. Within a region opts.MaxConcurrency and the batching options apply
+// as usual; regions don't share the limit.
+//
+// AMI IDs are regional, so opts.NewAMI is taken to be in amiRegion. The other
+// regions migrate to the AMI we own there with the same name, such as the
+// copy made by CopyAMI. With an empty opts.NewAMI each region looks up the
+// latest AMI for each instance's OS type as usual.
+//
+// A region that fails doesn't stop the others. The merged result lists every
+// region's instances, with the per-region results, including any dry-run
+// plan, in Regions and the errors in RegionErrors. The returned error joins
+// the regions' errors.
+func MigrateRegions(ctx context.Context, services map[string]*Service, enabledValue string, opts MigrateOptions, amiRegion string) (*MigrationResult, error) {
+	logger.Info("Starting migration in several regions", "regions", len(services), "newAMI", opts.NewAMI, "amiRegion", amiRegion)
+	start := time.Now()
+
+	// The regions report progress concurrently, so serialize it across all of them
+	opts.Progress = serializeProgress(opts.Progress)
+
+	var mu sync.Mutex
+	results := make(map[string]*MigrationResult)
+	errs := make(map[string]error)
+	var wg sync.WaitGroup
+	for region, svc := range services {
+		wg.Add(1)
+		go func(region string, svc *Service) {
+			defer wg.Done()
+			result, err := svc.migrateRegion(ctx, region, enabledValue, opts, amiRegion)
+			if err != nil {
+				logger.Error("Migration failed in region", "region", region, "error", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if result != nil {
+				results[region] = result
+			}
+			if err != nil {
+				errs[region] = err
+			}
+		}(region, svc)
+	}
+	wg.Wait()
+
+	merged := &MigrationResult{
+		EnabledValue: strings.Join(opts.enabledValues(enabledValue), ","),
+		Instances:    []InstanceResult{},
+		Regions:      results,
+	}
+	regions := make([]string, 0, len(services))
+	for region := range services {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var joined []error
+	for _, region := range regions {
+		if result, ok := results[region]; ok {
+			for _, instance := range result.Instances {
+				instance.Region = region
+				merged.Instances = append(merged.Instances, instance)
+			}
+		}
+		if err, ok := errs[region]; ok {
+			if merged.RegionErrors == nil {
+				merged.RegionErrors = make(map[string]string)
+			}
+			merged.RegionErrors[region] = err.Error()
+			joined = append(joined, fmt.Errorf("%s: %w", region, err))
+		}
+	}
+	merged.Summarize()
+	merged.Duration = time.Since(start)
+	return merged, errors.Join(joined...)
+}
+
+// migrateRegion runs the region's part of MigrateRegions
+func (s *Service) migrateRegion(ctx context.Context, region, enabledValue string, opts MigrateOptions, amiRegion string) (*MigrationResult, error) {
+	if opts.NewAMI != "" && region != amiRegion {
+		target, err := s.FindAMICopy(ctx, opts.NewAMI, amiRegion, region)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Using regional copy of target AMI", "region", region, "sourceAMI", opts.NewAMI, "newAMI", target)
+		opts.NewAMI = target
+	}
+	return s.MigrateInstances(ctx, enabledValue, opts)
+}
+
+// FindAMICopy returns the AMI we own in destRegion with the same name as
+// amiID in sourceRegion, such as the copy made by CopyAMI
+func (s *Service) FindAMICopy(ctx context.Context, amiID, sourceRegion, destRegion string) (string, error) {
+	if sourceRegion == destRegion {
+		return amiID, nil
+	}
+
+	result, err := s.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []string{amiID},
+	}, withRegion(sourceRegion))
+	if err != nil {
+		return "", classifyError(fmt.Errorf("describe image %s in %s: %w", amiID, sourceRegion, err))
+	}
+	var name string
+	for _, image := range result.Images {
+		if aws.ToString(image.ImageId) == amiID {
+			name = aws.ToString(image.Name)
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("%w in %s: %s", ErrAMINotFound, sourceRegion, amiID)
+	}
+
+	image, err := s.findImageByName(ctx, name, destRegion)
+	if err != nil {
+		return "", fmt.Errorf("find copy of %s in %s: %w", amiID, destRegion, err)
+	}
+	if image == nil {
+		return "", fmt.Errorf("%w in %s named %q, copy %s there first", ErrAMINotFound, destRegion, name, amiID)
+	}
+	return aws.ToString(image.ImageId), nil
+}
+
+// EnabledRegions lists the regions enabled for the account, sorted
+func (s *Service) EnabledRegions(ctx context.Context) ([]string, error) {
+	result, err := s.client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, classifyError(fmt.Errorf("describe regions: %w", err))
+	}
+	regions := make([]string, 0, len(result.Regions))
+	for _, region := range result.Regions {
+		regions = append(regions, aws.ToString(region.RegionName))
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
@@ -0,0 +1,166 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateRegions(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	regionClient := func(instanceID string) *apitypes.MockEC2Client {
+		mockClient := apitypes.NewMockEC2Client()
+		mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{{Instances: []types.Instance{{
+				InstanceId: aws.String(instanceID),
+				ImageId:    aws.String("ami-old"),
+				State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+				Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+			}}}},
+		}
+		return mockClient
+	}
+	named := func(id string) types.Image {
+		image := availableImage(id)
+		image.Name = aws.String("app-v2")
+		return image
+	}
+
+	east := regionClient("i-east")
+	east.Images = []types.Image{named("ami-east")}
+	west := regionClient("i-west")
+	// Requests without a region go to the client's own region
+	west.ImagesByRegion = map[string][]types.Image{
+		"us-east-1": {named("ami-east")},
+		"us-west-2": {named("ami-west")},
+		"":          {named("ami-west")},
+	}
+	eu := regionClient("i-eu")
+	eu.ImagesByRegion = map[string][]types.Image{
+		"us-east-1": {named("ami-east")},
+	}
+
+	result, err := MigrateRegions(context.Background(), map[string]*Service{
+		"us-east-1": NewService(east),
+		"us-west-2": NewService(west),
+		"eu-west-1": NewService(eu),
+	}, "enabled", MigrateOptions{NewAMI: "ami-east"}, "us-east-1")
+
+	// The region without a copy of the AMI fails without stopping the others
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrAMINotFound)
+	assert.Contains(t, err.Error(), "eu-west-1: ")
+	require.Contains(t, result.RegionErrors, "eu-west-1")
+	assert.Contains(t, result.RegionErrors["eu-west-1"], "copy ami-east there first")
+	assert.Empty(t, eu.RunInstancesInputs)
+
+	require.Len(t, result.Instances, 2)
+	assert.Equal(t, "us-east-1", result.Instances[0].Region)
+	assert.Equal(t, "i-east", result.Instances[0].InstanceID)
+	assert.Equal(t, "us-west-2", result.Instances[1].Region)
+	assert.Equal(t, "i-west", result.Instances[1].InstanceID)
+	assert.Equal(t, 2, result.Summary.Completed)
+	assert.Len(t, result.Regions, 2)
+
+	// Each region launches from its own copy of the AMI
+	require.Len(t, east.RunInstancesInputs, 1)
+	assert.Equal(t, "ami-east", aws.ToString(east.RunInstancesInputs[0].ImageId))
+	require.Len(t, west.RunInstancesInputs, 1)
+	assert.Equal(t, "ami-west", aws.ToString(west.RunInstancesInputs[0].ImageId))
+}
+
+func TestFindAMICopy(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	image := func(id, name string) types.Image {
+		return types.Image{ImageId: aws.String(id), Name: aws.String(name), State: types.ImageStateAvailable}
+	}
+
+	tests := []struct {
+		name       string
+		images     map[string][]types.Image
+		sourceAMI  string
+		destRegion string
+		want       string
+		wantErr    error
+	}{
+		{
+			name: "finds the copy by name",
+			images: map[string][]types.Image{
+				"us-east-1": {image("ami-source", "app-v2")},
+				"us-west-2": {image("ami-old", "app-v1"), image("ami-copy", "app-v2")},
+			},
+			sourceAMI:  "ami-source",
+			destRegion: "us-west-2",
+			want:       "ami-copy",
+		},
+		{
+			name:       "same region",
+			sourceAMI:  "ami-source",
+			destRegion: "us-east-1",
+			want:       "ami-source",
+		},
+		{
+			name: "no copy",
+			images: map[string][]types.Image{
+				"us-east-1": {image("ami-source", "app-v2")},
+				"us-west-2": {image("ami-old", "app-v1")},
+			},
+			sourceAMI:  "ami-source",
+			destRegion: "us-west-2",
+			wantErr:    ErrAMINotFound,
+		},
+		{
+			name: "source AMI not found",
+			images: map[string][]types.Image{
+				"us-west-2": {image("ami-copy", "app-v2")},
+			},
+			sourceAMI:  "ami-source",
+			destRegion: "us-west-2",
+			wantErr:    ErrAMINotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.ImagesByRegion = tt.images
+
+			svc := NewService(mockClient)
+			got, err := svc.FindAMICopy(context.Background(), tt.sourceAMI, "us-east-1", tt.destRegion)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEnabledRegions(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Regions = []types.Region{
+		{RegionName: aws.String("us-west-2")},
+		{RegionName: aws.String("eu-west-1")},
+		{RegionName: aws.String("us-east-1")},
+	}
+
+	svc := NewService(mockClient)
+	regions, err := svc.EnabledRegions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1", "us-east-1", "us-west-2"}, regions)
+}
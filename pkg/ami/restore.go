@@ -0,0 +1,295 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// RestoreSpec describes the instance RestoreInstance launches. Empty fields
+// are taken from the original instance when it still exists, or from the
+// metadata recorded on migration snapshots.
+type RestoreSpec struct {
+	// SourceInstanceID is the instance the snapshots were taken of. It
+	// defaults to the ami-migrate-instance tag of the snapshots.
+	SourceInstanceID string
+	// ImageID is the AMI to launch from. When a snapshot restores the root
+	// volume it only supplies the architecture and boot settings.
+	ImageID          string
+	InstanceType     types.InstanceType
+	SubnetID         string
+	SecurityGroupIDs []string
+	KeyName          string
+}
+
+// RestoreInstance launches a new instance with volumes created from the
+// given snapshots, each attached at the device recorded in its
+// ami-migrate-device tag. A snapshot of the AMI's root device becomes the
+// root volume through a temporary AMI that is deregistered once the instance
+// has launched. The original instance's tags are restored when it still
+// exists. The new instance ID is returned.
+func (s *Service) RestoreInstance(ctx context.Context, snapshotIDs []string, spec RestoreSpec) (string, error) {
+	if len(snapshotIDs) == 0 {
+		return "", fmt.Errorf("no snapshots to restore")
+	}
+	logger.Info("Starting instance restore", "snapshotIDs", snapshotIDs)
+
+	snapshots, err := s.restoreSnapshots(ctx, snapshotIDs)
+	if err != nil {
+		return "", err
+	}
+
+	sourceID := spec.SourceInstanceID
+	if sourceID == "" {
+		sourceID = tagValue(snapshots[0].Tags, "ami-migrate-instance")
+	}
+	var original *types.Instance
+	if sourceID != "" {
+		if original, err = s.findInstance(ctx, sourceID); err != nil {
+			return "", fmt.Errorf("find original instance: %w", err)
+		}
+	}
+
+	imageID, instanceType := spec.ImageID, spec.InstanceType
+	if original != nil {
+		if imageID == "" {
+			imageID = aws.ToString(original.ImageId)
+		}
+		if instanceType == "" {
+			instanceType = original.InstanceType
+		}
+	}
+	if imageID == "" {
+		imageID = tagValue(snapshots[0].Tags, sourceAMITagKey)
+	}
+	if instanceType == "" {
+		instanceType = types.InstanceType(tagValue(snapshots[0].Tags, instanceTypeTagKey))
+	}
+	if imageID == "" {
+		return "", fmt.Errorf("no AMI to restore from: original instance and AMI unknown")
+	}
+	if instanceType == "" {
+		return "", fmt.Errorf("no instance type to restore with: original instance and instance type unknown")
+	}
+
+	image, err := s.getImage(ctx, imageID)
+	if err != nil {
+		return "", err
+	}
+	rootDevice := aws.ToString(image.RootDeviceName)
+
+	if err := s.waitForSnapshotsCompleted(ctx, snapshotIDs); err != nil {
+		return "", fmt.Errorf("wait for snapshots: %w", err)
+	}
+
+	var mappings []types.BlockDeviceMapping
+	var rootSnapshot *types.Snapshot
+	for i, snapshot := range snapshots {
+		device := tagValue(snapshot.Tags, "ami-migrate-device")
+		if device == rootDevice {
+			rootSnapshot = &snapshots[i]
+			continue
+		}
+		mappings = append(mappings, types.BlockDeviceMapping{
+			DeviceName: aws.String(device),
+			Ebs: &types.EbsBlockDevice{
+				SnapshotId:          snapshot.SnapshotId,
+				DeleteOnTermination: aws.Bool(false),
+			},
+		})
+	}
+
+	launchImageID := imageID
+	if rootSnapshot != nil {
+		launchImageID, err = s.registerRestoreImage(ctx, image, *rootSnapshot, sourceID)
+		if err != nil {
+			return "", err
+		}
+		// The instance keeps running once launched, so the AMI is only needed until then
+		defer s.deregisterRestoreImage(ctx, launchImageID)
+	}
+
+	runInput := &ec2.RunInstancesInput{
+		ImageId:             aws.String(launchImageID),
+		InstanceType:        instanceType,
+		MinCount:            aws.Int32(1),
+		MaxCount:            aws.Int32(1),
+		BlockDeviceMappings: mappings,
+	}
+	if original != nil {
+		applyNetworking(runInput, *original)
+		runInput.KeyName = original.KeyName
+	}
+	if spec.SubnetID != "" {
+		runInput.SubnetId = aws.String(spec.SubnetID)
+	}
+	if len(spec.SecurityGroupIDs) > 0 {
+		runInput.SecurityGroupIds = spec.SecurityGroupIDs
+	}
+	if spec.KeyName != "" {
+		runInput.KeyName = aws.String(spec.KeyName)
+	}
+
+	runResult, err := s.client.RunInstances(ctx, runInput)
+	if err != nil {
+		return "", fmt.Errorf("run instances: %w", err)
+	}
+	if len(runResult.Instances) == 0 {
+		return "", fmt.Errorf("no instance created")
+	}
+	newInstance := runResult.Instances[0]
+
+	if original != nil {
+		if err := s.copyTags(ctx, types.Instance{Tags: rollbackTags(original.Tags)}, newInstance, tagCopyRules{}); err != nil {
+			return "", fmt.Errorf("copy tags: %w", err)
+		}
+	}
+
+	if err := s.tagInstanceStatus(ctx, newInstance, "restored", fmt.Sprintf("Restored from snapshots: %s", strings.Join(snapshotIDs, ", "))); err != nil {
+		return "", fmt.Errorf("tag instance status: %w", err)
+	}
+
+	logger.Info("Instance restore completed", "sourceInstanceID", sourceID, "newInstanceID", aws.ToString(newInstance.InstanceId))
+	return aws.ToString(newInstance.InstanceId), nil
+}
+
+// BackupSnapshots returns the IDs of the newest snapshot per device taken of
+// an instance by a backup or migration
+func (s *Service) BackupSnapshots(ctx context.Context, instanceID string) ([]string, error) {
+	snapshots, err := s.instanceSnapshots(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("find backup snapshots: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no backup snapshots found for instance: %s", instanceID)
+	}
+
+	snapshotIDs := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		snapshotIDs = append(snapshotIDs, aws.ToString(snapshot.SnapshotId))
+	}
+	return snapshotIDs, nil
+}
+
+// restoreSnapshots describes the snapshots to restore in the given order and
+// checks that each one maps to its own device
+func (s *Service) restoreSnapshots(ctx context.Context, snapshotIDs []string) ([]types.Snapshot, error) {
+	result, err := s.client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{
+		SnapshotIds: snapshotIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe snapshots: %w", err)
+	}
+	described := make(map[string]types.Snapshot, len(result.Snapshots))
+	for _, snapshot := range result.Snapshots {
+		described[aws.ToString(snapshot.SnapshotId)] = snapshot
+	}
+
+	devices := make(map[string]string, len(snapshotIDs))
+	snapshots := make([]types.Snapshot, 0, len(snapshotIDs))
+	for _, snapshotID := range snapshotIDs {
+		snapshot, ok := described[snapshotID]
+		if !ok {
+			return nil, fmt.Errorf("snapshot not found: %s", snapshotID)
+		}
+		device := tagValue(snapshot.Tags, "ami-migrate-device")
+		if device == "" {
+			return nil, fmt.Errorf("snapshot %s has no ami-migrate-device tag", snapshotID)
+		}
+		if other, exists := devices[device]; exists {
+			return nil, fmt.Errorf("snapshots %s and %s are both for device %s", other, snapshotID, device)
+		}
+		devices[device] = snapshotID
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// findInstance returns the instance with the given ID, or nil once it no
+// longer exists. Unlike getInstance it doesn't fail for unknown IDs.
+func (s *Service) findInstance(ctx context.Context, instanceID string) (*types.Instance, error) {
+	instances, err := s.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("instance-id"),
+				Values: []string{instanceID},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe instances: %w", err)
+	}
+	for i, instance := range instances {
+		if aws.ToString(instance.InstanceId) == instanceID {
+			return &instances[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// registerRestoreImage registers an AMI booting from rootSnapshot with the
+// architecture and boot settings of image, and waits for it to be available
+func (s *Service) registerRestoreImage(ctx context.Context, image *types.Image, rootSnapshot types.Snapshot, sourceID string) (string, error) {
+	name := sourceID
+	if name == "" {
+		name = aws.ToString(rootSnapshot.SnapshotId)
+	}
+
+	input := &ec2.RegisterImageInput{
+		Name:           aws.String(fmt.Sprintf("ec-manager-restore-%s-%d", name, time.Now().Unix())),
+		Description:    aws.String(fmt.Sprintf("Restore of %s from snapshot %s", name, aws.ToString(rootSnapshot.SnapshotId))),
+		Architecture:   image.Architecture,
+		RootDeviceName: image.RootDeviceName,
+		EnaSupport:     image.EnaSupport,
+		BootMode:       image.BootMode,
+		BlockDeviceMappings: []types.BlockDeviceMapping{
+			{
+				DeviceName: image.RootDeviceName,
+				Ebs: &types.EbsBlockDevice{
+					SnapshotId:          rootSnapshot.SnapshotId,
+					DeleteOnTermination: aws.Bool(true),
+				},
+			},
+		},
+	}
+	if image.VirtualizationType != "" {
+		input.VirtualizationType = aws.String(string(image.VirtualizationType))
+	}
+
+	result, err := s.client.RegisterImage(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("register image from snapshot %s: %w", aws.ToString(rootSnapshot.SnapshotId), err)
+	}
+	imageID := aws.ToString(result.ImageId)
+
+	maxWaitTime, err := s.remainingWait(ctx)
+	if err == nil {
+		err = ec2.NewImageAvailableWaiter(s.client).Wait(ctx, &ec2.DescribeImagesInput{
+			ImageIds: []string{imageID},
+		}, maxWaitTime)
+	}
+	if err != nil {
+		s.deregisterRestoreImage(ctx, imageID)
+		return "", fmt.Errorf("wait for image %s: %w", imageID, err)
+	}
+	return imageID, nil
+}
+
+// deregisterRestoreImage removes the temporary AMI registered for a restore.
+// The root snapshot it was registered from is left in place.
+func (s *Service) deregisterRestoreImage(ctx context.Context, imageID string) {
+	cleanupCtx, cancel := detachedContext(ctx)
+	defer cancel()
+
+	if _, err := s.client.DeregisterImage(cleanupCtx, &ec2.DeregisterImageInput{
+		ImageId: aws.String(imageID),
+	}); err != nil {
+		logger.Warn("Failed to deregister restore image", "imageID", imageID, "error", err)
+	}
+}
@@ -0,0 +1,225 @@
+package ami
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// tagRecordingClient records the tags applied to each resource
+type tagRecordingClient struct {
+	*apitypes.MockEC2Client
+	tags map[string][]types.Tag
+}
+
+func (c *tagRecordingClient) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	for _, resource := range params.Resources {
+		c.tags[resource] = append(c.tags[resource], params.Tags...)
+	}
+	return c.MockEC2Client.CreateTags(ctx, params, optFns...)
+}
+
+func backupSnapshot(snapshotID, device string) types.Snapshot {
+	return types.Snapshot{
+		SnapshotId: aws.String(snapshotID),
+		State:      types.SnapshotStateCompleted,
+		Tags: []types.Tag{
+			{Key: aws.String("ami-migrate-instance"), Value: aws.String("i-123")},
+			{Key: aws.String("ami-migrate-device"), Value: aws.String(device)},
+		},
+	}
+}
+
+func TestRestoreInstance(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	original := types.Instance{
+		InstanceId:     aws.String("i-123"),
+		ImageId:        aws.String("ami-old"),
+		InstanceType:   types.InstanceTypeT3Micro,
+		SubnetId:       aws.String("subnet-1"),
+		KeyName:        aws.String("ops"),
+		SecurityGroups: []types.GroupIdentifier{{GroupId: aws.String("sg-1")}},
+		State:          &types.InstanceState{Name: types.InstanceStateNameStopped},
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String("web")},
+			{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("web-stack")},
+		},
+	}
+	snapshots := []types.Snapshot{
+		backupSnapshot("snap-root", "/dev/xvda"),
+		backupSnapshot("snap-data", "/dev/sdf"),
+		backupSnapshot("snap-logs", "/dev/sdg"),
+	}
+
+	tests := []struct {
+		name         string
+		snapshotIDs  []string
+		spec         RestoreSpec
+		original     bool
+		wantImage    string
+		wantDevices  []string
+		wantType     types.InstanceType
+		wantSubnet   string
+		wantTags     bool
+		wantErr      string
+		wantRegister bool
+	}{
+		{
+			name:         "root and data volumes",
+			snapshotIDs:  []string{"snap-root", "snap-data", "snap-logs"},
+			original:     true,
+			wantImage:    "ami-registered-1",
+			wantDevices:  []string{"/dev/sdf", "/dev/sdg"},
+			wantType:     types.InstanceTypeT3Micro,
+			wantSubnet:   "subnet-1",
+			wantTags:     true,
+			wantRegister: true,
+		},
+		{
+			name:        "data volumes only",
+			snapshotIDs: []string{"snap-logs", "snap-data"},
+			original:    true,
+			wantImage:   "ami-old",
+			wantDevices: []string{"/dev/sdg", "/dev/sdf"},
+			wantType:    types.InstanceTypeT3Micro,
+			wantSubnet:  "subnet-1",
+			wantTags:    true,
+		},
+		{
+			name:        "spec overrides original",
+			snapshotIDs: []string{"snap-data"},
+			spec:        RestoreSpec{InstanceType: types.InstanceTypeM6iLarge, SubnetID: "subnet-2"},
+			original:    true,
+			wantImage:   "ami-old",
+			wantDevices: []string{"/dev/sdf"},
+			wantType:    types.InstanceTypeM6iLarge,
+			wantSubnet:  "subnet-2",
+			wantTags:    true,
+		},
+		{
+			name:        "original gone",
+			snapshotIDs: []string{"snap-data"},
+			spec:        RestoreSpec{ImageID: "ami-old", InstanceType: types.InstanceTypeT3Micro},
+			wantImage:   "ami-old",
+			wantDevices: []string{"/dev/sdf"},
+			wantType:    types.InstanceTypeT3Micro,
+		},
+		{
+			name:        "original gone without an AMI",
+			snapshotIDs: []string{"snap-data"},
+			wantErr:     "no AMI to restore from",
+		},
+		{
+			name:        "unknown snapshot",
+			snapshotIDs: []string{"snap-data", "snap-missing"},
+			original:    true,
+			wantErr:     "snapshot not found: snap-missing",
+		},
+		{
+			name:        "two snapshots for one device",
+			snapshotIDs: []string{"snap-data", "snap-data-2"},
+			original:    true,
+			wantErr:     "snapshots snap-data and snap-data-2 are both for device /dev/sdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Snapshots = append(append([]types.Snapshot{}, snapshots...), backupSnapshot("snap-data-2", "/dev/sdf"))
+			mockClient.Images = []types.Image{
+				{
+					ImageId:        aws.String("ami-old"),
+					State:          types.ImageStateAvailable,
+					Architecture:   types.ArchitectureValuesX8664,
+					RootDeviceName: aws.String("/dev/xvda"),
+				},
+			}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{}
+			if tt.original {
+				mockClient.DescribeInstancesOutput.Reservations = []types.Reservation{
+					{Instances: []types.Instance{original}},
+				}
+			}
+			client := &tagRecordingClient{MockEC2Client: mockClient, tags: make(map[string][]types.Tag)}
+
+			svc := NewService(client)
+			newInstanceID, err := svc.RestoreInstance(context.Background(), tt.snapshotIDs, tt.spec)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, mockClient.RunInstancesInputs)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "i-456", newInstanceID)
+
+			if tt.wantRegister {
+				if assert.Len(t, mockClient.RegisterImageInputs, 1) {
+					input := mockClient.RegisterImageInputs[0]
+					assert.Equal(t, "/dev/xvda", aws.ToString(input.RootDeviceName))
+					assert.Equal(t, types.ArchitectureValuesX8664, input.Architecture)
+					assert.Equal(t, "snap-root", aws.ToString(input.BlockDeviceMappings[0].Ebs.SnapshotId))
+				}
+				// The temporary AMI doesn't outlive the launch
+				assert.Equal(t, []string{"ami-registered-1"}, mockClient.DeregisteredImages)
+			} else {
+				assert.Empty(t, mockClient.RegisterImageInputs)
+			}
+
+			if assert.Len(t, mockClient.RunInstancesInputs, 1) {
+				input := mockClient.RunInstancesInputs[0]
+				assert.Equal(t, tt.wantImage, aws.ToString(input.ImageId))
+				assert.Equal(t, tt.wantType, input.InstanceType)
+				assert.Equal(t, tt.wantSubnet, aws.ToString(input.SubnetId))
+
+				var devices []string
+				for _, mapping := range input.BlockDeviceMappings {
+					devices = append(devices, aws.ToString(mapping.DeviceName))
+				}
+				assert.Equal(t, tt.wantDevices, devices)
+			}
+
+			restoredTags := client.tags["i-456"]
+			assert.Equal(t, "restored", tagValue(restoredTags, "ami-migrate-status"))
+			if tt.wantTags {
+				assert.Equal(t, "web", tagValue(restoredTags, "Name"))
+			} else {
+				assert.Empty(t, tagValue(restoredTags, "Name"))
+			}
+			// AWS reserved tags can't be set and are never copied
+			assert.Empty(t, tagValue(restoredTags, "aws:cloudformation:stack-name"))
+		})
+	}
+}
+
+func TestBackupSnapshots(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	older := backupSnapshot("snap-old", "/dev/xvda")
+	older.StartTime = aws.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newer := backupSnapshot("snap-new", "/dev/xvda")
+	newer.StartTime = aws.Time(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	data := backupSnapshot("snap-data", "/dev/sdf")
+	data.StartTime = older.StartTime
+	mockClient.Snapshots = []types.Snapshot{older, data, newer}
+
+	svc := NewService(mockClient)
+	snapshotIDs, err := svc.BackupSnapshots(context.Background(), "i-123")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"snap-new", "snap-data"}, snapshotIDs)
+
+	mockClient.Snapshots = nil
+	_, err = svc.BackupSnapshots(context.Background(), "i-123")
+	assert.EqualError(t, err, "no backup snapshots found for instance: i-123")
+}
@@ -0,0 +1,164 @@
+package ami
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Migration outcomes, matching the values written to the ami-migrate-status tag
+const (
+	StatusCompleted = "completed"
+	StatusSkipped   = "skipped"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	// StatusSnapshotted is the outcome of a SnapshotOnly run, whose
+	// instances are ready for a Cutover
+	StatusSnapshotted = "snapshotted"
+	// StatusRolledBack marks an instance relaunched by RollbackInstance
+	StatusRolledBack = "rolled-back"
+)
+
+// InstanceResult describes the outcome of migrating a single instance
+type InstanceResult struct {
+	InstanceID    string        `json:"instance_id"`
+	Status        string        `json:"status"`
+	OldAMI        string        `json:"old_ami"`
+	NewAMI        string        `json:"new_ami"`
+	NewInstanceID string        `json:"new_instance_id,omitempty"`
+	Message       string        `json:"message,omitempty"`
+	Duration      time.Duration `json:"duration"`
+	// Snapshots lists the backup snapshot of each volume taken for a
+	// completed migration or a SnapshotOnly run, or used by a Cutover
+	Snapshots []MigrationSnapshot `json:"snapshots,omitempty"`
+	// KeptSnapshots lists the snapshots a failed migration left behind
+	KeptSnapshots []string `json:"kept_snapshots,omitempty"`
+	// Region is the instance's region in the result of MigrateRegions
+	Region string `json:"region,omitempty"`
+}
+
+// MigrationSnapshot records the backup snapshot of one of an instance's volumes
+type MigrationSnapshot struct {
+	DeviceName string `json:"device_name"`
+	VolumeID   string `json:"volume_id"`
+	SnapshotID string `json:"snapshot_id"`
+}
+
+// MigrationSummary counts the instance outcomes of a migration run
+type MigrationSummary struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+	// Snapshotted counts the instances staged by a SnapshotOnly run
+	Snapshotted int `json:"snapshotted,omitempty"`
+}
+
+// MigrationResult collects the outcome of a migration run. For a dry run
+// Instances is empty and Plan describes what would have been done.
+type MigrationResult struct {
+	EnabledValue string           `json:"enabled_value"`
+	Instances    []InstanceResult `json:"instances"`
+	Summary      MigrationSummary `json:"summary"`
+	Duration     time.Duration    `json:"duration"`
+	Plan         *MigrationPlan   `json:"plan,omitempty"`
+	// Regions holds each region's own result in the result of MigrateRegions
+	Regions map[string]*MigrationResult `json:"regions,omitempty"`
+	// RegionErrors holds the error of each region whose run failed in the
+	// result of MigrateRegions
+	RegionErrors map[string]string `json:"region_errors,omitempty"`
+}
+
+// Summarize recounts the summary from the instance results
+func (r *MigrationResult) Summarize() {
+	r.Summary = MigrationSummary{Total: len(r.Instances)}
+	for _, instance := range r.Instances {
+		switch instance.Status {
+		case StatusCompleted:
+			r.Summary.Completed++
+		case StatusSkipped:
+			r.Summary.Skipped++
+		case StatusFailed:
+			r.Summary.Failed++
+		case StatusCancelled:
+			r.Summary.Cancelled++
+		case StatusSnapshotted:
+			r.Summary.Snapshotted++
+		}
+	}
+}
+
+// FormatMigrationResult formats the result as a table, followed by a table
+// of the instances' snapshots if they have any and a summary line
+func (r *MigrationResult) FormatMigrationResult() string {
+	var b strings.Builder
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	// Results of MigrateRegions name each instance's region first
+	if r.Regions != nil {
+		fmt.Fprint(w, "REGION\t")
+	}
+	fmt.Fprintln(w, "INSTANCE\tSTATUS\tOLD AMI\tNEW AMI\tNEW INSTANCE\tDURATION\tMESSAGE")
+	for _, instance := range r.Instances {
+		if r.Regions != nil {
+			fmt.Fprintf(w, "%s\t", instance.Region)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			instance.InstanceID,
+			instance.Status,
+			instance.OldAMI,
+			instance.NewAMI,
+			instance.NewInstanceID,
+			instance.Duration.Round(time.Second),
+			instance.Message)
+	}
+	w.Flush()
+	r.formatSnapshots(&b)
+
+	b.WriteString(fmt.Sprintf("\n%d instances: %d completed, %d skipped, %d failed",
+		r.Summary.Total, r.Summary.Completed, r.Summary.Skipped, r.Summary.Failed))
+	if r.Summary.Cancelled > 0 {
+		b.WriteString(fmt.Sprintf(", %d cancelled", r.Summary.Cancelled))
+	}
+	if r.Summary.Snapshotted > 0 {
+		b.WriteString(fmt.Sprintf(", %d snapshotted", r.Summary.Snapshotted))
+	}
+	b.WriteString("\n")
+
+	regions := make([]string, 0, len(r.RegionErrors))
+	for region := range r.RegionErrors {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		b.WriteString(fmt.Sprintf("%s failed: %s\n", region, r.RegionErrors[region]))
+	}
+
+	return b.String()
+}
+
+// formatSnapshots writes a table of the instances' migration snapshots to b,
+// for an audit record of where each volume's backup is. Nothing is written
+// when no instance has snapshots.
+func (r *MigrationResult) formatSnapshots(b *strings.Builder) {
+	w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	header := false
+	for _, instance := range r.Instances {
+		for _, snapshot := range instance.Snapshots {
+			if !header {
+				fmt.Fprintln(w, "\nINSTANCE\tNEW INSTANCE\tDEVICE\tVOLUME\tSNAPSHOT")
+				header = true
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				instance.InstanceID,
+				instance.NewInstanceID,
+				snapshot.DeviceName,
+				snapshot.VolumeID,
+				snapshot.SnapshotID)
+		}
+	}
+	w.Flush()
+}
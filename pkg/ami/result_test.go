@@ -0,0 +1,84 @@
+package ami
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatMigrationResult(t *testing.T) {
+	result := &MigrationResult{
+		Instances: []InstanceResult{
+			{
+				InstanceID:    "i-1",
+				Status:        StatusCompleted,
+				OldAMI:        "ami-old",
+				NewAMI:        "ami-new",
+				NewInstanceID: "i-9",
+				Duration:      90 * time.Second,
+			},
+			{
+				InstanceID: "i-2",
+				Status:     StatusFailed,
+				OldAMI:     "ami-old",
+				NewAMI:     "ami-new",
+				Message:    "run instances: insufficient capacity",
+			},
+		},
+	}
+	result.Summarize()
+
+	out := result.FormatMigrationResult()
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if assert.Len(t, lines, 5) {
+		assert.True(t, strings.HasPrefix(lines[0], "INSTANCE"))
+		assert.Contains(t, lines[1], "i-1")
+		assert.Contains(t, lines[1], "i-9")
+		assert.Contains(t, lines[1], "1m30s")
+		assert.Contains(t, lines[2], "insufficient capacity")
+		assert.Equal(t, "2 instances: 1 completed, 0 skipped, 1 failed", lines[4])
+	}
+}
+
+func TestFormatMigrationResultCancelled(t *testing.T) {
+	result := &MigrationResult{
+		Instances: []InstanceResult{
+			{InstanceID: "i-1", Status: StatusCompleted},
+			{InstanceID: "i-2", Status: StatusCancelled, Message: "Migration cancelled before it started: context canceled"},
+		},
+	}
+	result.Summarize()
+
+	assert.Equal(t, MigrationSummary{Total: 2, Completed: 1, Cancelled: 1}, result.Summary)
+	lines := strings.Split(strings.TrimSpace(result.FormatMigrationResult()), "\n")
+	assert.Equal(t, "2 instances: 1 completed, 0 skipped, 0 failed, 1 cancelled", lines[len(lines)-1])
+}
+
+func TestFormatMigrationResultSnapshots(t *testing.T) {
+	result := &MigrationResult{
+		Instances: []InstanceResult{
+			{
+				InstanceID:    "i-1",
+				Status:        StatusCompleted,
+				NewInstanceID: "i-9",
+				Snapshots: []MigrationSnapshot{
+					{DeviceName: "/dev/xvda", VolumeID: "vol-root", SnapshotID: "snap-root"},
+					{DeviceName: "/dev/sdf", VolumeID: "vol-data", SnapshotID: "snap-data"},
+				},
+			},
+			{InstanceID: "i-2", Status: StatusSkipped},
+		},
+	}
+	result.Summarize()
+
+	lines := strings.Split(strings.TrimSpace(result.FormatMigrationResult()), "\n")
+	if assert.Len(t, lines, 9) {
+		assert.Empty(t, lines[3])
+		assert.Equal(t, []string{"INSTANCE", "NEW", "INSTANCE", "DEVICE", "VOLUME", "SNAPSHOT"}, strings.Fields(lines[4]))
+		assert.Equal(t, []string{"i-1", "i-9", "/dev/xvda", "vol-root", "snap-root"}, strings.Fields(lines[5]))
+		assert.Equal(t, []string{"i-1", "i-9", "/dev/sdf", "vol-data", "snap-data"}, strings.Fields(lines[6]))
+		assert.Equal(t, "2 instances: 1 completed, 1 skipped, 0 failed", lines[8])
+	}
+}
@@ -0,0 +1,180 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// resumableStatuses are the status tag values of the migrations
+// ResumeMigration picks up: interrupted while migrating or backing up, or
+// failed
+var resumableStatuses = []string{"migrating", "in-progress", StatusFailed}
+
+// ResumeMigration finishes the migrations to newAMI that were interrupted or
+// failed, going by the status tag. Completed migrations are left alone. The
+// result is reported as for MigrateInstances.
+func (s *Service) ResumeMigration(ctx context.Context, newAMI string) (*MigrationResult, error) {
+	return s.ResumeMigrationWithOptions(ctx, MigrateOptions{NewAMI: newAMI})
+}
+
+// ResumeMigrationWithOptions resumes migrations like ResumeMigration,
+// applying opts to the instances that are migrated again.
+//
+// An instance that is still around is migrated again, after terminating any
+// replacement a previous attempt left behind without copying its tags, since
+// the old instance is still the one in use. An instance that was terminated
+// is finished off instead: its replacement gets its tags and it is marked
+// completed. Without a replacement the migration is reported as failed, to
+// be rolled back from its snapshots.
+func (s *Service) ResumeMigrationWithOptions(ctx context.Context, opts MigrateOptions) (*MigrationResult, error) {
+	if opts.DryRun {
+		return nil, fmt.Errorf("resuming migrations doesn't support dry runs")
+	}
+	logger.Info("Resuming interrupted migrations", "newAMI", opts.NewAMI)
+	start := time.Now()
+
+	instances, err := s.fetchInstances(ctx, types.Filter{
+		Name:   aws.String("tag:" + s.tags.Status),
+		Values: resumableStatuses,
+	}, opts)
+	if err != nil {
+		logger.Error("Failed to fetch interrupted migrations", "error", err)
+		return nil, classifyError(fmt.Errorf("fetch interrupted migrations: %w", err))
+	}
+
+	var retry []types.Instance
+	var finished []InstanceResult
+	for _, instance := range instances {
+		replacements, err := s.replacementsOf(ctx, aws.ToString(instance.InstanceId))
+		if err != nil {
+			return nil, err
+		}
+		if instance.State != nil && (instance.State.Name == types.InstanceStateNameTerminated ||
+			instance.State.Name == types.InstanceStateNameShuttingDown) {
+			finished = append(finished, s.finishOrphanedMigration(ctx, instance, replacements, opts))
+			continue
+		}
+		if err := s.terminateUnfinishedReplacements(ctx, instance, replacements); err != nil {
+			finished = append(finished, InstanceResult{
+				InstanceID: aws.ToString(instance.InstanceId),
+				Status:     StatusFailed,
+				OldAMI:     aws.ToString(instance.ImageId),
+				NewAMI:     opts.NewAMI,
+				Message:    err.Error(),
+			})
+			continue
+		}
+		retry = append(retry, instance)
+	}
+
+	var result *MigrationResult
+	if len(retry) > 0 {
+		result, err = s.migrateSelected(ctx, start, "", retry, opts)
+		if result == nil {
+			return nil, err
+		}
+	} else {
+		result = &MigrationResult{Instances: []InstanceResult{}}
+	}
+
+	result.Instances = append(result.Instances, finished...)
+	sort.Slice(result.Instances, func(i, j int) bool {
+		return result.Instances[i].InstanceID < result.Instances[j].InstanceID
+	})
+	result.Summarize()
+	result.Duration = time.Since(start)
+	if err == nil && result.Summary.Failed > 0 {
+		err = fmt.Errorf("failed to migrate %d of %d instances", result.Summary.Failed, result.Summary.Total)
+	}
+	return result, err
+}
+
+// replacementsOf returns the live instances launched to replace instanceID
+func (s *Service) replacementsOf(ctx context.Context, instanceID string) ([]types.Instance, error) {
+	replacements, err := s.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:" + sourceInstanceTagKey),
+				Values: []string{instanceID},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: migratableStates,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find replacements of %s: %w", instanceID, err)
+	}
+	return replacements, nil
+}
+
+// finishOrphanedMigration completes the migration of an instance that was
+// terminated before its replacement got its tags
+func (s *Service) finishOrphanedMigration(ctx context.Context, instance types.Instance, replacements []types.Instance, opts MigrateOptions) InstanceResult {
+	instanceID := aws.ToString(instance.InstanceId)
+	result := InstanceResult{
+		InstanceID: instanceID,
+		OldAMI:     aws.ToString(instance.ImageId),
+		NewAMI:     opts.NewAMI,
+	}
+	fail := func(err error) InstanceResult {
+		s.tagMigrationFailed(ctx, instance, err)
+		result.Status = StatusFailed
+		result.Message = err.Error()
+		return result
+	}
+
+	if len(replacements) == 0 {
+		return fail(fmt.Errorf("instance was terminated without a replacement, roll it back from its migration snapshots"))
+	}
+	if len(replacements) > 1 {
+		return fail(fmt.Errorf("instance has %d replacements, keep one and terminate the others", len(replacements)))
+	}
+	replacement := replacements[0]
+	result.NewInstanceID = aws.ToString(replacement.InstanceId)
+	result.NewAMI = aws.ToString(replacement.ImageId)
+
+	if !hasTagKey(replacement.Tags, s.tags.Enabled) {
+		logger.Info("Copying tags to orphaned replacement", "instanceID", instanceID, "newInstanceID", result.NewInstanceID)
+		source := instance
+		source.Tags = withNameSuffix(instance.Tags, opts.NameSuffix)
+		if err := s.copyTags(ctx, source, replacement, opts.tagCopyRules()); err != nil {
+			return fail(fmt.Errorf("copy tags: %w", err))
+		}
+	}
+	result.Message = fmt.Sprintf("Resumed migration to AMI: %s", result.NewAMI)
+	if err := s.tagMigrationOutcome(ctx, instance, result.NewInstanceID, StatusCompleted, result.Message); err != nil {
+		return fail(fmt.Errorf("tag instance status: %w", err))
+	}
+	result.Status = StatusCompleted
+	return result
+}
+
+// terminateUnfinishedReplacements terminates the replacements an earlier
+// attempt launched for an instance that is still in use. A replacement that
+// already has the instance's tags can't be told apart from the instance, so
+// the migration isn't retried.
+func (s *Service) terminateUnfinishedReplacements(ctx context.Context, instance types.Instance, replacements []types.Instance) error {
+	for _, replacement := range replacements {
+		if hasTagKey(replacement.Tags, s.tags.Enabled) {
+			return fmt.Errorf("replacement %s already has the instance's tags, terminate one of them before resuming",
+				aws.ToString(replacement.InstanceId))
+		}
+	}
+	for _, replacement := range replacements {
+		logger.Warn("Terminating replacement left by an earlier migration attempt",
+			"instanceID", aws.ToString(instance.InstanceId), "replacementID", aws.ToString(replacement.InstanceId))
+		if err := s.terminateInstance(ctx, replacement); err != nil {
+			return fmt.Errorf("terminate earlier replacement %s: %w", aws.ToString(replacement.InstanceId), err)
+		}
+	}
+	return nil
+}
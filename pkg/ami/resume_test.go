@@ -0,0 +1,130 @@
+package ami
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// tagFilterClient serves DescribeInstances requests with tag or state
+// filters from instances, applying the filters
+type tagFilterClient struct {
+	*apitypes.MockEC2Client
+	instances []types.Instance
+}
+
+func (c *tagFilterClient) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	if len(params.Filters) == 0 {
+		return c.MockEC2Client.DescribeInstances(ctx, params, optFns...)
+	}
+	var matched []types.Instance
+	for _, instance := range c.instances {
+		matches := true
+		for _, filter := range params.Filters {
+			name := aws.ToString(filter.Name)
+			switch {
+			case strings.HasPrefix(name, "tag:"):
+				matches = matches && slices.Contains(filter.Values, tagValue(instance.Tags, strings.TrimPrefix(name, "tag:")))
+			case name == "instance-state-name":
+				matches = matches && slices.Contains(filter.Values, string(c.GetInstanceState(aws.ToString(instance.InstanceId))))
+			}
+		}
+		if matches {
+			matched = append(matched, instance)
+		}
+	}
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: matched}},
+	}, nil
+}
+
+func TestResumeMigration(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := func(id, imageID, status string, state types.InstanceStateName, extra ...types.Tag) types.Instance {
+		tags := []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}}
+		if status != "" {
+			tags = append(tags, types.Tag{Key: aws.String("ami-migrate-status"), Value: aws.String(status)})
+		}
+		return types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String(imageID),
+			State:      &types.InstanceState{Name: state},
+			Tags:       append(tags, extra...),
+		}
+	}
+	replacement := func(id, sourceID string, tags ...types.Tag) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String("ami-new"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+			Tags:       append([]types.Tag{{Key: aws.String(sourceInstanceTagKey), Value: aws.String(sourceID)}}, tags...),
+		}
+	}
+	instances := []types.Instance{
+		// Interrupted while migrating, with a replacement that never got its tags
+		instance("i-interrupted", "ami-old", "migrating", types.InstanceStateNameStopped),
+		replacement("i-interrupted-new", "i-interrupted"),
+		// Failed before anything was launched
+		instance("i-failed", "ami-old", StatusFailed, types.InstanceStateNameStopped),
+		// Terminated before the tags were copied
+		instance("i-orphaned", "ami-old", "migrating", types.InstanceStateNameTerminated,
+			types.Tag{Key: aws.String("Team"), Value: aws.String("web")}),
+		replacement("i-orphaned-new", "i-orphaned"),
+		// Terminated without a replacement
+		instance("i-lost", "ami-old", StatusFailed, types.InstanceStateNameTerminated),
+		// Already done
+		instance("i-completed", "ami-old", StatusCompleted, types.InstanceStateNameTerminated),
+	}
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: instances}},
+	}
+	for _, inst := range instances {
+		mockClient.InstanceStates[aws.ToString(inst.InstanceId)] = inst.State.Name
+	}
+
+	svc := NewService(&tagFilterClient{MockEC2Client: mockClient, instances: instances})
+	result, err := svc.ResumeMigration(context.Background(), "ami-new")
+	assert.EqualError(t, err, "failed to migrate 1 of 4 instances")
+	require.NotNil(t, result)
+
+	statuses := make(map[string]InstanceResult)
+	for _, r := range result.Instances {
+		statuses[r.InstanceID] = r
+	}
+	require.Len(t, statuses, 4)
+	assert.Equal(t, StatusCompleted, statuses["i-interrupted"].Status)
+	assert.Equal(t, StatusCompleted, statuses["i-failed"].Status)
+	assert.Equal(t, StatusCompleted, statuses["i-orphaned"].Status)
+	assert.Equal(t, "i-orphaned-new", statuses["i-orphaned"].NewInstanceID)
+	assert.Equal(t, StatusFailed, statuses["i-lost"].Status)
+	assert.Contains(t, statuses["i-lost"].Message, "terminated without a replacement")
+
+	// The stale replacement was thrown away and the instance migrated again
+	assert.Equal(t, types.InstanceStateNameTerminated, mockClient.GetInstanceState("i-interrupted-new"))
+	assert.Equal(t, types.InstanceStateNameTerminated, mockClient.GetInstanceState("i-interrupted"))
+	assert.Len(t, mockClient.RunInstancesInputs, 2)
+
+	// The orphaned replacement was kept and given the old instance's tags
+	assert.Equal(t, types.InstanceStateNameRunning, mockClient.GetInstanceState("i-orphaned-new"))
+	var orphanTags []types.Tag
+	for _, input := range mockClient.CreateTagsInputs {
+		if slices.Contains(input.Resources, "i-orphaned-new") {
+			orphanTags = append(orphanTags, input.Tags...)
+		}
+	}
+	assert.Equal(t, "web", tagValue(orphanTags, "Team"))
+}
@@ -0,0 +1,209 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+const (
+	// replacedByTagKey records the replacement on an old instance kept
+	// stopped by MigrateOptions.RetainOldInstance
+	replacedByTagKey = "ami-migrate-replaced-by"
+	// retainedAtTagKey records when the old instance was retained, so
+	// CleanupReplacedInstances can tell how long it has been kept
+	retainedAtTagKey = "ami-migrate-retained-at"
+)
+
+// ReplacedInstance describes what happened to a retained old instance during
+// cleanup
+type ReplacedInstance struct {
+	InstanceID       string    `json:"instance_id"`
+	ReplacedBy       string    `json:"replaced_by"`
+	RetainedAt       time.Time `json:"retained_at"`
+	Terminated       bool      `json:"terminated"`
+	DeletedSnapshots []string  `json:"deleted_snapshots,omitempty"`
+	Reason           string    `json:"reason,omitempty"`
+}
+
+// ReplacedCleanupOptions controls CleanupReplacedInstances
+type ReplacedCleanupOptions struct {
+	// DryRun lists the instances that would be terminated without
+	// terminating anything
+	DryRun bool
+	// DeleteSnapshots also deletes the migration snapshots of the terminated
+	// instances, except those backing an AMI. The instances can't be rolled
+	// back afterwards.
+	DeleteSnapshots bool
+}
+
+// retainOldInstance keeps the stopped old instance instead of terminating it,
+// tagging it with its replacement. The Elastic IP is moved off it like on
+// termination, and it no longer gets migrated.
+func (s *Service) retainOldInstance(ctx context.Context, instance types.Instance, newInstanceID string) (*types.Address, error) {
+	address, err := s.detachElasticIP(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Retaining old instance", "instanceID", aws.ToString(instance.InstanceId), "newInstanceID", newInstanceID)
+	if _, err := s.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{aws.ToString(instance.InstanceId)},
+		Tags: []types.Tag{
+			{
+				Key:   aws.String(replacedByTagKey),
+				Value: aws.String(newInstanceID),
+			},
+			{
+				Key:   aws.String(retainedAtTagKey),
+				Value: aws.String(time.Now().UTC().Format(time.RFC3339)),
+			},
+		},
+	}); err != nil {
+		return address, fmt.Errorf("tag retained instance: %w", err)
+	}
+	return address, nil
+}
+
+// CleanupReplacedInstances terminates the old instances kept stopped by
+// MigrateOptions.RetainOldInstance once they have been retained for longer
+// than olderThan. Only stopped instances carrying both retention tags are
+// considered, and only while their replacement is running and names them as
+// its source; anything else is left alone. With opts.DeleteSnapshots their
+// migration snapshots are deleted too.
+func (s *Service) CleanupReplacedInstances(ctx context.Context, olderThan time.Duration, opts ReplacedCleanupOptions) ([]ReplacedInstance, error) {
+	logger.Info("Starting replaced instance cleanup", "olderThan", olderThan, "dryRun", opts.DryRun, "deleteSnapshots", opts.DeleteSnapshots)
+
+	instances, err := s.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []string{replacedByTagKey},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{string(types.InstanceStateNameStopped)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe replaced instances: %w", err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var cleanups []ReplacedInstance
+	for _, instance := range instances {
+		// Never rely on the filter alone to decide what is ours to terminate
+		replacedBy := tagValue(instance.Tags, replacedByTagKey)
+		if replacedBy == "" || instance.State == nil || instance.State.Name != types.InstanceStateNameStopped {
+			continue
+		}
+		retainedAt, err := time.Parse(time.RFC3339, tagValue(instance.Tags, retainedAtTagKey))
+		if err != nil || !retainedAt.Before(cutoff) {
+			continue
+		}
+		instanceID := aws.ToString(instance.InstanceId)
+		cleanup := ReplacedInstance{
+			InstanceID: instanceID,
+			ReplacedBy: replacedBy,
+			RetainedAt: retainedAt,
+		}
+
+		// The old instance may be the only working copy if the replacement went away
+		replacement, err := s.getInstance(ctx, replacedBy)
+		if err != nil && !errors.Is(err, ErrInstanceNotFound) {
+			return cleanups, fmt.Errorf("check replacement of %s: %w", instanceID, err)
+		}
+		switch {
+		case err != nil || replacement.State == nil || replacement.State.Name != types.InstanceStateNameRunning:
+			cleanup.Reason = fmt.Sprintf("replacement %s is not running", replacedBy)
+		case tagValue(replacement.Tags, sourceInstanceTagKey) != instanceID:
+			cleanup.Reason = fmt.Sprintf("replacement %s was not migrated from this instance", replacedBy)
+		case opts.DryRun:
+			cleanup.Reason = "dry run"
+		}
+		if cleanup.Reason != "" {
+			cleanups = append(cleanups, cleanup)
+			continue
+		}
+
+		logger.Info("Terminating replaced instance", "instanceID", instanceID, "replacedBy", replacedBy)
+		if err := s.terminateInstance(ctx, instance); err != nil {
+			return cleanups, fmt.Errorf("terminate replaced instance %s: %w", instanceID, err)
+		}
+		cleanup.Terminated = true
+		if opts.DeleteSnapshots {
+			cleanup.DeletedSnapshots, err = s.deleteMigrationSnapshots(ctx, instanceID)
+			if err != nil {
+				cleanups = append(cleanups, cleanup)
+				return cleanups, fmt.Errorf("delete migration snapshots of %s: %w", instanceID, err)
+			}
+		}
+		cleanups = append(cleanups, cleanup)
+	}
+
+	if len(cleanups) == 0 {
+		logger.Info("No replaced instances to clean up")
+	}
+	return cleanups, nil
+}
+
+// deleteMigrationSnapshots deletes every migration snapshot taken of an
+// instance, except those backing an AMI, and returns the deleted IDs
+func (s *Service) deleteMigrationSnapshots(ctx context.Context, instanceID string) ([]string, error) {
+	result, err := s.client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{
+		OwnerIds: []string{"self"},
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:ami-migrate-instance"),
+				Values: []string{instanceID},
+			},
+			{
+				Name:   aws.String("tag:" + createdByTagKey),
+				Values: []string{createdByTagValue},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe snapshots: %w", err)
+	}
+
+	var snapshots []types.Snapshot
+	for _, snapshot := range result.Snapshots {
+		// Backups share the instance tag but aren't part of the migration
+		if tagValue(snapshot.Tags, "ami-migrate-instance") != instanceID ||
+			tagValue(snapshot.Tags, createdByTagKey) != createdByTagValue ||
+			!hasTagKey(snapshot.Tags, sourceAMITagKey) {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	referenced, err := s.snapshotsReferencedByImages(ctx, snapshots)
+	if err != nil {
+		return nil, fmt.Errorf("find snapshots referenced by AMIs: %w", err)
+	}
+
+	var deleted []string
+	for _, snapshot := range snapshots {
+		snapshotID := aws.ToString(snapshot.SnapshotId)
+		if _, ok := referenced[snapshotID]; ok {
+			continue
+		}
+		logger.Info("Deleting migration snapshot", "instanceID", instanceID, "snapshotID", snapshotID)
+		if _, err := s.client.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{
+			SnapshotId: snapshot.SnapshotId,
+		}); err != nil {
+			return deleted, fmt.Errorf("delete snapshot %s: %w", snapshotID, err)
+		}
+		deleted = append(deleted, snapshotID)
+	}
+	return deleted, nil
+}
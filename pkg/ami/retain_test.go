@@ -0,0 +1,218 @@
+package ami
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstanceRetainOldInstance(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{
+			InstanceId: aws.String("i-123"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+			},
+		}}}},
+	}
+
+	var stages []string
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", MigrateOptions{
+		NewAMI:            "ami-new",
+		RetainOldInstance: true,
+		Progress: func(p InstanceProgress) {
+			stages = append(stages, p.Stage)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, result.Status)
+	assert.Equal(t, "i-456", result.NewInstanceID)
+
+	// The old instance is left stopped and points at its replacement
+	assert.Equal(t, types.InstanceStateNameStopped, mockClient.GetInstanceState("i-123"))
+	assert.Contains(t, stages, ProgressRetained)
+	assert.NotContains(t, stages, ProgressTerminated)
+	var retainTags []types.Tag
+	for _, input := range mockClient.CreateTagsInputs {
+		if input.Resources[0] == "i-123" {
+			retainTags = append(retainTags, input.Tags...)
+		}
+	}
+	assert.Equal(t, "i-456", tagValue(retainTags, replacedByTagKey))
+	_, err = time.Parse(time.RFC3339, tagValue(retainTags, retainedAtTagKey))
+	assert.NoError(t, err)
+}
+
+func TestShouldMigrateInstanceRetained(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	svc := NewService(apitypes.NewMockEC2Client())
+	instance := types.Instance{
+		InstanceId: aws.String("i-123"),
+		ImageId:    aws.String("ami-old"),
+		State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+		Tags: []types.Tag{
+			{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+			{Key: aws.String(replacedByTagKey), Value: aws.String("i-456")},
+		},
+	}
+	migrate, reason := svc.shouldMigrateInstance(instance, "ami-new", false)
+	assert.False(t, migrate)
+	assert.Equal(t, "Retained after migration to i-456", reason)
+
+	// Migrating it by ID gives the same reason
+	assert.EqualError(t, svc.checkMigrationTags(instance, false), "instance i-123 can't be migrated: Retained after migration to i-456")
+}
+
+func TestCleanupReplacedInstances(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	now := time.Now()
+	retained := func(id, replacedBy string, retainedAt time.Time, state types.InstanceStateName) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			State:      &types.InstanceState{Name: state},
+			Tags: []types.Tag{
+				{Key: aws.String(replacedByTagKey), Value: aws.String(replacedBy)},
+				{Key: aws.String(retainedAtTagKey), Value: aws.String(retainedAt.UTC().Format(time.RFC3339))},
+			},
+		}
+	}
+	replacement := func(id, sourceID string) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+			Tags:       []types.Tag{{Key: aws.String(sourceInstanceTagKey), Value: aws.String(sourceID)}},
+		}
+	}
+	tenDaysAgo := now.Add(-10 * 24 * time.Hour)
+	instances := []types.Instance{
+		retained("i-old", "i-new", tenDaysAgo, types.InstanceStateNameStopped),
+		replacement("i-new", "i-old"),
+		retained("i-recent", "i-new", now.Add(-time.Hour), types.InstanceStateNameStopped),
+		retained("i-orphan", "i-gone", tenDaysAgo, types.InstanceStateNameStopped),
+		retained("i-started", "i-new", tenDaysAgo, types.InstanceStateNameRunning),
+		retained("i-mismatch", "i-other", tenDaysAgo, types.InstanceStateNameStopped),
+		replacement("i-other", "i-someone-else"),
+		// Stopped, but not marked as replaced
+		{
+			InstanceId: aws.String("i-unmarked"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags:       []types.Tag{{Key: aws.String(retainedAtTagKey), Value: aws.String(tenDaysAgo.Format(time.RFC3339))}},
+		},
+	}
+	snapshot := func(id, instanceID string, migration bool) types.Snapshot {
+		tags := []types.Tag{
+			{Key: aws.String("ami-migrate-instance"), Value: aws.String(instanceID)},
+			{Key: aws.String(createdByTagKey), Value: aws.String(createdByTagValue)},
+		}
+		if migration {
+			tags = append(tags, types.Tag{Key: aws.String(sourceAMITagKey), Value: aws.String("ami-old")})
+		}
+		return types.Snapshot{SnapshotId: aws.String(id), Tags: tags}
+	}
+	snapshots := []types.Snapshot{
+		snapshot("snap-migration", "i-old", true),
+		snapshot("snap-backup", "i-old", false),
+		snapshot("snap-ami", "i-old", true),
+		snapshot("snap-other", "i-recent", true),
+	}
+
+	tests := []struct {
+		name           string
+		opts           ReplacedCleanupOptions
+		wantReasons    map[string]string
+		wantTerminated []string
+		wantSnapshots  []string
+	}{
+		{
+			name: "terminates replaced instances past the TTL",
+			wantReasons: map[string]string{
+				"i-old":      "",
+				"i-orphan":   "replacement i-gone is not running",
+				"i-mismatch": "replacement i-other was not migrated from this instance",
+			},
+			wantTerminated: []string{"i-old"},
+		},
+		{
+			name: "deletes migration snapshots",
+			opts: ReplacedCleanupOptions{DeleteSnapshots: true},
+			wantReasons: map[string]string{
+				"i-old":      "",
+				"i-orphan":   "replacement i-gone is not running",
+				"i-mismatch": "replacement i-other was not migrated from this instance",
+			},
+			wantTerminated: []string{"i-old"},
+			wantSnapshots:  []string{"snap-migration"},
+		},
+		{
+			name: "dry run",
+			opts: ReplacedCleanupOptions{DryRun: true, DeleteSnapshots: true},
+			wantReasons: map[string]string{
+				"i-old":      "dry run",
+				"i-orphan":   "replacement i-gone is not running",
+				"i-mismatch": "replacement i-other was not migrated from this instance",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: slices.Clone(instances)}},
+			}
+			for _, instance := range instances {
+				mockClient.InstanceStates[aws.ToString(instance.InstanceId)] = instance.State.Name
+			}
+			mockClient.Snapshots = snapshots
+			mockClient.Images = []types.Image{{
+				ImageId: aws.String("ami-backup"),
+				BlockDeviceMappings: []types.BlockDeviceMapping{
+					{Ebs: &types.EbsBlockDevice{SnapshotId: aws.String("snap-ami")}},
+				},
+			}}
+
+			svc := NewService(mockClient)
+			cleanups, err := svc.CleanupReplacedInstances(context.Background(), 7*24*time.Hour, tt.opts)
+			require.NoError(t, err)
+
+			reasons := make(map[string]string)
+			var terminated, deleted []string
+			for _, cleanup := range cleanups {
+				reasons[cleanup.InstanceID] = cleanup.Reason
+				if cleanup.Terminated {
+					terminated = append(terminated, cleanup.InstanceID)
+				}
+				deleted = append(deleted, cleanup.DeletedSnapshots...)
+			}
+			assert.Equal(t, tt.wantReasons, reasons)
+			assert.Equal(t, tt.wantTerminated, terminated)
+			assert.Equal(t, tt.wantSnapshots, deleted)
+			assert.Equal(t, tt.wantSnapshots, mockClient.DeletedSnapshots)
+			for _, id := range []string{"i-recent", "i-orphan", "i-started", "i-mismatch", "i-unmarked"} {
+				assert.NotEqual(t, types.InstanceStateNameTerminated, mockClient.GetInstanceState(id), id)
+			}
+		})
+	}
+}
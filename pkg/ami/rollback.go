@@ -0,0 +1,280 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+const (
+	// sourceInstanceTagKey records which instance a replacement was migrated from
+	sourceInstanceTagKey = "ami-migrate-source-instance"
+	// sourceAMITagKey records the AMI the source instance was running
+	sourceAMITagKey = "ami-migrate-source-ami"
+	// instanceTypeTagKey records the instance type of the source instance
+	instanceTypeTagKey = "ami-migrate-instance-type"
+	// createdByTagKey marks resources created by ec-manager so they can be cleaned up safely
+	createdByTagKey = "created-by"
+	// createdByTagValue is the createdByTagKey value for resources created by ec-manager
+	createdByTagValue = "ec-manager"
+)
+
+// migrationSnapshotTags returns the tags recorded on a snapshot taken during migration
+// so the source instance can be rebuilt from it and the snapshot cleaned up later
+func migrationSnapshotTags(instance types.Instance, mapping types.InstanceBlockDeviceMapping, migratedAt time.Time) []types.Tag {
+	tags := []types.Tag{
+		{
+			Key:   aws.String("ami-migrate-instance"),
+			Value: instance.InstanceId,
+		},
+		{
+			Key:   aws.String("ami-migrate-device"),
+			Value: mapping.DeviceName,
+		},
+		{
+			Key:   aws.String("ami-migrate-timestamp"),
+			Value: aws.String(migratedAt.UTC().Format(time.RFC3339)),
+		},
+		{
+			Key:   aws.String(createdByTagKey),
+			Value: aws.String(createdByTagValue),
+		},
+		{
+			Key:   aws.String(sourceAMITagKey),
+			Value: instance.ImageId,
+		},
+		{
+			Key:   aws.String(instanceTypeTagKey),
+			Value: aws.String(string(instance.InstanceType)),
+		},
+	}
+	if mapping.Ebs != nil {
+		tags = append(tags, types.Tag{
+			Key:   aws.String("ami-migrate-volume"),
+			Value: mapping.Ebs.VolumeId,
+		})
+	}
+	return tags
+}
+
+// RollbackInstance recreates an instance that was migrated to a new AMI. The
+// instance is relaunched from its original AMI with its volumes restored from
+// the snapshots taken during migration. The root volume comes back through a
+// temporary AMI registered from its snapshot, which is deregistered once the
+// instance has launched. A replacement instance that is still around is
+// terminated first; its tags, subnet, security groups, key pair, instance profile and
+// placement are carried over, as the migration copied them from the
+// original. The new instance ID is returned.
+func (s *Service) RollbackInstance(ctx context.Context, instanceID string) (string, error) {
+	logger.Info("Starting instance rollback", "instanceID", instanceID)
+
+	snapshots, err := s.migrationSnapshots(ctx, instanceID)
+	if err != nil {
+		return "", fmt.Errorf("find migration snapshots: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return "", fmt.Errorf("no migration snapshots found for instance: %s", instanceID)
+	}
+
+	originalAMI := tagValue(snapshots[0].Tags, sourceAMITagKey)
+	instanceType := tagValue(snapshots[0].Tags, instanceTypeTagKey)
+	if originalAMI == "" || instanceType == "" {
+		return "", fmt.Errorf("snapshot %s is missing migration metadata", aws.ToString(snapshots[0].SnapshotId))
+	}
+
+	imageResult, err := s.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []string{originalAMI},
+	})
+	if err != nil {
+		return "", fmt.Errorf("describe images: %w", err)
+	}
+	if len(imageResult.Images) == 0 {
+		return "", fmt.Errorf("original AMI not found: %s", originalAMI)
+	}
+	image := imageResult.Images[0]
+	rootDevice := aws.ToString(image.RootDeviceName)
+
+	var mappings []types.BlockDeviceMapping
+	var rootSnapshot *types.Snapshot
+	for i, snapshot := range snapshots {
+		device := tagValue(snapshot.Tags, "ami-migrate-device")
+		if device == "" {
+			continue
+		}
+		if device == rootDevice {
+			rootSnapshot = &snapshots[i]
+			continue
+		}
+		mappings = append(mappings, types.BlockDeviceMapping{
+			DeviceName: aws.String(device),
+			Ebs: &types.EbsBlockDevice{
+				SnapshotId:          snapshot.SnapshotId,
+				DeleteOnTermination: aws.Bool(false),
+			},
+		})
+	}
+
+	// Registered before the replacement goes so a failure leaves it running
+	launchImageID := originalAMI
+	if rootSnapshot != nil {
+		launchImageID, err = s.registerRestoreImage(ctx, &image, *rootSnapshot, instanceID)
+		if err != nil {
+			return "", err
+		}
+		// The instance keeps running once launched, so the AMI is only needed until then
+		defer s.deregisterRestoreImage(ctx, launchImageID)
+	} else {
+		logger.Warn("No root volume snapshot found, launching with the original AMI's root volume",
+			"instanceID", instanceID, "rootDevice", rootDevice)
+	}
+
+	// Terminate any replacement that is still around so the two don't run side by side
+	replacements, err := s.replacementInstances(ctx, instanceID)
+	if err != nil {
+		return "", fmt.Errorf("find replacement instances: %w", err)
+	}
+	var restoredTags []types.Tag
+	// The replacement the original's launch settings are taken from
+	var launchedFrom *types.Instance
+	for i, replacement := range replacements {
+		if launchedFrom == nil {
+			launchedFrom = &replacements[i]
+		}
+		if restoredTags == nil {
+			restoredTags = rollbackTags(replacement.Tags)
+		}
+
+		logger.Info("Terminating replacement instance", "instanceID", aws.ToString(replacement.InstanceId))
+		if _, err := s.client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+			InstanceIds: []string{aws.ToString(replacement.InstanceId)},
+		}); err != nil {
+			return "", fmt.Errorf("terminate replacement instance %s: %w", aws.ToString(replacement.InstanceId), err)
+		}
+	}
+
+	runInput := &ec2.RunInstancesInput{
+		ImageId:             aws.String(launchImageID),
+		InstanceType:        types.InstanceType(instanceType),
+		MinCount:            aws.Int32(1),
+		MaxCount:            aws.Int32(1),
+		BlockDeviceMappings: mappings,
+	}
+	if launchedFrom != nil {
+		applyNetworking(runInput, *launchedFrom)
+		runInput.Placement = placementFor(*launchedFrom)
+		runInput.KeyName = launchedFrom.KeyName
+	} else {
+		logger.Warn("No replacement instance left to take the network settings from, launching with the account defaults",
+			"instanceID", instanceID)
+	}
+
+	runResult, err := s.client.RunInstances(ctx, runInput)
+	if err != nil {
+		return "", fmt.Errorf("run instances: %w", err)
+	}
+	if len(runResult.Instances) == 0 {
+		return "", fmt.Errorf("no instance created")
+	}
+	newInstance := runResult.Instances[0]
+
+	if len(restoredTags) > 0 {
+		if err := s.copyTags(ctx, types.Instance{Tags: restoredTags}, newInstance, tagCopyRules{}); err != nil {
+			return "", fmt.Errorf("copy tags: %w", err)
+		}
+	}
+
+	if err := s.tagInstanceStatus(ctx, newInstance, StatusRolledBack, fmt.Sprintf("Rolled back %s to AMI: %s", instanceID, originalAMI)); err != nil {
+		return "", fmt.Errorf("tag instance status: %w", err)
+	}
+
+	logger.Info("Instance rollback completed", "instanceID", instanceID, "newInstanceID", aws.ToString(newInstance.InstanceId))
+	return aws.ToString(newInstance.InstanceId), nil
+}
+
+// migrationSnapshots returns the newest migration snapshot per device for an instance
+func (s *Service) migrationSnapshots(ctx context.Context, instanceID string) ([]types.Snapshot, error) {
+	return s.instanceSnapshots(ctx, instanceID, types.Filter{
+		Name:   aws.String("tag-key"),
+		Values: []string{sourceAMITagKey},
+	})
+}
+
+// instanceSnapshots returns the newest snapshot per device taken of an
+// instance, by migrations or backups, that also matches filters
+func (s *Service) instanceSnapshots(ctx context.Context, instanceID string, filters ...types.Filter) ([]types.Snapshot, error) {
+	result, err := s.client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{
+		Filters: append([]types.Filter{
+			{
+				Name:   aws.String("tag:ami-migrate-instance"),
+				Values: []string{instanceID},
+			},
+		}, filters...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe snapshots: %w", err)
+	}
+
+	var devices []string
+	newest := make(map[string]types.Snapshot)
+	for _, snapshot := range result.Snapshots {
+		device := tagValue(snapshot.Tags, "ami-migrate-device")
+		current, exists := newest[device]
+		if !exists {
+			devices = append(devices, device)
+		}
+		if !exists || aws.ToTime(snapshot.StartTime).After(aws.ToTime(current.StartTime)) {
+			newest[device] = snapshot
+		}
+	}
+
+	snapshots := make([]types.Snapshot, 0, len(devices))
+	for _, device := range devices {
+		snapshots = append(snapshots, newest[device])
+	}
+	return snapshots, nil
+}
+
+// replacementInstances returns the live instances that were migrated from the given instance
+func (s *Service) replacementInstances(ctx context.Context, instanceID string) ([]types.Instance, error) {
+	described, err := s.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:" + sourceInstanceTagKey),
+				Values: []string{instanceID},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe instances: %w", err)
+	}
+
+	var instances []types.Instance
+	for _, instance := range described {
+		if instance.State != nil &&
+			(instance.State.Name == types.InstanceStateNameTerminated ||
+				instance.State.Name == types.InstanceStateNameShuttingDown) {
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// rollbackTags drops the migration bookkeeping and AWS reserved tags from a replacement's tags
+func rollbackTags(tags []types.Tag) []types.Tag {
+	var restored []types.Tag
+	for _, tag := range tags {
+		key := aws.ToString(tag.Key)
+		if key == sourceInstanceTagKey || key == sourceAMITagKey || strings.HasPrefix(key, "aws:") {
+			continue
+		}
+		restored = append(restored, tag)
+	}
+	return restored
+}
@@ -0,0 +1,283 @@
+package ami
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestRollbackInstance(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	migrationSnapshots := []types.Snapshot{
+		{
+			SnapshotId: aws.String("snap-root"),
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate-instance"), Value: aws.String("i-123")},
+				{Key: aws.String("ami-migrate-device"), Value: aws.String("/dev/xvda")},
+				{Key: aws.String(sourceAMITagKey), Value: aws.String("ami-old")},
+				{Key: aws.String(instanceTypeTagKey), Value: aws.String("t3.small")},
+			},
+		},
+		{
+			SnapshotId: aws.String("snap-data"),
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate-instance"), Value: aws.String("i-123")},
+				{Key: aws.String("ami-migrate-device"), Value: aws.String("/dev/sdf")},
+				{Key: aws.String(sourceAMITagKey), Value: aws.String("ami-old")},
+				{Key: aws.String(instanceTypeTagKey), Value: aws.String("t3.small")},
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		setupMock    func(*apitypes.MockEC2Client)
+		validate     func(*testing.T, *apitypes.MockEC2Client)
+		wantErr      bool
+		errContains  string
+		wantInstance string
+	}{
+		{
+			name: "terminates running replacement and relaunches original",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.Snapshots = migrationSnapshots
+				m.Images = []types.Image{
+					{
+						ImageId:        aws.String("ami-old"),
+						RootDeviceName: aws.String("/dev/xvda"),
+						State:          types.ImageStateAvailable,
+					},
+				}
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{
+						{
+							Instances: []types.Instance{
+								{
+									InstanceId: aws.String("i-456"),
+									ImageId:    aws.String("ami-new"),
+									State: &types.InstanceState{
+										Name: types.InstanceStateNameRunning,
+									},
+									SubnetId:           aws.String("subnet-1"),
+									SecurityGroups:     []types.GroupIdentifier{{GroupId: aws.String("sg-1")}},
+									KeyName:            aws.String("ops"),
+									IamInstanceProfile: &types.IamInstanceProfile{Arn: aws.String("arn:aws:iam::123456789012:instance-profile/web")},
+									Placement:          &types.Placement{AvailabilityZone: aws.String("us-east-1b"), GroupName: aws.String("web-spread")},
+									Tags: []types.Tag{
+										{Key: aws.String("Name"), Value: aws.String("web-1")},
+										{Key: aws.String(sourceInstanceTagKey), Value: aws.String("i-123")},
+									},
+								},
+							},
+						},
+					},
+				}
+				m.RunInstancesOutput = &ec2.RunInstancesOutput{
+					Instances: []types.Instance{
+						{
+							InstanceId: aws.String("i-789"),
+						},
+					},
+				}
+			},
+			validate: func(t *testing.T, m *apitypes.MockEC2Client) {
+				assert.Equal(t, types.InstanceStateNameTerminated, m.InstanceStates["i-456"])
+				// The root volume comes back from its snapshot through a temporary AMI
+				if assert.Len(t, m.RegisterImageInputs, 1) {
+					root := m.RegisterImageInputs[0].BlockDeviceMappings
+					if assert.Len(t, root, 1) {
+						assert.Equal(t, "/dev/xvda", aws.ToString(root[0].DeviceName))
+						assert.Equal(t, "snap-root", aws.ToString(root[0].Ebs.SnapshotId))
+					}
+				}
+				assert.Equal(t, []string{"ami-registered-1"}, m.DeregisteredImages)
+				if assert.Len(t, m.RunInstancesInputs, 1) {
+					input := m.RunInstancesInputs[0]
+					assert.Equal(t, "ami-registered-1", aws.ToString(input.ImageId))
+					assert.Equal(t, types.InstanceType("t3.small"), input.InstanceType)
+					// The original's launch settings come back from its replacement
+					assert.Equal(t, "subnet-1", aws.ToString(input.SubnetId))
+					assert.Equal(t, []string{"sg-1"}, input.SecurityGroupIds)
+					assert.Equal(t, "ops", aws.ToString(input.KeyName))
+					assert.Equal(t, "arn:aws:iam::123456789012:instance-profile/web", aws.ToString(input.IamInstanceProfile.Arn))
+					assert.Equal(t, "us-east-1b", aws.ToString(input.Placement.AvailabilityZone))
+					assert.Equal(t, "web-spread", aws.ToString(input.Placement.GroupName))
+					if assert.Len(t, input.BlockDeviceMappings, 1) {
+						assert.Equal(t, "/dev/sdf", aws.ToString(input.BlockDeviceMappings[0].DeviceName))
+						assert.Equal(t, "snap-data", aws.ToString(input.BlockDeviceMappings[0].Ebs.SnapshotId))
+					}
+				}
+			},
+			wantInstance: "i-789",
+		},
+		{
+			name: "no replacement left",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.Snapshots = migrationSnapshots
+				m.Images = []types.Image{
+					{
+						ImageId:        aws.String("ami-old"),
+						RootDeviceName: aws.String("/dev/xvda"),
+						State:          types.ImageStateAvailable,
+					},
+				}
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{}
+			},
+			validate: func(t *testing.T, m *apitypes.MockEC2Client) {
+				if assert.Len(t, m.RunInstancesInputs, 1) {
+					assert.Nil(t, m.RunInstancesInputs[0].SubnetId)
+					assert.Nil(t, m.RunInstancesInputs[0].Placement)
+				}
+				var statuses []string
+				for _, input := range m.CreateTagsInputs {
+					if status := tagValue(input.Tags, "ami-migrate-status"); status != "" {
+						statuses = append(statuses, status)
+					}
+				}
+				assert.Contains(t, statuses, StatusRolledBack)
+			},
+			wantInstance: "i-456",
+		},
+		{
+			name: "no root volume snapshot",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.Snapshots = migrationSnapshots[1:]
+				m.Images = []types.Image{
+					{
+						ImageId:        aws.String("ami-old"),
+						RootDeviceName: aws.String("/dev/xvda"),
+						State:          types.ImageStateAvailable,
+					},
+				}
+				m.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{}
+			},
+			validate: func(t *testing.T, m *apitypes.MockEC2Client) {
+				assert.Empty(t, m.RegisterImageInputs)
+				if assert.Len(t, m.RunInstancesInputs, 1) {
+					assert.Equal(t, "ami-old", aws.ToString(m.RunInstancesInputs[0].ImageId))
+				}
+			},
+			wantInstance: "i-456",
+		},
+		{
+			name: "no migration snapshots",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.Snapshots = []types.Snapshot{}
+			},
+			wantErr:     true,
+			errContains: "no migration snapshots found for instance: i-123",
+		},
+		{
+			name: "original AMI no longer exists",
+			setupMock: func(m *apitypes.MockEC2Client) {
+				m.Snapshots = migrationSnapshots
+				m.Images = []types.Image{}
+			},
+			wantErr:     true,
+			errContains: "original AMI not found: ami-old",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock client
+			mockClient := apitypes.NewMockEC2Client()
+			if tt.setupMock != nil {
+				tt.setupMock(mockClient)
+			}
+
+			// Create service with mock client
+			svc := NewService(mockClient)
+
+			// Run test
+			newInstanceID, err := svc.RollbackInstance(context.Background(), "i-123")
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errContains != "" {
+					assert.Contains(t, err.Error(), tt.errContains)
+				}
+				assert.Empty(t, mockClient.RunInstancesInputs)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantInstance, newInstanceID)
+			}
+
+			if tt.validate != nil {
+				tt.validate(t, mockClient)
+			}
+		})
+	}
+}
+
+func TestMigrationSnapshotTags(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId:   aws.String("i-123"),
+						ImageId:      aws.String("ami-old"),
+						InstanceType: types.InstanceTypeT3Small,
+						State: &types.InstanceState{
+							Name: types.InstanceStateNameStopped,
+						},
+						Tags: []types.Tag{
+							{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+						},
+						BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+							{
+								DeviceName: aws.String("/dev/xvda"),
+								Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-123")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	if err := client.SetEC2Client(mockClient); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(mockClient)
+	before := time.Now().UTC().Truncate(time.Second)
+	_, err := svc.MigrateInstance(context.Background(), "i-123", "ami-new")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	result, err := mockClient.DescribeSnapshots(context.Background(), &ec2.DescribeSnapshotsInput{
+		SnapshotIds: []string{"snap-123"},
+	})
+	if !assert.NoError(t, err) || !assert.Len(t, result.Snapshots, 1) {
+		return
+	}
+
+	tags := result.Snapshots[0].Tags
+	assert.Equal(t, "i-123", tagValue(tags, "ami-migrate-instance"))
+	assert.Equal(t, "vol-123", tagValue(tags, "ami-migrate-volume"))
+	assert.Equal(t, "/dev/xvda", tagValue(tags, "ami-migrate-device"))
+	assert.Equal(t, "ami-old", tagValue(tags, sourceAMITagKey))
+	assert.Equal(t, "t3.small", tagValue(tags, instanceTypeTagKey))
+	assert.Equal(t, "ec-manager", tagValue(tags, "created-by"))
+
+	migratedAt, err := time.Parse(time.RFC3339, tagValue(tags, "ami-migrate-timestamp"))
+	if assert.NoError(t, err) {
+		assert.False(t, migratedAt.Before(before))
+	}
+}
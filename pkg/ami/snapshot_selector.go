@@ -0,0 +1,83 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// SnapshotVolume is one of an instance's EBS volumes, as passed to a
+// SnapshotSelector
+type SnapshotVolume struct {
+	Instance types.Instance
+	Mapping  types.InstanceBlockDeviceMapping
+	// Root reports whether this is the instance's root volume
+	Root bool
+	// Volume is the described volume, including its tags
+	Volume types.Volume
+}
+
+// SnapshotSelector reports whether a volume is snapshotted before its
+// instance is migrated
+type SnapshotSelector func(volume SnapshotVolume) bool
+
+// RootVolumeOnly is a SnapshotSelector that only snapshots the root volume
+func RootVolumeOnly(volume SnapshotVolume) bool {
+	return volume.Root
+}
+
+// VolumesTagged returns a SnapshotSelector that only snapshots the volumes
+// tagged key=value, e.g. backup=true
+func VolumesTagged(key, value string) SnapshotSelector {
+	return func(volume SnapshotVolume) bool {
+		return hasTag(volume.Volume.Tags, key, value)
+	}
+}
+
+// snapshotMappings returns the EBS volume mappings of instance that selector
+// picks for snapshotting. Without a selector every EBS volume is picked.
+func (s *Service) snapshotMappings(ctx context.Context, instance types.Instance, selector SnapshotSelector) ([]types.InstanceBlockDeviceMapping, error) {
+	var mappings []types.InstanceBlockDeviceMapping
+	var volumeIDs []string
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs != nil {
+			mappings = append(mappings, mapping)
+			volumeIDs = append(volumeIDs, aws.ToString(mapping.Ebs.VolumeId))
+		}
+	}
+	if selector == nil || len(mappings) == 0 {
+		return mappings, nil
+	}
+
+	result, err := s.client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+		VolumeIds: volumeIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe volumes: %w", err)
+	}
+	volumes := make(map[string]types.Volume, len(result.Volumes))
+	for _, volume := range result.Volumes {
+		volumes[aws.ToString(volume.VolumeId)] = volume
+	}
+
+	var selected []types.InstanceBlockDeviceMapping
+	for _, mapping := range mappings {
+		volumeID := aws.ToString(mapping.Ebs.VolumeId)
+		volume, ok := volumes[volumeID]
+		if !ok {
+			return nil, fmt.Errorf("volume not found: %s", volumeID)
+		}
+		if selector(SnapshotVolume{
+			Instance: instance,
+			Mapping:  mapping,
+			Root:     aws.ToString(mapping.DeviceName) == aws.ToString(instance.RootDeviceName),
+			Volume:   volume,
+		}) {
+			selected = append(selected, mapping)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,116 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// multiVolumeInstance has a root volume, a data volume tagged backup=true and
+// an untagged data volume
+func multiVolumeInstance() (types.Instance, []types.Volume) {
+	instance := types.Instance{
+		InstanceId:     aws.String("i-123"),
+		ImageId:        aws.String("ami-old"),
+		RootDeviceName: aws.String("/dev/xvda"),
+		State:          &types.InstanceState{Name: types.InstanceStateNameStopped},
+		BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+			{DeviceName: aws.String("/dev/xvda"), Ebs: &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-root")}},
+			{DeviceName: aws.String("/dev/sdf"), Ebs: &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-data")}},
+			{DeviceName: aws.String("/dev/sdg"), Ebs: &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-scratch")}},
+		},
+	}
+	volumes := []types.Volume{
+		{VolumeId: aws.String("vol-root"), VolumeType: types.VolumeTypeGp3, Size: aws.Int32(8)},
+		{
+			VolumeId:   aws.String("vol-data"),
+			VolumeType: types.VolumeTypeGp3,
+			Size:       aws.Int32(100),
+			Tags:       []types.Tag{{Key: aws.String("backup"), Value: aws.String("true")}},
+		},
+		{VolumeId: aws.String("vol-scratch"), VolumeType: types.VolumeTypeGp2, Size: aws.Int32(50)},
+	}
+	return instance, volumes
+}
+
+func TestSnapshotMappings(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name     string
+		selector SnapshotSelector
+		expected []string
+	}{
+		{
+			name:     "every volume by default",
+			expected: []string{"vol-root", "vol-data", "vol-scratch"},
+		},
+		{
+			name:     "root volume only",
+			selector: RootVolumeOnly,
+			expected: []string{"vol-root"},
+		},
+		{
+			name:     "tagged volumes",
+			selector: VolumesTagged("backup", "true"),
+			expected: []string{"vol-data"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance, volumes := multiVolumeInstance()
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Volumes = volumes
+
+			svc := NewService(mockClient)
+			mappings, err := svc.snapshotMappings(context.Background(), instance, tt.selector)
+			require.NoError(t, err)
+			var volumeIDs []string
+			for _, mapping := range mappings {
+				volumeIDs = append(volumeIDs, aws.ToString(mapping.Ebs.VolumeId))
+			}
+			assert.Equal(t, tt.expected, volumeIDs)
+		})
+	}
+}
+
+func TestMigrateInstancesSnapshotSelector(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance, volumes := multiVolumeInstance()
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.Volumes = volumes
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{instance}}},
+	}
+
+	svc := NewService(mockClient)
+	_, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:           "ami-new",
+		SnapshotSelector: VolumesTagged("backup", "true"),
+	})
+	require.NoError(t, err)
+
+	// Only the tagged volume is snapshotted and recreated on the replacement
+	if assert.Len(t, mockClient.Snapshots, 1) {
+		assert.Equal(t, "vol-data", aws.ToString(mockClient.Snapshots[0].VolumeId))
+	}
+	if assert.Len(t, mockClient.RunInstancesInputs, 1) {
+		mappings := mockClient.RunInstancesInputs[0].BlockDeviceMappings
+		if assert.Len(t, mappings, 1) {
+			assert.Equal(t, "/dev/sdf", aws.ToString(mappings[0].DeviceName))
+			assert.Equal(t, "snap-data", aws.ToString(mappings[0].Ebs.SnapshotId))
+		}
+	}
+}
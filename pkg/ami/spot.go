@@ -0,0 +1,46 @@
+package ami
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// SpotOptions launches the replacements as Spot instances
+type SpotOptions struct {
+	// MaxPrice is the most to pay per instance hour, e.g. "0.05". Empty caps
+	// it at the On-Demand price.
+	MaxPrice string
+	// InterruptionBehavior is what EC2 does to the instance when it reclaims
+	// the capacity. Empty terminates it. Stopping and hibernating need a
+	// persistent Spot request, which is made for them.
+	InterruptionBehavior types.InstanceInterruptionBehavior
+}
+
+// spotMarketOptions returns the market options launching the replacement for
+// instance as a Spot instance, or nil to launch it On-Demand. Instances
+// carrying the critical tag are always replaced On-Demand.
+func (s *Service) spotMarketOptions(instance types.Instance, spot *SpotOptions) *types.InstanceMarketOptionsRequest {
+	if spot == nil {
+		return nil
+	}
+	if hasTag(instance.Tags, s.tags.Critical, "enabled") {
+		logger.Warn("Launching critical instance's replacement On-Demand instead of Spot",
+			"instanceID", aws.ToString(instance.InstanceId), "tag", s.tags.Critical)
+		return nil
+	}
+
+	options := &types.SpotMarketOptions{
+		InstanceInterruptionBehavior: spot.InterruptionBehavior,
+	}
+	if spot.MaxPrice != "" {
+		options.MaxPrice = aws.String(spot.MaxPrice)
+	}
+	if spot.InterruptionBehavior != "" && spot.InterruptionBehavior != types.InstanceInterruptionBehaviorTerminate {
+		options.SpotInstanceType = types.SpotInstanceTypePersistent
+	}
+	return &types.InstanceMarketOptionsRequest{
+		MarketType:  types.MarketTypeSpot,
+		SpotOptions: options,
+	}
+}
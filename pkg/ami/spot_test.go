@@ -0,0 +1,98 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestSpotMarketOptions(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	criticalTag := types.Tag{Key: aws.String("ami-migrate-critical"), Value: aws.String("enabled")}
+	tests := []struct {
+		name     string
+		tags     []types.Tag
+		spot     *SpotOptions
+		expected *types.InstanceMarketOptionsRequest
+	}{
+		{
+			name: "On-Demand without spot options",
+		},
+		{
+			name: "one-time request terminates by default",
+			spot: &SpotOptions{MaxPrice: "0.05"},
+			expected: &types.InstanceMarketOptionsRequest{
+				MarketType:  types.MarketTypeSpot,
+				SpotOptions: &types.SpotMarketOptions{MaxPrice: aws.String("0.05")},
+			},
+		},
+		{
+			name: "stopping needs a persistent request",
+			spot: &SpotOptions{InterruptionBehavior: types.InstanceInterruptionBehaviorStop},
+			expected: &types.InstanceMarketOptionsRequest{
+				MarketType: types.MarketTypeSpot,
+				SpotOptions: &types.SpotMarketOptions{
+					InstanceInterruptionBehavior: types.InstanceInterruptionBehaviorStop,
+					SpotInstanceType:             types.SpotInstanceTypePersistent,
+				},
+			},
+		},
+		{
+			name: "critical instances stay On-Demand",
+			tags: []types.Tag{criticalTag},
+			spot: &SpotOptions{MaxPrice: "0.05"},
+		},
+	}
+
+	svc := NewService(apitypes.NewMockEC2Client())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := types.Instance{InstanceId: aws.String("i-123"), Tags: tt.tags}
+			assert.Equal(t, tt.expected, svc.spotMarketOptions(instance, tt.spot))
+		})
+	}
+}
+
+func TestMigrateInstancesSpot(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+					},
+				},
+			},
+		},
+	}
+
+	svc := NewService(mockClient)
+	_, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI: "ami-new",
+		Spot:   &SpotOptions{MaxPrice: "0.10"},
+	})
+	require.NoError(t, err)
+	if assert.Len(t, mockClient.RunInstancesInputs, 1) {
+		options := mockClient.RunInstancesInputs[0].InstanceMarketOptions
+		if assert.NotNil(t, options) {
+			assert.Equal(t, types.MarketTypeSpot, options.MarketType)
+			assert.Equal(t, "0.10", aws.ToString(options.SpotOptions.MaxPrice))
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// defaultCommandDocument is the SSM document that runs commands when no
+// other document is named
+const defaultCommandDocument = "AWS-RunShellScript"
+
+// WithSSMClient sets the Systems Manager client used to run commands on
+// instances, such as pre-stop hooks and health checks
+func WithSSMClient(client apitypes.SSMClientAPI) ServiceOption {
+	return func(s *Service) {
+		s.ssm = client
+	}
+}
+
+// runCommand runs an SSM document on the instance and waits for it to
+// complete. An empty documentName runs commands with AWS-RunShellScript;
+// otherwise commands, when given, are passed as its commands parameter.
+func (s *Service) runCommand(ctx context.Context, instanceID, documentName string, commands []string, comment string) error {
+	input := &ssm.SendCommandInput{
+		DocumentName: aws.String(defaultCommandDocument),
+		InstanceIds:  []string{instanceID},
+		Comment:      aws.String(comment),
+	}
+	if documentName != "" {
+		input.DocumentName = aws.String(documentName)
+	}
+	if len(commands) > 0 {
+		input.Parameters = map[string][]string{"commands": commands}
+	}
+	output, err := s.ssm.SendCommand(ctx, input)
+	if err != nil {
+		return fmt.Errorf("send command: %w", err)
+	}
+	commandID := aws.ToString(output.Command.CommandId)
+	logger.Info("Running SSM command", "instanceID", instanceID, "document", aws.ToString(input.DocumentName), "commandID", commandID, "comment", comment)
+
+	invocation := &ssm.GetCommandInvocationInput{
+		CommandId:  aws.String(commandID),
+		InstanceId: aws.String(instanceID),
+	}
+	maxWaitTime, err := s.remainingWait(ctx)
+	if err != nil {
+		return fmt.Errorf("wait for command %s: %w", commandID, err)
+	}
+	if err := ssm.NewCommandExecutedWaiter(s.ssm).Wait(ctx, invocation, maxWaitTime); err != nil {
+		// The waiter only says the command failed, so report how it did
+		result, getErr := s.ssm.GetCommandInvocation(ctx, invocation)
+		if getErr == nil && result.Status != ssmtypes.CommandInvocationStatusSuccess &&
+			result.Status != ssmtypes.CommandInvocationStatusPending &&
+			result.Status != ssmtypes.CommandInvocationStatusInProgress {
+			return fmt.Errorf("command %s %s: %s", commandID, strings.ToLower(string(result.Status)),
+				strings.TrimSpace(aws.ToString(result.StandardErrorContent)))
+		}
+		return fmt.Errorf("wait for command %s: %w", commandID, err)
+	}
+	return nil
+}
+
+// ssmAgentOnline reports whether the instance's SSM agent is registered and online
+func (s *Service) ssmAgentOnline(ctx context.Context, instanceID string) (bool, error) {
+	output, err := s.ssm.DescribeInstanceInformation(ctx, &ssm.DescribeInstanceInformationInput{
+		Filters: []ssmtypes.InstanceInformationStringFilter{
+			{Key: aws.String("InstanceIds"), Values: []string{instanceID}},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, info := range output.InstanceInformationList {
+		if aws.ToString(info.InstanceId) == instanceID {
+			return info.PingStatus == ssmtypes.PingStatusOnline, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,76 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// stageSnapshots takes the migration snapshots of the instance for a
+// SnapshotOnly run and tags it snapshotted, leaving it as it is, and returns
+// the snapshots. If that fails the snapshots are cleaned up as for a failed
+// migration and the instance keeps its status.
+func (s *Service) stageSnapshots(ctx context.Context, instance types.Instance, newAMI string, opts MigrateOptions) ([]MigrationSnapshot, error) {
+	ctx, cancel := s.withWaitBudget(ctx, instance)
+	defer cancel()
+
+	// A cutover to an AMI the replacement can't run would fail anyway
+	if err := s.checkInstanceTypeArchitecture(ctx, opts.instanceTypeFor(instance), newAMI); err != nil {
+		return nil, err
+	}
+
+	snapshotIDs, createdSnapshots, err := s.takeMigrationSnapshots(ctx, instance, opts)
+	if err == nil {
+		err = s.tagInstanceStatus(ctx, instance, StatusSnapshotted, s.migrationMessage(instance, "Snapshotted for migration", newAMI, opts))
+		if err != nil {
+			err = fmt.Errorf("tag instance status: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, s.failedMigration(ctx, instance, createdSnapshots, false, opts, err)
+	}
+	logger.Info("Staged migration snapshots", "instanceID", aws.ToString(instance.InstanceId), "snapshotIDs", createdSnapshots)
+	return migrationSnapshotRecords(instance, snapshotIDs), nil
+}
+
+// stagedSnapshots returns the snapshots a SnapshotOnly run took of the
+// instance by volume ID, along with their IDs. Every volume
+// opts.SnapshotSelector picks needs one, so a volume attached since isn't
+// silently left off the replacement.
+func (s *Service) stagedSnapshots(ctx context.Context, instance types.Instance, opts MigrateOptions) (map[string]string, []string, error) {
+	instanceID := aws.ToString(instance.InstanceId)
+	if status := tagValue(instance.Tags, s.tags.Status); status != StatusSnapshotted {
+		return nil, nil, fmt.Errorf("instance %s has no staged snapshots: status is %q", instanceID, status)
+	}
+
+	snapshots, err := s.migrationSnapshots(ctx, instanceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find staged snapshots: %w", err)
+	}
+	byVolume := make(map[string]string, len(snapshots))
+	for _, snapshot := range snapshots {
+		if volumeID := tagValue(snapshot.Tags, "ami-migrate-volume"); volumeID != "" {
+			byVolume[volumeID] = aws.ToString(snapshot.SnapshotId)
+		}
+	}
+
+	mappings, err := s.snapshotMappings(ctx, instance, opts.SnapshotSelector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("select volumes to snapshot: %w", err)
+	}
+	snapshotIDs := make(map[string]string, len(mappings))
+	var staged []string
+	for _, mapping := range mappings {
+		volumeID := aws.ToString(mapping.Ebs.VolumeId)
+		snapshotID, ok := byVolume[volumeID]
+		if !ok {
+			return nil, nil, fmt.Errorf("volume %s of instance %s has no staged snapshot", volumeID, instanceID)
+		}
+		snapshotIDs[volumeID] = snapshotID
+		staged = append(staged, snapshotID)
+	}
+	return snapshotIDs, staged, nil
+}
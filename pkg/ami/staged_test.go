@@ -0,0 +1,204 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// stagedInstance is a running instance with a root and a data volume
+func stagedInstance(tags ...types.Tag) types.Instance {
+	return types.Instance{
+		InstanceId:     aws.String("i-1"),
+		ImageId:        aws.String("ami-old"),
+		RootDeviceName: aws.String("/dev/xvda"),
+		State:          &types.InstanceState{Name: types.InstanceStateNameRunning},
+		BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-root")},
+			},
+			{
+				DeviceName: aws.String("/dev/sdf"),
+				Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-data")},
+			},
+		},
+		Tags: append([]types.Tag{
+			{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+			{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+		}, tags...),
+	}
+}
+
+func newStagedMock(instance types.Instance) *apitypes.MockEC2Client {
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.Volumes = []types.Volume{{VolumeId: aws.String("vol-data"), VolumeType: types.VolumeTypeGp3, Size: aws.Int32(100)}}
+	mockClient.InstanceStates["i-1"] = types.InstanceStateNameRunning
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{instance}}},
+	}
+	return mockClient
+}
+
+func TestMigrateInstancesSnapshotOnly(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := newStagedMock(stagedInstance())
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+		NewAMI:       "ami-new",
+		SnapshotOnly: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Instances, 1)
+	assert.Equal(t, StatusSnapshotted, result.Instances[0].Status)
+	assert.Equal(t, "Snapshotted for migration to AMI: ami-new", result.Instances[0].Message)
+	assert.Equal(t, 1, result.Summary.Snapshotted)
+	assert.Contains(t, result.FormatMigrationResult(), ", 1 snapshotted\n")
+
+	// Both volumes are snapshotted but the instance is left running
+	assert.Len(t, mockClient.Snapshots, 2)
+	assert.Equal(t, []MigrationSnapshot{
+		{DeviceName: "/dev/xvda", VolumeID: "vol-root", SnapshotID: aws.ToString(mockClient.Snapshots[0].SnapshotId)},
+		{DeviceName: "/dev/sdf", VolumeID: "vol-data", SnapshotID: aws.ToString(mockClient.Snapshots[1].SnapshotId)},
+	}, result.Instances[0].Snapshots)
+	assert.Empty(t, mockClient.RunInstancesInputs)
+	assert.Equal(t, types.InstanceStateNameRunning, mockClient.GetInstanceState("i-1"))
+
+	var statuses []string
+	for _, input := range mockClient.CreateTagsInputs {
+		if status := tagValue(input.Tags, "ami-migrate-status"); status != "" {
+			statuses = append(statuses, status)
+		}
+	}
+	assert.Equal(t, []string{StatusSnapshotted}, statuses)
+}
+
+func TestMigrateInstancesCutover(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	snapshotted := types.Tag{Key: aws.String("ami-migrate-status"), Value: aws.String(StatusSnapshotted)}
+	staged := func(volumeID, device string) types.Snapshot {
+		return types.Snapshot{
+			SnapshotId: aws.String("snap-staged-" + volumeID),
+			State:      types.SnapshotStateCompleted,
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate-instance"), Value: aws.String("i-1")},
+				{Key: aws.String("ami-migrate-device"), Value: aws.String(device)},
+				{Key: aws.String("ami-migrate-volume"), Value: aws.String(volumeID)},
+				{Key: aws.String(sourceAMITagKey), Value: aws.String("ami-old")},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		instance  types.Instance
+		snapshots []types.Snapshot
+		wantErr   string
+	}{
+		{
+			name:      "launches from the staged snapshots",
+			instance:  stagedInstance(snapshotted),
+			snapshots: []types.Snapshot{staged("vol-root", "/dev/xvda"), staged("vol-data", "/dev/sdf")},
+		},
+		{
+			name:      "volume without a staged snapshot",
+			instance:  stagedInstance(snapshotted),
+			snapshots: []types.Snapshot{staged("vol-root", "/dev/xvda")},
+			wantErr:   "volume vol-data of instance i-1 has no staged snapshot",
+		},
+		{
+			name:     "instance not snapshotted",
+			instance: stagedInstance(),
+			wantErr:  `instance i-1 has no staged snapshots: status is ""`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := newStagedMock(tt.instance)
+			mockClient.Snapshots = tt.snapshots
+
+			svc := NewService(mockClient)
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+				NewAMI:  "ami-new",
+				Cutover: true,
+			})
+
+			// Only snapshotted instances are selected
+			require.NotEmpty(t, mockClient.DescribeInstancesInputs)
+			assert.Contains(t, mockClient.DescribeInstancesInputs[0].Filters, types.Filter{
+				Name:   aws.String("tag:ami-migrate-status"),
+				Values: []string{StatusSnapshotted},
+			})
+			// No new snapshots are taken and the staged ones are never deleted
+			assert.Len(t, mockClient.Snapshots, len(tt.snapshots))
+			assert.Empty(t, mockClient.DeletedSnapshots)
+			require.Len(t, result.Instances, 1)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Equal(t, StatusFailed, result.Instances[0].Status)
+				assert.Empty(t, mockClient.RunInstancesInputs)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+			assert.Equal(t, []MigrationSnapshot{
+				{DeviceName: "/dev/xvda", VolumeID: "vol-root", SnapshotID: "snap-staged-vol-root"},
+				{DeviceName: "/dev/sdf", VolumeID: "vol-data", SnapshotID: "snap-staged-vol-data"},
+			}, result.Instances[0].Snapshots)
+			require.Len(t, mockClient.RunInstancesInputs, 1)
+			mappings := mockClient.RunInstancesInputs[0].BlockDeviceMappings
+			require.Len(t, mappings, 1)
+			assert.Equal(t, "/dev/sdf", aws.ToString(mappings[0].DeviceName))
+			assert.Equal(t, "snap-staged-vol-data", aws.ToString(mappings[0].Ebs.SnapshotId))
+			assert.Equal(t, types.InstanceStateNameTerminated, mockClient.GetInstanceState("i-1"))
+		})
+	}
+}
+
+func TestPlanInstanceStaged(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name        string
+		opts        MigrateOptions
+		wantActions []PlanAction
+	}{
+		{
+			name:        "snapshot only",
+			opts:        MigrateOptions{NewAMI: "ami-new", SnapshotOnly: true},
+			wantActions: []PlanAction{ActionSnapshot, ActionSnapshot},
+		},
+		{
+			name:        "cutover",
+			opts:        MigrateOptions{NewAMI: "ami-new", Cutover: true},
+			wantActions: []PlanAction{ActionStop, ActionLaunch, ActionTerminate, ActionCopyTags},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := newStagedMock(stagedInstance())
+			svc := NewService(mockClient)
+
+			plan := svc.planInstance(context.Background(), stagedInstance(), tt.opts)
+			assert.True(t, plan.Migrate)
+			assert.Equal(t, tt.wantActions, plan.Actions)
+			assert.Equal(t, []string{"vol-root", "vol-data"}, plan.SnapshotVolumes)
+		})
+	}
+}
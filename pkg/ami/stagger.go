@@ -0,0 +1,31 @@
+package ami
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// staggerDelay returns a random delay between half of stagger and stagger,
+// so instances started together don't stay in lockstep
+func staggerDelay(stagger time.Duration) time.Duration {
+	if stagger <= 0 {
+		return 0
+	}
+	half := stagger / 2
+	return half + time.Duration(rand.Int63n(int64(stagger-half)+1))
+}
+
+// staggerStart waits a jittered StartStagger before the next instance of a
+// batch is started. It returns early once ctx is done; the instance is then
+// cancelled like any other that hasn't started.
+func staggerStart(ctx context.Context, stagger time.Duration) {
+	delay := staggerDelay(stagger)
+	if delay <= 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+	}
+}
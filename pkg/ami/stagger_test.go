@@ -0,0 +1,79 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestStaggerDelay(t *testing.T) {
+	assert.Zero(t, staggerDelay(0))
+	assert.Zero(t, staggerDelay(-time.Second))
+	for i := 0; i < 100; i++ {
+		delay := staggerDelay(time.Second)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		assert.LessOrEqual(t, delay, time.Second)
+	}
+}
+
+func TestMigrateInstancesStartStagger(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	var instances []types.Instance
+	for i := 0; i < 3; i++ {
+		instances = append(instances, types.Instance{
+			InstanceId: aws.String(fmt.Sprintf("i-%d", i)),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+			},
+		})
+	}
+	newMock := func() *apitypes.MockEC2Client {
+		mockClient := apitypes.NewMockEC2Client()
+		mockClient.Images = []types.Image{availableImage("ami-new")}
+		mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{{Instances: instances}},
+		}
+		return mockClient
+	}
+
+	t.Run("spaces out the starts", func(t *testing.T) {
+		svc := NewService(newMock())
+		start := time.Now()
+		result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+			NewAMI:       "ami-new",
+			StartStagger: 40 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Summary.Completed)
+		// Two waits of at least half the stagger each
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("cancelled run doesn't wait", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		svc := NewService(newMock())
+		start := time.Now()
+		result, err := svc.MigrateInstances(ctx, "enabled", MigrateOptions{
+			NewAMI:       "ami-new",
+			StartStagger: time.Hour,
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 3, result.Summary.Cancelled)
+		assert.Less(t, time.Since(start), time.Minute)
+	})
+}
@@ -0,0 +1,68 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// MigrationHandle tracks a migration started by StartMigration
+type MigrationHandle struct {
+	// InstanceIDs are the instances selected for the run, including any that
+	// will turn out to be skipped
+	InstanceIDs []string
+
+	done   chan struct{}
+	result *MigrationResult
+	err    error
+}
+
+// Done is closed once every instance of the run has finished
+func (h *MigrationHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait blocks until the run finishes and returns what MigrateInstances would
+// have returned
+func (h *MigrationHandle) Wait() (*MigrationResult, error) {
+	<-h.done
+	return h.result, h.err
+}
+
+// StartMigration selects the enabled instances like MigrateInstances and
+// starts migrating them in the background, returning as soon as they have
+// been selected. Progress can be followed through the status tags with
+// GetInstanceStatus, or the handle waited on for the full result. Errors
+// after the selection, such as an unusable target AMI, come from Wait.
+//
+// The migrations run under ctx and in this process: cancelling ctx stops the
+// instances not yet started, and the process has to stay up until the handle
+// is done. Instances whose migration is in flight when the process exits may
+// be left stopped, or with their replacement launched but not tagged.
+func (s *Service) StartMigration(ctx context.Context, enabledValue string, opts MigrateOptions) (*MigrationHandle, error) {
+	if opts.DryRun {
+		return nil, fmt.Errorf("a dry run can't be started in the background")
+	}
+	logger.Info("Starting background migration of enabled instances", "enabledValue", enabledValue)
+	start := time.Now()
+
+	instances, err := s.fetchEnabledInstances(ctx, enabledValue, opts)
+	if err != nil {
+		logger.Error("Failed to fetch enabled instances", "error", err)
+		return nil, classifyError(fmt.Errorf("fetch enabled instances: %w", err))
+	}
+
+	handle := &MigrationHandle{done: make(chan struct{})}
+	for _, instance := range instances {
+		handle.InstanceIDs = append(handle.InstanceIDs, aws.ToString(instance.InstanceId))
+	}
+	go func() {
+		defer close(handle.done)
+		handle.result, handle.err = s.migrateSelected(ctx, start, strings.Join(opts.enabledValues(enabledValue), ","), instances, opts)
+	}()
+	return handle, nil
+}
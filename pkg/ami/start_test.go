@@ -0,0 +1,79 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// blockingStopClient holds stop requests until release is closed
+type blockingStopClient struct {
+	*apitypes.MockEC2Client
+	release chan struct{}
+}
+
+func (c *blockingStopClient) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	<-c.release
+	return c.MockEC2Client.StopInstances(ctx, params, optFns...)
+}
+
+func TestStartMigration(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags: []types.Tag{
+							{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+							{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+						},
+					},
+				},
+			},
+		},
+	}
+	mockClient.InstanceStates["i-1"] = types.InstanceStateNameRunning
+	ec2Client := &blockingStopClient{MockEC2Client: mockClient, release: make(chan struct{})}
+
+	svc := NewService(ec2Client)
+	handle, err := svc.StartMigration(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-1"}, handle.InstanceIDs)
+
+	// The migration is still in flight while stopping is held up
+	select {
+	case <-handle.Done():
+		t.Fatal("migration finished before the instance was stopped")
+	default:
+	}
+
+	close(ec2Client.release)
+	result, err := handle.Wait()
+	require.NoError(t, err)
+	require.Len(t, result.Instances, 1)
+	assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+}
+
+func TestStartMigrationRejectsDryRun(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	svc := NewService(apitypes.NewMockEC2Client())
+	_, err := svc.StartMigration(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new", DryRun: true})
+	assert.EqualError(t, err, "a dry run can't be started in the background")
+}
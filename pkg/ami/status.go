@@ -0,0 +1,47 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// InstanceStatus is the migration status of a single instance
+type InstanceStatus struct {
+	ManagedInstance
+	// Enrolled reports whether the instance carries the enabled tag. An
+	// instance that isn't enrolled is never migrated by --enabled runs.
+	Enrolled bool `json:"enrolled"`
+}
+
+// GetInstanceStatus returns the migration status recorded on the instance's
+// status, message and timestamp tags along with its current AMI and state.
+// It returns an error wrapping ErrInstanceNotFound when the instance doesn't
+// exist.
+func (s *Service) GetInstanceStatus(ctx context.Context, instanceID string) (*InstanceStatus, error) {
+	instance, err := s.getInstance(ctx, instanceID)
+	if errors.Is(err, ErrInstanceNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get instance: %w", err)
+	}
+
+	return &InstanceStatus{
+		ManagedInstance: s.managedInstance(instance),
+		Enrolled:        hasTagKey(instance.Tags, s.tags.Enabled),
+	}, nil
+}
+
+// hasTagKey reports whether tags contain key, whatever its value
+func hasTagKey(tags []types.Tag, key string) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			return true
+		}
+	}
+	return false
+}
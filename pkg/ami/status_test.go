@@ -0,0 +1,107 @@
+package ami
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestGetInstanceStatus(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	describe := func(tags ...types.Tag) *ec2.DescribeInstancesOutput {
+		return &ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{
+				{
+					Instances: []types.Instance{
+						{
+							InstanceId: aws.String("i-abc"),
+							ImageId:    aws.String("ami-123"),
+							State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+							Tags:       tags,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		output     *ec2.DescribeInstancesOutput
+		want       *InstanceStatus
+		wantErr    error
+		errMessage string
+	}{
+		{
+			name: "enrolled with status",
+			output: describe(
+				types.Tag{Key: aws.String("Name"), Value: aws.String("web-1")},
+				types.Tag{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				types.Tag{Key: aws.String("ami-migrate-status"), Value: aws.String("failed")},
+				types.Tag{Key: aws.String("ami-migrate-message"), Value: aws.String("Migration failed: boom")},
+				types.Tag{Key: aws.String("ami-migrate-timestamp"), Value: aws.String("2024-05-01T12:00:00Z")},
+			),
+			want: &InstanceStatus{
+				ManagedInstance: ManagedInstance{
+					InstanceID: "i-abc",
+					Name:       "web-1",
+					State:      "running",
+					CurrentAMI: "ami-123",
+					Status:     "failed",
+					Message:    "Migration failed: boom",
+					Timestamp:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+				},
+				Enrolled: true,
+			},
+		},
+		{
+			name:   "enrolled but never migrated",
+			output: describe(types.Tag{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}),
+			want: &InstanceStatus{
+				ManagedInstance: ManagedInstance{InstanceID: "i-abc", State: "running", CurrentAMI: "ami-123"},
+				Enrolled:        true,
+			},
+		},
+		{
+			name:   "not enrolled",
+			output: describe(types.Tag{Key: aws.String("Name"), Value: aws.String("db-1")}),
+			want: &InstanceStatus{
+				ManagedInstance: ManagedInstance{InstanceID: "i-abc", Name: "db-1", State: "running", CurrentAMI: "ami-123"},
+			},
+		},
+		{
+			name:       "instance doesn't exist",
+			output:     &ec2.DescribeInstancesOutput{},
+			wantErr:    ErrInstanceNotFound,
+			errMessage: "instance not found: i-abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = tt.output
+
+			svc := NewService(mockClient)
+			status, err := svc.GetInstanceStatus(context.Background(), "i-abc")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.EqualError(t, err, tt.errMessage)
+				assert.Nil(t, status)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, status)
+		})
+	}
+}
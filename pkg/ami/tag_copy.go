@@ -0,0 +1,59 @@
+package ami
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// tagCopyRules decides which of an instance's tags are copied to its
+// replacement, see MigrateOptions.CopyTagKeys and SkipTagKeys
+type tagCopyRules struct {
+	include []string
+	exclude []string
+}
+
+// tagCopyRules returns the rules set by CopyTagKeys and SkipTagKeys
+func (o MigrateOptions) tagCopyRules() tagCopyRules {
+	return tagCopyRules{include: o.CopyTagKeys, exclude: o.SkipTagKeys}
+}
+
+// copiedTags returns the tags to put on a replacement. The status tag and
+// tags in the aws: namespace, which EC2 refuses to set, are always dropped.
+// The other migration tags are always kept so the replacement stays enrolled.
+func (s *Service) copiedTags(tags []types.Tag, rules tagCopyRules) []types.Tag {
+	migrationTags := map[string]bool{
+		s.tags.Enabled:   true,
+		s.tags.IfRunning: true,
+		s.tags.Critical:  true,
+		s.tags.Timeout:   true,
+		s.tags.Window:    true,
+	}
+
+	var copied []types.Tag
+	for _, tag := range tags {
+		key := aws.ToString(tag.Key)
+		switch {
+		case key == s.tags.Status, strings.HasPrefix(key, "aws:"):
+			continue
+		case migrationTags[key]:
+		case len(rules.include) > 0 && !matchesAnyGlob(key, rules.include):
+			continue
+		case matchesAnyGlob(key, rules.exclude):
+			continue
+		}
+		copied = append(copied, tag)
+	}
+	return copied
+}
+
+// matchesAnyGlob reports whether s matches one of patterns, see compileGlob
+func matchesAnyGlob(s string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if compileGlob(pattern).MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
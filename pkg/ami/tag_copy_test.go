@@ -0,0 +1,133 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestCopiedTags(t *testing.T) {
+	tags := []types.Tag{
+		{Key: aws.String("Name"), Value: aws.String("web-1")},
+		{Key: aws.String("team-owner"), Value: aws.String("web")},
+		{Key: aws.String("backup-schedule"), Value: aws.String("daily")},
+		{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("web")},
+		{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+		{Key: aws.String("ami-migrate-status"), Value: aws.String("migrating")},
+	}
+
+	tests := []struct {
+		name  string
+		rules tagCopyRules
+		want  []string
+	}{
+		{
+			name: "drops the status and aws: tags",
+			want: []string{"Name", "team-owner", "backup-schedule", "ami-migrate"},
+		},
+		{
+			name:  "include list keeps the migration tags",
+			rules: tagCopyRules{include: []string{"Name", "team-*"}},
+			want:  []string{"Name", "team-owner", "ami-migrate"},
+		},
+		{
+			name:  "exclude list",
+			rules: tagCopyRules{exclude: []string{"backup-*"}},
+			want:  []string{"Name", "team-owner", "ami-migrate"},
+		},
+		{
+			name:  "exclude wins over include",
+			rules: tagCopyRules{include: []string{"*"}, exclude: []string{"Name"}},
+			want:  []string{"team-owner", "backup-schedule", "ami-migrate"},
+		},
+		{
+			name:  "aws: tags can't be included",
+			rules: tagCopyRules{include: []string{"aws:*"}},
+			want:  []string{"ami-migrate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(apitypes.NewMockEC2Client())
+			var keys []string
+			for _, tag := range svc.copiedTags(tags, tt.rules) {
+				keys = append(keys, aws.ToString(tag.Key))
+			}
+			assert.Equal(t, tt.want, keys)
+		})
+	}
+}
+
+func TestMigrateInstanceDropsAWSTags(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{
+			InstanceId: aws.String("i-123"),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+				{Key: aws.String("Name"), Value: aws.String("web-1")},
+				{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("web")},
+				{Key: aws.String("aws:cloudformation:logical-id"), Value: aws.String("WebServer")},
+			},
+		}}}},
+	}
+
+	svc := NewService(mockClient)
+	result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", MigrateOptions{NewAMI: "ami-new"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, result.Status)
+
+	var copied []string
+	for _, input := range mockClient.CreateTagsInputs {
+		if len(input.Resources) == 1 && input.Resources[0] == "i-456" {
+			for _, tag := range input.Tags {
+				copied = append(copied, aws.ToString(tag.Key))
+			}
+		}
+	}
+	assert.Contains(t, copied, "Name")
+	assert.Contains(t, copied, "ami-migrate")
+	for _, key := range copied {
+		assert.NotContains(t, key, "aws:")
+	}
+}
+
+func TestCopyTagsReservedKeys(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	// The mock refuses aws: keys as EC2 does, so the copy only succeeds
+	// without them
+	mockClient := apitypes.NewMockEC2Client()
+	svc := NewService(mockClient)
+	old := types.Instance{
+		InstanceId: aws.String("i-123"),
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String("web-1")},
+			{Key: aws.String("aws:autoscaling:groupName"), Value: aws.String("web-asg")},
+			{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+		},
+	}
+	err := svc.copyTags(context.Background(), old, types.Instance{InstanceId: aws.String("i-456")}, tagCopyRules{})
+	require.NoError(t, err)
+
+	require.Len(t, mockClient.CreateTagsInputs, 1)
+	assert.Equal(t, []types.Tag{
+		{Key: aws.String("Name"), Value: aws.String("web-1")},
+		{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+	}, mockClient.CreateTagsInputs[0].Tags)
+}
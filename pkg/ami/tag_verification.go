@@ -0,0 +1,79 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// DefaultStatusTagVerifyAttempts and DefaultStatusTagVerifyInterval are the
+// re-reads the CLI makes with --verify-status-tags
+const (
+	DefaultStatusTagVerifyAttempts = 5
+	DefaultStatusTagVerifyInterval = time.Second
+)
+
+// WithStatusTagVerification makes the service re-read an instance after
+// writing its status tags, up to attempts times interval apart, until
+// DescribeInstances returns them. EC2 tags are eventually consistent, so a
+// list or status right after a migration can otherwise briefly show the
+// previous status. Each status update then costs DescribeInstances calls;
+// tags still not visible after the last read are logged as a warning.
+func WithStatusTagVerification(attempts int, interval time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.tagVerifyAttempts = attempts
+		s.tagVerifyInterval = interval
+	}
+}
+
+// verifyStatusTags re-reads the instance until all of tags are visible on it
+func (s *Service) verifyStatusTags(ctx context.Context, instanceID string, tags []types.Tag) error {
+	for attempt := 1; ; attempt++ {
+		visible, err := s.tagsVisible(ctx, instanceID, tags)
+		if err != nil {
+			return fmt.Errorf("verify status tags of %s: %w", instanceID, err)
+		}
+		if visible {
+			return nil
+		}
+		if attempt >= s.tagVerifyAttempts {
+			return fmt.Errorf("status tags of %s not visible after %d reads", instanceID, attempt)
+		}
+		logger.Debug("Status tags not visible yet, retrying", "instanceID", instanceID, "attempt", attempt)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("verify status tags of %s: %w", instanceID, ctx.Err())
+		case <-time.After(s.tagVerifyInterval):
+		}
+	}
+}
+
+// tagsVisible reports whether DescribeInstances returns the instance with
+// every one of tags
+func (s *Service) tagsVisible(ctx context.Context, instanceID string, tags []types.Tag) (bool, error) {
+	result, err := s.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return false, classifyError(fmt.Errorf("describe instance: %w", err))
+	}
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			if aws.ToString(instance.InstanceId) != instanceID {
+				continue
+			}
+			for _, tag := range tags {
+				if !hasTag(instance.Tags, aws.ToString(tag.Key), aws.ToString(tag.Value)) {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
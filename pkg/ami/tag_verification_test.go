@@ -0,0 +1,100 @@
+package ami
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// laggingTagsClient only shows the tags written by CreateTags once the
+// instance has been described visibleAfter times since
+type laggingTagsClient struct {
+	*apitypes.MockEC2Client
+	visibleAfter int
+	reads        int
+	tags         []types.Tag
+}
+
+func (c *laggingTagsClient) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	c.tags = params.Tags
+	c.reads = 0
+	return c.MockEC2Client.CreateTags(ctx, params, optFns...)
+}
+
+func (c *laggingTagsClient) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	c.reads++
+	instance := types.Instance{InstanceId: aws.String("i-123")}
+	if c.reads > c.visibleAfter {
+		instance.Tags = c.tags
+	}
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{instance}}},
+	}, nil
+}
+
+func TestTagInstanceStatusVerification(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name         string
+		attempts     int
+		visibleAfter int
+		wantReads    int
+		wantErr      string
+	}{
+		{
+			name:         "not verified by default",
+			visibleAfter: 10,
+		},
+		{
+			name:      "visible at once",
+			attempts:  3,
+			wantReads: 1,
+		},
+		{
+			name:         "visible after retries",
+			attempts:     3,
+			visibleAfter: 2,
+			wantReads:    3,
+		},
+		{
+			name:         "never visible",
+			attempts:     3,
+			visibleAfter: 10,
+			wantReads:    3,
+			wantErr:      "status tags of i-123 not visible after 3 reads",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &laggingTagsClient{MockEC2Client: apitypes.NewMockEC2Client(), visibleAfter: tt.visibleAfter}
+			svc := NewService(client, WithStatusTagVerification(tt.attempts, time.Millisecond))
+
+			// Tags that never show up are only logged
+			err := svc.tagInstanceStatus(context.Background(), types.Instance{InstanceId: aws.String("i-123")}, StatusCompleted, "Migrated to AMI: ami-new")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantReads, client.reads)
+			if tt.attempts == 0 {
+				return
+			}
+
+			client.reads = 0
+			err = svc.verifyStatusTags(context.Background(), "i-123", client.tags)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
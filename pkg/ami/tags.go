@@ -0,0 +1,87 @@
+package ami
+
+// TagScheme names the instance tags that opt instances into migration and
+// record its outcome. Organizations with their own tagging conventions can
+// pass a custom scheme to NewService with WithTagScheme. The tags recorded on
+// migration snapshots are not affected.
+type TagScheme struct {
+	// Enabled opts an instance into migration, e.g. ami-migrate=enabled.
+	// AMIs with it set to latest are the default migration targets.
+	Enabled string
+	// IfRunning also allows a running instance to be migrated
+	IfRunning string
+	// Critical marks an instance whose replacement must never be a Spot
+	// instance, see MigrateOptions.Spot
+	Critical string
+	// Status, Message and Timestamp record the outcome of the last run
+	Status    string
+	Message   string
+	Timestamp string
+	// History keeps a short log of past migrations, see HistoryEntry
+	History string
+	// Timeout overrides the service timeout for the instance's waits, as a
+	// duration such as 45m
+	Timeout string
+	// Window limits the instance's migrations to a daily maintenance window,
+	// e.g. 02:00-04:00 UTC
+	Window string
+}
+
+// DefaultTagScheme returns the ami-migrate tags used unless WithTagScheme is given
+func DefaultTagScheme() TagScheme {
+	return TagSchemeWithPrefix("ami-migrate")
+}
+
+// TagSchemeWithPrefix returns a scheme whose tags all start with prefix:
+// prefix itself, prefix-if-running, prefix-critical, prefix-status,
+// prefix-message, prefix-timestamp, prefix-history, prefix-timeout and
+// prefix-window
+func TagSchemeWithPrefix(prefix string) TagScheme {
+	return TagScheme{
+		Enabled:   prefix,
+		IfRunning: prefix + "-if-running",
+		Critical:  prefix + "-critical",
+		Status:    prefix + "-status",
+		Message:   prefix + "-message",
+		Timestamp: prefix + "-timestamp",
+		History:   prefix + "-history",
+		Timeout:   prefix + "-timeout",
+		Window:    prefix + "-window",
+	}
+}
+
+// WithTagScheme sets the instance tags the service reads and writes. Keys
+// left empty keep their default.
+func WithTagScheme(scheme TagScheme) ServiceOption {
+	return func(s *Service) {
+		defaults := DefaultTagScheme()
+		if scheme.Enabled == "" {
+			scheme.Enabled = defaults.Enabled
+		}
+		if scheme.IfRunning == "" {
+			scheme.IfRunning = defaults.IfRunning
+		}
+		if scheme.Critical == "" {
+			scheme.Critical = defaults.Critical
+		}
+		if scheme.Status == "" {
+			scheme.Status = defaults.Status
+		}
+		if scheme.Message == "" {
+			scheme.Message = defaults.Message
+		}
+		if scheme.Timestamp == "" {
+			scheme.Timestamp = defaults.Timestamp
+		}
+		if scheme.History == "" {
+			scheme.History = defaults.History
+		}
+		if scheme.Timeout == "" {
+			scheme.Timeout = defaults.Timeout
+		}
+		if scheme.Window == "" {
+			scheme.Window = defaults.Window
+		}
+		s.tags = scheme
+	}
+}
@@ -0,0 +1,110 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestWithTagScheme(t *testing.T) {
+	svc := NewService(apitypes.NewMockEC2Client())
+	assert.Equal(t, TagScheme{
+		Enabled:   "ami-migrate",
+		IfRunning: "ami-migrate-if-running",
+		Critical:  "ami-migrate-critical",
+		Status:    "ami-migrate-status",
+		Message:   "ami-migrate-message",
+		Timestamp: "ami-migrate-timestamp",
+		History:   "ami-migrate-history",
+		Timeout:   "ami-migrate-timeout",
+		Window:    "ami-migrate-window",
+	}, svc.tags)
+
+	// Keys left empty keep their default
+	svc = NewService(apitypes.NewMockEC2Client(), WithTagScheme(TagScheme{Enabled: "patching", Status: "patching-state"}))
+	assert.Equal(t, TagScheme{
+		Enabled:   "patching",
+		IfRunning: "ami-migrate-if-running",
+		Critical:  "ami-migrate-critical",
+		Status:    "patching-state",
+		Message:   "ami-migrate-message",
+		Timestamp: "ami-migrate-timestamp",
+		History:   "ami-migrate-history",
+		Timeout:   "ami-migrate-timeout",
+		Window:    "ami-migrate-window",
+	}, svc.tags)
+}
+
+func TestMigrateInstancesCustomTagPrefix(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId: aws.String("i-1"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags: []types.Tag{
+							{Key: aws.String("acme-upgrade"), Value: aws.String("enabled")},
+							{Key: aws.String("acme-upgrade-if-running"), Value: aws.String("enabled")},
+							{Key: aws.String("acme-upgrade-status"), Value: aws.String("failed")},
+						},
+					},
+					{
+						// The default if-running tag doesn't count under a custom scheme
+						InstanceId: aws.String("i-2"),
+						ImageId:    aws.String("ami-old"),
+						State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+						Tags: []types.Tag{
+							{Key: aws.String("acme-upgrade"), Value: aws.String("enabled")},
+							{Key: aws.String("ami-migrate-if-running"), Value: aws.String("enabled")},
+						},
+					},
+				},
+			},
+		},
+	}
+	mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+		Instances: []types.Instance{{InstanceId: aws.String("i-new")}},
+	}
+	client := &tagRecordingClient{MockEC2Client: mockClient, tags: make(map[string][]types.Tag)}
+
+	svc := NewService(client, WithTagScheme(TagSchemeWithPrefix("acme-upgrade")))
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+	assert.NoError(t, err)
+
+	// Instances are selected by the custom enabled tag
+	if assert.NotEmpty(t, mockClient.DescribeInstancesInputs) {
+		filter := mockClient.DescribeInstancesInputs[0].Filters[0]
+		assert.Equal(t, "tag:acme-upgrade", aws.ToString(filter.Name))
+	}
+
+	if assert.NotNil(t, result) && assert.Len(t, result.Instances, 2) {
+		assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+		assert.Equal(t, StatusSkipped, result.Instances[1].Status)
+		assert.Equal(t, "Running instance without acme-upgrade-if-running tag", result.Instances[1].Message)
+	}
+
+	// Status is recorded under the custom keys only
+	assert.Equal(t, StatusSkipped, tagValue(client.tags["i-2"], "acme-upgrade-status"))
+	assert.NotEmpty(t, tagValue(client.tags["i-2"], "acme-upgrade-message"))
+	assert.Empty(t, tagValue(client.tags["i-2"], "ami-migrate-status"))
+
+	// The old instance's status isn't carried over to its replacement
+	newTags := client.tags["i-new"]
+	assert.Equal(t, "enabled", tagValue(newTags, "acme-upgrade"))
+	for _, tag := range newTags {
+		assert.NotEqual(t, "failed", aws.ToString(tag.Value), "status tag %s copied", aws.ToString(tag.Key))
+	}
+}
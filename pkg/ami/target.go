@@ -0,0 +1,55 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// validateTargetAMI returns an error unless amiID exists, is available and
+// matches the architecture of the instances that will be migrated to it.
+// Instances launched as another instance type are checked against that type
+// when they are migrated instead.
+func (s *Service) validateTargetAMI(ctx context.Context, amiID string, instances []types.Instance, opts MigrateOptions) error {
+	image, err := s.getImage(ctx, amiID)
+	if errors.Is(err, ErrAMINotFound) {
+		return &categorizedError{category: ErrInvalidAMI, err: err}
+	}
+	if err != nil {
+		return err
+	}
+	if image.State != types.ImageStateAvailable {
+		return &categorizedError{
+			category: ErrInvalidAMI,
+			err:      fmt.Errorf("AMI %s is %s, not available", amiID, image.State),
+		}
+	}
+	if image.Architecture == "" {
+		return nil
+	}
+
+	var mismatched []string
+	for _, instance := range instances {
+		if migrate, _ := s.shouldMigrateInstance(instance, amiID, opts.Force); !migrate {
+			continue
+		}
+		if excluded, _ := excludedByTag(instance, opts.ExcludeTags); excluded {
+			continue
+		}
+		if opts.instanceTypeFor(instance) != instance.InstanceType {
+			continue
+		}
+		if instance.Architecture != "" && instance.Architecture != image.Architecture {
+			mismatched = append(mismatched, fmt.Sprintf("%s (%s)", aws.ToString(instance.InstanceId), instance.Architecture))
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("AMI %s is %s, which does not match instances: %s",
+			amiID, image.Architecture, strings.Join(mismatched, ", "))
+	}
+	return nil
+}
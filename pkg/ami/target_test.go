@@ -0,0 +1,129 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// availableImage returns an available AMI that migrations can target
+func availableImage(amiID string) types.Image {
+	return types.Image{
+		ImageId: aws.String(amiID),
+		State:   types.ImageStateAvailable,
+	}
+}
+
+func TestMigrateInstancesValidatesTargetAMI(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := func(instanceID string, arch types.ArchitectureValues) types.Instance {
+		return types.Instance{
+			InstanceId:   aws.String(instanceID),
+			ImageId:      aws.String("ami-old"),
+			InstanceType: types.InstanceTypeT3Micro,
+			Architecture: arch,
+			State:        &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags: []types.Tag{
+				{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+			},
+			BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+				{
+					DeviceName: aws.String("/dev/xvda"),
+					Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-" + instanceID)},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		image        *types.Image
+		instances    []types.Instance
+		opts         MigrateOptions
+		wantErr      string
+		wantNotFound bool
+	}{
+		{
+			name:      "available with matching architecture",
+			image:     &types.Image{ImageId: aws.String("ami-new"), State: types.ImageStateAvailable, Architecture: types.ArchitectureValuesX8664},
+			instances: []types.Instance{instance("i-1", types.ArchitectureValuesX8664)},
+		},
+		{
+			name:         "missing",
+			instances:    []types.Instance{instance("i-1", types.ArchitectureValuesX8664)},
+			wantErr:      "validate target AMI: no AMI found: ami-new",
+			wantNotFound: true,
+		},
+		{
+			name:      "still pending",
+			image:     &types.Image{ImageId: aws.String("ami-new"), State: types.ImageStatePending},
+			instances: []types.Instance{instance("i-1", types.ArchitectureValuesX8664)},
+			wantErr:   "validate target AMI: AMI ami-new is pending, not available",
+		},
+		{
+			name:  "architecture mismatch",
+			image: &types.Image{ImageId: aws.String("ami-new"), State: types.ImageStateAvailable, Architecture: types.ArchitectureValuesArm64},
+			instances: []types.Instance{
+				instance("i-1", types.ArchitectureValuesX8664),
+				instance("i-2", types.ArchitectureValuesArm64),
+				instance("i-3", types.ArchitectureValuesX8664),
+			},
+			wantErr: "validate target AMI: AMI ami-new is arm64, which does not match instances: i-1 (x86_64), i-3 (x86_64)",
+		},
+		{
+			name:      "instance type override checked per instance",
+			image:     &types.Image{ImageId: aws.String("ami-new"), State: types.ImageStateAvailable, Architecture: types.ArchitectureValuesArm64},
+			instances: []types.Instance{instance("i-1", types.ArchitectureValuesX8664)},
+			opts:      MigrateOptions{InstanceType: types.InstanceTypeM7gLarge},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: tt.instances}},
+			}
+			if tt.image != nil {
+				mockClient.Images = []types.Image{*tt.image}
+			}
+			mockClient.InstanceTypes = []types.InstanceTypeInfo{
+				{
+					InstanceType:  types.InstanceTypeT3Micro,
+					ProcessorInfo: &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{types.ArchitectureTypeX8664}},
+				},
+				{
+					InstanceType:  types.InstanceTypeM7gLarge,
+					ProcessorInfo: &types.ProcessorInfo{SupportedArchitectures: []types.ArchitectureType{types.ArchitectureTypeArm64}},
+				},
+			}
+
+			svc := NewService(mockClient)
+			opts := tt.opts
+			opts.NewAMI = "ami-new"
+			result, err := svc.MigrateInstances(context.Background(), "enabled", opts)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				if assert.NotNil(t, result) {
+					assert.Equal(t, len(tt.instances), result.Summary.Completed)
+				}
+				return
+			}
+
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantNotFound, errors.Is(err, ErrAMINotFound))
+			// Nothing is touched when the AMI can't be used
+			assert.Empty(t, mockClient.Snapshots)
+			assert.Empty(t, mockClient.RunInstancesInputs)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// ErrTerminationProtected is returned when an instance to be replaced has
+// termination protection enabled and MigrateOptions.OverrideTerminationProtection
+// isn't set
+var ErrTerminationProtected = errors.New("termination protection is enabled")
+
+// terminationProtected reports whether the instance has termination
+// protection (the disableApiTermination attribute) enabled
+func (s *Service) terminationProtected(ctx context.Context, instanceID string) (bool, error) {
+	result, err := s.client.DescribeInstanceAttribute(ctx, &ec2.DescribeInstanceAttributeInput{
+		InstanceId: aws.String(instanceID),
+		Attribute:  types.InstanceAttributeNameDisableApiTermination,
+	})
+	if err != nil {
+		return false, fmt.Errorf("describe termination protection of instance %s: %w", instanceID, err)
+	}
+	return result.DisableApiTermination != nil && aws.ToBool(result.DisableApiTermination.Value), nil
+}
+
+// setTerminationProtection enables or disables termination protection on the instance
+func (s *Service) setTerminationProtection(ctx context.Context, instanceID string, enabled bool) error {
+	_, err := s.client.ModifyInstanceAttribute(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId:            aws.String(instanceID),
+		DisableApiTermination: &types.AttributeBooleanValue{Value: aws.Bool(enabled)},
+	})
+	if err != nil {
+		return fmt.Errorf("set termination protection of instance %s to %t: %w", instanceID, enabled, err)
+	}
+	return nil
+}
+
+// checkTerminationProtection fails with ErrTerminationProtected when the
+// migration would have to terminate a protected instance, so it is found
+// out before the instance is stopped rather than once the replacement is up
+func (s *Service) checkTerminationProtection(ctx context.Context, instance types.Instance, opts MigrateOptions) error {
+	if opts.RetainOldInstance || opts.OverrideTerminationProtection {
+		return nil
+	}
+	instanceID := aws.ToString(instance.InstanceId)
+	protected, err := s.terminationProtected(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	if protected {
+		return fmt.Errorf("instance %s: %w, disable it or override it to migrate the instance", instanceID, ErrTerminationProtected)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstanceTerminationProtection(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name           string
+		protected      bool
+		opts           MigrateOptions
+		wantErr        bool
+		wantModified   []string
+		wantProtection map[string]bool
+	}{
+		{
+			name:           "protected instance fails before it is touched",
+			protected:      true,
+			wantErr:        true,
+			wantProtection: map[string]bool{"i-123": true},
+		},
+		{
+			name:           "override moves the protection to the replacement",
+			protected:      true,
+			opts:           MigrateOptions{OverrideTerminationProtection: true},
+			wantModified:   []string{"i-123 false", "i-456 true"},
+			wantProtection: map[string]bool{"i-123": false, "i-456": true},
+		},
+		{
+			name:           "retained instance isn't terminated",
+			protected:      true,
+			opts:           MigrateOptions{RetainOldInstance: true},
+			wantProtection: map[string]bool{"i-123": true},
+		},
+		{
+			name: "unprotected instance is left alone",
+			opts: MigrateOptions{OverrideTerminationProtection: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			if tt.protected {
+				mockClient.TerminationProtection = map[string]bool{"i-123": true}
+			}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{{
+					InstanceId: aws.String("i-123"),
+					ImageId:    aws.String("ami-old"),
+					State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+					Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+				}}}},
+			}
+
+			opts := tt.opts
+			opts.NewAMI = "ami-new"
+			svc := NewService(mockClient)
+			result, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", opts)
+
+			var modified []string
+			for _, input := range mockClient.ModifyInstanceAttributeInputs {
+				modified = append(modified, fmt.Sprintf("%s %t", aws.ToString(input.InstanceId), aws.ToBool(input.DisableApiTermination.Value)))
+			}
+			assert.Equal(t, tt.wantModified, modified)
+			assert.Equal(t, tt.wantProtection, mockClient.TerminationProtection)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrTerminationProtected)
+				assert.Equal(t, StatusFailed, result.Status)
+				assert.Empty(t, mockClient.Snapshots)
+				assert.Empty(t, mockClient.RunInstancesInputs)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, StatusCompleted, result.Status)
+		})
+	}
+}
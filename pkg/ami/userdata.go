@@ -0,0 +1,37 @@
+package ami
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// userDataFor returns the base64-encoded user data to launch the
+// instance's replacement with: opts.UserData when set, otherwise the
+// instance's own. It returns nil when a launch template provides the user
+// data or the instance has none.
+func (s *Service) userDataFor(ctx context.Context, instance types.Instance, opts MigrateOptions) (*string, error) {
+	if opts.UserData != "" {
+		return aws.String(base64.StdEncoding.EncodeToString([]byte(opts.UserData))), nil
+	}
+	if opts.LaunchTemplate != nil {
+		return nil, nil
+	}
+
+	result, err := s.client.DescribeInstanceAttribute(ctx, &ec2.DescribeInstanceAttributeInput{
+		InstanceId: instance.InstanceId,
+		Attribute:  types.InstanceAttributeNameUserData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe user data of instance %s: %w", aws.ToString(instance.InstanceId), err)
+	}
+	// EC2 returns the user data base64-encoded, as RunInstances takes it
+	if result.UserData == nil || aws.ToString(result.UserData.Value) == "" {
+		return nil, nil
+	}
+	return result.UserData.Value, nil
+}
@@ -0,0 +1,103 @@
+package ami
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// userDataErrorClient fails to describe the userData attribute
+type userDataErrorClient struct {
+	*apitypes.MockEC2Client
+	err error
+}
+
+func (c *userDataErrorClient) DescribeInstanceAttribute(ctx context.Context, params *ec2.DescribeInstanceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceAttributeOutput, error) {
+	if params.Attribute == types.InstanceAttributeNameUserData {
+		return nil, c.err
+	}
+	return c.MockEC2Client.DescribeInstanceAttribute(ctx, params, optFns...)
+}
+
+func TestMigrateInstanceUserData(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	original := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho original\n"))
+
+	tests := []struct {
+		name         string
+		userData     map[string]string
+		describeErr  error
+		opts         MigrateOptions
+		wantUserData *string
+		wantErr      string
+	}{
+		{
+			name:         "copies the original user data",
+			userData:     map[string]string{"i-123": original},
+			wantUserData: aws.String(original),
+		},
+		{
+			name: "no user data",
+		},
+		{
+			name:         "replaces the user data",
+			userData:     map[string]string{"i-123": original},
+			opts:         MigrateOptions{UserData: "#cloud-config\npackages: [nginx]\n"},
+			wantUserData: aws.String(base64.StdEncoding.EncodeToString([]byte("#cloud-config\npackages: [nginx]\n"))),
+		},
+		{
+			name:     "launch template provides the user data",
+			userData: map[string]string{"i-123": original},
+			opts:     MigrateOptions{LaunchTemplate: &LaunchTemplate{Name: "web"}},
+		},
+		{
+			name:        "user data can't be read",
+			describeErr: errors.New("access denied"),
+			wantErr:     "read user data: describe user data of instance i-123: access denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.UserData = tt.userData
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{{
+					InstanceId: aws.String("i-123"),
+					ImageId:    aws.String("ami-old"),
+					State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+					Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+				}}}},
+			}
+
+			opts := tt.opts
+			opts.NewAMI = "ami-new"
+			var client apitypes.EC2ClientAPI = mockClient
+			if tt.describeErr != nil {
+				client = &userDataErrorClient{MockEC2Client: mockClient, err: tt.describeErr}
+			}
+			svc := NewService(client)
+			_, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", opts)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, mockClient.RunInstancesInputs)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, mockClient.RunInstancesInputs, 1)
+			assert.Equal(t, tt.wantUserData, mockClient.RunInstancesInputs[0].UserData)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// InstanceVerification compares the AMI an enrolled instance runs with the
+// AMI it was expected to be migrated to
+type InstanceVerification struct {
+	InstanceID  string `json:"instance_id"`
+	State       string `json:"state"`
+	CurrentAMI  string `json:"current_ami"`
+	ExpectedAMI string `json:"expected_ami"`
+	UpToDate    bool   `json:"up_to_date"`
+	// NameSuffixStripped is set when VerifyOptions.StripNameSuffix took the
+	// migration suffix off the instance's Name tag
+	NameSuffixStripped bool `json:"name_suffix_stripped,omitempty"`
+}
+
+// VerificationReport lists every enrolled instance and whether it runs the
+// expected AMI
+type VerificationReport struct {
+	EnabledValue string                 `json:"enabled_value"`
+	ExpectedAMI  string                 `json:"expected_ami"`
+	Instances    []InstanceVerification `json:"instances"`
+	Lagging      int                    `json:"lagging"`
+}
+
+// VerifyOptions controls VerifyMigrationWithOptions
+type VerifyOptions struct {
+	// StripNameSuffix takes the suffix added by MigrateOptions.NameSuffix
+	// off the Name tag of every instance found running newAMI
+	StripNameSuffix bool
+}
+
+// VerifyMigration reports which instances tagged ami-migrate=enabledValue are
+// not running newAMI. Terminated instances, such as those replaced by a
+// migration, are left out.
+func (s *Service) VerifyMigration(ctx context.Context, newAMI, enabledValue string) (*VerificationReport, error) {
+	return s.VerifyMigrationWithOptions(ctx, newAMI, enabledValue, VerifyOptions{})
+}
+
+// VerifyMigrationWithOptions verifies the instances like VerifyMigration,
+// applying opts to those found running newAMI
+func (s *Service) VerifyMigrationWithOptions(ctx context.Context, newAMI, enabledValue string, opts VerifyOptions) (*VerificationReport, error) {
+	logger.Info("Verifying migration", "newAMI", newAMI, "enabledValue", enabledValue, "stripNameSuffix", opts.StripNameSuffix)
+
+	instances, err := s.describeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:" + s.tags.Enabled),
+				Values: []string{enabledValue},
+			},
+			{
+				Name: aws.String("instance-state-name"),
+				Values: []string{
+					string(types.InstanceStateNamePending),
+					string(types.InstanceStateNameRunning),
+					string(types.InstanceStateNameStopping),
+					string(types.InstanceStateNameStopped),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe instances: %w", err)
+	}
+
+	report := &VerificationReport{
+		EnabledValue: enabledValue,
+		ExpectedAMI:  newAMI,
+		Instances:    []InstanceVerification{},
+	}
+	for _, instance := range instances {
+		// Never rely on the filters alone
+		if !hasTag(instance.Tags, s.tags.Enabled, enabledValue) || !verifiableState(instance.State) {
+			continue
+		}
+
+		verification := InstanceVerification{
+			InstanceID:  aws.ToString(instance.InstanceId),
+			State:       string(instance.State.Name),
+			CurrentAMI:  aws.ToString(instance.ImageId),
+			ExpectedAMI: newAMI,
+		}
+		verification.UpToDate = verification.CurrentAMI == newAMI
+		if !verification.UpToDate {
+			report.Lagging++
+		} else if opts.StripNameSuffix {
+			if verification.NameSuffixStripped, err = s.StripNameSuffix(ctx, instance); err != nil {
+				return nil, err
+			}
+		}
+		report.Instances = append(report.Instances, verification)
+	}
+
+	sort.Slice(report.Instances, func(i, j int) bool {
+		return report.Instances[i].InstanceID < report.Instances[j].InstanceID
+	})
+	return report, nil
+}
+
+// verifiableState reports whether an instance in this state still counts as
+// part of the fleet
+func verifiableState(state *types.InstanceState) bool {
+	if state == nil {
+		return false
+	}
+	switch state.Name {
+	case types.InstanceStateNameShuttingDown, types.InstanceStateNameTerminated:
+		return false
+	}
+	return true
+}
+
+// FormatVerificationReport formats the report as a table followed by a summary line
+func (r *VerificationReport) FormatVerificationReport() string {
+	var b strings.Builder
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tSTATE\tCURRENT AMI\tEXPECTED AMI\tUP TO DATE")
+	for _, instance := range r.Instances {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\n",
+			instance.InstanceID,
+			instance.State,
+			instance.CurrentAMI,
+			instance.ExpectedAMI,
+			instance.UpToDate)
+	}
+	w.Flush()
+
+	b.WriteString(fmt.Sprintf("\n%d instances: %d up to date, %d lagging\n",
+		len(r.Instances), len(r.Instances)-r.Lagging, r.Lagging))
+
+	stripped := 0
+	for _, instance := range r.Instances {
+		if instance.NameSuffixStripped {
+			stripped++
+		}
+	}
+	if stripped > 0 {
+		b.WriteString(fmt.Sprintf("Stripped the name suffix from %d instances\n", stripped))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestVerifyMigration(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	enabledTag := []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}}
+	instance := func(id, imageID string, state types.InstanceStateName, tags []types.Tag) types.Instance {
+		return types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String(imageID),
+			State:      &types.InstanceState{Name: state},
+			Tags:       tags,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		instances   []types.Instance
+		describeErr error
+		want        []InstanceVerification
+		wantLagging int
+		wantErr     bool
+	}{
+		{
+			name: "fleet up to date",
+			instances: []types.Instance{
+				instance("i-2", "ami-new", types.InstanceStateNameStopped, enabledTag),
+				instance("i-1", "ami-new", types.InstanceStateNameRunning, enabledTag),
+			},
+			want: []InstanceVerification{
+				{InstanceID: "i-1", State: "running", CurrentAMI: "ami-new", ExpectedAMI: "ami-new", UpToDate: true},
+				{InstanceID: "i-2", State: "stopped", CurrentAMI: "ami-new", ExpectedAMI: "ami-new", UpToDate: true},
+			},
+		},
+		{
+			name: "lagging instance",
+			instances: []types.Instance{
+				instance("i-1", "ami-new", types.InstanceStateNameRunning, enabledTag),
+				instance("i-2", "ami-old", types.InstanceStateNameRunning, enabledTag),
+			},
+			want: []InstanceVerification{
+				{InstanceID: "i-1", State: "running", CurrentAMI: "ami-new", ExpectedAMI: "ami-new", UpToDate: true},
+				{InstanceID: "i-2", State: "running", CurrentAMI: "ami-old", ExpectedAMI: "ami-new"},
+			},
+			wantLagging: 1,
+		},
+		{
+			name: "terminated and unenrolled instances are ignored",
+			instances: []types.Instance{
+				instance("i-1", "ami-old", types.InstanceStateNameTerminated, enabledTag),
+				instance("i-2", "ami-old", types.InstanceStateNameRunning, nil),
+				instance("i-3", "ami-new", types.InstanceStateNameRunning, enabledTag),
+			},
+			want: []InstanceVerification{
+				{InstanceID: "i-3", State: "running", CurrentAMI: "ami-new", ExpectedAMI: "ami-new", UpToDate: true},
+			},
+		},
+		{
+			name:        "describe error",
+			describeErr: fmt.Errorf("access denied"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: tt.instances}},
+			}
+			mockClient.DescribeInstancesError = tt.describeErr
+
+			svc := NewService(mockClient)
+			report, err := svc.VerifyMigration(context.Background(), "ami-new", "enabled")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "ami-new", report.ExpectedAMI)
+			assert.Equal(t, tt.want, report.Instances)
+			assert.Equal(t, tt.wantLagging, report.Lagging)
+		})
+	}
+}
+
+func TestFormatVerificationReport(t *testing.T) {
+	report := &VerificationReport{
+		ExpectedAMI: "ami-new",
+		Instances: []InstanceVerification{
+			{InstanceID: "i-1", State: "running", CurrentAMI: "ami-new", ExpectedAMI: "ami-new", UpToDate: true},
+			{InstanceID: "i-2", State: "stopped", CurrentAMI: "ami-old", ExpectedAMI: "ami-new"},
+		},
+		Lagging: 1,
+	}
+
+	lines := strings.Split(strings.TrimSpace(report.FormatVerificationReport()), "\n")
+	if assert.Len(t, lines, 5) {
+		assert.True(t, strings.HasPrefix(lines[0], "INSTANCE"))
+		assert.Contains(t, lines[2], "ami-old")
+		assert.Contains(t, lines[2], "false")
+		assert.Equal(t, "2 instances: 1 up to date, 1 lagging", lines[4])
+	}
+}
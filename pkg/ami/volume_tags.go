@@ -0,0 +1,105 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// rootDeviceKey stands in for the root device name in volume tag lookups. The
+// new AMI may name its root device differently from the old one.
+const rootDeviceKey = "root"
+
+// volumeTags returns the tags on the instance's EBS volumes keyed by device
+// name, with the root volume under rootDeviceKey. They have to be read before
+// the instance is terminated, as its volumes may be deleted along with it.
+// Volumes without tags are left out.
+func (s *Service) volumeTags(ctx context.Context, instance types.Instance) (map[string][]types.Tag, error) {
+	devices := make(map[string]string)
+	var volumeIDs []string
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs == nil || mapping.Ebs.VolumeId == nil {
+			continue
+		}
+		device := aws.ToString(mapping.DeviceName)
+		if device == aws.ToString(instance.RootDeviceName) {
+			device = rootDeviceKey
+		}
+		devices[aws.ToString(mapping.Ebs.VolumeId)] = device
+		volumeIDs = append(volumeIDs, aws.ToString(mapping.Ebs.VolumeId))
+	}
+	if len(volumeIDs) == 0 {
+		return nil, nil
+	}
+
+	result, err := s.client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+		VolumeIds: volumeIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe volumes: %w", err)
+	}
+
+	tags := make(map[string][]types.Tag)
+	for _, volume := range result.Volumes {
+		device, ok := devices[aws.ToString(volume.VolumeId)]
+		if !ok {
+			continue
+		}
+		for _, tag := range volume.Tags {
+			// Tags in the aws: namespace belong to AWS and can't be set
+			if strings.HasPrefix(aws.ToString(tag.Key), "aws:") {
+				continue
+			}
+			tags[device] = append(tags[device], tag)
+		}
+	}
+	return tags, nil
+}
+
+// copyVolumeTags puts the tags returned by volumeTags on the volumes attached
+// to newInstance, matching them up by device name. The root volumes are
+// matched with each other whatever their device names.
+func (s *Service) copyVolumeTags(ctx context.Context, newInstance types.Instance, tags map[string][]types.Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	instanceID := aws.ToString(newInstance.InstanceId)
+	result, err := s.client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("attachment.instance-id"),
+				Values: []string{instanceID},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("describe volumes: %w", err)
+	}
+
+	for _, volume := range result.Volumes {
+		for _, attachment := range volume.Attachments {
+			if aws.ToString(attachment.InstanceId) != instanceID {
+				continue
+			}
+			device := aws.ToString(attachment.Device)
+			if device == aws.ToString(newInstance.RootDeviceName) {
+				device = rootDeviceKey
+			}
+			if len(tags[device]) == 0 {
+				continue
+			}
+			if _, err := s.client.CreateTags(ctx, &ec2.CreateTagsInput{
+				Resources: []string{aws.ToString(volume.VolumeId)},
+				Tags:      tags[device],
+			}); err != nil {
+				return fmt.Errorf("tag volume %s: %w", aws.ToString(volume.VolumeId), err)
+			}
+		}
+	}
+	return nil
+}
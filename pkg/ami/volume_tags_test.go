@@ -0,0 +1,122 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMigrateInstancesCopiesVolumeTags(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	attached := func(instanceID, device string) []types.VolumeAttachment {
+		return []types.VolumeAttachment{{InstanceId: aws.String(instanceID), Device: aws.String(device)}}
+	}
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					{
+						InstanceId:     aws.String("i-1"),
+						ImageId:        aws.String("ami-old"),
+						RootDeviceName: aws.String("/dev/xvda"),
+						State:          &types.InstanceState{Name: types.InstanceStateNameStopped},
+						BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+							{
+								DeviceName: aws.String("/dev/xvda"),
+								Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-root")},
+							},
+							{
+								DeviceName: aws.String("/dev/sdf"),
+								Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-data")},
+							},
+						},
+						Tags: []types.Tag{
+							{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+						},
+					},
+				},
+			},
+		},
+	}
+	mockClient.Volumes = []types.Volume{
+		{
+			VolumeId:    aws.String("vol-root"),
+			Size:        aws.Int32(8),
+			Attachments: attached("i-1", "/dev/xvda"),
+			Tags: []types.Tag{
+				{Key: aws.String("Name"), Value: aws.String("web-root")},
+				{Key: aws.String("aws:backup:source-resource"), Value: aws.String("i-1")},
+			},
+		},
+		{
+			VolumeId:    aws.String("vol-data"),
+			Size:        aws.Int32(100),
+			Attachments: attached("i-1", "/dev/sdf"),
+			Tags: []types.Tag{
+				{Key: aws.String("Name"), Value: aws.String("web-data")},
+				{Key: aws.String("CostCenter"), Value: aws.String("42")},
+			},
+		},
+		{
+			VolumeId:    aws.String("vol-new-root"),
+			Attachments: attached("i-2", "/dev/sda1"),
+		},
+		{
+			VolumeId:    aws.String("vol-new-data"),
+			Attachments: attached("i-2", "/dev/sdf"),
+		},
+	}
+	mockClient.RunInstancesOutput = &ec2.RunInstancesOutput{
+		Instances: []types.Instance{
+			{
+				InstanceId:     aws.String("i-2"),
+				RootDeviceName: aws.String("/dev/sda1"),
+			},
+		},
+	}
+
+	svc := NewService(mockClient)
+	_, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new"})
+	require.NoError(t, err)
+
+	tagged := make(map[string][]types.Tag)
+	for _, input := range mockClient.CreateTagsInputs {
+		for _, resource := range input.Resources {
+			tagged[resource] = append(tagged[resource], input.Tags...)
+		}
+	}
+	assert.Equal(t, []types.Tag{
+		{Key: aws.String("Name"), Value: aws.String("web-root")},
+	}, tagged["vol-new-root"])
+	assert.Equal(t, []types.Tag{
+		{Key: aws.String("Name"), Value: aws.String("web-data")},
+		{Key: aws.String("CostCenter"), Value: aws.String("42")},
+	}, tagged["vol-new-data"])
+}
+
+func TestCopyVolumeTagsWithoutVolumes(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.DescribeVolumesError = assert.AnError
+	svc := NewService(mockClient)
+
+	tags, err := svc.volumeTags(context.Background(), types.Instance{InstanceId: aws.String("i-1")})
+	require.NoError(t, err)
+	assert.Nil(t, tags)
+	assert.NoError(t, svc.copyVolumeTags(context.Background(), types.Instance{InstanceId: aws.String("i-2")}, tags))
+	assert.Empty(t, mockClient.CreateTagsInputs)
+}
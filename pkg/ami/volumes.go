@@ -0,0 +1,211 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// dataVolumeMappings builds the block device mappings that recreate the source
+// instance's non-root EBS volumes from the snapshots taken during migration.
+// snapshotIDs maps each source volume ID to its snapshot. Device names, volume
+// type, size, IOPS, throughput and encryption settings are preserved, and the
+// mappings keep the instance's device order. Volumes without a snapshot are
+// left out. The root volume always comes from the new AMI, so nothing is
+// returned when the root device is unknown.
+func (s *Service) dataVolumeMappings(ctx context.Context, instance types.Instance, snapshotIDs map[string]string) ([]types.BlockDeviceMapping, error) {
+	rootDevice := aws.ToString(instance.RootDeviceName)
+	if rootDevice == "" {
+		return nil, nil
+	}
+
+	var dataDevices []types.InstanceBlockDeviceMapping
+	var volumeIDs []string
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs == nil || aws.ToString(mapping.DeviceName) == rootDevice {
+			continue
+		}
+		dataDevices = append(dataDevices, mapping)
+		volumeIDs = append(volumeIDs, aws.ToString(mapping.Ebs.VolumeId))
+	}
+	if len(dataDevices) == 0 {
+		return nil, nil
+	}
+
+	result, err := s.client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+		VolumeIds: volumeIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe volumes: %w", err)
+	}
+	volumes := make(map[string]types.Volume, len(result.Volumes))
+	for _, volume := range result.Volumes {
+		volumes[aws.ToString(volume.VolumeId)] = volume
+	}
+
+	var mappings []types.BlockDeviceMapping
+	var pending []string
+	for _, device := range dataDevices {
+		volumeID := aws.ToString(device.Ebs.VolumeId)
+		volume, ok := volumes[volumeID]
+		if !ok {
+			return nil, fmt.Errorf("volume not found: %s", volumeID)
+		}
+		snapshotID, ok := snapshotIDs[volumeID]
+		if !ok {
+			logger.Warn("Not recreating data volume on replacement, no snapshot was taken",
+				"instanceID", aws.ToString(instance.InstanceId), "volumeID", volumeID)
+			continue
+		}
+
+		ebs := &types.EbsBlockDevice{
+			SnapshotId:          aws.String(snapshotID),
+			VolumeType:          volume.VolumeType,
+			VolumeSize:          volume.Size,
+			DeleteOnTermination: device.Ebs.DeleteOnTermination,
+		}
+		copyProvisionedPerformance(ebs, volume)
+		if aws.ToBool(volume.Encrypted) {
+			ebs.Encrypted = aws.Bool(true)
+			ebs.KmsKeyId = volume.KmsKeyId
+		}
+
+		mappings = append(mappings, types.BlockDeviceMapping{
+			DeviceName: device.DeviceName,
+			Ebs:        ebs,
+		})
+		pending = append(pending, snapshotID)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if err := s.waitForSnapshotsCompleted(ctx, pending); err != nil {
+		return nil, fmt.Errorf("wait for data volume snapshots: %w", err)
+	}
+
+	return mappings, nil
+}
+
+// rootVolumeMapping builds the block device mapping that keeps the source
+// instance's root volume size and type on a replacement launched from
+// newAMI. The root volume still comes from the AMI's snapshot, grown to the
+// source volume's size but never shrunk below the AMI's own. It returns nil
+// when the AMI's defaults already match, or when either root device is
+// unknown.
+func (s *Service) rootVolumeMapping(ctx context.Context, instance types.Instance, newAMI string) (*types.BlockDeviceMapping, error) {
+	rootDevice := aws.ToString(instance.RootDeviceName)
+	var root *types.InstanceBlockDeviceMapping
+	for i, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs != nil && rootDevice != "" && aws.ToString(mapping.DeviceName) == rootDevice {
+			root = &instance.BlockDeviceMappings[i]
+			break
+		}
+	}
+	if root == nil {
+		return nil, nil
+	}
+
+	image, err := s.getImage(ctx, newAMI)
+	if err != nil {
+		return nil, err
+	}
+	var imageRoot *types.EbsBlockDevice
+	for _, mapping := range image.BlockDeviceMappings {
+		if mapping.Ebs != nil && aws.ToString(mapping.DeviceName) == aws.ToString(image.RootDeviceName) {
+			imageRoot = mapping.Ebs
+			break
+		}
+	}
+	if imageRoot == nil {
+		return nil, nil
+	}
+
+	volumeID := aws.ToString(root.Ebs.VolumeId)
+	result, err := s.client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+		VolumeIds: []string{volumeID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe root volume: %w", err)
+	}
+	if len(result.Volumes) == 0 {
+		return nil, fmt.Errorf("volume not found: %s", volumeID)
+	}
+	volume := result.Volumes[0]
+
+	size := aws.ToInt32(volume.Size)
+	if minSize := aws.ToInt32(imageRoot.VolumeSize); size < minSize {
+		size = minSize
+	}
+	if size == aws.ToInt32(imageRoot.VolumeSize) && volume.VolumeType == imageRoot.VolumeType {
+		return nil, nil
+	}
+
+	// The snapshot and encryption are left to the AMI
+	ebs := &types.EbsBlockDevice{
+		VolumeSize:          aws.Int32(size),
+		VolumeType:          volume.VolumeType,
+		DeleteOnTermination: root.Ebs.DeleteOnTermination,
+	}
+	copyProvisionedPerformance(ebs, volume)
+	logger.Info("Keeping root volume size and type on replacement",
+		"instanceID", aws.ToString(instance.InstanceId), "size", size, "volumeType", volume.VolumeType,
+		"amiSize", aws.ToInt32(imageRoot.VolumeSize), "amiVolumeType", imageRoot.VolumeType)
+	return &types.BlockDeviceMapping{
+		DeviceName: image.RootDeviceName,
+		Ebs:        ebs,
+	}, nil
+}
+
+// copyProvisionedPerformance copies the IOPS and throughput of volume to ebs.
+// They can only be set for the volume types that provision them, so a gp2
+// volume carries neither while gp3 carries both.
+func copyProvisionedPerformance(ebs *types.EbsBlockDevice, volume types.Volume) {
+	switch volume.VolumeType {
+	case types.VolumeTypeIo1, types.VolumeTypeIo2:
+		ebs.Iops = volume.Iops
+	case types.VolumeTypeGp3:
+		ebs.Iops = volume.Iops
+		ebs.Throughput = volume.Throughput
+	}
+}
+
+// waitForSnapshotsCompleted waits for snapshots to finish so volumes can be created from them
+func (s *Service) waitForSnapshotsCompleted(ctx context.Context, snapshotIDs []string) error {
+	maxWaitTime, err := s.remainingWait(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Waiting for snapshots to complete", "snapshotIDs", snapshotIDs, "timeout", maxWaitTime)
+
+	waiter := ec2.NewSnapshotCompletedWaiter(s.client)
+	return waiter.Wait(ctx, &ec2.DescribeSnapshotsInput{
+		SnapshotIds: snapshotIDs,
+	}, maxWaitTime)
+}
+
+// waitForMigrationSnapshots waits for the snapshots of every volume of the
+// instance, keyed by volume ID, to complete before it is terminated
+func (s *Service) waitForMigrationSnapshots(ctx context.Context, instance types.Instance, snapshotIDs map[string]string) error {
+	if len(snapshotIDs) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(snapshotIDs))
+	for _, snapshotID := range snapshotIDs {
+		ids = append(ids, snapshotID)
+	}
+	sort.Strings(ids)
+
+	logger.Info("Waiting for migration snapshots before terminating instance",
+		"instanceID", aws.ToString(instance.InstanceId), "snapshotIDs", ids)
+	if err := s.waitForSnapshotsCompleted(ctx, ids); err != nil {
+		return fmt.Errorf("wait for snapshots %s: %w", strings.Join(ids, ", "), err)
+	}
+	return nil
+}
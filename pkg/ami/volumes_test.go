@@ -0,0 +1,397 @@
+package ami
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/client"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestDataVolumeMappings(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := types.Instance{
+		InstanceId:     aws.String("i-123"),
+		RootDeviceName: aws.String("/dev/xvda"),
+		BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-root")},
+			},
+			{
+				DeviceName: aws.String("/dev/sdg"),
+				Ebs: &types.EbsInstanceBlockDevice{
+					VolumeId:            aws.String("vol-logs"),
+					DeleteOnTermination: aws.Bool(true),
+				},
+			},
+			{
+				DeviceName: aws.String("/dev/sdf"),
+				Ebs: &types.EbsInstanceBlockDevice{
+					VolumeId:            aws.String("vol-data"),
+					DeleteOnTermination: aws.Bool(false),
+				},
+			},
+		},
+	}
+
+	volumes := []types.Volume{
+		{
+			VolumeId:   aws.String("vol-data"),
+			VolumeType: types.VolumeTypeGp3,
+			Size:       aws.Int32(100),
+			Iops:       aws.Int32(6000),
+			Throughput: aws.Int32(250),
+			Encrypted:  aws.Bool(true),
+			KmsKeyId:   aws.String("arn:aws:kms:us-east-1:123456789012:key/abc"),
+		},
+		{
+			VolumeId:   aws.String("vol-logs"),
+			VolumeType: types.VolumeTypeGp2,
+			Size:       aws.Int32(20),
+			Iops:       aws.Int32(100),
+			Encrypted:  aws.Bool(false),
+		},
+	}
+
+	snapshotIDs := map[string]string{
+		"vol-root": "snap-root",
+		"vol-data": "snap-data",
+		"vol-logs": "snap-logs",
+	}
+	snapshots := []types.Snapshot{
+		{SnapshotId: aws.String("snap-data"), State: types.SnapshotStateCompleted},
+		{SnapshotId: aws.String("snap-logs"), State: types.SnapshotStateCompleted},
+	}
+
+	tests := []struct {
+		name        string
+		instance    types.Instance
+		volumes     []types.Volume
+		snapshotIDs map[string]string
+		wantErr     bool
+		errContains string
+		validate    func(*testing.T, []types.BlockDeviceMapping)
+	}{
+		{
+			name:     "recreates data volumes in device order",
+			instance: instance,
+			volumes:  volumes,
+			validate: func(t *testing.T, mappings []types.BlockDeviceMapping) {
+				if !assert.Len(t, mappings, 2) {
+					return
+				}
+
+				logs := mappings[0]
+				assert.Equal(t, "/dev/sdg", aws.ToString(logs.DeviceName))
+				assert.Equal(t, "snap-logs", aws.ToString(logs.Ebs.SnapshotId))
+				assert.Equal(t, types.VolumeTypeGp2, logs.Ebs.VolumeType)
+				assert.Equal(t, int32(20), aws.ToInt32(logs.Ebs.VolumeSize))
+				assert.Nil(t, logs.Ebs.Iops, "gp2 volumes can't provision IOPS")
+				assert.Nil(t, logs.Ebs.Encrypted)
+				assert.True(t, aws.ToBool(logs.Ebs.DeleteOnTermination))
+
+				data := mappings[1]
+				assert.Equal(t, "/dev/sdf", aws.ToString(data.DeviceName))
+				assert.Equal(t, "snap-data", aws.ToString(data.Ebs.SnapshotId))
+				assert.Equal(t, types.VolumeTypeGp3, data.Ebs.VolumeType)
+				assert.Equal(t, int32(6000), aws.ToInt32(data.Ebs.Iops))
+				assert.Equal(t, int32(250), aws.ToInt32(data.Ebs.Throughput))
+				assert.True(t, aws.ToBool(data.Ebs.Encrypted))
+				assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/abc", aws.ToString(data.Ebs.KmsKeyId))
+				assert.False(t, aws.ToBool(data.Ebs.DeleteOnTermination))
+			},
+		},
+		{
+			name: "unknown root device leaves the AMI mapping alone",
+			instance: types.Instance{
+				InstanceId:          aws.String("i-123"),
+				BlockDeviceMappings: instance.BlockDeviceMappings,
+			},
+			volumes: volumes,
+			validate: func(t *testing.T, mappings []types.BlockDeviceMapping) {
+				assert.Empty(t, mappings)
+			},
+		},
+		{
+			name:        "no data volume snapshots",
+			instance:    instance,
+			volumes:     volumes,
+			snapshotIDs: map[string]string{"vol-root": "snap-root"},
+			validate: func(t *testing.T, mappings []types.BlockDeviceMapping) {
+				assert.Empty(t, mappings)
+			},
+		},
+		{
+			name:        "volume not found",
+			instance:    instance,
+			volumes:     volumes[:1],
+			wantErr:     true,
+			errContains: "volume not found: vol-logs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock client
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Volumes = tt.volumes
+			mockClient.Snapshots = snapshots
+			if err := client.SetEC2Client(mockClient); err != nil {
+				t.Fatal(err)
+			}
+			recorder := &describeSnapshotsRecorder{MockEC2Client: mockClient}
+
+			// Create service with mock client
+			svc := NewService(recorder)
+
+			// Run test
+			ids := snapshotIDs
+			if tt.snapshotIDs != nil {
+				ids = tt.snapshotIDs
+			}
+			mappings, err := svc.dataVolumeMappings(context.Background(), tt.instance, ids)
+			// Without IDs DescribeSnapshots would list every snapshot in the account
+			for _, described := range recorder.described {
+				assert.NotEmpty(t, described, "DescribeSnapshots called without snapshot IDs")
+			}
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errContains != "" {
+					assert.Contains(t, err.Error(), tt.errContains)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			if tt.validate != nil {
+				tt.validate(t, mappings)
+			}
+		})
+	}
+}
+
+// describeSnapshotsRecorder records the snapshot IDs of every DescribeSnapshots call
+type describeSnapshotsRecorder struct {
+	*apitypes.MockEC2Client
+	described [][]string
+}
+
+func (c *describeSnapshotsRecorder) DescribeSnapshots(ctx context.Context, params *ec2.DescribeSnapshotsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSnapshotsOutput, error) {
+	c.described = append(c.described, params.SnapshotIds)
+	return c.MockEC2Client.DescribeSnapshots(ctx, params, optFns...)
+}
+
+// snapshotStateClient reports every snapshot it is asked about in state
+type snapshotStateClient struct {
+	*apitypes.MockEC2Client
+	state     types.SnapshotState
+	described [][]string
+}
+
+func (c *snapshotStateClient) DescribeSnapshots(ctx context.Context, params *ec2.DescribeSnapshotsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSnapshotsOutput, error) {
+	c.described = append(c.described, params.SnapshotIds)
+	output := &ec2.DescribeSnapshotsOutput{}
+	for _, snapshotID := range params.SnapshotIds {
+		output.Snapshots = append(output.Snapshots, types.Snapshot{
+			SnapshotId: aws.String(snapshotID),
+			State:      c.state,
+		})
+	}
+	return output, nil
+}
+
+func TestMigrateInstancesWaitForSnapshots(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name          string
+		wait          bool
+		state         types.SnapshotState
+		wantDescribed [][]string
+		wantErr       string
+	}{
+		{
+			name:  "terminates without waiting by default",
+			state: types.SnapshotStateError,
+		},
+		{
+			name:          "waits for the root snapshot",
+			wait:          true,
+			state:         types.SnapshotStateCompleted,
+			wantDescribed: [][]string{{"snap-root"}},
+		},
+		{
+			name:          "keeps the old instance when a snapshot fails",
+			wait:          true,
+			state:         types.SnapshotStateError,
+			wantDescribed: [][]string{{"snap-root"}},
+			wantErr:       "wait for snapshots snap-root",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId:     aws.String("i-1"),
+								ImageId:        aws.String("ami-old"),
+								RootDeviceName: aws.String("/dev/xvda"),
+								State:          &types.InstanceState{Name: types.InstanceStateNameStopped},
+								BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+									{
+										DeviceName: aws.String("/dev/xvda"),
+										Ebs:        &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-root")},
+									},
+								},
+								Tags: []types.Tag{
+									{Key: aws.String("ami-migrate"), Value: aws.String("enabled")},
+								},
+							},
+						},
+					},
+				},
+			}
+			client := &snapshotStateClient{MockEC2Client: mockClient, state: tt.state}
+
+			svc := NewService(client)
+			result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{
+				NewAMI:                 "ami-new",
+				WaitForSnapshots:       tt.wait,
+				KeepSnapshotsOnFailure: true,
+			})
+			require.NotNil(t, result)
+			require.Len(t, result.Instances, 1)
+			assert.Equal(t, tt.wantDescribed, client.described)
+
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, StatusCompleted, result.Instances[0].Status)
+				assert.Equal(t, types.InstanceStateNameTerminated, mockClient.InstanceStates["i-1"])
+				return
+			}
+			assert.Error(t, err)
+			assert.Contains(t, result.Instances[0].Message, tt.wantErr)
+			assert.NotEqual(t, types.InstanceStateNameTerminated, mockClient.InstanceStates["i-1"])
+		})
+	}
+}
+
+func TestRootVolumeMapping(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	instance := types.Instance{
+		InstanceId:     aws.String("i-123"),
+		RootDeviceName: aws.String("/dev/xvda"),
+		BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				Ebs: &types.EbsInstanceBlockDevice{
+					VolumeId:            aws.String("vol-root"),
+					DeleteOnTermination: aws.Bool(true),
+				},
+			},
+		},
+	}
+	image := func(size int32, volumeType types.VolumeType) types.Image {
+		return types.Image{
+			ImageId:        aws.String("ami-new"),
+			State:          types.ImageStateAvailable,
+			RootDeviceName: aws.String("/dev/sda1"),
+			BlockDeviceMappings: []types.BlockDeviceMapping{
+				{
+					DeviceName: aws.String("/dev/sda1"),
+					Ebs: &types.EbsBlockDevice{
+						SnapshotId: aws.String("snap-ami"),
+						VolumeSize: aws.Int32(size),
+						VolumeType: volumeType,
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		instance types.Instance
+		image    types.Image
+		volume   types.Volume
+		expected *types.BlockDeviceMapping
+	}{
+		{
+			name:     "grown root volume keeps its size",
+			instance: instance,
+			image:    image(8, types.VolumeTypeGp2),
+			volume:   types.Volume{VolumeId: aws.String("vol-root"), Size: aws.Int32(50), VolumeType: types.VolumeTypeGp2, Iops: aws.Int32(150)},
+			expected: &types.BlockDeviceMapping{
+				DeviceName: aws.String("/dev/sda1"),
+				Ebs: &types.EbsBlockDevice{
+					VolumeSize:          aws.Int32(50),
+					VolumeType:          types.VolumeTypeGp2,
+					DeleteOnTermination: aws.Bool(true),
+				},
+			},
+		},
+		{
+			name:     "never shrinks below the AMI's size",
+			instance: instance,
+			image:    image(30, types.VolumeTypeGp2),
+			volume: types.Volume{
+				VolumeId:   aws.String("vol-root"),
+				Size:       aws.Int32(20),
+				VolumeType: types.VolumeTypeGp3,
+				Iops:       aws.Int32(4000),
+				Throughput: aws.Int32(200),
+			},
+			expected: &types.BlockDeviceMapping{
+				DeviceName: aws.String("/dev/sda1"),
+				Ebs: &types.EbsBlockDevice{
+					VolumeSize:          aws.Int32(30),
+					VolumeType:          types.VolumeTypeGp3,
+					Iops:                aws.Int32(4000),
+					Throughput:          aws.Int32(200),
+					DeleteOnTermination: aws.Bool(true),
+				},
+			},
+		},
+		{
+			name:     "AMI defaults already match",
+			instance: instance,
+			image:    image(8, types.VolumeTypeGp3),
+			volume:   types.Volume{VolumeId: aws.String("vol-root"), Size: aws.Int32(8), VolumeType: types.VolumeTypeGp3},
+		},
+		{
+			name:     "unknown root device",
+			instance: types.Instance{InstanceId: aws.String("i-123")},
+			image:    image(8, types.VolumeTypeGp2),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{tt.image}
+			mockClient.Volumes = []types.Volume{tt.volume}
+
+			svc := NewService(mockClient)
+			mapping, err := svc.rootVolumeMapping(context.Background(), tt.instance, "ami-new")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, mapping)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package ami
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// InstanceStateWaiter waits for an instance to reach a state. The service
+// only waits for running, stopped and terminated.
+type InstanceStateWaiter interface {
+	// WaitForState returns once the instance is in state, or an error if it
+	// can't get there within maxWait
+	WaitForState(ctx context.Context, instanceID string, state types.InstanceStateName, maxWait time.Duration) error
+}
+
+// WithInstanceStateWaiter replaces the SDK waiters the service polls
+// instances with, e.g. so tests don't have to poll
+func WithInstanceStateWaiter(waiter InstanceStateWaiter) ServiceOption {
+	return func(s *Service) {
+		s.waiter = waiter
+	}
+}
+
+// sdkInstanceStateWaiter polls DescribeInstances with the SDK's instance
+// waiters
+type sdkInstanceStateWaiter struct {
+	client apitypes.EC2ClientAPI
+}
+
+func (w *sdkInstanceStateWaiter) WaitForState(ctx context.Context, instanceID string, state types.InstanceStateName, maxWait time.Duration) error {
+	input := &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	}
+	switch state {
+	case types.InstanceStateNameRunning:
+		return ec2.NewInstanceRunningWaiter(w.client).Wait(ctx, input, maxWait)
+	case types.InstanceStateNameStopped:
+		return ec2.NewInstanceStoppedWaiter(w.client).Wait(ctx, input, maxWait)
+	case types.InstanceStateNameTerminated:
+		return ec2.NewInstanceTerminatedWaiter(w.client).Wait(ctx, input, maxWait)
+	default:
+		return fmt.Errorf("unsupported instance state: %s", state)
+	}
+}
@@ -0,0 +1,81 @@
+package ami
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+// fakeWaiter records the waits it is asked for and returns at once
+type fakeWaiter struct {
+	waits    []string
+	maxWaits []time.Duration
+	err      error
+}
+
+func (w *fakeWaiter) WaitForState(ctx context.Context, instanceID string, state types.InstanceStateName, maxWait time.Duration) error {
+	w.waits = append(w.waits, instanceID+" "+string(state))
+	w.maxWaits = append(w.maxWaits, maxWait)
+	return w.err
+}
+
+func TestInstanceStateWaiter(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	tests := []struct {
+		name              string
+		waitErr           error
+		wantWaits         []string
+		wantOldTerminated bool
+		wantErr           string
+	}{
+		{
+			name:              "waits through the fake",
+			wantWaits:         []string{"i-456 running"},
+			wantOldTerminated: true,
+		},
+		{
+			name:      "wait failure fails the migration",
+			waitErr:   errors.New("exceeded max wait time"),
+			wantWaits: []string{"i-456 running"},
+			wantErr:   "new instance i-456 is not healthy, leaving i-123 in place",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := apitypes.NewMockEC2Client()
+			mockClient.Images = []types.Image{availableImage("ami-new")}
+			mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{Instances: []types.Instance{{
+					InstanceId: aws.String("i-123"),
+					ImageId:    aws.String("ami-old"),
+					State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+					Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+				}}}},
+			}
+			waiter := &fakeWaiter{err: tt.waitErr}
+
+			svc := NewService(mockClient, WithInstanceStateWaiter(waiter))
+			_, err := svc.MigrateInstanceWithOptions(context.Background(), "i-123", MigrateOptions{NewAMI: "ami-new"})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantWaits, waiter.waits)
+			// The old instance is only terminated once its replacement is running
+			assert.Equal(t, tt.wantOldTerminated, mockClient.GetInstanceState("i-123") == types.InstanceStateNameTerminated)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package ami
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/taemon1337/ec-manager/pkg/logger"
+)
+
+// maintenanceWindow is the time of day an instance's window tag allows it
+// to be migrated in, e.g. ami-migrate-window=02:00-04:00 UTC. A window whose
+// end is before its start runs past midnight.
+type maintenanceWindow struct {
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// parseMaintenanceWindow parses a window tag value: a start and end time,
+// as HH:MM, optionally followed by a time zone, which is an IANA name such
+// as Europe/Berlin, UTC or an offset such as +05:30. Without one the times
+// are UTC.
+func parseMaintenanceWindow(value string) (maintenanceWindow, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return maintenanceWindow{}, fmt.Errorf("want HH:MM-HH:MM followed by an optional time zone")
+	}
+	startValue, endValue, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return maintenanceWindow{}, fmt.Errorf("want HH:MM-HH:MM followed by an optional time zone")
+	}
+
+	var window maintenanceWindow
+	var err error
+	if window.start, err = parseTimeOfDay(startValue); err != nil {
+		return maintenanceWindow{}, fmt.Errorf("start: %w", err)
+	}
+	if window.end, err = parseTimeOfDay(endValue); err != nil {
+		return maintenanceWindow{}, fmt.Errorf("end: %w", err)
+	}
+	if window.start == window.end {
+		return maintenanceWindow{}, fmt.Errorf("start and end are both %s", startValue)
+	}
+
+	window.location = time.UTC
+	if len(fields) == 2 {
+		if window.location, err = parseTimeZone(fields[1]); err != nil {
+			return maintenanceWindow{}, fmt.Errorf("time zone: %w", err)
+		}
+	}
+	return window, nil
+}
+
+// parseTimeOfDay parses HH:MM as the time since midnight
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time such as 02:00", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseTimeZone parses an offset such as +05:30 or -0800, or else a zone
+// name such as UTC or Europe/Berlin
+func parseTimeZone(value string) (*time.Location, error) {
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		for _, layout := range []string{"-07:00", "-0700", "-07"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				_, offset := t.Zone()
+				return time.FixedZone(value, offset), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not an offset such as +05:30", value)
+	}
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", value)
+	}
+	return location, nil
+}
+
+// contains reports whether t falls in the window, in the window's time zone
+func (w maintenanceWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	timeOfDay := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return timeOfDay >= w.start && timeOfDay < w.end
+	}
+	return timeOfDay >= w.start || timeOfDay < w.end
+}
+
+// windowSkipReason returns why the instance may not be migrated now because
+// of its window tag: it is outside the window, or the tag can't be parsed.
+// It is empty when the instance has no window tag or is inside its window.
+func (s *Service) windowSkipReason(instance types.Instance) string {
+	value := tagValue(instance.Tags, s.tags.Window)
+	if value == "" {
+		return ""
+	}
+	window, err := parseMaintenanceWindow(value)
+	if err != nil {
+		// Migrating anyway could take the instance down when it's least expected
+		logger.Warn("Skipping instance with an invalid maintenance window tag",
+			"instanceID", aws.ToString(instance.InstanceId),
+			"tag", s.tags.Window,
+			"value", value,
+			"error", err)
+		return fmt.Sprintf("Invalid maintenance window: %s=%s: %v", s.tags.Window, value, err)
+	}
+	if !window.contains(s.now()) {
+		return fmt.Sprintf("Outside maintenance window: %s", value)
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+package ami
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/ec-manager/pkg/testutil"
+	apitypes "github.com/taemon1337/ec-manager/pkg/types"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		inside  []time.Time
+		outside []time.Time
+		wantErr string
+	}{
+		{
+			name:    "UTC",
+			value:   "02:00-04:00 UTC",
+			inside:  []time.Time{at(2, 0), at(3, 59)},
+			outside: []time.Time{at(1, 59), at(4, 0), at(14, 0)},
+		},
+		{
+			name:    "defaults to UTC",
+			value:   "02:00-04:00",
+			inside:  []time.Time{at(3, 0)},
+			outside: []time.Time{at(5, 0)},
+		},
+		{
+			name:    "past midnight",
+			value:   "23:00-01:30",
+			inside:  []time.Time{at(23, 0), at(0, 0), at(1, 29)},
+			outside: []time.Time{at(1, 30), at(22, 59)},
+		},
+		{
+			name:    "offset",
+			value:   "02:00-04:00 +05:30",
+			inside:  []time.Time{at(20, 30), at(22, 29)},
+			outside: []time.Time{at(2, 0), at(22, 30)},
+		},
+		{
+			name:    "missing end",
+			value:   "02:00",
+			wantErr: "want HH:MM-HH:MM followed by an optional time zone",
+		},
+		{
+			name:    "bad time",
+			value:   "02:00-25:00 UTC",
+			wantErr: `end: "25:00" is not a time such as 02:00`,
+		},
+		{
+			name:    "empty window",
+			value:   "02:00-02:00",
+			wantErr: "start and end are both 02:00",
+		},
+		{
+			name:    "unknown time zone",
+			value:   "02:00-04:00 Mars/Olympus",
+			wantErr: `time zone: unknown time zone "Mars/Olympus"`,
+		},
+		{
+			name:    "bad offset",
+			value:   "02:00-04:00 +5h",
+			wantErr: `time zone: "+5h" is not an offset such as +05:30`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := parseMaintenanceWindow(tt.value)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for _, now := range tt.inside {
+				assert.True(t, window.contains(now), "%s should be inside", now.Format("15:04"))
+			}
+			for _, now := range tt.outside {
+				assert.False(t, window.contains(now), "%s should be outside", now.Format("15:04"))
+			}
+		})
+	}
+}
+
+func TestMigrateInstancesMaintenanceWindow(t *testing.T) {
+	// Initialize test logger
+	testutil.InitTestLogger(t)
+
+	windowed := func(id, window string) types.Instance {
+		instance := types.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String("ami-old"),
+			State:      &types.InstanceState{Name: types.InstanceStateNameStopped},
+			Tags:       []types.Tag{{Key: aws.String("ami-migrate"), Value: aws.String("enabled")}},
+		}
+		if window != "" {
+			instance.Tags = append(instance.Tags, types.Tag{Key: aws.String("ami-migrate-window"), Value: aws.String(window)})
+		}
+		return instance
+	}
+	mockClient := apitypes.NewMockEC2Client()
+	mockClient.Images = []types.Image{availableImage("ami-new")}
+	mockClient.DescribeInstancesOutput = &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: []types.Instance{
+					windowed("i-inside", "02:00-04:00 UTC"),
+					windowed("i-outside", "22:00-23:00 UTC"),
+					windowed("i-invalid", "2am-4am"),
+					windowed("i-unwindowed", ""),
+				},
+			},
+		},
+	}
+
+	svc := NewService(mockClient)
+	svc.now = func() time.Time { return time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC) }
+	result, err := svc.MigrateInstances(context.Background(), "enabled", MigrateOptions{NewAMI: "ami-new", DryRun: true})
+	require.NoError(t, err)
+
+	reasons := make(map[string]string)
+	for _, instance := range result.Plan.Instances {
+		reasons[instance.InstanceID] = instance.Reason
+		assert.Equal(t, instance.Reason == "", instance.Migrate, instance.InstanceID)
+	}
+	assert.Equal(t, map[string]string{
+		"i-inside":     "",
+		"i-outside":    "Outside maintenance window: 22:00-23:00 UTC",
+		"i-invalid":    `Invalid maintenance window: ami-migrate-window=2am-4am: start: "2am" is not a time such as 02:00`,
+		"i-unwindowed": "",
+	}, reasons)
+
+	// Force doesn't override the window either
+	_, err = svc.MigrateInstanceWithOptions(context.Background(), "i-outside", MigrateOptions{NewAMI: "ami-new", Force: true})
+	assert.EqualError(t, err, "instance i-outside can't be migrated now: Outside maintenance window: 22:00-23:00 UTC")
+	assert.Empty(t, mockClient.RunInstancesInputs)
+}
@@ -9,7 +9,12 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/taemon1337/ec-manager/pkg/types"
 )
 
@@ -26,18 +31,50 @@ func (e *ClientError) Error() string {
 	return e.Message
 }
 
+// assumeRoleSessionName names the STS sessions opened for --assume-role-arn
+const assumeRoleSessionName = "ec-manager"
+
 var (
-	ec2Client types.EC2ClientAPI
-	mockMode  bool
+	ec2Client     types.EC2ClientAPI
+	ssmClient     types.SSMClientAPI
+	cwClient      types.CloudWatchClientAPI
+	snsClient     types.SNSClientAPI
+	mockMode      bool
+	region        string
+	assumeRoleARN string
+	externalID    string
 )
 
+// SetRegion sets the AWS region used for new clients, overriding the region
+// from the environment and shared config. An empty region restores the default
+// resolution.
+func SetRegion(r string) {
+	region = r
+}
+
+// regionKey is the context key of the region set with WithRegion
+type regionKey struct{}
+
+// WithRegion returns a context under which new clients are created in r,
+// overriding the region set with SetRegion, e.g. to work in several regions
+// in one run
+func WithRegion(ctx context.Context, r string) context.Context {
+	return context.WithValue(ctx, regionKey{}, r)
+}
+
 // SetMockMode enables or disables mock mode
 func SetMockMode(enabled bool) {
 	mockMode = enabled
 	if enabled {
 		ec2Client = types.NewMockEC2Client()
+		ssmClient = types.NewMockSSMClient()
+		cwClient = types.NewMockCloudWatchClient()
+		snsClient = types.NewMockSNSClient()
 	} else {
 		ec2Client = nil
+		ssmClient = nil
+		cwClient = nil
+		snsClient = nil
 	}
 }
 
@@ -60,9 +97,80 @@ func GetEC2Client(ctx context.Context) (types.EC2ClientAPI, error) {
 	return ec2.NewFromConfig(cfg), nil
 }
 
-// LoadAWSConfig loads AWS configuration and validates credentials
+// GetSSMClient returns an SSM client for testing or real usage
+func GetSSMClient(ctx context.Context) (types.SSMClientAPI, error) {
+	if mockMode || isTestPackage() {
+		if ssmClient == nil {
+			return nil, &ClientError{Message: "no SSM client set for mock mode"}
+		}
+		return ssmClient, nil
+	}
+
+	cfg, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return nil, &ClientError{Message: "failed to load AWS config", Err: err}
+	}
+
+	return ssm.NewFromConfig(cfg), nil
+}
+
+// GetCloudWatchClient returns a CloudWatch client for testing or real usage
+func GetCloudWatchClient(ctx context.Context) (types.CloudWatchClientAPI, error) {
+	if mockMode || isTestPackage() {
+		if cwClient == nil {
+			return nil, &ClientError{Message: "no CloudWatch client set for mock mode"}
+		}
+		return cwClient, nil
+	}
+
+	cfg, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return nil, &ClientError{Message: "failed to load AWS config", Err: err}
+	}
+
+	return cloudwatch.NewFromConfig(cfg), nil
+}
+
+// GetSNSClient returns an SNS client for testing or real usage
+func GetSNSClient(ctx context.Context) (types.SNSClientAPI, error) {
+	if mockMode || isTestPackage() {
+		if snsClient == nil {
+			return nil, &ClientError{Message: "no SNS client set for mock mode"}
+		}
+		return snsClient, nil
+	}
+
+	cfg, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return nil, &ClientError{Message: "failed to load AWS config", Err: err}
+	}
+
+	return sns.NewFromConfig(cfg), nil
+}
+
+// SetAssumeRole makes new clients use credentials from assuming roleARN, e.g.
+// to work in another account. externalID is passed to AssumeRole when set. An
+// empty roleARN uses the default credentials again.
+func SetAssumeRole(roleARN, extID string) {
+	assumeRoleARN = roleARN
+	externalID = extID
+}
+
+// AssumeRoleARN returns the role set with SetAssumeRole, if any
+func AssumeRoleARN() string {
+	return assumeRoleARN
+}
+
+// LoadAWSConfig loads AWS configuration and validates credentials and region
 func LoadAWSConfig(ctx context.Context) (aws.Config, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
+	var optFns []func(*config.LoadOptions) error
+	if r, ok := ctx.Value(regionKey{}).(string); ok && r != "" {
+		optFns = append(optFns, config.WithRegion(r))
+	} else if region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
 	if err != nil {
 		return aws.Config{}, checkCredentialsError(err)
 	}
@@ -73,6 +181,24 @@ func LoadAWSConfig(ctx context.Context) (aws.Config, error) {
 		return aws.Config{}, checkCredentialsError(err)
 	}
 
+	// Swap in the assumed role's credentials for every client built from cfg
+	if assumeRoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), assumeRoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = assumeRoleSessionName
+			if externalID != "" {
+				o.ExternalID = aws.String(externalID)
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+		if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			return aws.Config{}, &ClientError{Message: fmt.Sprintf("failed to assume role %s", assumeRoleARN), Err: err}
+		}
+	}
+
+	if cfg.Region == "" {
+		return aws.Config{}, &ClientError{Message: "no AWS region configured: use --region or set AWS_REGION"}
+	}
+
 	return cfg, nil
 }
 
@@ -123,6 +249,33 @@ func SetEC2Client(client types.EC2ClientAPI) error {
 	return nil
 }
 
+// SetSSMClient sets the SSM client (used for testing)
+func SetSSMClient(client types.SSMClientAPI) error {
+	if client == nil {
+		return &ClientError{Message: "cannot set nil SSM client"}
+	}
+	ssmClient = client
+	return nil
+}
+
+// SetCloudWatchClient sets the CloudWatch client (used for testing)
+func SetCloudWatchClient(client types.CloudWatchClientAPI) error {
+	if client == nil {
+		return &ClientError{Message: "cannot set nil CloudWatch client"}
+	}
+	cwClient = client
+	return nil
+}
+
+// SetSNSClient sets the SNS client (used for testing)
+func SetSNSClient(client types.SNSClientAPI) error {
+	if client == nil {
+		return &ClientError{Message: "cannot set nil SNS client"}
+	}
+	snsClient = client
+	return nil
+}
+
 // isTestPackage returns true if the code is running in a test package
 func isTestPackage() bool {
 	return strings.HasSuffix(os.Args[0], ".test") || strings.Contains(os.Args[0], "/_test/")
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -73,6 +74,84 @@ func TestGetEC2Client(t *testing.T) {
 	}
 }
 
+func TestGetSSMClient(t *testing.T) {
+	// Save original args and restore after test
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+
+	// Set test args to simulate test environment
+	os.Args = []string{"test.test"}
+
+	if err := SetSSMClient(nil); err == nil {
+		t.Error("SetSSMClient accepted a nil client")
+	}
+
+	mockClient := apitypes.NewMockSSMClient()
+	if err := SetSSMClient(mockClient); err != nil {
+		t.Errorf("SetSSMClient failed: %v", err)
+	}
+
+	client, err := GetSSMClient(context.Background())
+	if err != nil {
+		t.Errorf("GetSSMClient failed: %v", err)
+	}
+	if client != mockClient {
+		t.Error("GetSSMClient didn't return the client that was set")
+	}
+}
+
+func TestGetCloudWatchClient(t *testing.T) {
+	// Save original args and restore after test
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+
+	// Set test args to simulate test environment
+	os.Args = []string{"test.test"}
+
+	if err := SetCloudWatchClient(nil); err == nil {
+		t.Error("SetCloudWatchClient accepted a nil client")
+	}
+
+	mockClient := apitypes.NewMockCloudWatchClient()
+	if err := SetCloudWatchClient(mockClient); err != nil {
+		t.Errorf("SetCloudWatchClient failed: %v", err)
+	}
+
+	client, err := GetCloudWatchClient(context.Background())
+	if err != nil {
+		t.Errorf("GetCloudWatchClient failed: %v", err)
+	}
+	if client != mockClient {
+		t.Error("GetCloudWatchClient didn't return the client that was set")
+	}
+}
+
+func TestGetSNSClient(t *testing.T) {
+	// Save original args and restore after test
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+
+	// Set test args to simulate test environment
+	os.Args = []string{"test.test"}
+
+	if err := SetSNSClient(nil); err == nil {
+		t.Error("SetSNSClient accepted a nil client")
+	}
+
+	mockClient := apitypes.NewMockSNSClient()
+	if err := SetSNSClient(mockClient); err != nil {
+		t.Errorf("SetSNSClient failed: %v", err)
+	}
+
+	client, err := GetSNSClient(context.Background())
+	if err != nil {
+		t.Errorf("GetSNSClient failed: %v", err)
+	}
+	if client != mockClient {
+		t.Error("GetSNSClient didn't return the client that was set")
+	}
+}
+
 func TestLoadAWSConfig(t *testing.T) {
 	// Test with missing credentials
 	_, err := LoadAWSConfig(context.Background())
@@ -96,3 +175,71 @@ func containsCredentialHelp(msg string) bool {
 func contains(s, substr string) bool {
 	return len(s) > 0 && len(substr) > 0 && s != substr && len(s) > len(substr) && s[len(s)-1] != substr[0]
 }
+
+func TestLoadAWSConfigRegion(t *testing.T) {
+	// Use static credentials and no shared config so only the region varies
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Cleanup(func() { SetRegion("") })
+
+	tests := []struct {
+		name       string
+		envRegion  string
+		flagRegion string
+		ctxRegion  string
+		wantRegion string
+		wantErr    string
+	}{
+		{
+			name:       "region from environment",
+			envRegion:  "us-east-1",
+			wantRegion: "us-east-1",
+		},
+		{
+			name:       "flag overrides environment",
+			envRegion:  "us-east-1",
+			flagRegion: "eu-west-1",
+			wantRegion: "eu-west-1",
+		},
+		{
+			name:       "context overrides flag",
+			envRegion:  "us-east-1",
+			flagRegion: "eu-west-1",
+			ctxRegion:  "ap-southeast-2",
+			wantRegion: "ap-southeast-2",
+		},
+		{
+			name:    "no region",
+			wantErr: "no AWS region configured: use --region or set AWS_REGION",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_REGION", tt.envRegion)
+			t.Setenv("AWS_DEFAULT_REGION", "")
+			SetRegion(tt.flagRegion)
+
+			ctx := context.Background()
+			if tt.ctxRegion != "" {
+				ctx = WithRegion(ctx, tt.ctxRegion)
+			}
+			cfg, err := LoadAWSConfig(ctx)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("LoadAWSConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadAWSConfig() failed: %v", err)
+			}
+			if cfg.Region != tt.wantRegion {
+				t.Errorf("LoadAWSConfig() region = %q, want %q", cfg.Region, tt.wantRegion)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/taemon1337/ec-manager/pkg/client"
 	"context"
 	"strings"
 	"bufio"
@@ -16,7 +17,13 @@ import (
 // 1. AWS credentials file
 // 2. IAM user info
 // 3. STS caller identity
+// When a role is assumed with --assume-role-arn the assumed role's name is
+// returned instead.
 func GetAWSUsername(ctx context.Context) (string, error) {
+	if client.AssumeRoleARN() != "" {
+		return getAssumedRoleName(ctx)
+	}
+
 	// First try to get from credentials file
 	if username := getUserFromCredentials(); username != "" {
 		return username, nil
@@ -52,6 +59,32 @@ func GetAWSUsername(ctx context.Context) (string, error) {
 	return "", nil
 }
 
+// getAssumedRoleName returns the name of the role the clients assume, taken
+// from the caller identity of the assumed credentials
+func getAssumedRoleName(ctx context.Context) (string, error) {
+	cfg, err := client.LoadAWSConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return roleNameFromARN(*identity.Arn), nil
+}
+
+// roleNameFromARN extracts the role name from an assumed-role ARN
+// (arn:aws:sts::123456789012:assumed-role/role-name/session-name), falling back
+// to the last path segment for other ARNs
+func roleNameFromARN(arn string) string {
+	parts := strings.Split(arn, "/")
+	if strings.HasSuffix(parts[0], ":assumed-role") && len(parts) >= 3 {
+		return parts[1]
+	}
+	return parts[len(parts)-1]
+}
+
 // getUserFromCredentials attempts to read the username from AWS credentials file
 func getUserFromCredentials() string {
 	// Get home directory
@@ -0,0 +1,20 @@
+package config
+
+import "testing"
+
+func TestRoleNameFromARN(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{arn: "arn:aws:sts::123456789012:assumed-role/migration-role/ec-manager", want: "migration-role"},
+		{arn: "arn:aws:iam::123456789012:user/johndoe", want: "johndoe"},
+		{arn: "arn:aws:iam::123456789012:root", want: "arn:aws:iam::123456789012:root"},
+	}
+
+	for _, tt := range tests {
+		if got := roleNameFromARN(tt.arn); got != tt.want {
+			t.Errorf("roleNameFromARN(%q) = %q, want %q", tt.arn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variables that set the global flags,
+// e.g. ECMAN_REGION for --region
+const EnvPrefix = "ECMAN_"
+
+// FileKeys are the keys accepted in the config file. Each sets the global
+// flag of the same name, and can also be set with the environment variable
+// returned by EnvVar.
+var FileKeys = []string{
+	"region",
+	"timeout",
+	"log-level",
+	"tag-prefix",
+	"assume-role-arn",
+	"external-id",
+	"output",
+	"user",
+}
+
+// DefaultFilePath returns the config file read when --config isn't given,
+// ~/.ecman/config.yaml
+func DefaultFilePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".ecman", "config.yaml"), nil
+}
+
+// EnvVar returns the environment variable that sets key, e.g.
+// ECMAN_LOG_LEVEL for log-level
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// LoadFile reads the YAML config file at path and returns its settings keyed
+// by flag name, e.g.
+//
+//	region: eu-west-1
+//	timeout: 10m
+//	tag-prefix: ami-migrate
+//
+// A missing file returns no settings. A malformed file, an unknown key or a
+// value that isn't a scalar is an error.
+func LoadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if !slices.Contains(FileKeys, key) {
+			return nil, fmt.Errorf("config file %s: unknown key %q (valid keys: %s)", path, key, strings.Join(FileKeys, ", "))
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("config file %s: %s must be a single value", path, key)
+		case nil:
+			continue
+		}
+		settings[key] = fmt.Sprint(value)
+	}
+	return settings, nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "settings are keyed by flag name",
+			content: "region: eu-west-1\ntimeout: 10m\nlog-level: debug\ntag-prefix: fleet\n",
+			want:    map[string]string{"region": "eu-west-1", "timeout": "10m", "log-level": "debug", "tag-prefix": "fleet"},
+		},
+		{
+			name:    "empty values are left out",
+			content: "region:\noutput: json\n",
+			want:    map[string]string{"output": "json"},
+		},
+		{
+			name:    "malformed",
+			content: "region: [eu-west-1\n",
+			wantErr: "parse config file",
+		},
+		{
+			name:    "unknown key",
+			content: "regoin: eu-west-1\n",
+			wantErr: `unknown key "regoin"`,
+		},
+		{
+			name:    "nested value",
+			content: "region:\n  - eu-west-1\n",
+			wantErr: "region must be a single value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadFile() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	got, err := LoadFile(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if got != nil {
+		t.Errorf("LoadFile() = %v, want no settings", got)
+	}
+}
+
+func TestEnvVar(t *testing.T) {
+	if got := EnvVar("log-level"); got != "ECMAN_LOG_LEVEL" {
+		t.Errorf("EnvVar(%q) = %q, want %q", "log-level", got, "ECMAN_LOG_LEVEL")
+	}
+}
@@ -110,3 +110,8 @@ func Error(msg string, args ...any) {
 func With(args ...any) *slog.Logger {
 	return getLogger().With(args...)
 }
+
+// Get returns the underlying logger, e.g. to hand to packages that take a *slog.Logger
+func Get() *slog.Logger {
+	return getLogger()
+}
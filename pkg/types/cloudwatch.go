@@ -0,0 +1,13 @@
+package types
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+// CloudWatchClientAPI is the interface for the CloudWatch operations used to
+// publish migration metrics
+type CloudWatchClientAPI interface {
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
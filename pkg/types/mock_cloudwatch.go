@@ -0,0 +1,36 @@
+package types
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+// MockCloudWatchClient is a mock implementation of CloudWatchClientAPI
+type MockCloudWatchClient struct {
+	sync.Mutex
+	// Error fields for each operation
+	PutMetricDataError error
+
+	// Inputs recorded for assertions
+	PutMetricDataInputs []*cloudwatch.PutMetricDataInput
+}
+
+// NewMockCloudWatchClient creates a new mock CloudWatch client
+func NewMockCloudWatchClient() *MockCloudWatchClient {
+	return &MockCloudWatchClient{}
+}
+
+// PutMetricData implements CloudWatchClientAPI
+func (m *MockCloudWatchClient) PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.PutMetricDataInputs = append(m.PutMetricDataInputs, params)
+
+	if m.PutMetricDataError != nil {
+		return nil, m.PutMetricDataError
+	}
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
@@ -2,11 +2,14 @@ package types
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
 )
 
 // MockEC2Client is a mock implementation of EC2ClientAPI
@@ -15,8 +18,16 @@ type MockEC2Client struct {
 	// Output and error fields for each operation
 	DescribeInstancesOutput *ec2.DescribeInstancesOutput
 	DescribeInstancesError  error
+	// DescribeInstancesPages, when set, is served one page per call to
+	// DescribeInstances requests that don't ask for specific instance IDs
+	DescribeInstancesPages []*ec2.DescribeInstancesOutput
 	DescribeImagesOutput   *ec2.DescribeImagesOutput
+	// DescribeImagesPages, when set, is served one page per call to
+	// DescribeImages requests that don't ask for specific image IDs
+	DescribeImagesPages    []*ec2.DescribeImagesOutput
 	DescribeImagesError    error
+	CopyImageOutput        *ec2.CopyImageOutput
+	CopyImageError         error
 	RunInstancesOutput     *ec2.RunInstancesOutput
 	RunInstancesError      error
 	StopInstancesOutput    *ec2.StopInstancesOutput
@@ -25,25 +36,80 @@ type MockEC2Client struct {
 	StartInstancesError    error
 	CreateTagsOutput       *ec2.CreateTagsOutput
 	CreateTagsError        error
+	DeleteTagsError        error
 	TerminateInstancesOutput *ec2.TerminateInstancesOutput
 	TerminateInstancesError  error
 	CreateSnapshotOutput    *ec2.CreateSnapshotOutput
 	CreateSnapshotError     error
 	DescribeSnapshotsOutput *ec2.DescribeSnapshotsOutput
 	DescribeSnapshotsError  error
+	DeleteSnapshotOutput    *ec2.DeleteSnapshotOutput
+	DeleteSnapshotError     error
+	DeregisterImageOutput   *ec2.DeregisterImageOutput
+	DeregisterImageError    error
+	EnableImageDeprecationOutput *ec2.EnableImageDeprecationOutput
+	EnableImageDeprecationError  error
 	CreateVolumeOutput      *ec2.CreateVolumeOutput
 	CreateVolumeError       error
 	DescribeVolumesOutput   *ec2.DescribeVolumesOutput
 	DescribeVolumesError    error
 	AttachVolumeOutput      *ec2.AttachVolumeOutput
 	AttachVolumeError       error
+	DescribeInstanceStatusOutput *ec2.DescribeInstanceStatusOutput
+	DescribeInstanceStatusError  error
+	DescribeInstanceTypesOutput  *ec2.DescribeInstanceTypesOutput
+	DescribeInstanceTypesError   error
+	CopySnapshotError            error
+	RegisterImageError           error
+	CreateImageError             error
+	DescribeAddressesError       error
+	AssociateAddressError        error
+	DisassociateAddressError     error
+	AssociateIamInstanceProfileError error
+	DescribeRegionsError             error
+	DescribeInstanceAttributeError   error
+	ModifyInstanceAttributeError     error
+
+	// Inputs recorded for assertions
+	DescribeInstancesInputs []*ec2.DescribeInstancesInput
+	CreateTagsInputs        []*ec2.CreateTagsInput
+	DeleteTagsInputs        []*ec2.DeleteTagsInput
+	RunInstancesInputs []*ec2.RunInstancesInput
+	DeletedSnapshots   []string
+	DeregisteredImages []string
+	CopyImageInputs    []*ec2.CopyImageInput
+	CopySnapshotInputs []*ec2.CopySnapshotInput
+	RegisterImageInputs []*ec2.RegisterImageInput
+	CreateImageInputs   []*ec2.CreateImageInput
+	EnableImageDeprecationInputs []*ec2.EnableImageDeprecationInput
+	AssociateAddressInputs       []*ec2.AssociateAddressInput
+	DisassociateAddressInputs    []*ec2.DisassociateAddressInput
+	AssociateIamInstanceProfileInputs []*ec2.AssociateIamInstanceProfileInput
+	ModifyInstanceAttributeInputs     []*ec2.ModifyInstanceAttributeInput
 
 	// Data fields for convenience
 	Images    []types.Image
+	// ImagesByRegion, when set, serves DescribeImages from the images of the
+	// region each request is sent to
+	ImagesByRegion map[string][]types.Image
 	Instances []types.Instance
 	Instance  *types.Instance
 	Snapshots []types.Snapshot
 	Volumes   []types.Volume
+	// InstanceTypes serves DescribeInstanceTypes, filtered by the requested types
+	InstanceTypes []types.InstanceTypeInfo
+	// Addresses serves DescribeAddresses and tracks the associations made
+	// and removed through the mock
+	Addresses []types.Address
+	// Regions serves DescribeRegions
+	Regions []types.Region
+	// UserData serves the userData attribute of DescribeInstanceAttribute,
+	// base64-encoded as EC2 returns it, by instance ID
+	UserData map[string]string
+	// TerminationProtection serves the disableApiTermination attribute by
+	// instance ID. ModifyInstanceAttribute updates it and TerminateInstances
+	// refuses protected instances, as EC2 does.
+	TerminationProtection map[string]bool
 
 	// Track instance states for waiters
 	InstanceStates map[string]types.InstanceStateName
@@ -61,10 +127,16 @@ func (m *MockEC2Client) DescribeInstances(ctx context.Context, params *ec2.Descr
 	m.Lock()
 	defer m.Unlock()
 
+	m.DescribeInstancesInputs = append(m.DescribeInstancesInputs, params)
+
 	if m.DescribeInstancesError != nil {
 		return nil, m.DescribeInstancesError
 	}
 
+	if len(m.DescribeInstancesPages) > 0 && len(params.InstanceIds) == 0 {
+		return m.describeInstancesPage(aws.ToString(params.NextToken)), nil
+	}
+
 	if m.DescribeInstancesOutput != nil {
 		// Update the instance states in the output based on our tracked states
 		for i, reservation := range m.DescribeInstancesOutput.Reservations {
@@ -78,6 +150,9 @@ func (m *MockEC2Client) DescribeInstances(ctx context.Context, params *ec2.Descr
 				}
 			}
 		}
+		if len(params.InstanceIds) > 0 {
+			return m.describeInstancesByID(params.InstanceIds), nil
+		}
 		return m.DescribeInstancesOutput, nil
 	}
 
@@ -107,6 +182,62 @@ func (m *MockEC2Client) DescribeInstances(ctx context.Context, params *ec2.Descr
 	}, nil
 }
 
+// describeInstancesPage returns the page of DescribeInstancesPages named by
+// token, linking it to the next page through NextToken
+func (m *MockEC2Client) describeInstancesPage(token string) *ec2.DescribeInstancesOutput {
+	page := 0
+	if token != "" {
+		fmt.Sscanf(token, "page-%d", &page)
+	}
+	if page >= len(m.DescribeInstancesPages) {
+		return &ec2.DescribeInstancesOutput{}
+	}
+
+	output := *m.DescribeInstancesPages[page]
+	output.NextToken = nil
+	if page+1 < len(m.DescribeInstancesPages) {
+		output.NextToken = aws.String(fmt.Sprintf("page-%d", page+1))
+	}
+	return &output
+}
+
+// describeInstancesByID returns the configured instances matching the given IDs.
+// Instances that are only known through their tracked state (e.g. ones launched
+// by RunInstances) are returned with just their ID and state.
+func (m *MockEC2Client) describeInstancesByID(instanceIDs []string) *ec2.DescribeInstancesOutput {
+	var instances []types.Instance
+	for _, id := range instanceIDs {
+		found := false
+		for _, reservation := range m.DescribeInstancesOutput.Reservations {
+			for _, instance := range reservation.Instances {
+				if aws.ToString(instance.InstanceId) == id {
+					instances = append(instances, instance)
+					found = true
+				}
+			}
+		}
+		if state, exists := m.InstanceStates[id]; exists && !found {
+			instances = append(instances, types.Instance{
+				InstanceId: aws.String(id),
+				State: &types.InstanceState{
+					Name: state,
+				},
+			})
+		}
+	}
+
+	if len(instances) == 0 {
+		return &ec2.DescribeInstancesOutput{}
+	}
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{
+				Instances: instances,
+			},
+		},
+	}
+}
+
 // DescribeImages implements EC2ClientAPI
 func (m *MockEC2Client) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
 	m.Lock()
@@ -115,23 +246,91 @@ func (m *MockEC2Client) DescribeImages(ctx context.Context, params *ec2.Describe
 	if m.DescribeImagesError != nil {
 		return nil, m.DescribeImagesError
 	}
+	if len(m.DescribeImagesPages) > 0 && len(params.ImageIds) == 0 {
+		return m.describeImagesPage(aws.ToString(params.NextToken)), nil
+	}
 	if m.DescribeImagesOutput != nil {
 		return m.DescribeImagesOutput, nil
 	}
+	if m.ImagesByRegion != nil {
+		return &ec2.DescribeImagesOutput{
+			Images: m.ImagesByRegion[requestRegion(optFns)],
+		}, nil
+	}
 
 	return &ec2.DescribeImagesOutput{
 		Images: m.Images,
 	}, nil
 }
 
+// describeImagesPage returns the page of DescribeImagesPages named by token,
+// linking it to the next page through NextToken
+func (m *MockEC2Client) describeImagesPage(token string) *ec2.DescribeImagesOutput {
+	page := 0
+	if token != "" {
+		fmt.Sscanf(token, "page-%d", &page)
+	}
+	if page >= len(m.DescribeImagesPages) {
+		return &ec2.DescribeImagesOutput{}
+	}
+
+	output := *m.DescribeImagesPages[page]
+	output.NextToken = nil
+	if page+1 < len(m.DescribeImagesPages) {
+		output.NextToken = aws.String(fmt.Sprintf("page-%d", page+1))
+	}
+	return &output
+}
+
+// CopyImage implements EC2ClientAPI. When ImagesByRegion is set the copy is
+// added to the destination region as an available image.
+func (m *MockEC2Client) CopyImage(ctx context.Context, params *ec2.CopyImageInput, optFns ...func(*ec2.Options)) (*ec2.CopyImageOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.CopyImageInputs = append(m.CopyImageInputs, params)
+
+	if m.CopyImageError != nil {
+		return nil, m.CopyImageError
+	}
+	if m.CopyImageOutput != nil {
+		return m.CopyImageOutput, nil
+	}
+
+	imageID := fmt.Sprintf("ami-copy-%d", len(m.CopyImageInputs))
+	if m.ImagesByRegion != nil {
+		region := requestRegion(optFns)
+		m.ImagesByRegion[region] = append(m.ImagesByRegion[region], types.Image{
+			ImageId: aws.String(imageID),
+			Name:    params.Name,
+			State:   types.ImageStateAvailable,
+		})
+	}
+	return &ec2.CopyImageOutput{ImageId: aws.String(imageID)}, nil
+}
+
+// requestRegion returns the region a request's options send it to
+func requestRegion(optFns []func(*ec2.Options)) string {
+	var options ec2.Options
+	for _, fn := range optFns {
+		fn(&options)
+	}
+	return options.Region
+}
+
 // RunInstances mocks the RunInstances operation
 func (m *MockEC2Client) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
 	m.Lock()
 	defer m.Unlock()
 
+	m.RunInstancesInputs = append(m.RunInstancesInputs, params)
+
 	if m.RunInstancesError != nil {
 		return nil, m.RunInstancesError
 	}
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
 
 	if m.RunInstancesOutput != nil {
 		// Update instance state to running for all instances
@@ -167,6 +366,9 @@ func (m *MockEC2Client) StopInstances(ctx context.Context, params *ec2.StopInsta
 	if m.StopInstancesError != nil {
 		return nil, m.StopInstancesError
 	}
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
 
 	if m.StopInstancesOutput != nil {
 		// Update instance state to stopped
@@ -233,17 +435,41 @@ func (m *MockEC2Client) StartInstances(ctx context.Context, params *ec2.StartIns
 	}, nil
 }
 
-// CreateTags implements EC2ClientAPI
+// CreateTags implements EC2ClientAPI. Like EC2, it refuses tags in the
+// reserved aws: namespace.
 func (m *MockEC2Client) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
 	m.Lock()
 	defer m.Unlock()
 
+	m.CreateTagsInputs = append(m.CreateTagsInputs, params)
+
 	if m.CreateTagsError != nil {
 		return nil, m.CreateTagsError
 	}
+	for _, tag := range params.Tags {
+		if strings.HasPrefix(aws.ToString(tag.Key), "aws:") {
+			return nil, &smithy.GenericAPIError{
+				Code:    "InvalidParameterValue",
+				Message: fmt.Sprintf("Value ( %s ) for parameter key is invalid. Tag keys starting with 'aws:' are reserved for internal use", aws.ToString(tag.Key)),
+			}
+		}
+	}
 	return m.CreateTagsOutput, nil
 }
 
+// DeleteTags implements EC2ClientAPI
+func (m *MockEC2Client) DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.DeleteTagsInputs = append(m.DeleteTagsInputs, params)
+
+	if m.DeleteTagsError != nil {
+		return nil, m.DeleteTagsError
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
 // TerminateInstances mocks the TerminateInstances operation
 func (m *MockEC2Client) TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
 	m.Lock()
@@ -252,6 +478,17 @@ func (m *MockEC2Client) TerminateInstances(ctx context.Context, params *ec2.Term
 	if m.TerminateInstancesError != nil {
 		return nil, m.TerminateInstancesError
 	}
+	for _, instanceID := range params.InstanceIds {
+		if m.TerminationProtection[instanceID] {
+			return nil, &smithy.GenericAPIError{
+				Code:    "OperationNotPermitted",
+				Message: fmt.Sprintf("The instance '%s' may not be terminated. Modify its 'disableApiTermination' instance attribute and try again.", instanceID),
+			}
+		}
+	}
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
 
 	if m.TerminateInstancesOutput != nil {
 		// Update instance state to terminated
@@ -289,7 +526,29 @@ func (m *MockEC2Client) CreateSnapshot(ctx context.Context, params *ec2.CreateSn
 	if m.CreateSnapshotError != nil {
 		return nil, m.CreateSnapshotError
 	}
-	return m.CreateSnapshotOutput, nil
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
+
+	output := m.CreateSnapshotOutput
+	if output == nil {
+		output = &ec2.CreateSnapshotOutput{
+			SnapshotId: aws.String("snap-" + strings.TrimPrefix(aws.ToString(params.VolumeId), "vol-")),
+		}
+	}
+
+	// Record the snapshot so it can be described and waited on
+	snapshot := types.Snapshot{
+		SnapshotId: output.SnapshotId,
+		VolumeId:   params.VolumeId,
+		State:      types.SnapshotStateCompleted,
+	}
+	for _, spec := range params.TagSpecifications {
+		snapshot.Tags = append(snapshot.Tags, spec.Tags...)
+	}
+	m.Snapshots = append(m.Snapshots, snapshot)
+
+	return output, nil
 }
 
 // DescribeSnapshots implements EC2ClientAPI
@@ -304,11 +563,177 @@ func (m *MockEC2Client) DescribeSnapshots(ctx context.Context, params *ec2.Descr
 		return m.DescribeSnapshotsOutput, nil
 	}
 
+	if len(params.SnapshotIds) > 0 {
+		var snapshots []types.Snapshot
+		for _, snapshot := range m.Snapshots {
+			if containsString(params.SnapshotIds, aws.ToString(snapshot.SnapshotId)) {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+		return &ec2.DescribeSnapshotsOutput{
+			Snapshots: snapshots,
+		}, nil
+	}
+
 	return &ec2.DescribeSnapshotsOutput{
 		Snapshots: m.Snapshots,
 	}, nil
 }
 
+// CopySnapshot implements EC2ClientAPI. The copy is recorded as a completed
+// snapshot so it can be described and waited on.
+func (m *MockEC2Client) CopySnapshot(ctx context.Context, params *ec2.CopySnapshotInput, optFns ...func(*ec2.Options)) (*ec2.CopySnapshotOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.CopySnapshotInputs = append(m.CopySnapshotInputs, params)
+
+	if m.CopySnapshotError != nil {
+		return nil, m.CopySnapshotError
+	}
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
+
+	snapshotID := fmt.Sprintf("snap-copy-%d", len(m.CopySnapshotInputs))
+	snapshot := types.Snapshot{
+		SnapshotId: aws.String(snapshotID),
+		State:      types.SnapshotStateCompleted,
+		Encrypted:  params.Encrypted,
+		KmsKeyId:   params.KmsKeyId,
+	}
+	for _, spec := range params.TagSpecifications {
+		snapshot.Tags = append(snapshot.Tags, spec.Tags...)
+	}
+	m.Snapshots = append(m.Snapshots, snapshot)
+
+	return &ec2.CopySnapshotOutput{SnapshotId: aws.String(snapshotID)}, nil
+}
+
+// RegisterImage implements EC2ClientAPI. The image is recorded as available
+// so it can be described and launched.
+func (m *MockEC2Client) RegisterImage(ctx context.Context, params *ec2.RegisterImageInput, optFns ...func(*ec2.Options)) (*ec2.RegisterImageOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.RegisterImageInputs = append(m.RegisterImageInputs, params)
+
+	if m.RegisterImageError != nil {
+		return nil, m.RegisterImageError
+	}
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
+
+	imageID := fmt.Sprintf("ami-registered-%d", len(m.RegisterImageInputs))
+	m.Images = append(m.Images, types.Image{
+		ImageId:             aws.String(imageID),
+		Name:                params.Name,
+		State:               types.ImageStateAvailable,
+		Architecture:        params.Architecture,
+		RootDeviceName:      params.RootDeviceName,
+		BlockDeviceMappings: params.BlockDeviceMappings,
+	})
+
+	return &ec2.RegisterImageOutput{ImageId: aws.String(imageID)}, nil
+}
+
+// CreateImage implements EC2ClientAPI. The image is recorded as available
+// so it can be described and launched.
+func (m *MockEC2Client) CreateImage(ctx context.Context, params *ec2.CreateImageInput, optFns ...func(*ec2.Options)) (*ec2.CreateImageOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.CreateImageInputs = append(m.CreateImageInputs, params)
+
+	if m.CreateImageError != nil {
+		return nil, m.CreateImageError
+	}
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
+
+	imageID := fmt.Sprintf("ami-created-%d", len(m.CreateImageInputs))
+	m.Images = append(m.Images, types.Image{
+		ImageId:     aws.String(imageID),
+		Name:        params.Name,
+		Description: params.Description,
+		State:       types.ImageStateAvailable,
+	})
+
+	return &ec2.CreateImageOutput{ImageId: aws.String(imageID)}, nil
+}
+
+// DeleteSnapshot implements EC2ClientAPI
+func (m *MockEC2Client) DeleteSnapshot(ctx context.Context, params *ec2.DeleteSnapshotInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSnapshotOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.DeleteSnapshotError != nil {
+		return nil, m.DeleteSnapshotError
+	}
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
+
+	snapshotID := aws.ToString(params.SnapshotId)
+	m.DeletedSnapshots = append(m.DeletedSnapshots, snapshotID)
+	for i, snapshot := range m.Snapshots {
+		if aws.ToString(snapshot.SnapshotId) == snapshotID {
+			m.Snapshots = append(m.Snapshots[:i], m.Snapshots[i+1:]...)
+			break
+		}
+	}
+
+	if m.DeleteSnapshotOutput != nil {
+		return m.DeleteSnapshotOutput, nil
+	}
+	return &ec2.DeleteSnapshotOutput{}, nil
+}
+
+// DeregisterImage implements EC2ClientAPI
+func (m *MockEC2Client) DeregisterImage(ctx context.Context, params *ec2.DeregisterImageInput, optFns ...func(*ec2.Options)) (*ec2.DeregisterImageOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.DeregisterImageError != nil {
+		return nil, m.DeregisterImageError
+	}
+	if aws.ToBool(params.DryRun) {
+		return nil, dryRunError()
+	}
+
+	imageID := aws.ToString(params.ImageId)
+	m.DeregisteredImages = append(m.DeregisteredImages, imageID)
+	for i, image := range m.Images {
+		if aws.ToString(image.ImageId) == imageID {
+			m.Images = append(m.Images[:i], m.Images[i+1:]...)
+			break
+		}
+	}
+
+	if m.DeregisterImageOutput != nil {
+		return m.DeregisterImageOutput, nil
+	}
+	return &ec2.DeregisterImageOutput{}, nil
+}
+
+// EnableImageDeprecation implements EC2ClientAPI
+func (m *MockEC2Client) EnableImageDeprecation(ctx context.Context, params *ec2.EnableImageDeprecationInput, optFns ...func(*ec2.Options)) (*ec2.EnableImageDeprecationOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.EnableImageDeprecationInputs = append(m.EnableImageDeprecationInputs, params)
+
+	if m.EnableImageDeprecationError != nil {
+		return nil, m.EnableImageDeprecationError
+	}
+	if m.EnableImageDeprecationOutput != nil {
+		return m.EnableImageDeprecationOutput, nil
+	}
+	return &ec2.EnableImageDeprecationOutput{Return: aws.Bool(true)}, nil
+}
+
 // CreateVolume implements EC2ClientAPI
 func (m *MockEC2Client) CreateVolume(ctx context.Context, params *ec2.CreateVolumeInput, optFns ...func(*ec2.Options)) (*ec2.CreateVolumeOutput, error) {
 	m.Lock()
@@ -332,6 +757,37 @@ func (m *MockEC2Client) DescribeVolumes(ctx context.Context, params *ec2.Describ
 		return m.DescribeVolumesOutput, nil
 	}
 
+	if len(params.VolumeIds) > 0 {
+		var volumes []types.Volume
+		for _, volume := range m.Volumes {
+			if containsString(params.VolumeIds, aws.ToString(volume.VolumeId)) {
+				volumes = append(volumes, volume)
+			}
+		}
+		return &ec2.DescribeVolumesOutput{
+			Volumes: volumes,
+		}, nil
+	}
+
+	var instanceIDs []string
+	for _, filter := range params.Filters {
+		if aws.ToString(filter.Name) == "attachment.instance-id" {
+			instanceIDs = append(instanceIDs, filter.Values...)
+		}
+	}
+	if len(instanceIDs) > 0 {
+		output := &ec2.DescribeVolumesOutput{}
+		for _, volume := range m.Volumes {
+			for _, attachment := range volume.Attachments {
+				if containsString(instanceIDs, aws.ToString(attachment.InstanceId)) {
+					output.Volumes = append(output.Volumes, volume)
+					break
+				}
+			}
+		}
+		return output, nil
+	}
+
 	return &ec2.DescribeVolumesOutput{
 		Volumes: m.Volumes,
 	}, nil
@@ -348,6 +804,81 @@ func (m *MockEC2Client) AttachVolume(ctx context.Context, params *ec2.AttachVolu
 	return m.AttachVolumeOutput, nil
 }
 
+// containsString reports whether value is in values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunError mimics the error EC2 returns when a DryRun request would have succeeded
+func dryRunError() error {
+	return &smithy.GenericAPIError{
+		Code:    "DryRunOperation",
+		Message: "Request would have succeeded, but DryRun flag is set.",
+	}
+}
+
+// DescribeInstanceStatus implements EC2ClientAPI
+func (m *MockEC2Client) DescribeInstanceStatus(ctx context.Context, params *ec2.DescribeInstanceStatusInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceStatusOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.DescribeInstanceStatusError != nil {
+		return nil, m.DescribeInstanceStatusError
+	}
+	if m.DescribeInstanceStatusOutput != nil {
+		return m.DescribeInstanceStatusOutput, nil
+	}
+
+	// Default behavior: every requested instance passes its status checks
+	statuses := make([]types.InstanceStatus, 0, len(params.InstanceIds))
+	for _, id := range params.InstanceIds {
+		statuses = append(statuses, types.InstanceStatus{
+			InstanceId: aws.String(id),
+			InstanceState: &types.InstanceState{
+				Name: types.InstanceStateNameRunning,
+			},
+			InstanceStatus: &types.InstanceStatusSummary{
+				Status: types.SummaryStatusOk,
+			},
+			SystemStatus: &types.InstanceStatusSummary{
+				Status: types.SummaryStatusOk,
+			},
+		})
+	}
+
+	return &ec2.DescribeInstanceStatusOutput{
+		InstanceStatuses: statuses,
+	}, nil
+}
+
+// DescribeInstanceTypes implements EC2ClientAPI
+func (m *MockEC2Client) DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.DescribeInstanceTypesError != nil {
+		return nil, m.DescribeInstanceTypesError
+	}
+	if m.DescribeInstanceTypesOutput != nil {
+		return m.DescribeInstanceTypesOutput, nil
+	}
+
+	var infos []types.InstanceTypeInfo
+	for _, info := range m.InstanceTypes {
+		for _, instanceType := range params.InstanceTypes {
+			if info.InstanceType == instanceType {
+				infos = append(infos, info)
+			}
+		}
+	}
+	return &ec2.DescribeInstanceTypesOutput{InstanceTypes: infos}, nil
+}
+
 // GetInstanceState returns the current state of an instance
 func (m *MockEC2Client) GetInstanceState(instanceID string) types.InstanceStateName {
 	m.Lock()
@@ -381,3 +912,152 @@ func (m *MockEC2Client) SetInstanceState(instanceID string, state types.Instance
 	defer m.Unlock()
 	m.setInstanceStateWithLock(instanceID, state)
 }
+
+// DescribeAddresses implements EC2ClientAPI. Only the instance-id filter is
+// applied.
+func (m *MockEC2Client) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.DescribeAddressesError != nil {
+		return nil, m.DescribeAddressesError
+	}
+
+	var instanceIDs []string
+	for _, filter := range params.Filters {
+		if aws.ToString(filter.Name) == "instance-id" {
+			instanceIDs = append(instanceIDs, filter.Values...)
+		}
+	}
+
+	output := &ec2.DescribeAddressesOutput{}
+	for _, address := range m.Addresses {
+		if len(instanceIDs) > 0 && !containsString(instanceIDs, aws.ToString(address.InstanceId)) {
+			continue
+		}
+		output.Addresses = append(output.Addresses, address)
+	}
+	return output, nil
+}
+
+// AssociateAddress implements EC2ClientAPI
+func (m *MockEC2Client) AssociateAddress(ctx context.Context, params *ec2.AssociateAddressInput, optFns ...func(*ec2.Options)) (*ec2.AssociateAddressOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.AssociateAddressInputs = append(m.AssociateAddressInputs, params)
+
+	if m.AssociateAddressError != nil {
+		return nil, m.AssociateAddressError
+	}
+
+	for i, address := range m.Addresses {
+		if (params.AllocationId != nil && aws.ToString(address.AllocationId) == aws.ToString(params.AllocationId)) ||
+			(params.PublicIp != nil && aws.ToString(address.PublicIp) == aws.ToString(params.PublicIp)) {
+			associationID := fmt.Sprintf("eipassoc-%d", len(m.AssociateAddressInputs))
+			m.Addresses[i].InstanceId = params.InstanceId
+			if address.Domain == types.DomainTypeVpc {
+				m.Addresses[i].AssociationId = aws.String(associationID)
+			}
+			return &ec2.AssociateAddressOutput{AssociationId: m.Addresses[i].AssociationId}, nil
+		}
+	}
+	return nil, &smithy.GenericAPIError{Code: "InvalidAddress.NotFound", Message: "address not found"}
+}
+
+// DisassociateAddress implements EC2ClientAPI
+func (m *MockEC2Client) DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput, optFns ...func(*ec2.Options)) (*ec2.DisassociateAddressOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.DisassociateAddressInputs = append(m.DisassociateAddressInputs, params)
+
+	if m.DisassociateAddressError != nil {
+		return nil, m.DisassociateAddressError
+	}
+
+	for i, address := range m.Addresses {
+		if (params.AssociationId != nil && aws.ToString(address.AssociationId) == aws.ToString(params.AssociationId)) ||
+			(params.PublicIp != nil && aws.ToString(address.PublicIp) == aws.ToString(params.PublicIp)) {
+			m.Addresses[i].InstanceId = nil
+			m.Addresses[i].AssociationId = nil
+			return &ec2.DisassociateAddressOutput{}, nil
+		}
+	}
+	return nil, &smithy.GenericAPIError{Code: "InvalidAssociationID.NotFound", Message: "association not found"}
+}
+
+// AssociateIamInstanceProfile implements EC2ClientAPI
+func (m *MockEC2Client) AssociateIamInstanceProfile(ctx context.Context, params *ec2.AssociateIamInstanceProfileInput, optFns ...func(*ec2.Options)) (*ec2.AssociateIamInstanceProfileOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.AssociateIamInstanceProfileInputs = append(m.AssociateIamInstanceProfileInputs, params)
+
+	if m.AssociateIamInstanceProfileError != nil {
+		return nil, m.AssociateIamInstanceProfileError
+	}
+	return &ec2.AssociateIamInstanceProfileOutput{
+		IamInstanceProfileAssociation: &types.IamInstanceProfileAssociation{
+			AssociationId:      aws.String(fmt.Sprintf("iip-assoc-%d", len(m.AssociateIamInstanceProfileInputs))),
+			InstanceId:         params.InstanceId,
+			IamInstanceProfile: &types.IamInstanceProfile{Arn: params.IamInstanceProfile.Arn},
+			State:              types.IamInstanceProfileAssociationStateAssociating,
+		},
+	}, nil
+}
+
+// DescribeRegions implements EC2ClientAPI
+func (m *MockEC2Client) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.DescribeRegionsError != nil {
+		return nil, m.DescribeRegionsError
+	}
+	return &ec2.DescribeRegionsOutput{Regions: m.Regions}, nil
+}
+
+// DescribeInstanceAttribute implements EC2ClientAPI. Only the userData and
+// disableApiTermination attributes are served.
+func (m *MockEC2Client) DescribeInstanceAttribute(ctx context.Context, params *ec2.DescribeInstanceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceAttributeOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.DescribeInstanceAttributeError != nil {
+		return nil, m.DescribeInstanceAttributeError
+	}
+	output := &ec2.DescribeInstanceAttributeOutput{InstanceId: params.InstanceId}
+	if params.Attribute == types.InstanceAttributeNameUserData {
+		output.UserData = &types.AttributeValue{}
+		if userData, ok := m.UserData[aws.ToString(params.InstanceId)]; ok {
+			output.UserData.Value = aws.String(userData)
+		}
+	}
+	if params.Attribute == types.InstanceAttributeNameDisableApiTermination {
+		output.DisableApiTermination = &types.AttributeBooleanValue{
+			Value: aws.Bool(m.TerminationProtection[aws.ToString(params.InstanceId)]),
+		}
+	}
+	return output, nil
+}
+
+// ModifyInstanceAttribute implements EC2ClientAPI. Only changes to
+// disableApiTermination are tracked.
+func (m *MockEC2Client) ModifyInstanceAttribute(ctx context.Context, params *ec2.ModifyInstanceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyInstanceAttributeOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.ModifyInstanceAttributeInputs = append(m.ModifyInstanceAttributeInputs, params)
+
+	if m.ModifyInstanceAttributeError != nil {
+		return nil, m.ModifyInstanceAttributeError
+	}
+	if params.DisableApiTermination != nil {
+		if m.TerminationProtection == nil {
+			m.TerminationProtection = make(map[string]bool)
+		}
+		m.TerminationProtection[aws.ToString(params.InstanceId)] = aws.ToBool(params.DisableApiTermination.Value)
+	}
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
+}
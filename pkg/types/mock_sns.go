@@ -0,0 +1,40 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// MockSNSClient is a mock implementation of SNSClientAPI
+type MockSNSClient struct {
+	sync.Mutex
+	// Error fields for each operation
+	PublishError error
+
+	// Inputs recorded for assertions
+	PublishInputs []*sns.PublishInput
+}
+
+// NewMockSNSClient creates a new mock SNS client
+func NewMockSNSClient() *MockSNSClient {
+	return &MockSNSClient{}
+}
+
+// Publish implements SNSClientAPI
+func (m *MockSNSClient) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.PublishInputs = append(m.PublishInputs, params)
+
+	if m.PublishError != nil {
+		return nil, m.PublishError
+	}
+	return &sns.PublishOutput{
+		MessageId: aws.String(fmt.Sprintf("msg-%d", len(m.PublishInputs))),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// MockSSMClient is a mock implementation of SSMClientAPI
+type MockSSMClient struct {
+	sync.Mutex
+	// Output and error fields for each operation
+	DescribeInstanceInformationError error
+	SendCommandError                 error
+	GetCommandInvocationError        error
+
+	// Inputs recorded for assertions
+	SendCommandInputs []*ssm.SendCommandInput
+
+	// ManagedInstances lists the instances with an online SSM agent
+	ManagedInstances []string
+	// CommandStatus is the final status of every command sent. It defaults
+	// to Success.
+	CommandStatus ssmtypes.CommandInvocationStatus
+	// CommandOutput is reported as the standard error of failed commands
+	CommandOutput string
+}
+
+// NewMockSSMClient creates a new mock SSM client
+func NewMockSSMClient() *MockSSMClient {
+	return &MockSSMClient{}
+}
+
+// DescribeInstanceInformation implements SSMClientAPI
+func (m *MockSSMClient) DescribeInstanceInformation(ctx context.Context, params *ssm.DescribeInstanceInformationInput, optFns ...func(*ssm.Options)) (*ssm.DescribeInstanceInformationOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.DescribeInstanceInformationError != nil {
+		return nil, m.DescribeInstanceInformationError
+	}
+
+	var requested []string
+	for _, filter := range params.Filters {
+		if aws.ToString(filter.Key) == "InstanceIds" {
+			requested = append(requested, filter.Values...)
+		}
+	}
+
+	output := &ssm.DescribeInstanceInformationOutput{}
+	for _, id := range m.ManagedInstances {
+		if len(requested) > 0 && !containsString(requested, id) {
+			continue
+		}
+		output.InstanceInformationList = append(output.InstanceInformationList, ssmtypes.InstanceInformation{
+			InstanceId: aws.String(id),
+			PingStatus: ssmtypes.PingStatusOnline,
+		})
+	}
+	return output, nil
+}
+
+// SendCommand implements SSMClientAPI
+func (m *MockSSMClient) SendCommand(ctx context.Context, params *ssm.SendCommandInput, optFns ...func(*ssm.Options)) (*ssm.SendCommandOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.SendCommandInputs = append(m.SendCommandInputs, params)
+
+	if m.SendCommandError != nil {
+		return nil, m.SendCommandError
+	}
+
+	return &ssm.SendCommandOutput{
+		Command: &ssmtypes.Command{
+			CommandId:    aws.String(fmt.Sprintf("cmd-%d", len(m.SendCommandInputs))),
+			DocumentName: params.DocumentName,
+			InstanceIds:  params.InstanceIds,
+		},
+	}, nil
+}
+
+// GetCommandInvocation implements SSMClientAPI
+func (m *MockSSMClient) GetCommandInvocation(ctx context.Context, params *ssm.GetCommandInvocationInput, optFns ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.GetCommandInvocationError != nil {
+		return nil, m.GetCommandInvocationError
+	}
+
+	status := m.CommandStatus
+	if status == "" {
+		status = ssmtypes.CommandInvocationStatusSuccess
+	}
+	output := &ssm.GetCommandInvocationOutput{
+		CommandId:  params.CommandId,
+		InstanceId: params.InstanceId,
+		Status:     status,
+	}
+	if status != ssmtypes.CommandInvocationStatusSuccess {
+		output.StandardErrorContent = aws.String(m.CommandOutput)
+	}
+	return output, nil
+}
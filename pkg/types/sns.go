@@ -0,0 +1,13 @@
+package types
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// SNSClientAPI is the interface for the SNS operations used to send
+// migration notifications
+type SNSClientAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}